@@ -166,6 +166,10 @@ func (d dummyNotificationManager) SendChampionshipReminderMessage(championship *
 	return nil
 }
 
+func (d dummyNotificationManager) SendStandings(title string, standings []StandingsTableRow) error {
+	return nil
+}
+
 func (d dummyNotificationManager) SaveServerOptions(oldServerOpts *GlobalServerConfig, newServerOpts *GlobalServerConfig) error {
 	return nil
 }
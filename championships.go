@@ -1285,6 +1285,36 @@ func (c *ChampionshipClass) Standings(championship *Championship, inEvents []*Ch
 	return out
 }
 
+// InvertedPointsGrid orders drivers (by GUID) for an inverted grid, based on their championship points. Drivers are
+// ranked by points, most first, with ties broken by GUID so that the grid is always the same for the same points.
+// The top numToInvert drivers are then reversed, so the points leader starts at the back of them: -1 inverts the
+// whole grid, and 0 inverts none of it.
+func InvertedPointsGrid(points map[string]float64, numToInvert int) []string {
+	grid := make([]string, 0, len(points))
+
+	for guid := range points {
+		grid = append(grid, guid)
+	}
+
+	sort.Slice(grid, func(i, j int) bool {
+		if points[grid[i]] == points[grid[j]] {
+			return grid[i] < grid[j]
+		}
+
+		return points[grid[i]] > points[grid[j]]
+	})
+
+	if numToInvert < 0 || numToInvert > len(grid) {
+		numToInvert = len(grid)
+	}
+
+	for i, j := 0, numToInvert-1; i < j; i, j = i+1, j-1 {
+		grid[i], grid[j] = grid[j], grid[i]
+	}
+
+	return grid
+}
+
 // StandingsForEvent reports the standings for a single event, not including any generic points penalties applied to the championship.
 func (c *ChampionshipClass) StandingsForEvent(championship *Championship, event *ChampionshipEvent) []*ChampionshipStanding {
 	return c.Standings(championship, []*ChampionshipEvent{event}, StandingsNoPointsPenalties)
@@ -2,6 +2,7 @@ package servermanager
 
 import (
 	"math/rand"
+	"reflect"
 	"testing"
 )
 
@@ -440,3 +441,42 @@ func TestChampionship_AddEntrantFromSession(t *testing.T) {
 		}
 	})
 }
+
+func TestInvertedPointsGrid(t *testing.T) {
+	points := map[string]float64{
+		"1": 50,
+		"2": 40,
+		"3": 40,
+		"4": 25,
+		"5": 0,
+	}
+
+	for _, testCase := range []struct {
+		Name        string
+		NumToInvert int
+		Expected    []string
+	}{
+		{Name: "No inversion", NumToInvert: 0, Expected: []string{"1", "2", "3", "4", "5"}},
+		{Name: "Full inversion", NumToInvert: -1, Expected: []string{"5", "4", "3", "2", "1"}},
+		{Name: "Partial inversion", NumToInvert: 3, Expected: []string{"3", "2", "1", "4", "5"}},
+		{Name: "Inversion larger than grid", NumToInvert: 10, Expected: []string{"5", "4", "3", "2", "1"}},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			grid := InvertedPointsGrid(points, testCase.NumToInvert)
+
+			if !reflect.DeepEqual(grid, testCase.Expected) {
+				t.Errorf("Expected grid %v, got %v", testCase.Expected, grid)
+			}
+		})
+	}
+
+	t.Run("Ties are broken deterministically", func(t *testing.T) {
+		tied := map[string]float64{"c": 10, "a": 10, "b": 10}
+
+		for i := 0; i < 10; i++ {
+			if grid := InvertedPointsGrid(tied, 0); !reflect.DeepEqual(grid, []string{"a", "b", "c"}) {
+				t.Fatalf("Expected tied drivers to be ordered by GUID, got %v", grid)
+			}
+		}
+	})
+}
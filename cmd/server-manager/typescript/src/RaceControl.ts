@@ -336,6 +336,13 @@ export class RaceControl {
 
 declare var useMPH: boolean;
 
+// toPreferredSpeed converts a speed sent by the server in the given unit ("km/h" or "mph") to the unit the user prefers.
+function toPreferredSpeed(speed: number, unit: string): number {
+    const kmh = unit === "mph" ? speed / 0.621371 : speed;
+
+    return useMPH ? kmh * 0.621371 : kmh;
+}
+
 class LiveMap implements WebsocketHandler {
     private mapImageHasLoaded: boolean = false;
 
@@ -627,11 +634,13 @@ class LiveTimings implements WebsocketHandler {
         $(document).on("click", ".driver-link", this.toggleDriverSpeed.bind(this));
 
         $(document).on("click", "#countdown", this.getFromClickEvent.bind(this));
+        $(document).on("click", "#clear-manual-positions", this.getFromClickEvent.bind(this));
 
         $(document).on("submit", "#broadcast-chat-form", this.processChatForm.bind(this));
         $(document).on("submit", "#admin-command-form", this.processAdminCommandForm.bind(this));
         $(document).on("submit", "#kick-user-form", this.processKickUserForm.bind(this));
         $(document).on("submit", "#send-chat-form", this.processSendChatForm.bind(this));
+        $(document).on("submit", "#manual-position-form", this.processManualPositionForm.bind(this));
     }
 
     private getFromClickEvent(e: ClickEvent): void {
@@ -674,6 +683,14 @@ class LiveTimings implements WebsocketHandler {
         return false
     }
 
+    private processManualPositionForm(e: JQuery.SubmitEvent): boolean {
+        this.postForm(e);
+
+        $("#manual-position").val('');
+
+        return false
+    }
+
     private postForm(e: JQuery.SubmitEvent) {
         e.preventDefault();
         e.stopPropagation();
@@ -787,6 +804,7 @@ class LiveTimings implements WebsocketHandler {
             <td class="current-lap"></td>
             <td class="last-lap"></td>
             <td class="best-lap"></td>
+            <td class="average-lap"></td>
             <td class="gap"></td>
             <td class="num-laps"></td>
             <td class="top-speed"></td>
@@ -882,6 +900,11 @@ class LiveTimings implements WebsocketHandler {
         // best lap
         $tr.find(".best-lap").text(msToTime(carInfo.BestLap / 1000000));
 
+        if (addingDriverToConnectedTable) {
+            // average lap
+            $tr.find(".average-lap").text(carInfo.AverageLap ? msToTime(carInfo.AverageLap / 1000000) : "");
+        }
+
         if (addingDriverToConnectedTable) {
             // gap
             $tr.find(".gap").text(driver.Split);
@@ -890,14 +913,12 @@ class LiveTimings implements WebsocketHandler {
         // lap number
         $tr.find(".num-laps").text(carInfo.NumLaps ? carInfo.NumLaps : "0");
 
-        let topSpeed;
+        let topSpeed = toPreferredSpeed(carInfo.TopSpeedBestLap, this.raceControl.status.SpeedUnit);
         let speedUnits;
 
         if (useMPH) {
-            topSpeed = carInfo.TopSpeedBestLap * 0.621371;
             speedUnits = "MPH";
         } else {
-            topSpeed = carInfo.TopSpeedBestLap;
             speedUnits = "Km/h";
         }
 
@@ -930,13 +951,7 @@ class LiveTimings implements WebsocketHandler {
                         $tag.attr("id", collisionID);
                         $tag.attr({'class': 'badge badge-danger live-badge'});
 
-                        let crashSpeed;
-
-                        if (useMPH) {
-                            crashSpeed = collision.Speed * 0.621371;
-                        } else {
-                            crashSpeed = collision.Speed;
-                        }
+                        let crashSpeed = toPreferredSpeed(collision.Speed, collision.SpeedUnit);
 
                         if (collision.Type === Collision.WithCar) {
                             $tag.text(
@@ -1058,6 +1073,7 @@ class LiveTimings implements WebsocketHandler {
     private addDriverToAdminSelects(carInfo: SessionCarInfo) {
         $(".kick-user option[value='default-driver-spacer']").remove();
         $(".chat-user option[value='default-driver-spacer']").remove();
+        $(".manual-position-user option[value='default-driver-spacer']").remove();
 
         if ($(".kick-user option[value=" + carInfo.DriverGUID + "]").length != 0) {
             // driver already exists
@@ -1078,11 +1094,19 @@ class LiveTimings implements WebsocketHandler {
                 text: carInfo.DriverName,
             }));
         }
+
+        if ($(".manual-position-user option[value=" + carInfo.DriverGUID + "]").length == 0) {
+            $('.manual-position-user').append($('<option>', {
+                value: carInfo.DriverGUID,
+                text: carInfo.DriverName,
+            }));
+        }
     }
 
     private removeDriverFromAdminSelects(carInfo: SessionCarInfo) {
         $(".kick-user option[value=" + carInfo.DriverGUID + "]").remove();
         $(".chat-user option[value=" + carInfo.DriverGUID + "]").remove();
+        $(".manual-position-user option[value=" + carInfo.DriverGUID + "]").remove();
     }
 }
 
@@ -241,6 +241,7 @@ class RaceControlDriverMapRaceControlDriverCollision {
     OtherDriverGUID: string;
     OtherDriverName: string;
     Speed: number;
+    SpeedUnit: string;
 
     constructor(data?: any) {
         const d: any = (data && typeof data === 'object') ? ToObject(data) : {};
@@ -250,6 +251,7 @@ class RaceControlDriverMapRaceControlDriverCollision {
         this.OtherDriverGUID = ('OtherDriverGUID' in d) ? d.OtherDriverGUID as string : '';
         this.OtherDriverName = ('OtherDriverName' in d) ? d.OtherDriverName as string : '';
         this.Speed = ('Speed' in d) ? d.Speed as number : 0;
+        this.SpeedUnit = ('SpeedUnit' in d) ? d.SpeedUnit as string : '';
     }
 
     toObject(): any {
@@ -269,6 +271,7 @@ class RaceControlDriverMapRaceControlDriverRaceControlCarLapInfo {
     LastLap: number;
     LastLapCompletedTime: Date;
     TotalLapTime: number;
+    AverageLap: number;
     CarName: string;
 
     constructor(data?: any) {
@@ -280,6 +283,7 @@ class RaceControlDriverMapRaceControlDriverRaceControlCarLapInfo {
         this.LastLap = ('LastLap' in d) ? d.LastLap as number : 0;
         this.LastLapCompletedTime = ('LastLapCompletedTime' in d) ? ParseDate(d.LastLapCompletedTime) : new Date();
         this.TotalLapTime = ('TotalLapTime' in d) ? d.TotalLapTime as number : 0;
+        this.AverageLap = ('AverageLap' in d) ? d.AverageLap as number : 0;
         this.CarName = ('CarName' in d) ? d.CarName as string : '';
     }
 
@@ -292,6 +296,7 @@ class RaceControlDriverMapRaceControlDriverRaceControlCarLapInfo {
         cfg.LastLap = 'number';
         cfg.LastLapCompletedTime = 'string';
         cfg.TotalLapTime = 'number';
+        cfg.AverageLap = 'number';
         return ToObject(this, cfg);
     }
 }
@@ -358,6 +363,7 @@ class RaceControl {
     TrackInfo: RaceControlTrackInfo;
     SessionStartTime: Date;
     CurrentRealtimePosInterval: number;
+    SpeedUnit: string;
     ConnectedDrivers: RaceControlDriverMap | null;
     DisconnectedDrivers: RaceControlDriverMap | null;
     CarIDToGUID: { [key: number]: string };
@@ -369,6 +375,7 @@ class RaceControl {
         this.TrackInfo = new RaceControlTrackInfo(d.TrackInfo);
         this.SessionStartTime = ('SessionStartTime' in d) ? ParseDate(d.SessionStartTime) : new Date();
         this.CurrentRealtimePosInterval = ('CurrentRealtimePosInterval' in d) ? d.CurrentRealtimePosInterval as number : 0;
+        this.SpeedUnit = ('SpeedUnit' in d) ? d.SpeedUnit as string : '';
         this.ConnectedDrivers = ('ConnectedDrivers' in d) ? new RaceControlDriverMap(d.ConnectedDrivers) : null;
         this.DisconnectedDrivers = ('DisconnectedDrivers' in d) ? new RaceControlDriverMap(d.DisconnectedDrivers) : null;
         this.CarIDToGUID = ('CarIDToGUID' in d) ? d.CarIDToGUID as { [key: number]: string } : {};
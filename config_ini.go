@@ -211,6 +211,63 @@ type GlobalServerConfig struct {
 	LogACServerOutputToFile           bool                 `ini:"-" show:"open" help:"When on, Server Manager will output each Assetto Corsa session into a log file in the logs folder."`
 	NumberOfACServerLogsToKeep        int                  `ini:"-" show:"open" help:"The number of AC Server logs to keep in the logs folder. (Oldest files will be deleted first. 0 = keep all files)"`
 	ShowEventDetailsPopup             bool                 `ini:"-" help:"Allows all users to view a popup that describes in detail the setup of Custom Races, Championship Events and Race Weekend Sessions."`
+	BoPTable                          string               `ini:"-" elem:"textarea" help:"A Balance of Performance table, with one car model per line in the format car_model,ballast,restrictor, e.g. ks_mazda_mx5_cup,20,5. Ballast is in kg and restrictor is a percentage."`
+	ApplyBoPTable                     bool                 `ini:"-" help:"When on, the ballast and restrictor in the Balance of Performance table are applied to every entrant in those car models when an event starts, replacing the values in the entry list."`
+
+	LiveTimings               FormHeading    `ini:"-" json:"-"`
+	WelcomeTemplate           string         `ini:"-" elem:"textarea" help:"A template for the chat message sent to drivers when they join the server, e.g. 'Welcome {{ .DriverName }}, enjoy the {{ .CarName }} at {{ .TrackName }}!'. The fields available are DriverName, ServerName, JoinMessage, SolWarning, LiveLink, CarModel, CarName, TrackName and SessionType. If not set (or if the template is not valid), the default welcome message is sent."`
+	SolWarningMode            SolWarningMode `ini:"-" name:"Sol Warning" help:"Controls when drivers are reminded in the welcome message that the server is running Sol. Regulars may find the warning repetitive, so it can be shown only the first time a driver joins each session, or never."`
+	MaxPlausibleImpactSpeed   int            `ini:"-" min:"0" help:"Collisions reported at a higher impact speed than this (in km/h) are assumed to come from corrupted data, and are recorded at this speed instead so that they don't skew collision statistics. Defaults to 500 km/h if not set."`
+	MinCollisionSpeed         int            `ini:"-" min:"0" help:"Collisions reported at a lower impact speed than this (in km/h), such as taps in the pits or while forming up on the grid, are ignored. They are not recorded or sent to Live Timings. 0 records all collisions."`
+	PileupWindowSeconds       int            `ini:"-" min:"0" help:"Collisions which happen within this many seconds of each other (and close together on track) are grouped into a single pileup when reviewing incidents involving three or more drivers. Defaults to 5 seconds if not set."`
+	CollisionChatWarningSpeed int            `ini:"-" min:"0" help:"When set, both drivers involved in a collision between two cars at or above this speed (in km/h) are sent a chat message noting the time of the incident, which is useful for self-reporting. 0 disables this."`
+	IncidentAlertCollisions   int            `ini:"-" min:"0" help:"If this many collisions happen across the field within the Incident Alert Window (e.g. in first lap chaos), all drivers are warned in chat to take care. Drivers are warned at most once a minute. 0 disables this."`
+	IncidentAlertWindow       int            `ini:"-" min:"0" help:"The length of time (in seconds) in which collisions are counted for incident alerts. Defaults to 10 seconds if not set."`
+	DisconnectGracePeriod     int            `ini:"-" min:"0" help:"When a driver who has completed laps disconnects, keep them in the connected drivers (marked as stale) for this many seconds before moving them to the disconnected drivers. If they reconnect in this time, they carry on as if they never left, so brief network problems don't make the standings flicker. Not used when a driver swap starts. 0 moves drivers immediately."`
+	MaxDisconnectedDrivers    int            `ini:"-" min:"0" help:"The maximum number of disconnected drivers to show in Live Timings. When exceeded, the least recently active disconnected drivers are removed (drivers who have set a time in Qualifying are always kept). 0 means no limit."`
+	ShowLappedCarTrackGap     bool           `ini:"-" help:"In races, calculate how far lapped cars are behind the leader on track (as a time gap), as well as the number of laps they are behind by."`
+	NextSessionReminder       int            `ini:"-" min:"0" help:"Remind drivers in chat about the next session of the event this many minutes before the end of each timed session, so they don't disconnect thinking that the event is over. 0 disables the reminder."`
+	BroadcastFirstLaps        bool           `ini:"-" help:"Send an event to Live Timings (and any overlays which use it) the first time each driver completes a lap in a session, e.g. to show that a driver has started their running."`
+	AnnounceFastestLap        bool           `ini:"-" help:"Send a chat message to all drivers when the fastest lap of the session is beaten."`
+	AnnouncePolePosition      bool           `ini:"-" help:"At the end of Qualifying, announce the pole sitter and front row (with the gap to pole) in chat."`
+	QualifyingExtension       int            `ini:"-" min:"0" help:"When the clock runs out in a timed Qualifying session, drivers who are on a flying lap are told that they have up to this many seconds (based on their best lap) to complete it, so that a lap started before the flag counts. Assetto Corsa can't add time to a session, so the extension is announced in chat. 0 disables this."`
+	QualifyingMinValidLaps    int            `ini:"-" min:"0" help:"The number of valid laps a driver must complete in Qualifying before their best lap counts towards their position in Live Timings. Defaults to 1 if not set."`
+	MinimumRaceDrivers        int            `ini:"-" min:"0" help:"If fewer than this many drivers have loaded into the server when a race starts, the race session is restarted (with a message in chat) to give more drivers time to join. The race is never restarted if no drivers are connected. 0 disables this."`
+	MaxRaceRestarts           int            `ini:"-" min:"0" help:"The maximum number of times a race session is restarted in each event for having fewer than the Minimum Race Drivers. Once reached, the race goes ahead with the drivers who are connected. Defaults to 3 if not set."`
+	MinRaceRestartDelay       int            `ini:"-" min:"0" help:"The minimum time (in seconds) between restarts of a race session for having fewer than the Minimum Race Drivers, so that drivers have time to join even if the race has no wait time. Defaults to 60 seconds if not set."`
+	DriverSwapDQInResults     bool           `ini:"-" help:"When a driver is kicked for leaving the pits too early during a driver swap, also disqualify them in the session results (with the reason and time), so that the disqualification counts towards Championship standings."`
+	DriverSwapCountdownAt     string         `ini:"-" help:"A comma separated list of the number of seconds remaining in a driver swap at which the new driver is reminded in chat of how long they must wait before leaving the pits, e.g. 60,30,10,5,3,2,1 (the default if not set)."`
+	DriverSwapCountdownEvery  int            `ini:"-" min:"0" help:"Also remind the new driver in a driver swap of how long they must wait every this many seconds, e.g. 5 with a Driver Swap Countdown At of 4,3,2,1 reminds them every 5 seconds and then every second for the final 5 seconds. Kicks and penalties are always sent straight away. 0 disables this."`
+	PitLaneAreas              string         `ini:"-" elem:"textarea" help:"The area around the pit lane of each track, used to show which drivers are in the pits (and count their pit stops) in Live Timings, and to check that drivers start driver swaps in the pits. One track per line in the format track,layout,min_x,min_z,max_x,max_z (leave the layout empty if the track has none), where the coordinates are the corners of a box around the pit lane in world coordinates, e.g. ks_laguna_seca,,-120,-40,80,10"`
+	StationaryCarTime         int            `ini:"-" min:"0" help:"Flag drivers in Live Timings (and send an event to any overlays) when their car has not moved on track for this many seconds, e.g. because they have stalled or crashed. If a Pit Lane Area is set for the track, cars in the pit lane are never flagged. 0 disables stationary car detection."`
+	DetectCarContentSwaps     bool           `ini:"-" help:"Flag drivers in Live Timings who complete a lap in a different car to the one they connected in, without disconnecting first. This can happen if a driver swaps their car's content mid-session."`
+	CarContentSwapPenalty     int            `ini:"-" min:"0" help:"If detecting car content swaps, the time penalty (in seconds) given to drivers who are flagged, which is applied to the session results. 0 only flags the driver."`
+	MaxCutsBeforePenalty      int            `ini:"-" min:"0" help:"Give drivers a time penalty each time their total cuts in a car this session reach a multiple of this number. Drivers are warned in chat when they are one cut away from a penalty. The penalty is applied to the session results. 0 disables this."`
+	CutPenalty                int            `ini:"-" min:"0" help:"The time penalty (in seconds) given to drivers who reach the Max Cuts Before Penalty. Defaults to 5 seconds if not set."`
+	MaxConsecutiveInvalidLaps int            `ini:"-" min:"0" help:"Warn drivers in chat each time they complete this many invalid laps (laps with cuts) in a row. The count is reset by a lap without cuts, and at the start of each session. 0 disables the warning."`
+	WarnCarModelMismatch      bool           `ini:"-" help:"When the entry list is not locked, send a chat message to drivers who join in a car which is not configured for the event. Drivers in mismatched cars are always highlighted in Live Timings."`
+	JoinSpamMaxConnections    int            `ini:"-" min:"0" help:"If a driver connects to the server more than this many times within the Join Spam Window, the Join Spam Action is taken. Repeatedly joining and leaving disrupts the grid for other drivers. 0 disables this."`
+	JoinSpamWindowMinutes     int            `ini:"-" min:"0" help:"The length of time (in minutes) in which driver connections are counted for join spam detection. Defaults to 5 minutes if not set."`
+	JoinSpamAction            JoinSpamAction `ini:"-" help:"The action to take when a driver is detected as join spamming."`
+	MaxPlausibleLapTime       int            `ini:"-" min:"0" help:"Laps longer than this many seconds (e.g. laps including a long pit stop, or a spin and rejoin) are treated as in/out laps. They still count towards a driver's number of laps, but not their best or average lap in Live Timings. Defaults to 1200 seconds (20 minutes) if not set."`
+	AverageLapSkipsFirstLap   bool           `ini:"-" help:"Leave out each driver's first lap in a car when calculating their average lap in Live Timings, since a standing start (or a lap started from the pits) skews the average. In/out laps longer than the Max Plausible Lap Time are always left out."`
+	PersistTimingsMinDrivers  int            `ini:"-" min:"0" help:"Live Timings are only saved (so that they can be restored if Server Manager restarts) while at least this many drivers are connected. Live Timings are always shown on the Live Timings page. Defaults to 1."`
+	LogAllLaps                bool           `ini:"-" help:"Keeps a permanent log of every lap completed on the server (driver, car, lap time, cuts and time completed). Unlike Live Timings, this log is never overwritten, so it can be used to audit lap times. This can use a lot of storage on busy servers."`
+	LiveLapCSV                bool           `ini:"-" help:"Writes every lap to a CSV file as soon as it is completed, with a new file for each session. The files are stored in the logs/laps folder of your Assetto Corsa Server install, and are kept up to date even if Server Manager stops unexpectedly."`
+	LogUDPEvents              bool           `ini:"-" help:"Keeps a log of every UDP message received from the Assetto Corsa server, which can be replayed through Live Timings to reproduce problems. This is intended for debugging, and can use a lot of storage on busy servers."`
+	WebhookURL                string         `ini:"-" help:"A URL which race control events are POSTed to as JSON, e.g. to send them to Discord or another external system. Events are sent in the background, and retried a few times if the URL can't be reached. Leave empty to disable the webhook."`
+	WebhookEvents             string         `ini:"-" help:"A comma separated list of the events to send to the Webhook URL, from: new_session, end_session, client_connect, client_disconnect and session_fastest_lap (a new fastest lap of the session). All events are sent if not set."`
+	GroupPracticeByCar        bool           `ini:"-" help:"In practice sessions with more than one car, show Live Timings standings for each car model separately instead of one combined list."`
+	ClearDisconnectedOnLoop   bool           `ini:"-" help:"In looped practice sessions, clear the disconnected drivers from Live Timings at the start of each loop. Connected drivers are always kept."`
+	ReconnectDriversByName    bool           `ini:"-" help:"If a driver connects with a GUID which Live Timings does not know, but their name matches exactly one disconnected driver who was in the same car slot, restore the disconnected driver's laps for them. Only enable this if you trust the drivers on your server, as anyone could join using another driver's name."`
+	StrictDriverResolution    bool           `ini:"-" help:"If Server Manager repeatedly receives messages about a car which it does not have a connected driver for (e.g. after missing a driver's connection), request the car's information from the server to resynchronise Live Timings."`
+	CarUpdateMissThreshold    int            `ini:"-" min:"0" help:"The number of car position updates a driver can miss in a row before Live Timings assumes they have disconnected, e.g. if their disconnection was not received. Increase this on servers with high ping drivers. Defaults to 5 minutes' worth of updates if not set."`
+	CarUpdateBroadcastMs      int            `ini:"-" min:"0" help:"The minimum time (in milliseconds) between car position updates sent to Live Timings for each car. Increase this to reduce the amount of data sent to Live Timings and minimap overlays, e.g. 100 for 10 updates per second. 0 sends every update."`
+	SessionInfoInterval       int            `ini:"-" min:"0" help:"How often (in seconds) Server Manager asks the server for session information, such as the track and air temperatures and the session time. Shorter intervals keep Live Timings more up to date, at the cost of more UDP messages. The minimum is 5 seconds. Defaults to 30 seconds if not set."`
+	AnonymiseDriverGUIDs      bool           `ini:"-" help:"Replace drivers' GUIDs (Steam IDs) in Live Timings with anonymous tokens, so that they aren't visible to the public. Each driver's token stays the same while the salt below is unchanged, so they keep their token when they reconnect."`
+	AnonymiseGUIDSalt         string         `ini:"-" help:"A secret value which is used to generate the anonymous tokens for driver GUIDs. Changing it gives every driver a new token. It should be set to something hard to guess, otherwise tokens could be matched to known Steam IDs."`
+	SpeedTrapSplinePosition   float64        `ini:"-" min:"0" max:"1" step:"0.001" help:"The position around the lap (from 0 to 1, where 0.5 is half way around the lap) of a speed trap. Each driver's speed is recorded as they pass it, and shown in a speed trap leaderboard. 0 disables the speed trap."`
+	SpeedUnit                 SpeedUnit      `ini:"-" help:"The unit used for speeds in Live Timings and overlays (top speeds, the speed trap and collision speeds), and in collision chat messages. Speed settings on this page, such as the Min Collision Speed, are always in km/h."`
 
 	// Discord Integration
 	DiscordIntegration FormHeading `ini:"-" json:"-"`
@@ -223,6 +280,10 @@ type GlobalServerConfig struct {
 	NotificationReminderTimers  string               `ini:"-" help:"If Discord is enabled, a reminder will be sent this many minutes prior to race start.  If 0 or empty, only race start messages will be sent.  You may schedule multiple reminders by using a comma separated list like 120,15."`
 	ShowPasswordInNotifications formulate.BoolNumber `ini:"-" help:"Show the server password in race start notifications."`
 	NotifyWhenScheduled         formulate.BoolNumber `ini:"-" help:"Send a notification when a race is scheduled (or cancelled)."`
+	QuietHoursStart             string               `ini:"-" help:"If set (along with Quiet Hours End), notifications, webhook events and chat announcements of fastest laps, pole position and incidents will not be sent between these times. Use the 24 hour format HH:MM, e.g. 23:00."`
+	QuietHoursEnd               string               `ini:"-" help:"The end of the quiet hours period, in the 24 hour format HH:MM, e.g. 07:30. Quiet hours may span midnight."`
+	QuietHoursTimezone          string               `ini:"-" help:"The timezone of the quiet hours, e.g. Europe/London. If empty, the timezone of the server is used."`
+	DiscordStandingsInterval    int                  `ini:"-" min:"0" help:"If Discord is enabled, post the current standings to the Discord channel as a table, updating it at most every this many minutes while drivers are completing laps. The same message is edited for each update rather than sending a new one. 0 disables this."`
 
 	// Messages
 	ContentManagerWelcomeMessage string `ini:"-" show:"-"`
@@ -269,6 +330,110 @@ func (s StartRule) String() string {
 	return ""
 }
 
+type SolWarningMode uint8
+
+const (
+	SolWarningModeAlways    SolWarningMode = 0
+	SolWarningModeFirstJoin SolWarningMode = 1
+	SolWarningModeNever     SolWarningMode = 2
+)
+
+func (s SolWarningMode) SelectMultiple() bool {
+	return false
+}
+
+func (s SolWarningMode) SelectOptions() []formulate.Option {
+	return []formulate.Option{
+		{
+			Value: SolWarningModeAlways,
+			Label: "Always show the Sol warning",
+		},
+		{
+			Value: SolWarningModeFirstJoin,
+			Label: "Only show the Sol warning the first time a driver joins each session",
+		},
+		{
+			Value: SolWarningModeNever,
+			Label: "Never show the Sol warning",
+		},
+	}
+}
+
+type JoinSpamAction uint8
+
+const (
+	JoinSpamActionLog  JoinSpamAction = 0
+	JoinSpamActionKick JoinSpamAction = 1
+)
+
+func (j JoinSpamAction) SelectMultiple() bool {
+	return false
+}
+
+func (j JoinSpamAction) SelectOptions() []formulate.Option {
+	return []formulate.Option{
+		{
+			Value: JoinSpamActionLog,
+			Label: "Log a warning about the driver",
+		},
+		{
+			Value: JoinSpamActionKick,
+			Label: "Kick the driver",
+		},
+	}
+}
+
+type SpeedUnit uint8
+
+const (
+	SpeedUnitKMH SpeedUnit = 0
+	SpeedUnitMPH SpeedUnit = 1
+)
+
+func (s SpeedUnit) SelectMultiple() bool {
+	return false
+}
+
+func (s SpeedUnit) SelectOptions() []formulate.Option {
+	return []formulate.Option{
+		{
+			Value: SpeedUnitKMH,
+			Label: "Kilometres per hour (km/h)",
+		},
+		{
+			Value: SpeedUnitMPH,
+			Label: "Miles per hour (mph)",
+		},
+	}
+}
+
+// Label is the abbreviation of the unit, as shown after a speed.
+func (s SpeedUnit) Label() string {
+	if s == SpeedUnitMPH {
+		return "mph"
+	}
+
+	return "km/h"
+}
+
+// FromMetersPerSecond converts a speed in metres per second to the unit.
+func (s SpeedUnit) FromMetersPerSecond(mps float64) float64 {
+	if s == SpeedUnitMPH {
+		return metersPerSecondToMilesPerHour(mps)
+	}
+
+	return metersPerSecondToKilometersPerHour(mps)
+}
+
+// FromKilometersPerHour converts a speed in km/h to the unit.
+func (s SpeedUnit) FromKilometersPerHour(kmh float64) float64 {
+	if s == SpeedUnitMPH {
+		return kmh / kilometersPerMile
+	}
+
+	return kmh
+}
+
 type BlockListMode uint8
 
 func (b BlockListMode) SelectMultiple() bool {
@@ -329,12 +494,14 @@ type CurrentRaceConfig struct {
 	LockedEntryList   int `ini:"LOCKED_ENTRY_LIST" input:"checkbox" help:"Only players already included in the entry list can join the server"`
 	LoopMode          int `ini:"LOOP_MODE" input:"checkbox" help:"the server restarts from the first track, to disable this set it to 0"`
 
-	DriverSwapEnabled               int `ini:"-" help:"Enable Driver Swaps, in order to carry out a Driver Swap give an entrant two or more GUIDs separated by ;'s'"`
-	DriverSwapMinTime               int `ini:"-" help:"Minimum time for a driver swap, used to avoid giving users with faster computers an advantage. If the second driver sets off before this time they will be disqualified/given a penalty based on configuration"`
-	DriverSwapDisqualifyTime        int `ini:"-" help:"Driver should be disqualified if they set off this many seconds or more before the minimum time during a Driver Swap"`
-	DriverSwapPenaltyTime           int `ini:"-" help:"Driver should be given a penalty of this many seconds if they set off this many seconds or more before the minimum time during a Driver Swap"`
-	DriverSwapMinimumNumberOfSwaps  int `ini:"-" help:"Minimum number of swaps required."`
-	DriverSwapNotEnoughSwapsPenalty int `ini:"-" help:"Penalty to be applied if the minimum number of swaps is not met. Applied once per each swap not taken. (Seconds)"`
+	DriverSwapEnabled               int           `ini:"-" help:"Enable Driver Swaps, in order to carry out a Driver Swap give an entrant two or more GUIDs separated by ;'s'"`
+	DriverSwapMinTime               int           `ini:"-" help:"Minimum time for a driver swap, used to avoid giving users with faster computers an advantage. If the second driver sets off before this time they will be disqualified/given a penalty based on configuration"`
+	DriverSwapDisqualifyTime        int           `ini:"-" help:"Driver should be disqualified if they set off this many seconds or more before the minimum time during a Driver Swap"`
+	DriverSwapPenaltyTime           int           `ini:"-" help:"Driver should be given a penalty of this many seconds if they set off this many seconds or more before the minimum time during a Driver Swap"`
+	DriverSwapMinimumNumberOfSwaps  int           `ini:"-" help:"Minimum number of swaps required."`
+	DriverSwapNotEnoughSwapsPenalty int           `ini:"-" help:"Penalty to be applied if the minimum number of swaps is not met. Applied once per each swap not taken. (Seconds)"`
+	DriverSwapSessions              []SessionType `ini:"-" help:"The sessions in which Driver Swaps are enforced. If none are selected, Driver Swaps are only enforced in the race"`
+	DriverSwapMovementThreshold     float64       `ini:"-" help:"How far (in metres, along any axis) a car can move during a Driver Swap before it is considered to have left its pit box. Defaults to 10"`
 
 	MaxClients   int       `ini:"MAX_CLIENTS" help:"max number of clients (must be <= track's number of pits)"`
 	RaceOverTime int       `ini:"RACE_OVER_TIME" help:"time remaining in seconds to finish the race from the moment the first one passes on the finish line"`
@@ -406,6 +573,37 @@ func (c CurrentRaceConfig) HasSession(sess SessionType) bool {
 	return ok
 }
 
+// DriverSwapEnabledForSession reports whether Driver Swaps should be enforced in the given session.
+func (c CurrentRaceConfig) DriverSwapEnabledForSession(sess SessionType) bool {
+	return c.DriverSwapEnabled == 1 && c.DriverSwapSessionsInclude(sess)
+}
+
+// DriverSwapSessionsInclude reports whether the given session is one of the DriverSwapSessions.
+func (c CurrentRaceConfig) DriverSwapSessionsInclude(sess SessionType) bool {
+	if len(c.DriverSwapSessions) == 0 {
+		// events created before DriverSwapSessions existed only enforced swaps in the race
+		return sess == SessionTypeRace
+	}
+
+	for _, swapSession := range c.DriverSwapSessions {
+		if swapSession == sess {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DriverSwapPositionThreshold is how far a car can move along any axis during a driver swap before it is
+// considered to have left the pits.
+func (c CurrentRaceConfig) DriverSwapPositionThreshold() float64 {
+	if c.DriverSwapMovementThreshold <= 0 {
+		return defaultDriverSwapMovementThreshold
+	}
+
+	return c.DriverSwapMovementThreshold
+}
+
 func (c CurrentRaceConfig) GetSession(sessionType SessionType) *SessionConfig {
 	sess, ok := c.Sessions[sessionType]
 
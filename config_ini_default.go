@@ -71,6 +71,7 @@ func ConfigIniDefault() ServerConfig {
 			DriverSwapPenaltyTime:           0,
 			DriverSwapMinimumNumberOfSwaps:  0,
 			DriverSwapNotEnoughSwapsPenalty: 0,
+			DriverSwapMovementThreshold:     defaultDriverSwapMovementThreshold,
 
 			Sessions: map[SessionType]*SessionConfig{
 				SessionTypePractice: {
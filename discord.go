@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	embed "github.com/Clinet/discordgo-embed"
@@ -18,6 +19,11 @@ type DiscordManager struct {
 	discord               *discordgo.Session
 	scheduledRacesManager *ScheduledRacesManager
 	enabled               bool
+
+	// the standings message is edited with each update, until the standings title changes
+	standingsMessage      *discordgo.Message
+	standingsMessageTitle string
+	standingsMutex        sync.Mutex
 }
 
 // NewDiscordManager instantiates the DiscordManager type.  On error, it will log the error and return the type
@@ -390,3 +396,52 @@ func (dm *DiscordManager) SendMessageWithLink(title string, msg string, linkText
 
 	return err
 }
+
+// SendOrUpdateStandings sends the standings table to the configured channel, or edits the previous standings message
+// if it has the same title, so that the channel isn't flooded with updates.
+func (dm *DiscordManager) SendOrUpdateStandings(title string, table string) error {
+	if !dm.enabled {
+		return nil
+	}
+
+	opts, err := dm.store.LoadServerOptions()
+
+	if err != nil {
+		logrus.WithError(err).Errorf("couldn't load server options")
+		return err
+	}
+
+	if opts.DiscordChannelID == "" {
+		err = errors.New("no channel ID set in config")
+		logrus.WithError(err).Errorf("couldn't send discord standings")
+		return err
+	}
+
+	dm.standingsMutex.Lock()
+	defer dm.standingsMutex.Unlock()
+
+	content := fmt.Sprintf("**%s**\n%s", title, table)
+
+	if dm.standingsMessage != nil && dm.standingsMessage.ChannelID == opts.DiscordChannelID && dm.standingsMessageTitle == title {
+		_, err = dm.discord.ChannelMessageEdit(dm.standingsMessage.ChannelID, dm.standingsMessage.ID, content)
+
+		if err == nil {
+			return nil
+		}
+
+		// the message may have been deleted, so send a new one instead
+		logrus.WithError(err).Warnf("couldn't edit discord standings message, sending a new one")
+	}
+
+	message, err := dm.discord.ChannelMessageSend(opts.DiscordChannelID, content)
+
+	if err != nil {
+		logrus.WithError(err).Errorf("couldn't send discord standings")
+		return err
+	}
+
+	dm.standingsMessage = message
+	dm.standingsMessageTitle = title
+
+	return nil
+}
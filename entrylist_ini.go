@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/cj123/ini"
@@ -354,3 +355,84 @@ func NormaliseEntrantGUIDs(guids []string) string {
 
 	return strings.Join(guids, driverSwapEntrantSeparator)
 }
+
+// BoPEntry is the ballast and restrictor given to every entrant in a car model.
+type BoPEntry struct {
+	Ballast    int
+	Restrictor int
+}
+
+// BoPTable is a Balance of Performance table, keyed by car model.
+type BoPTable map[string]BoPEntry
+
+// parseBoPTable reads a BoPTable with one car model per line, in the format car_model,ballast,restrictor.
+// Blank lines are ignored.
+func parseBoPTable(table string) (BoPTable, error) {
+	bop := make(BoPTable)
+
+	for i, line := range strings.Split(table, "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			continue
+		}
+
+		parts := strings.Split(line, ",")
+
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("servermanager: invalid bop table line %d: %q, expected car_model,ballast,restrictor", i+1, line)
+		}
+
+		model := strings.TrimSpace(parts[0])
+
+		ballast, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+
+		if err != nil || ballast < 0 {
+			return nil, fmt.Errorf("servermanager: invalid ballast on bop table line %d: %q", i+1, line)
+		}
+
+		restrictor, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+
+		if err != nil || restrictor < 0 || restrictor > 100 {
+			return nil, fmt.Errorf("servermanager: invalid restrictor on bop table line %d: %q", i+1, line)
+		}
+
+		bop[model] = BoPEntry{Ballast: ballast, Restrictor: restrictor}
+	}
+
+	return bop, nil
+}
+
+// GreatestBallast is the largest ballast in the table.
+func (bop BoPTable) GreatestBallast() int {
+	greatestBallast := 0
+
+	for _, entry := range bop {
+		if entry.Ballast > greatestBallast {
+			greatestBallast = entry.Ballast
+		}
+	}
+
+	return greatestBallast
+}
+
+// ApplyToEntrant sets the entrant's ballast and restrictor from the table, if their car model is in it.
+func (bop BoPTable) ApplyToEntrant(entrant *Entrant) {
+	entry, ok := bop[entrant.Model]
+
+	if !ok {
+		return
+	}
+
+	logrus.Infof("Applying BoP to entrant: %s (%s), ballast: %dkg, restrictor: %d%%", entrant.Name, entrant.Model, entry.Ballast, entry.Restrictor)
+
+	entrant.Ballast = entry.Ballast
+	entrant.Restrictor = entry.Restrictor
+}
+
+// ApplyToEntryList sets the ballast and restrictor of each entrant in the entry list from the table.
+func (bop BoPTable) ApplyToEntryList(entryList EntryList) {
+	for _, entrant := range entryList.AsSlice() {
+		bop.ApplyToEntrant(entrant)
+	}
+}
@@ -24,3 +24,68 @@ func TestCleanGUIDs(t *testing.T) {
 		}
 	}
 }
+
+func TestParseBoPTable(t *testing.T) {
+	bop, err := parseBoPTable("ks_mazda_mx5_cup,20,5\n\n ferrari_458_gt2 , 35 , 0 \n")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := BoPTable{
+		"ks_mazda_mx5_cup": {Ballast: 20, Restrictor: 5},
+		"ferrari_458_gt2":  {Ballast: 35, Restrictor: 0},
+	}
+
+	if len(bop) != len(expected) {
+		t.Fatalf("Expected %d BoP entries, got %d", len(expected), len(bop))
+	}
+
+	for model, entry := range expected {
+		if bop[model] != entry {
+			t.Errorf("Expected BoP for %s to be %+v, got %+v", model, entry, bop[model])
+		}
+	}
+
+	if bop.GreatestBallast() != 35 {
+		t.Errorf("Expected greatest ballast of 35, got %d", bop.GreatestBallast())
+	}
+
+	for _, invalid := range []string{"ks_mazda_mx5_cup,20", "ks_mazda_mx5_cup,heavy,5", "ks_mazda_mx5_cup,20,150", "ks_mazda_mx5_cup,-5,0"} {
+		if _, err := parseBoPTable(invalid); err == nil {
+			t.Errorf("Expected an error for invalid BoP table: %q", invalid)
+		}
+	}
+}
+
+func TestBoPTable_ApplyToEntryList(t *testing.T) {
+	entryList := make(EntryList)
+
+	entryList.AddToBackOfGrid(&Entrant{Name: "Driver 1", GUID: "1", Model: "ks_mazda_mx5_cup", Ballast: 100, Restrictor: 50})
+	entryList.AddToBackOfGrid(&Entrant{Name: "Driver 2", GUID: "2", Model: "ferrari_458_gt2"})
+	entryList.AddToBackOfGrid(&Entrant{Name: "Driver 3", GUID: "3", Model: "abarth500", Ballast: 10, Restrictor: 2})
+
+	bop := BoPTable{
+		"ks_mazda_mx5_cup": {Ballast: 20, Restrictor: 5},
+		"ferrari_458_gt2":  {Ballast: 35, Restrictor: 0},
+	}
+
+	bop.ApplyToEntryList(entryList)
+
+	expected := map[string]BoPEntry{
+		"ks_mazda_mx5_cup": {Ballast: 20, Restrictor: 5},
+		"ferrari_458_gt2":  {Ballast: 35, Restrictor: 0},
+		// car models which aren't in the table keep their entry list values
+		"abarth500": {Ballast: 10, Restrictor: 2},
+	}
+
+	for _, entrant := range entryList.AsSlice() {
+		if entry := expected[entrant.Model]; entrant.Ballast != entry.Ballast || entrant.Restrictor != entry.Restrictor {
+			t.Errorf("Expected %s to have ballast %d and restrictor %d, got %d and %d", entrant.Model, entry.Ballast, entry.Restrictor, entrant.Ballast, entrant.Restrictor)
+		}
+	}
+
+	if greatestBallast := entryList.FindGreatestBallast(); greatestBallast != 35 {
+		t.Errorf("Expected greatest ballast in the entry list to be 35, got %d", greatestBallast)
+	}
+}
@@ -23,6 +23,7 @@ type NotificationDispatcher interface {
 	SendRaceReminderMessage(event *CustomRace, timer int) error
 	SendChampionshipReminderMessage(championship *Championship, event *ChampionshipEvent, timer int) error
 	SendRaceWeekendReminderMessage(raceWeekend *RaceWeekend, session *RaceWeekendSession, timer int) error
+	SendStandings(title string, standings []StandingsTableRow) error
 	SaveServerOptions(oldServerOpts *GlobalServerConfig, newServerOpts *GlobalServerConfig) error
 }
 
@@ -96,10 +97,82 @@ func (nm *NotificationManager) GetNotificationReminders() []int {
 	return reminders
 }
 
+// inQuietHours determines whether t is within the quiet hours configured in the server options, during which
+// notifications are not sent.
+func (nm *NotificationManager) inQuietHours(t time.Time) bool {
+	serverOpts, err := nm.store.LoadServerOptions()
+
+	if err != nil {
+		logrus.WithError(err).Errorf("couldn't load server options, ignoring quiet hours")
+		return false
+	}
+
+	inQuietHours, err := isInQuietHours(t, serverOpts.QuietHoursStart, serverOpts.QuietHoursEnd, serverOpts.QuietHoursTimezone)
+
+	if err != nil {
+		logrus.WithError(err).Errorf("couldn't check quiet hours, ignoring quiet hours")
+		return false
+	}
+
+	return inQuietHours
+}
+
+// isInQuietHours determines whether t is between the start and end times (in the format HH:MM) in the given
+// timezone. The period may span midnight. If either start or end is empty, there are no quiet hours.
+func isInQuietHours(t time.Time, start, end, timezone string) (bool, error) {
+	if start == "" || end == "" {
+		return false, nil
+	}
+
+	location := time.Local
+
+	if timezone != "" {
+		var err error
+
+		location, err = time.LoadLocation(timezone)
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	startTime, err := time.Parse("15:04", start)
+
+	if err != nil {
+		return false, err
+	}
+
+	endTime, err := time.Parse("15:04", end)
+
+	if err != nil {
+		return false, err
+	}
+
+	t = t.In(location)
+
+	minuteOfDay := func(t time.Time) int {
+		return t.Hour()*60 + t.Minute()
+	}
+
+	now, startMinute, endMinute := minuteOfDay(t), minuteOfDay(startTime), minuteOfDay(endTime)
+
+	if startMinute <= endMinute {
+		return now >= startMinute && now < endMinute, nil
+	}
+
+	// quiet hours span midnight
+	return now >= startMinute || now < endMinute, nil
+}
+
 // SendMessage sends a message (surprise surprise)
 func (nm *NotificationManager) SendMessage(title string, msg string) error {
 	var err error
 
+	if nm.inQuietHours(time.Now()) {
+		logrus.Debugf("Notification '%s' not sent, it is currently quiet hours", title)
+		return nil
+	}
+
 	// Call all message senders here ... atm just discord.  The manager will know if it's enabled or not, so just call it
 	if !nm.testing {
 		err = nm.discordManager.SendMessage(title, msg)
@@ -112,6 +185,11 @@ func (nm *NotificationManager) SendMessage(title string, msg string) error {
 func (nm *NotificationManager) SendMessageWithLink(title string, msg string, linkText string, link *url.URL) error {
 	var err error
 
+	if nm.inQuietHours(time.Now()) {
+		logrus.Debugf("Notification '%s' not sent, it is currently quiet hours", title)
+		return nil
+	}
+
 	// Call all message senders here ... atm just discord.  The manager will know if it's enabled or not, so just call it
 	if !nm.testing {
 		err = nm.discordManager.SendMessageWithLink(title, msg, linkText, link)
@@ -314,3 +392,63 @@ func (nm *NotificationManager) SendRaceWeekendReminderMessage(raceWeekend *RaceW
 	msg := fmt.Sprintf("%s at %s (%s Race Weekend) starts in %s", session.Name(), raceWeekend.Name, trackInfo, reminder)
 	return nm.SendMessage(title, msg)
 }
+
+// maxStandingsTableRows is the number of drivers shown in a standings table, which keeps it within the length limit
+// of a Discord message.
+const maxStandingsTableRows = 30
+
+// formatStandingsTable formats the standings as a fixed width table in a code block.
+func formatStandingsTable(standings []StandingsTableRow) string {
+	var table strings.Builder
+
+	table.WriteString("```\n")
+	table.WriteString(fmt.Sprintf("%-4s %-20s %-10s %s\n", "Pos", "Driver", "Gap", "Best Lap"))
+
+	for i, row := range standings {
+		if i >= maxStandingsTableRows {
+			table.WriteString(fmt.Sprintf("... and %d more\n", len(standings)-maxStandingsTableRows))
+			break
+		}
+
+		name := []rune(row.Name)
+
+		if len(name) > 20 {
+			name = name[:20]
+		}
+
+		gap := row.Gap
+
+		if gap == "" {
+			gap = "-"
+		}
+
+		bestLap := "-"
+
+		if row.BestLap > 0 {
+			bestLap = formatDuration(row.BestLap, true)
+		}
+
+		table.WriteString(fmt.Sprintf("%-4d %-20s %-10s %s\n", row.Position, string(name), gap, bestLap))
+	}
+
+	table.WriteString("```")
+
+	return table.String()
+}
+
+// SendStandings posts the standings to Discord as a table. Rather than sending a new message each time, the previous
+// standings message is edited, until the title changes.
+func (nm *NotificationManager) SendStandings(title string, standings []StandingsTableRow) error {
+	var err error
+
+	if nm.inQuietHours(time.Now()) {
+		logrus.Debugf("Standings '%s' not sent, it is currently quiet hours", title)
+		return nil
+	}
+
+	if !nm.testing {
+		err = nm.discordManager.SendOrUpdateStandings(title, formatStandingsTable(standings))
+	}
+
+	return err
+}
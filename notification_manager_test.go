@@ -0,0 +1,107 @@
+package servermanager
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsInQuietHours(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		Name                 string
+		Time                 time.Time
+		Start, End, Timezone string
+		Expected             bool
+	}{
+		{Name: "No quiet hours", Time: time.Date(2020, 1, 1, 3, 0, 0, 0, london), Timezone: "Europe/London", Expected: false},
+		{Name: "In window", Time: time.Date(2020, 1, 1, 13, 30, 0, 0, london), Start: "12:00", End: "14:00", Timezone: "Europe/London", Expected: true},
+		{Name: "Before window", Time: time.Date(2020, 1, 1, 11, 59, 0, 0, london), Start: "12:00", End: "14:00", Timezone: "Europe/London", Expected: false},
+		{Name: "End of window", Time: time.Date(2020, 1, 1, 14, 0, 0, 0, london), Start: "12:00", End: "14:00", Timezone: "Europe/London", Expected: false},
+		{Name: "Spanning midnight, late", Time: time.Date(2020, 1, 1, 23, 30, 0, 0, london), Start: "23:00", End: "07:30", Timezone: "Europe/London", Expected: true},
+		{Name: "Spanning midnight, early", Time: time.Date(2020, 1, 1, 3, 0, 0, 0, london), Start: "23:00", End: "07:30", Timezone: "Europe/London", Expected: true},
+		{Name: "Spanning midnight, out of window", Time: time.Date(2020, 1, 1, 12, 0, 0, 0, london), Start: "23:00", End: "07:30", Timezone: "Europe/London", Expected: false},
+		{Name: "Other timezone", Time: time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC), Start: "23:00", End: "07:30", Timezone: "America/Los_Angeles", Expected: false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			inQuietHours, err := isInQuietHours(testCase.Time, testCase.Start, testCase.End, testCase.Timezone)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if inQuietHours != testCase.Expected {
+				t.Errorf("Expected in quiet hours: %t, got: %t", testCase.Expected, inQuietHours)
+			}
+		})
+	}
+
+	t.Run("Invalid time", func(t *testing.T) {
+		if _, err := isInQuietHours(time.Now(), "3am", "07:30", ""); err == nil {
+			t.Errorf("Expected an error for an invalid quiet hours time")
+		}
+	})
+}
+
+func TestNotificationManager_QuietHours(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.QuietHoursStart = "01:00"
+		opts.QuietHoursEnd = "06:00"
+		opts.QuietHoursTimezone = "UTC"
+	})
+	defer cleanup()
+
+	nm := NewNotificationManager(nil, nil, store)
+
+	if !nm.inQuietHours(time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected notifications to be suppressed during quiet hours")
+	}
+
+	if nm.inQuietHours(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected notifications to be sent outside of quiet hours")
+	}
+}
+
+func TestFormatStandingsTable(t *testing.T) {
+	standings := []StandingsTableRow{
+		{Position: 1, Name: "Test 1", Gap: "0s", BestLap: 88500 * time.Millisecond},
+		{Position: 2, Name: "A Driver With A Very Long Name", Gap: "1.234s", BestLap: 89 * time.Second},
+		{Position: 3, Name: "Test 3"},
+	}
+
+	expected := "```\n" +
+		"Pos  Driver               Gap        Best Lap\n" +
+		"1    Test 1               0s         01:28.500\n" +
+		"2    A Driver With A Very 1.234s     01:29.000\n" +
+		"3    Test 3               -          -\n" +
+		"```"
+
+	if table := formatStandingsTable(standings); table != expected {
+		t.Errorf("Expected standings table:\n%s\ngot:\n%s", expected, table)
+	}
+
+	t.Run("Long standings are truncated", func(t *testing.T) {
+		var standings []StandingsTableRow
+
+		for i := 1; i <= maxStandingsTableRows+5; i++ {
+			standings = append(standings, StandingsTableRow{Position: i, Name: "Test"})
+		}
+
+		table := formatStandingsTable(standings)
+
+		if !strings.Contains(table, "... and 5 more\n") {
+			t.Errorf("Expected standings table to be truncated, got:\n%s", table)
+		}
+
+		if len(table) > 2000 {
+			t.Errorf("Expected standings table to fit in a Discord message, got %d characters", len(table))
+		}
+	})
+}
@@ -88,7 +88,25 @@ func NewPenaltiesManager(store Store) *PenaltiesManager {
 	}
 }
 
+// resultPenalty is a penalty to be applied to a driver in a results file. A Penalty of 0 disqualifies the driver,
+// optionally with a Reason and the Time that the disqualification happened. If Add is false, all penalties are
+// cleared from the driver.
+type resultPenalty struct {
+	GUID     string
+	CarModel string
+	Penalty  float64
+	Add      bool
+
+	Reason string
+	Time   time.Time
+}
+
 func (pm *PenaltiesManager) applyPenalty(jsonFileName, guid, carModel string, penalty float64, add bool) error {
+	return pm.applyPenalties(jsonFileName, []resultPenalty{{GUID: guid, CarModel: carModel, Penalty: penalty, Add: add}})
+}
+
+// applyPenalties applies each of the penalties to the results file, then re-sorts and saves the results once.
+func (pm *PenaltiesManager) applyPenalties(jsonFileName string, penalties []resultPenalty) error {
 	var results *SessionResults
 
 	var fullFileName string
@@ -108,47 +126,9 @@ func (pm *PenaltiesManager) applyPenalty(jsonFileName, guid, carModel string, pe
 		return err
 	}
 
-	for _, result := range results.Result {
-		if result.DriverGUID == guid && result.CarModel == carModel {
-			if !add {
-				result.HasPenalty = false
-				result.Disqualified = false
-				result.PenaltyTime = 0
-				result.LapPenalty = 0
-
-				logrus.Infof("All penalties cleared from Driver: %s", guid)
-			} else {
-				if penalty == 0 {
-					result.Disqualified = true
-					result.HasPenalty = false
-					result.LapPenalty = 0
-
-					logrus.Infof("Driver: %s disqualified", guid)
-				} else {
-					result.HasPenalty = true
-					result.Disqualified = false
-
-					timeParsed, err := time.ParseDuration(fmt.Sprintf("%.1fs", penalty))
-
-					if err != nil {
-						logrus.WithError(err).Errorf("could not parse penalty time")
-						return err
-					}
-
-					result.PenaltyTime = timeParsed
-
-					// If penalty time is greater than a lap then add a lap penalty and change penalty time by one lap
-					lastLapTime := results.GetLastLapTime(result.DriverGUID, result.CarModel)
-
-					if result.PenaltyTime > lastLapTime {
-						result.LapPenalty = int(result.PenaltyTime / lastLapTime)
-					}
-
-					logrus.Infof("%s penalty applied to driver: %s", timeParsed.String(), guid)
-				}
-			}
-
-			break
+	for _, penalty := range penalties {
+		if err := applyPenaltyToResults(results, penalty); err != nil {
+			return err
 		}
 	}
 
@@ -294,3 +274,58 @@ func (pm *PenaltiesManager) applyPenalty(jsonFileName, guid, carModel string, pe
 
 	return nil
 }
+
+// applyPenaltyToResults applies a penalty to a driver in the results, without re-sorting them.
+func applyPenaltyToResults(results *SessionResults, penalty resultPenalty) error {
+	for _, result := range results.Result {
+		if result.DriverGUID == penalty.GUID && result.CarModel == penalty.CarModel {
+			if !penalty.Add {
+				result.HasPenalty = false
+				result.Disqualified = false
+				result.PenaltyTime = 0
+				result.LapPenalty = 0
+				result.DisqualificationReason = ""
+				result.DisqualificationTime = time.Time{}
+
+				logrus.Infof("All penalties cleared from Driver: %s", penalty.GUID)
+			} else {
+				if penalty.Penalty == 0 {
+					result.Disqualified = true
+					result.HasPenalty = false
+					result.LapPenalty = 0
+					result.DisqualificationReason = penalty.Reason
+					result.DisqualificationTime = penalty.Time
+
+					logrus.Infof("Driver: %s disqualified", penalty.GUID)
+				} else {
+					result.HasPenalty = true
+					result.Disqualified = false
+					result.DisqualificationReason = ""
+					result.DisqualificationTime = time.Time{}
+
+					timeParsed, err := time.ParseDuration(fmt.Sprintf("%.1fs", penalty.Penalty))
+
+					if err != nil {
+						logrus.WithError(err).Errorf("could not parse penalty time")
+						return err
+					}
+
+					result.PenaltyTime = timeParsed
+
+					// If penalty time is greater than a lap then add a lap penalty and change penalty time by one lap
+					lastLapTime := results.GetLastLapTime(result.DriverGUID, result.CarModel)
+
+					if result.PenaltyTime > lastLapTime {
+						result.LapPenalty = int(result.PenaltyTime / lastLapTime)
+					}
+
+					logrus.Infof("%s penalty applied to driver: %s", timeParsed.String(), penalty.GUID)
+				}
+			}
+
+			break
+		}
+	}
+
+	return nil
+}
@@ -271,6 +271,9 @@ func (asu *AssettoServerUDP) SendMessage(message Message) error {
 
 		return nil
 
+	case GetCarInfo:
+		return binary.Write(asu.listener, binary.LittleEndian, a)
+
 	case GetSessionInfo, *RestartSession, *NextSession:
 		err := binary.Write(asu.listener, binary.LittleEndian, a.Event())
 
@@ -252,6 +252,22 @@ func (GetSessionInfo) Event() Event {
 	return EventGetSessionInfo
 }
 
+type GetCarInfo struct {
+	Type  uint8
+	CarID CarID
+}
+
+func (GetCarInfo) Event() Event {
+	return EventGetCarInfo
+}
+
+func NewGetCarInfo(carID CarID) GetCarInfo {
+	return GetCarInfo{
+		Type:  uint8(EventGetCarInfo),
+		CarID: carID,
+	}
+}
+
 type EnableRealtimePosInterval struct {
 	Type     uint8
 	Interval uint16
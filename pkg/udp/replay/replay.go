@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"io"
 	"sort"
 	"sync"
 	"time"
@@ -267,3 +268,49 @@ func UDPMessages(db *bbolt.DB, multiplier int, callbackFunc udp.CallbackFunc, wa
 
 	return err
 }
+
+// ReadEntries reads a capture of UDP messages in JSON format (as used by the session file converter), sorted by the
+// time they were received.
+func ReadEntries(r io.Reader) (Entries, error) {
+	var entries Entries
+
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	sort.Stable(entries)
+
+	return entries, nil
+}
+
+// Replay sends each entry to the callbackFunc in the order they were received, waiting for each to be handled
+// before sending the next, so that a session can be reproduced deterministically. The time between entries is
+// divided by the multiplier (1 is real time) and capped at waitTime. A multiplier of 0 replays the entries
+// without waiting.
+func (e Entries) Replay(multiplier int, callbackFunc udp.CallbackFunc, waitTime time.Duration) {
+	if len(e) == 0 {
+		return
+	}
+
+	timeStart := e[0].Received
+
+	for _, entry := range e {
+		if multiplier > 0 {
+			tickDuration := entry.Received.Sub(timeStart) / time.Duration(multiplier)
+
+			if tickDuration > waitTime {
+				tickDuration = waitTime
+			}
+
+			if tickDuration > 0 {
+				time.Sleep(tickDuration)
+			}
+		}
+
+		if entry.Data != nil {
+			callbackFunc(entry.Data)
+		}
+
+		timeStart = entry.Received
+	}
+}
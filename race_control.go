@@ -1,12 +1,17 @@
 package servermanager
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"math"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/google/uuid"
@@ -28,18 +33,55 @@ type RaceControl struct {
 	SessionStartTime           time.Time       `json:"SessionStartTime"`
 	CurrentRealtimePosInterval int             `json:"CurrentRealtimePosInterval"`
 
+	// SessionID uniquely identifies the current session, e.g. for looking up its collisions in the store.
+	SessionID string `json:"SessionID"`
+
 	ChatMessages      []udp.Chat
 	ChatMessagesMutex sync.Mutex
 
 	ConnectedDrivers    *DriverMap `json:"ConnectedDrivers"`
 	DisconnectedDrivers *DriverMap `json:"DisconnectedDrivers"`
 
+	// StandingsByCar is set in practice sessions if GroupPracticeByCar is enabled. standingsByCarMutex guards it, as
+	// it is encoded for new Live Timings clients outside of the UDP callback.
+	StandingsByCar      []CarStandings `json:"StandingsByCar,omitempty"`
+	standingsByCarMutex sync.RWMutex
+
 	CarIDToGUID      map[udp.CarID]udp.DriverGUID `json:"CarIDToGUID"`
 	carIDToGUIDMutex sync.RWMutex
 
+	// staleCarIDs are CarIDToGUID entries which did not match a connected driver at the last check.
+	staleCarIDs map[udp.CarID]udp.DriverGUID
+
+	// unknownCarIDs counts the messages received for each CarID which could not be resolved to a connected driver,
+	// for StrictDriverResolution. lastResync is the most recent time that a resync was requested for each CarID.
+	unknownCarIDs      map[udp.CarID]int
+	lastResync         map[udp.CarID]time.Time
+	unknownCarIDsMutex sync.Mutex
+
 	carUpdaters          map[udp.CarID]chan udp.CarUpdate
 	serverProcessStopped chan struct{}
 
+	// synchronousCarUpdates handles each car update as it is received, rather than in a goroutine per car, so that a
+	// replay of the event log is deterministic.
+	synchronousCarUpdates bool
+
+	// stopped is closed by Stop, ending the background goroutines of a RaceControl which is no longer needed.
+	// background tracks the goroutines which use the store, so that Stop can wait for them to finish.
+	stopped    chan struct{}
+	stopOnce   sync.Once
+	background sync.WaitGroup
+
+	// sessionInfoInterval is how often session info is requested from the server, set from SessionInfoInterval at
+	// the start of each session. sessionInfoIntervalChanged tells the request loop when it changes.
+	sessionInfoInterval        time.Duration
+	sessionInfoIntervalMutex   sync.Mutex
+	sessionInfoIntervalChanged chan struct{}
+
+	// sessionInfoMaxBackoff is the longest the request loop waits between session info requests while the UDP
+	// connection is unavailable.
+	sessionInfoMaxBackoff time.Duration
+
 	broadcaster      Broadcaster
 	trackDataGateway TrackDataGateway
 
@@ -50,15 +92,261 @@ type RaceControl struct {
 
 	persistStoreDataMutex sync.Mutex
 
-	// driver swap
-	driverSwapTimers         map[int]*time.Timer
-	driverSwapPenaltiesMutex sync.Mutex
-	driverSwapPenalties      map[udp.DriverGUID]*driverSwapPenalty
+	// serverOptions are cached for use in frequently called handlers (e.g. car updates), where loading them
+	// from the store each time would be too expensive. They are refreshed at the start of each session, and by
+	// ReloadConfig. driverSwapCountdownIntervals are parsed from the serverOptions when they are refreshed.
+	serverOptions                *GlobalServerConfig
+	driverSwapCountdownIntervals map[time.Duration]bool
+	serverOptionsMutex           sync.RWMutex
+
+	// trackRecords are the fastest laps ever recorded in the lap log at the current track, keyed by car model.
+	// personalTrackBests are each driver's fastest laps at the current track before the session started, keyed by
+	// driver GUID and then car model. Both are loaded from the lap log at the start of each session.
+	trackRecords       map[string]trackRecord
+	personalTrackBests map[udp.DriverGUID]map[string]time.Duration
+	trackRecordsMutex  sync.RWMutex
+
+	// SessionFastestLap is the fastest valid lap of the session, nil until one has been set. It stores the driver's
+	// GUID and name rather than the driver, so that it is kept if they disconnect. lastFastestLapAnnouncement is the
+	// time that a new fastest lap was last announced in chat.
+	SessionFastestLap          *SessionFastestLap `json:"SessionFastestLap,omitempty"`
+	lastFastestLapAnnouncement time.Time
+	sessionFastestLapMutex     sync.Mutex
+
+	// temperatureSamples are the ambient and road temperatures reported by the server this session.
+	temperatureSamples      []temperatureSample
+	temperatureSamplesMutex sync.Mutex
+
+	// readyGUIDs are the drivers who have said they are ready this session
+	readyGUIDs      map[udp.DriverGUID]bool
+	readyGUIDsMutex sync.Mutex
+
+	// firstLapGUIDs are the drivers who have completed a lap this session
+	firstLapGUIDs      map[udp.DriverGUID]bool
+	firstLapGUIDsMutex sync.Mutex
+
+	// solWarningGUIDs tracks which drivers have been shown the Sol warning this session
+	solWarningGUIDs      map[udp.DriverGUID]bool
+	solWarningGUIDsMutex sync.Mutex
+
+	// connectionTimes records when each driver has connected, for join spam detection
+	connectionTimes      map[udp.DriverGUID][]time.Time
+	connectionTimesMutex sync.Mutex
+
+	// lapCSV is the CSV file that laps are written to in the current session, if LiveLapCSV is enabled.
+	lapCSV      *lapCSVWriter
+	lapCSVMutex sync.Mutex
+
+	// pendingLaps are laps waiting to be appended to the lap log in the background. lapLogWriteMutex is held while
+	// they are written, so that laps are added to the lap log in the order they were completed.
+	pendingLaps      []*LapLogEntry
+	pendingLapsMutex sync.Mutex
+	lapLogWriteMutex sync.Mutex
+
+	// webhook sends events to the WebhookURL in the background.
+	webhook *webhookSender
+
+	// eventLog appends every UDP message to the event log in the background, if LogUDPEvents is enabled.
+	eventLog *eventLogWriter
+
+	// raceStartCheckTimer checks that enough drivers are connected when a race starts. numRaceRestarts and
+	// lastRaceRestart record the restarts for too few drivers in the current event.
+	raceStartCheckTimer      *time.Timer
+	numRaceRestarts          int
+	lastRaceRestart          time.Time
+	raceStartCheckTimerMutex sync.Mutex
+
+	// nextSessionReminderTimer reminds drivers about the next session shortly before the end of the current session
+	nextSessionReminderTimer      *time.Timer
+	nextSessionReminderTimerMutex sync.Mutex
+
+	// qualifyingExtensionTimer checks for drivers on a flying lap when the qualifying clock runs out
+	qualifyingExtensionTimer      *time.Timer
+	qualifyingExtensionTimerMutex sync.Mutex
+
+	// recentCollisions are the times of collisions within the incident alert window, and lastIncidentAlert is when
+	// drivers were last warned about a collision-heavy period.
+	recentCollisions      []time.Time
+	lastIncidentAlert     time.Time
+	recentCollisionsMutex sync.Mutex
+
+	// sessionClockTimer broadcasts that the session clock has started once the wait time of a race has elapsed
+	sessionClockTimer      *time.Timer
+	sessionClockTimerMutex sync.Mutex
+
+	// sessionCountdownCfn stops the periodic broadcast of how much of the current session is left, which is sent
+	// every sessionCountdownInterval
+	sessionCountdownCfn      context.CancelFunc
+	sessionCountdownInterval time.Duration
+	sessionCountdownMutex    sync.Mutex
+
+	// sessionInfoUpdatedAt is when SessionInfo.ElapsedMilliseconds was last updated. sessionInfoMutex guards changes
+	// to both, as they are read by the session countdown in the background.
+	sessionInfoUpdatedAt time.Time
+	sessionInfoMutex     sync.RWMutex
+
+	// driverSwaps are the drivers with a driver swap in progress, keyed by the car they are swapping out of.
+	driverSwaps      map[udp.CarID]*RaceControlDriver
+	driverSwapsMutex sync.Mutex
+
+	// pendingDisconnects are the drivers in their disconnect grace period, keyed by GUID.
+	pendingDisconnects      map[udp.DriverGUID]*pendingDisconnect
+	pendingDisconnectsMutex sync.Mutex
+
+	// pitLaneArea is the pit lane area of the current track from PitLaneAreas, or nil if it is not configured.
+	pitLaneArea      *PitLaneArea
+	pitLaneAreaMutex sync.RWMutex
+
+	// sessionPenalties are accrued during a session (e.g. for driver swaps), and applied to the results file at the
+	// end of the session.
+	sessionPenaltiesMutex sync.Mutex
+	sessionPenalties      map[udp.DriverGUID]*sessionPenalty
 }
 
+// Race Control events are sent to Live Timings clients alongside the events received from the UDP plugin.
+const (
+	EventRaceControl       udp.Event = 200
+	EventSpeedTrap         udp.Event = 210
+	EventReadyCount        udp.Event = 211
+	EventPole              udp.Event = 212
+	EventSessionClock      udp.Event = 213
+	EventFirstLap          udp.Event = 214
+	EventPitLane           udp.Event = 215
+	EventSessionCountdown  udp.Event = 216
+	EventSessionFastestLap udp.Event = 217
+	EventStationary        udp.Event = 218
+	EventInvalidLap        udp.Event = 219
+)
+
 // RaceControl piggyback's on the udp.Message interface so that the entire data can be sent to newly connected clients.
 func (rc *RaceControl) Event() udp.Event {
-	return 200
+	return EventRaceControl
+}
+
+// raceControlJSON has the fields of RaceControl, without its MarshalJSON method.
+type raceControlJSON RaceControl
+
+// MarshalJSON encodes race control with copies of the drivers, whose speeds are converted from km/h to the
+// configured speed unit. The unit is read from the cached server options, which may be reloaded at any time.
+func (rc *RaceControl) MarshalJSON() ([]byte, error) {
+	return rc.marshalJSON(func(driverGUID udp.DriverGUID) udp.DriverGUID {
+		return driverGUID
+	})
+}
+
+// marshalJSON encodes race control as MarshalJSON does, replacing every DriverGUID with anonymise(DriverGUID).
+func (rc *RaceControl) marshalJSON(anonymise func(udp.DriverGUID) udp.DriverGUID) ([]byte, error) {
+	speedUnit := rc.cachedServerOptions().SpeedUnit
+
+	rc.carIDToGUIDMutex.RLock()
+	carIDToGUID := make(map[udp.CarID]udp.DriverGUID, len(rc.CarIDToGUID))
+
+	for carID, driverGUID := range rc.CarIDToGUID {
+		carIDToGUID[carID] = anonymise(driverGUID)
+	}
+	rc.carIDToGUIDMutex.RUnlock()
+
+	var standingsByCar []CarStandings
+
+	rc.standingsByCarMutex.RLock()
+	for _, standings := range rc.StandingsByCar {
+		driverGUIDs := make([]udp.DriverGUID, len(standings.DriverGUIDs))
+
+		for i, driverGUID := range standings.DriverGUIDs {
+			driverGUIDs[i] = anonymise(driverGUID)
+		}
+
+		standings.DriverGUIDs = driverGUIDs
+		standingsByCar = append(standingsByCar, standings)
+	}
+	rc.standingsByCarMutex.RUnlock()
+
+	sessionFastestLap := rc.FastestLapOfSession()
+
+	if sessionFastestLap != nil {
+		sessionFastestLap.DriverGUID = anonymise(sessionFastestLap.DriverGUID)
+	}
+
+	return json.Marshal(struct {
+		*raceControlJSON
+
+		ConnectedDrivers    *DriverMap                   `json:"ConnectedDrivers"`
+		DisconnectedDrivers *DriverMap                   `json:"DisconnectedDrivers"`
+		StandingsByCar      []CarStandings               `json:"StandingsByCar,omitempty"`
+		CarIDToGUID         map[udp.CarID]udp.DriverGUID `json:"CarIDToGUID"`
+		SessionFastestLap   *SessionFastestLap           `json:"SessionFastestLap,omitempty"`
+
+		// SpeedUnit is the unit of the drivers' top speeds and collision speeds, e.g. "km/h".
+		SpeedUnit string `json:"SpeedUnit"`
+	}{
+		raceControlJSON:     (*raceControlJSON)(rc),
+		ConnectedDrivers:    rc.ConnectedDrivers.copyForLiveTimings(speedUnit, anonymise),
+		DisconnectedDrivers: rc.DisconnectedDrivers.copyForLiveTimings(speedUnit, anonymise),
+		StandingsByCar:      standingsByCar,
+		CarIDToGUID:         carIDToGUID,
+		SessionFastestLap:   sessionFastestLap,
+		SpeedUnit:           speedUnit.Label(),
+	})
+}
+
+// refreshServerOptions reloads the cached server options from the store.
+func (rc *RaceControl) refreshServerOptions() {
+	serverOptions, err := rc.store.LoadServerOptions()
+
+	if err != nil {
+		logrus.WithError(err).Errorf("Could not load server options for race control")
+		return
+	}
+
+	driverSwapCountdownIntervals := parseDriverSwapCountdownIntervals(serverOptions.DriverSwapCountdownAt)
+
+	rc.serverOptionsMutex.Lock()
+	rc.serverOptions = serverOptions
+	rc.driverSwapCountdownIntervals = driverSwapCountdownIntervals
+	rc.serverOptionsMutex.Unlock()
+}
+
+// cachedServerOptions returns the server options as of the start of the current session, or the last ReloadConfig.
+func (rc *RaceControl) cachedServerOptions() *GlobalServerConfig {
+	rc.serverOptionsMutex.RLock()
+	defer rc.serverOptionsMutex.RUnlock()
+
+	if rc.serverOptions == nil {
+		defaultConfig := ConfigIniDefault()
+
+		return &defaultConfig.GlobalServerConfig
+	}
+
+	return rc.serverOptions
+}
+
+// driverSwapCountdown returns the seconds remaining at which the driver swap countdown is announced, and how often
+// it is repeated (0 if it is not), from the cached server options.
+func (rc *RaceControl) driverSwapCountdown() (map[time.Duration]bool, time.Duration) {
+	serverOptions := rc.cachedServerOptions()
+
+	rc.serverOptionsMutex.RLock()
+	intervals := rc.driverSwapCountdownIntervals
+	rc.serverOptionsMutex.RUnlock()
+
+	if intervals == nil {
+		intervals = parseDriverSwapCountdownIntervals(serverOptions.DriverSwapCountdownAt)
+	}
+
+	return intervals, time.Second * time.Duration(serverOptions.DriverSwapCountdownEvery)
+}
+
+// ReloadConfig refreshes the cached server options, so that changes to them take effect without waiting for the
+// next session. It is called when the server options are saved.
+//
+// Settings which are read as they are used pick up the changes straight away. These include the car update miss
+// threshold, the session info interval and the driver swap countdown (for swaps which are already in progress too).
+// Driver swap times and the movement threshold are part of the event's race config, so they are fixed when the swap
+// starts and its rules can't change part way through. Timers which have already been scheduled for the session
+// (e.g. the next session reminder and qualifying extension) are not rescheduled.
+func (rc *RaceControl) ReloadConfig() {
+	rc.refreshServerOptions()
+	rc.updateSessionInfoInterval()
 }
 
 type CollisionType string
@@ -72,9 +360,13 @@ type Collision struct {
 	ID              string         `json:"ID"`
 	Type            CollisionType  `json:"Type"`
 	Time            time.Time      `json:"Time" ts:"date"`
+	DriverGUID      udp.DriverGUID `json:"DriverGUID"`
+	DriverName      string         `json:"DriverName"`
 	OtherDriverGUID udp.DriverGUID `json:"OtherDriverGUID"`
 	OtherDriverName string         `json:"OtherDriverName"`
 	Speed           float64        `json:"Speed"`
+	SpeedUnit       string         `json:"SpeedUnit"`
+	WorldPos        udp.Vec        `json:"WorldPos"`
 }
 
 func NewRaceControl(broadcaster Broadcaster, trackDataGateway TrackDataGateway, process ServerProcess, store Store, penaltiesManager *PenaltiesManager) *RaceControl {
@@ -83,22 +375,96 @@ func NewRaceControl(broadcaster Broadcaster, trackDataGateway TrackDataGateway,
 		trackDataGateway:     trackDataGateway,
 		process:              process,
 		store:                store,
-		driverSwapTimers:     make(map[int]*time.Timer),
+		driverSwaps:          make(map[udp.CarID]*RaceControlDriver),
+		pendingDisconnects:   make(map[udp.DriverGUID]*pendingDisconnect),
 		penaltiesManager:     penaltiesManager,
 		carUpdaters:          make(map[udp.CarID]chan udp.CarUpdate),
 		serverProcessStopped: make(chan struct{}),
+		stopped:              make(chan struct{}),
+		eventLog:             newEventLogWriter(store),
+		solWarningGUIDs:      make(map[udp.DriverGUID]bool),
+		readyGUIDs:           make(map[udp.DriverGUID]bool),
+		firstLapGUIDs:        make(map[udp.DriverGUID]bool),
+		connectionTimes:      make(map[udp.DriverGUID][]time.Time),
+		unknownCarIDs:        make(map[udp.CarID]int),
+		lastResync:           make(map[udp.CarID]time.Time),
+
+		sessionCountdownInterval: defaultSessionCountdownInterval,
+		sessionInfoMaxBackoff:    defaultSessionInfoRequestMaxBackoff,
 	}
 
+	rc.webhook = newWebhookSender(rc.stopped, rc.goBackground)
+
 	process.NotifyDone(rc.serverProcessStopped)
 
 	rc.clearAllDrivers()
+	rc.refreshServerOptions()
 
-	go panicCapture(rc.watchForTimedOutDrivers)
+	rc.sessionInfoIntervalChanged = make(chan struct{}, 1)
+	rc.updateSessionInfoInterval()
+
+	rc.goBackground(rc.watchForTimedOutDrivers)
+	rc.goBackground(rc.watchForStaleCarIDs)
 
 	return rc
 }
 
+// goBackground runs fn in a goroutine which Stop waits for.
+func (rc *RaceControl) goBackground(fn func()) {
+	rc.background.Add(1)
+
+	go func() {
+		defer rc.background.Done()
+
+		panicCapture(fn)
+	}()
+}
+
+// Stop ends the background goroutines of a RaceControl which is no longer needed, e.g. one which has replayed the
+// event log, waiting for those which use the store to finish, and then stops its timers. The live RaceControl is
+// never stopped.
+func (rc *RaceControl) Stop() {
+	rc.stopOnce.Do(func() {
+		close(rc.stopped)
+	})
+
+	rc.background.Wait()
+
+	for _, timer := range []struct {
+		timer **time.Timer
+		mutex *sync.Mutex
+	}{
+		{&rc.raceStartCheckTimer, &rc.raceStartCheckTimerMutex},
+		{&rc.nextSessionReminderTimer, &rc.nextSessionReminderTimerMutex},
+		{&rc.qualifyingExtensionTimer, &rc.qualifyingExtensionTimerMutex},
+		{&rc.sessionClockTimer, &rc.sessionClockTimerMutex},
+	} {
+		timer.mutex.Lock()
+
+		if *timer.timer != nil {
+			(*timer.timer).Stop()
+			*timer.timer = nil
+		}
+
+		timer.mutex.Unlock()
+	}
+
+	rc.pendingDisconnectsMutex.Lock()
+
+	for driverGUID, pending := range rc.pendingDisconnects {
+		pending.timer.Stop()
+		delete(rc.pendingDisconnects, driverGUID)
+	}
+
+	rc.pendingDisconnectsMutex.Unlock()
+
+	rc.stopSessionCountdown()
+	rc.cancelDriverSwaps()
+}
+
 func (rc *RaceControl) UDPCallback(message udp.Message) {
+	rc.logUDPMessage(message)
+
 	var err error
 
 	sendUpdatedRaceControlStatus := false
@@ -120,6 +486,10 @@ func (rc *RaceControl) UDPCallback(message udp.Message) {
 		sendUpdatedRaceControlStatus = true
 	case udp.CarUpdate:
 		err = rc.OnCarUpdate(m)
+	case udp.CarInfo:
+		err = rc.OnCarInfo(m)
+
+		sendUpdatedRaceControlStatus = true
 	case udp.SessionCarInfo:
 		if m.Event() == udp.EventNewConnection {
 			err = rc.OnClientConnect(m)
@@ -158,13 +528,20 @@ func (rc *RaceControl) UDPCallback(message udp.Message) {
 
 		m.Time = time.Now()
 
-		err = rc.OnChatMessage(m)
+		command := strings.ToLower(strings.TrimSpace(m.Message))
+
+		if handler, ok := chatCommands[command]; ok && driver != nil {
+			err = handler(rc, driver)
+		} else {
+			err = rc.OnChatMessage(m)
+		}
 	default:
 		return
 	}
 
 	if err != nil {
 		logrus.WithError(err).Errorf("Unable to handle event: %d", message.Event())
+		rc.onUnknownDriver(err)
 		return
 	}
 
@@ -172,6 +549,16 @@ func (rc *RaceControl) UDPCallback(message udp.Message) {
 		// update the current refresh rate
 		rc.CurrentRealtimePosInterval = udp.CurrentRealtimePosIntervalMs
 
+		var standingsByCar []CarStandings
+
+		if rc.SessionInfo.Type == udp.SessionTypePractice && rc.cachedServerOptions().GroupPracticeByCar {
+			standingsByCar = rc.GroupStandingsByCar()
+		}
+
+		rc.standingsByCarMutex.Lock()
+		rc.StandingsByCar = standingsByCar
+		rc.standingsByCarMutex.Unlock()
+
 		lastUpdateMessage, err := rc.broadcaster.Send(rc)
 
 		if err != nil {
@@ -187,6 +574,42 @@ func (rc *RaceControl) UDPCallback(message udp.Message) {
 
 var driverTimeout = time.Minute * 5
 
+// driverTimeoutFor is how long a driver can go without a car update before they are disconnected. If
+// CarUpdateMissThreshold is set, this is the time taken to miss that many car updates at the current real time
+// position interval, otherwise it is driverTimeout.
+func (rc *RaceControl) driverTimeoutFor(intervalMs int) time.Duration {
+	threshold := rc.cachedServerOptions().CarUpdateMissThreshold
+
+	if threshold <= 0 || intervalMs <= 0 {
+		return driverTimeout
+	}
+
+	return time.Duration(threshold) * time.Duration(intervalMs) * time.Millisecond
+}
+
+// timedOutDrivers finds the connected drivers who have not been seen for longer than the timeout.
+func (rc *RaceControl) timedOutDrivers(timeout time.Duration) []*RaceControlDriver {
+	var driversToDisconnect []*RaceControlDriver
+
+	_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
+
+		if driver.Stale {
+			// the driver has already disconnected, and will be moved at the end of their grace period.
+			return nil
+		}
+
+		if !driver.LastSeen.IsZero() && time.Since(driver.LastSeen) > timeout || driver.LastSeen.IsZero() && time.Since(driver.ConnectedTime) > timeout {
+			driversToDisconnect = append(driversToDisconnect, driver)
+		}
+
+		return nil
+	})
+
+	return driversToDisconnect
+}
+
 func (rc *RaceControl) watchForTimedOutDrivers() {
 	if udp.RealtimePosIntervalMs <= 0 {
 		// with no real time pos interval, we have no driver positions, so no last update time.
@@ -194,23 +617,21 @@ func (rc *RaceControl) watchForTimedOutDrivers() {
 	}
 
 	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
 
-	for range ticker.C {
-		var driversToDisconnect []*RaceControlDriver
-
-		_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
-			driver.mutex.Lock()
-			defer driver.mutex.Unlock()
-
-			if !driver.LastSeen.IsZero() && time.Since(driver.LastSeen) > driverTimeout || driver.LastSeen.IsZero() && time.Since(driver.ConnectedTime) > driverTimeout {
-				driversToDisconnect = append(driversToDisconnect, driver)
-			}
+	for {
+		select {
+		case <-rc.stopped:
+			return
+		case <-ticker.C:
+		}
 
-			return nil
-		})
+		// the timeout is worked out on each check, so that changes to the server options take effect immediately.
+		timeout := rc.driverTimeoutFor(udp.RealtimePosIntervalMs)
+		driversToDisconnect := rc.timedOutDrivers(timeout)
 
 		for _, driver := range driversToDisconnect {
-			logrus.Debugf("Driver: %s (%s) has not been seen in 5 minutes, disconnecting", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID)
+			logrus.Debugf("Driver: %s (%s) has not been seen in %s, disconnecting", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, timeout)
 			err := rc.disconnectDriver(driver)
 
 			if err != nil {
@@ -229,15 +650,91 @@ func (rc *RaceControl) watchForTimedOutDrivers() {
 	}
 }
 
+// staleCarIDCheckInterval is how often CarIDToGUID is checked for entries which no longer match a connected driver.
+var staleCarIDCheckInterval = time.Minute
+
+func (rc *RaceControl) watchForStaleCarIDs() {
+	ticker := time.NewTicker(staleCarIDCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rc.stopped:
+			return
+		case <-ticker.C:
+			rc.pruneStaleCarIDs()
+		}
+	}
+}
+
+// pruneStaleCarIDs removes CarIDToGUID entries whose driver is no longer connected in that car, which can happen
+// if a disconnect message is missed. An entry must be stale at two consecutive checks before it is removed, so that
+// drivers who are part way through connecting are not affected. The removed CarIDs are returned.
+func (rc *RaceControl) pruneStaleCarIDs() []udp.CarID {
+	rc.carIDToGUIDMutex.RLock()
+	carIDToGUID := make(map[udp.CarID]udp.DriverGUID, len(rc.CarIDToGUID))
+
+	for carID, driverGUID := range rc.CarIDToGUID {
+		carIDToGUID[carID] = driverGUID
+	}
+	rc.carIDToGUIDMutex.RUnlock()
+
+	// drivers are checked without holding carIDToGUIDMutex, as driver mutexes may be held while looking up CarIDs.
+	staleCarIDs := make(map[udp.CarID]udp.DriverGUID)
+
+	for carID, driverGUID := range carIDToGUID {
+		driver, ok := rc.ConnectedDrivers.Get(driverGUID)
+
+		if ok {
+			driver.mutex.Lock()
+			ok = driver.CarInfo.CarID == carID
+			driver.mutex.Unlock()
+		}
+
+		if !ok {
+			staleCarIDs[carID] = driverGUID
+		}
+	}
+
+	rc.carIDToGUIDMutex.Lock()
+	defer rc.carIDToGUIDMutex.Unlock()
+
+	var pruned []udp.CarID
+
+	for carID, driverGUID := range staleCarIDs {
+		previousGUID, wasStale := rc.staleCarIDs[carID]
+
+		if !wasStale || previousGUID != driverGUID || rc.CarIDToGUID[carID] != driverGUID {
+			continue
+		}
+
+		logrus.Debugf("Removing stale CarID: %d for driver: %s", carID, driverGUID)
+
+		delete(rc.CarIDToGUID, carID)
+		delete(staleCarIDs, carID)
+		pruned = append(pruned, carID)
+	}
+
+	rc.staleCarIDs = staleCarIDs
+
+	return pruned
+}
+
 // OnVersion occurs when the Assetto Corsa Server starts up for the first time.
 func (rc *RaceControl) OnVersion(version udp.Version) error {
-	go panicCapture(rc.requestSessionInfo)
+	rc.goBackground(rc.requestSessionInfo)
 
 	// clear chat messages on new server start
 	rc.ChatMessagesMutex.Lock()
 	rc.ChatMessages = []udp.Chat{}
 	rc.ChatMessagesMutex.Unlock()
 
+	// each server start is a new event, so the race can be restarted for too few drivers again
+	rc.raceStartCheckTimerMutex.Lock()
+	rc.numRaceRestarts = 0
+	rc.lastRaceRestart = time.Time{}
+	rc.raceStartCheckTimerMutex.Unlock()
+
 	_, err := rc.broadcaster.Send(version)
 
 	return err
@@ -246,16 +743,18 @@ func (rc *RaceControl) OnVersion(version udp.Version) error {
 // OnCarUpdate occurs every udp.RealTimePosInterval and returns car position, speed, etc.
 // drivers top speeds are recorded per lap, as well as their last seen updated.
 func (rc *RaceControl) OnCarUpdate(update udp.CarUpdate) error {
+	if rc.synchronousCarUpdates {
+		rc.processCarUpdate(update)
+
+		return nil
+	}
+
 	if ch, ok := rc.carUpdaters[update.CarID]; !ok || ch == nil {
 		rc.carUpdaters[update.CarID] = make(chan udp.CarUpdate, 1000)
 
 		go panicCapture(func() {
 			for update := range rc.carUpdaters[update.CarID] {
-				err := rc.handleCarUpdate(update)
-
-				if err != nil {
-					logrus.WithError(err).Error("Could not handle car update")
-				}
+				rc.processCarUpdate(update)
 			}
 		})
 	}
@@ -265,6 +764,14 @@ func (rc *RaceControl) OnCarUpdate(update udp.CarUpdate) error {
 	return nil
 }
 
+// processCarUpdate handles a car update, logging any error.
+func (rc *RaceControl) processCarUpdate(update udp.CarUpdate) {
+	if err := rc.handleCarUpdate(update); err != nil {
+		logrus.WithError(err).Error("Could not handle car update")
+		rc.onUnknownDriver(err)
+	}
+}
+
 func (rc *RaceControl) handleCarUpdate(update udp.CarUpdate) error {
 	driver, err := rc.findConnectedDriverByCarID(update.CarID)
 
@@ -272,806 +779,4171 @@ func (rc *RaceControl) handleCarUpdate(update udp.CarUpdate) error {
 		return err
 	}
 
+	passedSpeedTrap := false
+
+	// the speed trap leaderboard looks at every driver, so it must be built once this driver's mutex is released.
+	defer func() {
+		if passedSpeedTrap {
+			if _, err := rc.broadcaster.Send(rc.SpeedTrapLeaderboard()); err != nil {
+				logrus.WithError(err).Error("Could not broadcast speed trap leaderboard")
+			}
+		}
+	}()
+
 	driver.mutex.Lock()
 	defer driver.mutex.Unlock()
 
-	speed := metersPerSecondToKilometersPerHour(
-		math.Sqrt(math.Pow(float64(update.Velocity.X), 2) + math.Pow(float64(update.Velocity.Z), 2)),
-	)
+	mps := math.Sqrt(math.Pow(float64(update.Velocity.X), 2) + math.Pow(float64(update.Velocity.Z), 2))
+	speed := metersPerSecondToKilometersPerHour(mps)
 
 	if speed > driver.CurrentCar().TopSpeedThisLap {
 		driver.CurrentCar().TopSpeedThisLap = speed
 	}
 
-	driver.LastSeen = time.Now()
-	driver.LastPos = update.Pos
-
-	_, err = rc.broadcaster.Send(update)
+	if speedTrap := float32(rc.cachedServerOptions().SpeedTrapSplinePosition); speedTrap > 0 && driver.hasSplinePos && passesSplinePosition(driver.lastSplinePos, update.NormalisedSplinePos, speedTrap) {
+		passedSpeedTrap = true
 
-	return err
-}
+		currentCar := driver.CurrentCar()
+		currentCar.SpeedTrapLast = speed
 
-var emptyCarInfoMutex = sync.Mutex{}
+		if speed > currentCar.SpeedTrapBest {
+			currentCar.SpeedTrapBest = speed
+		}
+	}
 
-// OnNewSession occurs every new session. If the session is the first in an event and it is not a looped practice,
-// then all driver information is cleared.
-func (rc *RaceControl) OnNewSession(sessionInfo udp.SessionInfo) error {
-	oldSessionInfo := rc.SessionInfo
-	rc.SessionInfo = sessionInfo
-	rc.SessionStartTime = time.Now()
+	driver.lastSplinePos = update.NormalisedSplinePos
+	driver.hasSplinePos = true
 
-	emptyCarInfo := true
+	now := time.Now()
+	previousPos := driver.LastPos
 
-	rc.driverSwapPenaltiesMutex.Lock()
-	rc.driverSwapPenalties = make(map[udp.DriverGUID]*driverSwapPenalty)
-	rc.driverSwapPenaltiesMutex.Unlock()
+	driver.LastSeen = now
+	driver.LastPos = update.Pos
 
-	if (rc.ConnectedDrivers.Len() > 0 || rc.DisconnectedDrivers.Len() > 0) && sessionInfo.Type == udp.SessionTypePractice {
-		if oldSessionInfo.Type == sessionInfo.Type && oldSessionInfo.Track == sessionInfo.Track && oldSessionInfo.TrackConfig == sessionInfo.TrackConfig && oldSessionInfo.Name == sessionInfo.Name {
-			// this is a looped event, keep the cars
-			emptyCarInfo = false
-		}
+	if rc.TrackMapData != (TrackMapData{}) {
+		driver.TrackPosition = float64(update.NormalisedSplinePos)
+	} else {
+		// without map data there is nothing to place the driver on
+		driver.TrackPosition = -1
 	}
 
-	if emptyCarInfo {
-		_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
-			emptyCarInfoMutex.Lock()
-			defer emptyCarInfoMutex.Unlock()
-
-			*driver = *NewRaceControlDriver(driver.CarInfo)
+	if pitLaneArea := rc.currentPitLaneArea(); pitLaneArea != nil {
+		if inPits := pitLaneArea.Contains(update.Pos); inPits != driver.InPits {
+			driver.InPits = inPits
 
-			return nil
-		})
+			if !inPits {
+				driver.onPitLaneExit()
+			}
 
-		// all disconnected drivers are removed when car info is emptied, otherwise we are just showing empty entries in
-		// the disconnected drivers table, which is pointless.
-		rc.DisconnectedDrivers = NewDriverMap(DisconnectedDrivers, rc.SortDrivers)
+			if _, err := rc.broadcaster.Send(PitLane{DriverGUID: driver.CarInfo.DriverGUID, CarID: update.CarID, InPits: inPits}); err != nil {
+				logrus.WithError(err).Error("Could not broadcast pit lane change")
+			}
+		}
 	}
 
-	// clear out last lap completed time each new session
-	_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
-		driver.mutex.Lock()
-		defer driver.mutex.Unlock()
-
-		driver.CurrentCar().LastLapCompletedTime = time.Now()
+	rc.checkStationary(driver, previousPos, update.Pos, metersPerSecondToKilometersPerHour(mps), now)
 
+	if !shouldBroadcastCarUpdate(driver.lastCarUpdateBroadcast, now, time.Duration(rc.cachedServerOptions().CarUpdateBroadcastMs)*time.Millisecond) {
 		return nil
-	})
+	}
 
-	var err error
+	driver.lastCarUpdateBroadcast = now
 
-	trackInfo, err := rc.trackDataGateway.TrackInfo(sessionInfo.Track, sessionInfo.TrackConfig)
+	_, err = rc.broadcaster.Send(update)
 
-	if err != nil {
-		return err
+	return err
+}
+
+// shouldBroadcastCarUpdate determines whether a car update should be sent to Live Timings, given the time that the
+// car's last update was sent. Car updates are always tracked internally, but their broadcast is limited to one
+// per interval.
+func shouldBroadcastCarUpdate(lastBroadcast, now time.Time, interval time.Duration) bool {
+	if interval <= 0 || lastBroadcast.IsZero() {
+		return true
 	}
 
-	rc.TrackInfo = *trackInfo
+	return now.Sub(lastBroadcast) >= interval
+}
 
-	trackMapData, err := rc.trackDataGateway.TrackMap(sessionInfo.Track, sessionInfo.TrackConfig)
+// trackGap estimates the time it will take a car at the spline position behind to reach the spline position ahead,
+// based on the car's lap time.
+func trackGap(ahead, behind float32, lapTime time.Duration) time.Duration {
+	distance := ahead - behind
 
-	if err != nil {
-		logrus.WithError(err).Errorf("Could not load track map data")
-	} else {
-		rc.TrackMapData = *trackMapData
+	if distance < 0 {
+		distance++
 	}
 
-	logrus.Debugf("New session detected: %s at %s (%s) [emptyCarInfo: %t]", sessionInfo.Type.String(), sessionInfo.Track, sessionInfo.TrackConfig, emptyCarInfo)
+	return time.Duration(float64(lapTime) * float64(distance)).Round(time.Millisecond)
+}
 
-	// look for live timings stored previously
-	persistedInfo, err := rc.store.LoadLiveTimingsData()
+// passesSplinePosition determines whether a car moving from the spline position previous to current has passed
+// the spline position point. Spline positions wrap around from 1 to 0 at the start/finish line.
+func passesSplinePosition(previous, current, point float32) bool {
+	if current >= previous {
+		return previous < point && point <= current
+	}
 
-	if err == nil && persistedInfo != nil {
-		if persistedInfo.SessionType == rc.SessionInfo.Type &&
-			persistedInfo.Track == rc.SessionInfo.Track &&
-			persistedInfo.TrackLayout == rc.SessionInfo.TrackConfig &&
-			persistedInfo.SessionName == rc.SessionInfo.Name {
+	if previous-current > 0.5 {
+		// the car has crossed the start/finish line
+		return point > previous || point <= current
+	}
 
-			for guid, driver := range persistedInfo.Drivers {
-				_, driverPresentInDisconnectedList := rc.DisconnectedDrivers.Get(guid)
-				_, driverPresentInConnectedList := rc.ConnectedDrivers.Get(guid)
+	// the car is going backwards
+	return false
+}
 
-				if !driverPresentInConnectedList && !driverPresentInDisconnectedList {
-					rc.DisconnectedDrivers.Add(guid, driver)
-				}
-			}
+// FirstLap is sent the first time each driver completes a lap in a session.
+type FirstLap struct {
+	DriverGUID udp.DriverGUID `json:"DriverGUID"`
+	DriverName string         `json:"DriverName"`
+	CarModel   string         `json:"CarModel"`
+	CarName    string         `json:"CarName"`
+	LapTime    time.Duration  `json:"LapTime"`
+}
 
-			logrus.Infof("Loaded previous Live Timings data for %s (%s), num drivers: %d", persistedInfo.Track, persistedInfo.TrackLayout, len(persistedInfo.Drivers))
-		}
-	} else {
-		logrus.WithError(err).Debugf("Could not load persisted live timings practice data")
-	}
+func (FirstLap) Event() udp.Event {
+	return EventFirstLap
+}
 
-	_, err = rc.broadcaster.Send(sessionInfo)
+// InvalidLap is sent when a driver completes a lap with cuts, which does not count towards their best lap.
+type InvalidLap struct {
+	DriverGUID             udp.DriverGUID `json:"DriverGUID"`
+	DriverName             string         `json:"DriverName"`
+	CarID                  udp.CarID      `json:"CarID"`
+	CarModel               string         `json:"CarModel"`
+	LapTime                time.Duration  `json:"LapTime"`
+	Cuts                   int            `json:"Cuts"`
+	ConsecutiveInvalidLaps int            `json:"ConsecutiveInvalidLaps"`
+}
 
-	return err
+func (InvalidLap) Event() udp.Event {
+	return EventInvalidLap
 }
 
-// clearAllDrivers removes all known information about connected and disconnected drivers from RaceControl
-func (rc *RaceControl) clearAllDrivers() {
-	rc.ConnectedDrivers = NewDriverMap(ConnectedDrivers, rc.SortDrivers)
-	rc.DisconnectedDrivers = NewDriverMap(DisconnectedDrivers, rc.SortDrivers)
-	rc.carIDToGUIDMutex.Lock()
-	rc.CarIDToGUID = make(map[udp.CarID]udp.DriverGUID)
-	rc.carIDToGUIDMutex.Unlock()
+// PitLane is sent when a driver enters or leaves the pit lane area of the track.
+type PitLane struct {
+	DriverGUID udp.DriverGUID `json:"DriverGUID"`
+	CarID      udp.CarID      `json:"CarID"`
+	InPits     bool           `json:"InPits"`
 }
 
-var sessionInfoRequestInterval = time.Second * 30
-
-// requestSessionInfo sends a request every sessionInfoRequestInterval to get information about temps, etc in the session.
-func (rc *RaceControl) requestSessionInfo() {
-	sessionInfoTicker := time.NewTicker(sessionInfoRequestInterval)
-
-	for {
-		select {
-		case <-sessionInfoTicker.C:
-			err := rc.process.SendUDPMessage(udp.GetSessionInfo{})
+func (PitLane) Event() udp.Event {
+	return EventPitLane
+}
 
-			if err == ErrNoOpenUDPConnection {
-				logrus.WithError(err).Warnf("Couldn't send session info udp request. Breaking loop.")
-				sessionInfoTicker.Stop()
-				return
-			} else if err != nil {
-				logrus.WithError(err).Errorf("Couldn't send session info udp request")
-			}
+// Stationary is sent when a driver's car stops moving on track for StationaryCarTime, and when it moves again.
+type Stationary struct {
+	DriverGUID udp.DriverGUID `json:"DriverGUID"`
+	CarID      udp.CarID      `json:"CarID"`
+	Stationary bool           `json:"Stationary"`
+}
 
-		case <-rc.serverProcessStopped:
-			logrus.Debugf("Assetto Process completed. Disconnecting all connected drivers. Session done.")
-			sessionInfoTicker.Stop()
+func (Stationary) Event() udp.Event {
+	return EventStationary
+}
 
-			var drivers []*RaceControlDriver
+const (
+	// stationaryMaxSpeed (in km/h) and stationaryMaxMovement (in metres) are the most that a car can be moving
+	// between car updates while still being considered stationary.
+	stationaryMaxSpeed    = 1.0
+	stationaryMaxMovement = 0.5
+)
 
-			rc.persistTimingData()
+// checkStationary flags a driver as Stationary once their car has not moved on track for StationaryCarTime, and
+// clears the flag once it moves again. Changes are broadcast. The caller must hold the driver's lock.
+func (rc *RaceControl) checkStationary(driver *RaceControlDriver, previousPos, pos udp.Vec, speed float64, now time.Time) {
+	stationaryTime := time.Duration(rc.cachedServerOptions().StationaryCarTime) * time.Second
+	movement := math.Hypot(float64(pos.X-previousPos.X), float64(pos.Z-previousPos.Z))
 
-			// the server has just stopped. send disconnect messages for all connected cars.
-			_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
-				// Each takes a read lock, so we cannot call disconnectDriver (which takes a write lock) from inside it.
-				// we must instead append them to a slice and disconnect them outside the Each call.
-				drivers = append(drivers, driver)
+	if stationaryTime <= 0 || driver.InPits || speed > stationaryMaxSpeed || movement > stationaryMaxMovement {
+		driver.stationarySince = time.Time{}
 
-				return nil
-			})
+		if driver.Stationary {
+			driver.Stationary = false
+			rc.broadcastStationary(driver)
+		}
 
-			for _, driver := range drivers {
-				// disconnect the driver
-				err := rc.disconnectDriver(driver)
+		return
+	}
 
-				if err != nil {
-					logrus.WithError(err).Errorf("Could not disconnect driver: %s (%s)", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID)
-					continue
-				}
-			}
+	if driver.stationarySince.IsZero() {
+		driver.stationarySince = now
+	}
 
-			if _, err := rc.broadcaster.Send(rc); err != nil {
-				logrus.WithError(err).Errorf("Couldn't broadcast race control")
-			}
+	if !driver.Stationary && now.Sub(driver.stationarySince) >= stationaryTime {
+		logrus.Infof("Driver: %s (%s) has been stationary on track for %s", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, stationaryTime)
 
-			return
-		}
+		driver.Stationary = true
+		rc.broadcastStationary(driver)
 	}
 }
 
-func (rc *RaceControl) disconnectDriver(driver *RaceControlDriver) error {
-	driver.mutex.Lock()
-	carInfo := driver.CarInfo
-	carInfo.EventType = udp.EventConnectionClosed
-	driver.mutex.Unlock()
-
-	return rc.OnClientDisconnect(carInfo)
+func (rc *RaceControl) broadcastStationary(driver *RaceControlDriver) {
+	if _, err := rc.broadcaster.Send(Stationary{DriverGUID: driver.CarInfo.DriverGUID, CarID: driver.CarInfo.CarID, Stationary: driver.Stationary}); err != nil {
+		logrus.WithError(err).Error("Could not broadcast stationary car")
+	}
 }
 
-// OnSessionUpdate is called every sessionRequestInterval.
-func (rc *RaceControl) OnSessionUpdate(sessionInfo udp.SessionInfo) (bool, error) {
-	oldSessionInfo := rc.SessionInfo
+// PitLaneArea is a box around the pit lane of a track, in world coordinates. Heights are ignored.
+type PitLaneArea struct {
+	MinX, MinZ float64
+	MaxX, MaxZ float64
+}
 
-	// we can't just copy over the session information, we must copy individual
-	// parts of it, as the session type is incorrect.
-	rc.SessionInfo.AmbientTemp = sessionInfo.AmbientTemp
-	rc.SessionInfo.RoadTemp = sessionInfo.RoadTemp
-	rc.SessionInfo.WeatherGraphics = sessionInfo.WeatherGraphics
-	rc.SessionInfo.ElapsedMilliseconds = sessionInfo.ElapsedMilliseconds
+// Contains reports whether a position is within the pit lane area.
+func (a PitLaneArea) Contains(pos udp.Vec) bool {
+	x, z := float64(pos.X), float64(pos.Z)
 
-	sessionHasChanged := oldSessionInfo.AmbientTemp != rc.SessionInfo.AmbientTemp || oldSessionInfo.RoadTemp != rc.SessionInfo.RoadTemp || oldSessionInfo.WeatherGraphics != rc.SessionInfo.WeatherGraphics
+	return x >= a.MinX && x <= a.MaxX && z >= a.MinZ && z <= a.MaxZ
+}
 
-	return sessionHasChanged, nil
+// pitLaneAreaKey is the key of a track and layout in the map returned by parsePitLaneAreas.
+func pitLaneAreaKey(track, layout string) string {
+	return track + "," + layout
 }
 
-// OnEndSession is called at the end of every session.
-func (rc *RaceControl) OnEndSession(sessionFile udp.EndSession) error {
-	filename := filepath.Base(string(sessionFile))
-	logrus.Infof("End Session, file outputted at: %s", filename)
+// parsePitLaneAreas reads the pit lane areas with one track per line, in the format
+// track,layout,min_x,min_z,max_x,max_z. Invalid lines are ignored.
+func parsePitLaneAreas(areas string) map[string]PitLaneArea {
+	out := make(map[string]PitLaneArea)
 
-	config := rc.process.Event().GetRaceConfig()
+	for _, line := range strings.Split(areas, "\n") {
+		line = strings.TrimSpace(line)
 
-	if config.DriverSwapEnabled == 1 {
-		_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
-			if driver.driverSwapCfn != nil {
-				logrus.Infof("Cancelling active driver swap for driver: %s. Reason: Session ended", driver.CarInfo.DriverGUID)
-				driver.driverSwapCfn()
-			}
+		if line == "" {
+			continue
+		}
 
-			return nil
-		})
+		parts := strings.Split(line, ",")
 
-		_ = rc.DisconnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
-			if driver.driverSwapCfn != nil {
-				logrus.Infof("Cancelling active driver swap for driver: %s. Reason: Session ended", driver.CarInfo.DriverGUID)
-				driver.driverSwapCfn()
-			}
+		if len(parts) != 6 {
+			logrus.Warnf("Ignoring invalid pit lane area: %q", line)
+			continue
+		}
 
-			return nil
-		})
+		var coords [4]float64
 
-		rc.driverSwapPenaltiesMutex.Lock()
-		defer rc.driverSwapPenaltiesMutex.Unlock()
+		valid := true
 
-		if config.DriverSwapMinimumNumberOfSwaps > 0 {
-			results, err := LoadResult(filename, LoadResultWithoutPluginFire)
+		for i, part := range parts[2:] {
+			coord, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
 
 			if err != nil {
-				logrus.WithError(err).Errorf("Could not load results file to check min driver swaps")
-			} else {
-				for _, result := range results.Result {
-					numSwaps := results.NumberOfDriverSwaps(result.CarID)
-
-					if numSwaps < config.DriverSwapMinimumNumberOfSwaps {
-						guid := udp.DriverGUID(result.DriverGUID)
-						penaltyTime := time.Duration((config.DriverSwapMinimumNumberOfSwaps-numSwaps)*config.DriverSwapNotEnoughSwapsPenalty) * time.Second
-
-						if _, ok := rc.driverSwapPenalties[guid]; ok {
-							rc.driverSwapPenalties[guid].penalty += penaltyTime
-						} else {
-							rc.driverSwapPenalties[guid] = &driverSwapPenalty{
-								carModel: result.CarModel,
-								penalty:  penaltyTime,
-							}
-						}
-					}
-				}
+				valid = false
+				break
 			}
+
+			coords[i] = coord
 		}
 
-		for guid, penalty := range rc.driverSwapPenalties {
-			err := rc.penaltiesManager.applyPenalty(filename, string(guid), penalty.carModel, penalty.penalty.Seconds(), true)
+		if !valid {
+			logrus.Warnf("Ignoring invalid pit lane area: %q", line)
+			continue
+		}
 
-			if err != nil {
-				logrus.WithError(err).Errorf("could not apply driver swap penalty of %s to driver %s", penalty.penalty.String(), guid)
-				continue
-			}
+		out[pitLaneAreaKey(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))] = PitLaneArea{
+			MinX: math.Min(coords[0], coords[2]),
+			MinZ: math.Min(coords[1], coords[3]),
+			MaxX: math.Max(coords[0], coords[2]),
+			MaxZ: math.Max(coords[1], coords[3]),
 		}
 	}
 
-	if rc.currentTimeAttackEvent != nil && Premium() {
-		filename := filepath.Base(string(sessionFile))
-
-		err := rc.addFileToTimeAttackEvent(filename)
+	return out
+}
 
-		if err != nil {
-			return err
-		}
+// loadPitLaneArea finds the pit lane area of the session's track in PitLaneAreas.
+func (rc *RaceControl) loadPitLaneArea(sessionInfo udp.SessionInfo) {
+	var pitLaneArea *PitLaneArea
 
-		logrus.Infof("Time Attack Event (%s) Finished, results files have been combined and saved as %s", rc.currentTimeAttackEvent.EventName(), filename)
+	if area, ok := parsePitLaneAreas(rc.cachedServerOptions().PitLaneAreas)[pitLaneAreaKey(sessionInfo.Track, sessionInfo.TrackConfig)]; ok {
+		pitLaneArea = &area
 	}
 
-	return nil
+	rc.pitLaneAreaMutex.Lock()
+	rc.pitLaneArea = pitLaneArea
+	rc.pitLaneAreaMutex.Unlock()
 }
 
-const timeAttackSuffix = "-time-attack"
+func (rc *RaceControl) currentPitLaneArea() *PitLaneArea {
+	rc.pitLaneAreaMutex.RLock()
+	defer rc.pitLaneAreaMutex.RUnlock()
 
-func (rc *RaceControl) addFileToTimeAttackEvent(file string) error {
-	logrus.Info("Time Attack event completed, combining with any previous results")
+	return rc.pitLaneArea
+}
 
-	results, err := LoadResult(file)
+// SpeedTrapEntry is a driver's best speed through the speed trap in a given car.
+type SpeedTrapEntry struct {
+	DriverGUID udp.DriverGUID `json:"DriverGUID"`
+	DriverName string         `json:"DriverName"`
+	CarModel   string         `json:"CarModel"`
+	CarName    string         `json:"CarName"`
+	Speed      float64        `json:"Speed"`
+	SpeedUnit  string         `json:"SpeedUnit"`
+}
 
-	if err != nil {
-		logrus.WithError(err).Errorf("Could not read session results: %s", file)
-		return err
-	}
+// SpeedTrapLeaderboard is a list of the fastest speeds through the speed trap, fastest first.
+type SpeedTrapLeaderboard []SpeedTrapEntry
 
-	var resultsArray []*SessionResults
+func (SpeedTrapLeaderboard) Event() udp.Event {
+	return EventSpeedTrap
+}
 
-	resultsArray = append(resultsArray, results)
+// SpeedTrapLeaderboard builds a leaderboard of each driver's best speed through the speed trap, for each car they
+// have driven in this session.
+func (rc *RaceControl) SpeedTrapLeaderboard() SpeedTrapLeaderboard {
+	leaderboard := SpeedTrapLeaderboard{}
+	speedUnit := rc.cachedServerOptions().SpeedUnit
 
-	if rc.currentTimeAttackEvent.TimeAttackCombinedResultFile != "" {
-		result, err := LoadResult(rc.currentTimeAttackEvent.TimeAttackCombinedResultFile + ".json")
+	addEntries := func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
 
-		if err != nil {
-			logrus.WithError(err).Errorf("Could not read session results: %s", file)
-			return err
+		for model, car := range driver.Cars {
+			if car.SpeedTrapBest <= 0 {
+				continue
+			}
+
+			leaderboard = append(leaderboard, SpeedTrapEntry{
+				DriverGUID: driver.CarInfo.DriverGUID,
+				DriverName: driver.CarInfo.DriverName,
+				CarModel:   model,
+				CarName:    car.CarName,
+				Speed:      speedUnit.FromKilometersPerHour(car.SpeedTrapBest),
+				SpeedUnit:  speedUnit.Label(),
+			})
 		}
 
-		resultsArray = append(resultsArray, result)
+		return nil
 	}
 
-	results = combineResults(resultsArray)
+	_ = rc.ConnectedDrivers.Each(addEntries)
+	_ = rc.DisconnectedDrivers.Each(addEntries)
 
-	results.FallBackSort()
-	results.ClearKickedGUIDs()
-	results.NormaliseCarIDs()
+	sort.SliceStable(leaderboard, func(i, j int) bool {
+		return leaderboard[i].Speed > leaderboard[j].Speed
+	})
 
-	if !strings.HasSuffix(results.SessionFile, timeAttackSuffix) {
-		results.SessionFile = results.SessionFile + timeAttackSuffix
-	}
+	return leaderboard
+}
 
-	results.Date = time.Now()
+const gridPenaltiesMetaKey = "grid_penalties"
 
-	err = saveResults(results.SessionFile+".json", results)
+// AddGridPenalty gives a driver a penalty of the given number of grid places, which is applied to the starting
+// grid of the next Race Weekend race session. Penalties for the same driver are added together.
+func (rc *RaceControl) AddGridPenalty(driverGUID udp.DriverGUID, places int) error {
+	if places <= 0 {
+		return fmt.Errorf("racecontrol: grid penalty must be at least one place, got %d", places)
+	}
+
+	gridPenalties, err := loadGridPenalties(rc.store)
 
 	if err != nil {
 		return err
 	}
 
-	rc.currentTimeAttackEvent.TimeAttackCombinedResultFile = results.SessionFile
+	gridPenalties[driverGUID] += places
 
-	return rc.store.UpsertCustomRace(rc.currentTimeAttackEvent)
+	return rc.store.SetMeta(gridPenaltiesMetaKey, gridPenalties)
 }
 
-// OnClientConnect stores CarID -> DriverGUID mappings. if a driver is known to have previously been in this event,
-// they will be moved from DisconnectedDrivers to ConnectedDrivers.
-func (rc *RaceControl) OnClientConnect(client udp.SessionCarInfo) error {
-	rc.carIDToGUIDMutex.Lock()
-	rc.CarIDToGUID[client.CarID] = client.DriverGUID
-	rc.carIDToGUIDMutex.Unlock()
-
-	client.DriverInitials = driverInitials(client.DriverName)
-	client.DriverName = driverName(client.DriverName)
-	client.CarName = prettifyName(client.CarModel, true)
-
-	var driver *RaceControlDriver
-
-	if disconnectedDriver, ok := rc.DisconnectedDrivers.Get(client.DriverGUID); ok {
-		driver = disconnectedDriver
-		logrus.Debugf("Driver %s (%s) reconnected in %s (car id: %d)", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, driver.CarInfo.CarModel, client.CarID)
-		rc.DisconnectedDrivers.Del(client.DriverGUID)
-	} else {
-		if connectedDriver, ok := rc.ConnectedDrivers.Get(client.DriverGUID); ok {
-			driver = connectedDriver
-			logrus.Debugf("Driver %s (%s) reconnected (but was already connected...) in %s (car id: %d)", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, driver.CarInfo.CarModel, client.CarID)
-		} else {
-			driver = NewRaceControlDriver(client)
-			logrus.Debugf("Driver %s (%s) connected in %s (car id: %d)", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, driver.CarInfo.CarModel, client.CarID)
-		}
-	}
+// loadGridPenalties loads grid penalties from the store, keyed by driver GUID.
+func loadGridPenalties(store Store) (map[udp.DriverGUID]int, error) {
+	gridPenalties := make(map[udp.DriverGUID]int)
 
-	driver.mutex.Lock()
-	defer driver.mutex.Unlock()
-	driver.CarInfo = client
+	err := store.GetMeta(gridPenaltiesMetaKey, &gridPenalties)
 
-	if _, ok := driver.Cars[driver.CarInfo.CarModel]; !ok {
-		driver.Cars[driver.CarInfo.CarModel] = NewRaceControlCarLapInfo(driver.CarInfo.CarModel)
+	if err != nil && err != ErrValueNotSet {
+		return nil, err
 	}
 
-	driver.ConnectedTime = time.Now()
-	driver.LastSeen = time.Time{}
-	driver.CurrentCar().LastLapCompletedTime = time.Now()
-
-	rc.ConnectedDrivers.Add(driver.CarInfo.DriverGUID, driver)
+	return gridPenalties, nil
+}
 
-	_, err := rc.broadcaster.Send(client)
+// applyGridPenalties applies stored grid penalties to the entryList. Penalties which are applied are removed
+// from the store, so that they only affect one race.
+func applyGridPenalties(store Store, entryList RaceWeekendEntryList) error {
+	gridPenalties, err := loadGridPenalties(store)
 
-	return err
-}
+	if err != nil {
+		return err
+	}
 
-// OnClientDisconnect moves a client from ConnectedDrivers to DisconnectedDrivers.
-func (rc *RaceControl) OnClientDisconnect(client udp.SessionCarInfo) error {
-	if ch, ok := rc.carUpdaters[client.CarID]; ok && ch != nil {
-		delete(rc.carUpdaters, client.CarID)
+	if len(gridPenalties) == 0 {
+		return nil
 	}
 
-	driver, ok := rc.ConnectedDrivers.Get(client.DriverGUID)
+	for _, driverGUID := range entryList.ApplyGridPenalties(gridPenalties) {
+		logrus.Infof("Applied grid penalty of %d places to driver: %s", gridPenalties[driverGUID], driverGUID)
 
-	if !ok {
-		return fmt.Errorf("racecontrol: client disconnected without ever being connected: %s (%s)", client.DriverName, client.DriverGUID)
+		delete(gridPenalties, driverGUID)
 	}
 
-	driver.mutex.Lock()
-	defer driver.mutex.Unlock()
+	return store.SetMeta(gridPenaltiesMetaKey, gridPenalties)
+}
 
-	logrus.Debugf("Driver %s (%s) disconnected", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID)
+// CarModelFastestLap is the fastest lap set in a car model, and the driver who set it.
+type CarModelFastestLap struct {
+	CarModel   string         `json:"CarModel"`
+	CarName    string         `json:"CarName"`
+	DriverGUID udp.DriverGUID `json:"DriverGUID"`
+	DriverName string         `json:"DriverName"`
+	LapTime    time.Duration  `json:"LapTime"`
+}
 
-	driver.LoadedTime = time.Time{}
+// FastestLapPerModel returns the fastest lap set in each car model this session, keyed by car model. Car models
+// which have no valid laps are not included.
+func (rc *RaceControl) FastestLapPerModel() map[string]CarModelFastestLap {
+	fastestLaps := make(map[string]CarModelFastestLap)
 
-	rc.ConnectedDrivers.Del(driver.CarInfo.DriverGUID)
+	checkLaps := func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
 
-	if driver.TotalNumLaps > 0 {
-		rc.DisconnectedDrivers.Add(driver.CarInfo.DriverGUID, driver)
-	}
+		for model, car := range driver.Cars {
+			if car.BestLap <= 0 {
+				continue
+			}
 
-	config := rc.process.Event().GetRaceConfig()
+			if fastestLap, ok := fastestLaps[model]; ok && fastestLap.LapTime <= car.BestLap {
+				continue
+			}
 
-	// if this race has driver swaps enabled we should initialise one now
-	if config.DriverSwapEnabled == 1 && rc.SessionInfo.Type.String() == SessionTypeRace.String() {
-		ticker := time.NewTicker(time.Second)
+			fastestLaps[model] = CarModelFastestLap{
+				CarModel:   model,
+				CarName:    car.CarName,
+				DriverGUID: driver.CarInfo.DriverGUID,
+				DriverName: driver.CarInfo.DriverName,
+				LapTime:    car.BestLap,
+			}
+		}
 
-		go rc.handleDriverSwap(ticker, config, client, driver)
+		return nil
 	}
 
-	_, err := rc.broadcaster.Send(client)
+	_ = rc.ConnectedDrivers.Each(checkLaps)
+	_ = rc.DisconnectedDrivers.Each(checkLaps)
 
-	return err
+	return fastestLaps
 }
 
-type driverSwapPenalty struct {
-	penalty  time.Duration
-	carModel string
+// CarStandings are the connected drivers in a car model, in positional order.
+type CarStandings struct {
+	CarModel    string           `json:"CarModel"`
+	CarName     string           `json:"CarName"`
+	DriverGUIDs []udp.DriverGUID `json:"DriverGUIDs"`
 }
 
-func (rc *RaceControl) handleDriverSwap(ticker *time.Ticker, config CurrentRaceConfig, client udp.SessionCarInfo, driver *RaceControlDriver) {
-	var (
-		totalTime           time.Duration
-		newDriverConnected  bool
-		firstPositionUpdate bool
-		resumeSwap          bool
-	)
+// GroupStandingsByCar splits the connected drivers into a separate set of standings for each car model. Car models
+// are ordered by the position of their leading driver.
+func (rc *RaceControl) GroupStandingsByCar() []CarStandings {
+	var standings []CarStandings
 
-	completeTime := time.Second * time.Duration(config.DriverSwapMinTime)
-	initialGUID := client.DriverGUID
-	currentDriver := driver
-	position := currentDriver.LastPos
+	carModelIndexes := make(map[string]int)
 
-	logrus.Infof(
-		"Driver: %s has initiated a driver swap, disconnected in position: %.2f, %.2f, %.2f. Next driver is expected to connect in the same position for a driver swap!",
-		currentDriver.CarInfo.DriverGUID,
-		currentDriver.LastPos.X,
-		currentDriver.LastPos.Y,
-		currentDriver.LastPos.Z,
-	)
+	_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
 
-	driver.driverSwapContext, driver.driverSwapCfn = context.WithCancel(context.Background())
+		carModel := driver.CarInfo.CarModel
 
-	for {
-		select {
-		case <-driver.driverSwapContext.Done():
-			return
-		case <-ticker.C:
-			totalTime += time.Second
+		index, ok := carModelIndexes[carModel]
 
-			countdown := completeTime - totalTime
+		if !ok {
+			index = len(standings)
+			carModelIndexes[carModel] = index
+
+			standings = append(standings, CarStandings{
+				CarModel: carModel,
+				CarName:  driver.CurrentCar().CarName,
+			})
+		}
+
+		standings[index].DriverGUIDs = append(standings[index].DriverGUIDs, driverGUID)
+
+		return nil
+	})
+
+	return standings
+}
+
+// trackRecord is the fastest lap set in a car at a track.
+type trackRecord struct {
+	LapTime    time.Duration
+	DriverGUID udp.DriverGUID
+}
+
+// loadTrackBests finds the fastest valid lap in each car at the session's track, and each driver's fastest valid lap
+// in each car there, from the laps in the lap log before the session started. The lap log is read once per session,
+// so that drivers' personal bests can be looked up when they connect.
+func (rc *RaceControl) loadTrackBests(sessionInfo udp.SessionInfo, sessionStartTime time.Time) {
+	records := make(map[string]trackRecord)
+	personalBests := make(map[udp.DriverGUID]map[string]time.Duration)
+
+	laps, err := rc.store.ListLaps(time.Time{}, sessionStartTime)
+
+	if err != nil {
+		logrus.WithError(err).Errorf("Could not load lap log for track records and personal track bests")
+	}
+
+	for _, lap := range laps {
+		if lap.Track != sessionInfo.Track || lap.TrackLayout != sessionInfo.TrackConfig || lap.Cuts > 0 || lap.LapTime <= 0 {
+			continue
+		}
+
+		if record, ok := records[lap.CarModel]; !ok || lap.LapTime < record.LapTime {
+			records[lap.CarModel] = trackRecord{LapTime: lap.LapTime, DriverGUID: lap.DriverGUID}
+		}
+
+		if _, ok := personalBests[lap.DriverGUID]; !ok {
+			personalBests[lap.DriverGUID] = make(map[string]time.Duration)
+		}
+
+		if personalBest, ok := personalBests[lap.DriverGUID][lap.CarModel]; !ok || lap.LapTime < personalBest {
+			personalBests[lap.DriverGUID][lap.CarModel] = lap.LapTime
+		}
+	}
+
+	rc.trackRecordsMutex.Lock()
+	rc.trackRecords = records
+	rc.personalTrackBests = personalBests
+	rc.trackRecordsMutex.Unlock()
+}
+
+// personalTrackBest is a driver's fastest lap in a car at the current track before the session started, or 0 if they
+// have not set one.
+func (rc *RaceControl) personalTrackBest(driverGUID udp.DriverGUID, carModel string) time.Duration {
+	rc.trackRecordsMutex.RLock()
+	defer rc.trackRecordsMutex.RUnlock()
+
+	return rc.personalTrackBests[driverGUID][carModel]
+}
+
+// updateTrackRecord sets a new track record for the car model if the lap is faster than the current record.
+func (rc *RaceControl) updateTrackRecord(carModel string, driverGUID udp.DriverGUID, lapTime time.Duration) bool {
+	rc.trackRecordsMutex.Lock()
+	defer rc.trackRecordsMutex.Unlock()
+
+	if rc.trackRecords == nil {
+		rc.trackRecords = make(map[string]trackRecord)
+	}
+
+	if record, ok := rc.trackRecords[carModel]; ok && record.LapTime <= lapTime {
+		return false
+	}
+
+	rc.trackRecords[carModel] = trackRecord{LapTime: lapTime, DriverGUID: driverGUID}
+
+	return true
+}
+
+// setDeltaToRecord compares the driver's best lap in their current car to the track record. The caller must hold
+// the driver's mutex.
+func (rc *RaceControl) setDeltaToRecord(driver *RaceControlDriver) {
+	rc.trackRecordsMutex.RLock()
+	record, ok := rc.trackRecords[driver.CarInfo.CarModel]
+	rc.trackRecordsMutex.RUnlock()
+
+	bestLap := driver.CurrentCar().BestLap
+
+	if !ok || bestLap <= 0 {
+		driver.DeltaToRecord = 0
+		driver.HoldsRecord = false
+		return
+	}
+
+	driver.DeltaToRecord = bestLap - record.LapTime
+	driver.HoldsRecord = record.DriverGUID == driver.CarInfo.DriverGUID && driver.DeltaToRecord == 0
+}
+
+// updateDeltasToRecord recalculates every driver's delta to the track record, e.g. after the record is broken.
+func (rc *RaceControl) updateDeltasToRecord() {
+	update := func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
+
+		rc.setDeltaToRecord(driver)
+
+		return nil
+	}
+
+	_ = rc.ConnectedDrivers.Each(update)
+	_ = rc.DisconnectedDrivers.Each(update)
+}
+
+type temperatureSample struct {
+	Ambient uint8
+	Road    uint8
+}
+
+// recordTemperatures adds the temperatures in the sessionInfo to the session's temperature history.
+func (rc *RaceControl) recordTemperatures(sessionInfo udp.SessionInfo) {
+	rc.temperatureSamplesMutex.Lock()
+	defer rc.temperatureSamplesMutex.Unlock()
+
+	rc.temperatureSamples = append(rc.temperatureSamples, temperatureSample{
+		Ambient: sessionInfo.AmbientTemp,
+		Road:    sessionInfo.RoadTemp,
+	})
+}
+
+// SessionStats are totals across all drivers in a session.
+type SessionStats struct {
+	NumLaps            int     `json:"NumLaps"`
+	NumCollisions      int     `json:"NumCollisions"`
+	NumDrivers         int     `json:"NumDrivers"`
+	AverageAmbientTemp float64 `json:"AverageAmbientTemp"`
+	AverageRoadTemp    float64 `json:"AverageRoadTemp"`
+}
+
+// SessionStats totals the laps and collisions of all drivers (connected and disconnected) in the current session,
+// and averages the temperatures reported by the server during the session.
+func (rc *RaceControl) SessionStats() SessionStats {
+	stats := SessionStats{}
+
+	addDriver := func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
+
+		stats.NumDrivers++
+		stats.NumLaps += driver.TotalNumLaps
+		stats.NumCollisions += len(driver.Collisions)
+
+		return nil
+	}
+
+	_ = rc.ConnectedDrivers.Each(addDriver)
+	_ = rc.DisconnectedDrivers.Each(addDriver)
+
+	rc.temperatureSamplesMutex.Lock()
+	defer rc.temperatureSamplesMutex.Unlock()
+
+	if len(rc.temperatureSamples) > 0 {
+		var ambientTotal, roadTotal float64
+
+		for _, sample := range rc.temperatureSamples {
+			ambientTotal += float64(sample.Ambient)
+			roadTotal += float64(sample.Road)
+		}
+
+		stats.AverageAmbientTemp = ambientTotal / float64(len(rc.temperatureSamples))
+		stats.AverageRoadTemp = roadTotal / float64(len(rc.temperatureSamples))
+	}
+
+	return stats
+}
+
+// fastestLapAnnouncementInterval is the minimum time between new fastest lap announcements in chat.
+const fastestLapAnnouncementInterval = time.Second * 10
+
+// SessionFastestLap is the fastest lap of the session, and the driver who set it. It is broadcast whenever it changes.
+type SessionFastestLap CarModelFastestLap
+
+func (SessionFastestLap) Event() udp.Event {
+	return EventSessionFastestLap
+}
+
+// updateSessionFastestLap sets the fastest lap of the session if the lap is faster than it.
+func (rc *RaceControl) updateSessionFastestLap(lap SessionFastestLap) bool {
+	rc.sessionFastestLapMutex.Lock()
+	defer rc.sessionFastestLapMutex.Unlock()
+
+	if rc.SessionFastestLap != nil && rc.SessionFastestLap.LapTime <= lap.LapTime {
+		return false
+	}
+
+	rc.SessionFastestLap = &lap
+
+	return true
+}
+
+// FastestLapOfSession returns a copy of the fastest lap of the session, or nil if no valid laps have been completed.
+func (rc *RaceControl) FastestLapOfSession() *SessionFastestLap {
+	rc.sessionFastestLapMutex.Lock()
+	defer rc.sessionFastestLapMutex.Unlock()
+
+	if rc.SessionFastestLap == nil {
+		return nil
+	}
+
+	fastestLap := *rc.SessionFastestLap
+
+	return &fastestLap
+}
+
+// inQuietHours determines whether t is within the quiet hours in the cached server options, during which webhook
+// events and chat announcements are not sent.
+func (rc *RaceControl) inQuietHours(t time.Time) bool {
+	serverOptions := rc.cachedServerOptions()
+
+	inQuietHours, err := isInQuietHours(t, serverOptions.QuietHoursStart, serverOptions.QuietHoursEnd, serverOptions.QuietHoursTimezone)
+
+	if err != nil {
+		logrus.WithError(err).Errorf("Could not check quiet hours, ignoring quiet hours")
+		return false
+	}
+
+	return inQuietHours
+}
+
+// announceFastestLap tells all drivers about a new fastest lap of the session, if AnnounceFastestLap is enabled and
+// it is not within the quiet hours. Announcements are limited to one per fastestLapAnnouncementInterval.
+func (rc *RaceControl) announceFastestLap(fastestLap SessionFastestLap) {
+	if !rc.cachedServerOptions().AnnounceFastestLap || rc.inQuietHours(time.Now()) {
+		return
+	}
+
+	rc.sessionFastestLapMutex.Lock()
+
+	if time.Since(rc.lastFastestLapAnnouncement) < fastestLapAnnouncementInterval {
+		rc.sessionFastestLapMutex.Unlock()
+		return
+	}
+
+	rc.lastFastestLapAnnouncement = time.Now()
+	rc.sessionFastestLapMutex.Unlock()
+
+	message := fmt.Sprintf("New fastest lap: %s, %s (%s)", fastestLap.DriverName, formatDuration(fastestLap.LapTime, true), fastestLap.CarName)
+
+	if err := rc.splitAndBroadcastChat(message, nil); err != nil {
+		logrus.WithError(err).Errorf("Could not announce fastest lap")
+	}
+}
+
+var emptyCarInfoMutex = sync.Mutex{}
+
+// OnNewSession occurs every new session. If the session is the first in an event and it is not a looped practice,
+// then all driver information is cleared.
+func (rc *RaceControl) OnNewSession(sessionInfo udp.SessionInfo) error {
+	oldSessionInfo := rc.SessionInfo
+	rc.sessionInfoMutex.Lock()
+	rc.SessionInfo = sessionInfo
+	rc.SessionStartTime = time.Now()
+	rc.sessionInfoUpdatedAt = rc.SessionStartTime
+	rc.sessionInfoMutex.Unlock()
+	rc.SessionID = uuid.New().String()
+
+	rc.refreshServerOptions()
+	rc.updateSessionInfoInterval()
+	rc.sendWebhook(WebhookEventNewSession, sessionInfo)
+	rc.scheduleRaceStartCheck(sessionInfo)
+	rc.scheduleSessionClockStart(sessionInfo)
+	rc.startSessionCountdown()
+	rc.scheduleNextSessionReminder(sessionInfo)
+	rc.scheduleQualifyingExtension(sessionInfo)
+
+	if isUnlimitedSession(sessionInfo) {
+		logrus.Warnf("Session %s has no laps or time configured, it will run until it is manually ended", sessionInfo.Name)
+	}
+	rc.rotateLapCSV(sessionInfo)
+	rc.loadTrackBests(sessionInfo, rc.SessionStartTime)
+	rc.loadPitLaneArea(sessionInfo)
+
+	emptyCarInfo := true
+
+	rc.sessionPenaltiesMutex.Lock()
+	rc.sessionPenalties = make(map[udp.DriverGUID]*sessionPenalty)
+	rc.sessionPenaltiesMutex.Unlock()
+
+	rc.solWarningGUIDsMutex.Lock()
+	rc.solWarningGUIDs = make(map[udp.DriverGUID]bool)
+	rc.solWarningGUIDsMutex.Unlock()
+
+	rc.readyGUIDsMutex.Lock()
+	rc.readyGUIDs = make(map[udp.DriverGUID]bool)
+	rc.readyGUIDsMutex.Unlock()
+
+	rc.firstLapGUIDsMutex.Lock()
+	rc.firstLapGUIDs = make(map[udp.DriverGUID]bool)
+	rc.firstLapGUIDsMutex.Unlock()
+
+	rc.temperatureSamplesMutex.Lock()
+	rc.temperatureSamples = nil
+	rc.temperatureSamplesMutex.Unlock()
+
+	rc.sessionFastestLapMutex.Lock()
+	rc.SessionFastestLap = nil
+	rc.sessionFastestLapMutex.Unlock()
+
+	rc.recordTemperatures(sessionInfo)
+
+	if (rc.ConnectedDrivers.Len() > 0 || rc.DisconnectedDrivers.Len() > 0) && sessionInfo.Type == udp.SessionTypePractice {
+		if oldSessionInfo.Type == sessionInfo.Type && oldSessionInfo.Track == sessionInfo.Track && oldSessionInfo.TrackConfig == sessionInfo.TrackConfig && oldSessionInfo.Name == sessionInfo.Name {
+			// this is a looped event, keep the cars
+			emptyCarInfo = false
+		}
+	}
+
+	clearedDisconnected := false
+
+	if emptyCarInfo {
+		_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+			emptyCarInfoMutex.Lock()
+			defer emptyCarInfoMutex.Unlock()
+
+			modelMismatch, activeSince, loaded, teamName := driver.ModelMismatch, driver.activeSince, !driver.pitExitTime.IsZero(), driver.TeamName
+
+			*driver = *NewRaceControlDriver(driver.CarInfo)
+			driver.ModelMismatch = modelMismatch
+			driver.activeSince = activeSince
+			driver.TeamName = teamName
+
+			if loaded {
+				// drivers start each session in the pits
+				driver.pitExitTime = rc.SessionStartTime
+				driver.LapPhase = rc.lapPhase(driver)
+			}
+
+			return nil
+		})
+
+		// all disconnected drivers are removed when car info is emptied, otherwise we are just showing empty entries in
+		// the disconnected drivers table, which is pointless.
+		rc.DisconnectedDrivers = NewDriverMap(DisconnectedDrivers, rc.SortDrivers)
+	} else if rc.cachedServerOptions().ClearDisconnectedOnLoop {
+		// connected drivers are kept across a looped session, but each loop can start with no disconnected drivers.
+		rc.DisconnectedDrivers = NewDriverMap(DisconnectedDrivers, rc.SortDrivers)
+		clearedDisconnected = true
+	}
+
+	// clear out last lap completed time each new session
+	_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
+
+		driver.CurrentCar().LastLapCompletedTime = time.Now()
+		driver.resetActiveDuration(rc.SessionStartTime)
+		driver.resetSessionStats()
+
+		driver.PersonalTrackBest = rc.personalTrackBest(driverGUID, driver.CarInfo.CarModel)
+		driver.updateBeatPersonalTrackBest()
+
+		return nil
+	})
+
+	trackInfo, err := rc.trackDataGateway.TrackInfo(sessionInfo.Track, sessionInfo.TrackConfig)
+
+	if err != nil {
+		return err
+	}
+
+	rc.TrackInfo = *trackInfo
+
+	trackMapData, err := rc.trackDataGateway.TrackMap(sessionInfo.Track, sessionInfo.TrackConfig)
+
+	if err != nil {
+		logrus.WithError(err).Errorf("Could not load track map data")
+		rc.TrackMapData = TrackMapData{}
+	} else {
+		rc.TrackMapData = *trackMapData
+	}
+
+	logrus.Debugf("New session detected: %s at %s (%s) [emptyCarInfo: %t]", sessionInfo.Type.String(), sessionInfo.Track, sessionInfo.TrackConfig, emptyCarInfo)
+
+	// look for live timings stored previously
+	persistedInfo, err := rc.store.LoadLiveTimingsData()
+
+	if err == nil && persistedInfo != nil {
+		if persistedInfo.SessionType == rc.SessionInfo.Type &&
+			persistedInfo.Track == rc.SessionInfo.Track &&
+			persistedInfo.TrackLayout == rc.SessionInfo.TrackConfig &&
+			persistedInfo.SessionName == rc.SessionInfo.Name {
+
+			for guid, driver := range persistedInfo.Drivers {
+				_, driverPresentInDisconnectedList := rc.DisconnectedDrivers.Get(guid)
+				_, driverPresentInConnectedList := rc.ConnectedDrivers.Get(guid)
+
+				// the persisted drivers of a looped session are its previous loop, so they must not bring back the
+				// disconnected drivers which have just been cleared.
+				if !driverPresentInConnectedList && !driverPresentInDisconnectedList && !clearedDisconnected {
+					rc.DisconnectedDrivers.Add(guid, driver)
+				}
+			}
+
+			logrus.Infof("Loaded previous Live Timings data for %s (%s), num drivers: %d", persistedInfo.Track, persistedInfo.TrackLayout, len(persistedInfo.Drivers))
+		}
+	} else {
+		logrus.WithError(err).Debugf("Could not load persisted live timings practice data")
+	}
+
+	_, err = rc.broadcaster.Send(sessionInfo)
+
+	return err
+}
+
+// SeedDisconnectedDrivers restores the standings of a completed session from its results file, e.g. so that a
+// restarted server can restore the grid for a continuation race. Each driver in the results is added to the
+// DisconnectedDrivers, unless they are already known. The results are not used if any
+// of their cars are not in the current entry list.
+func (rc *RaceControl) SeedDisconnectedDrivers(resultsFile string) error {
+	results, err := LoadResult(resultsFile, LoadResultWithoutPluginFire)
+
+	if err != nil {
+		return err
+	}
+
+	entryListModels := make(map[string]bool)
+
+	for _, entrant := range rc.process.Event().GetEntryList() {
+		entryListModels[entrant.Model] = true
+	}
+
+	for _, result := range results.Result {
+		if !entryListModels[result.CarModel] {
+			return fmt.Errorf("racecontrol: car %s (driven by %s) in results file %s is not in the entry list", result.CarModel, result.DriverName, resultsFile)
+		}
+	}
+
+	numSeeded := 0
+
+	for _, result := range results.Result {
+		guid := udp.DriverGUID(result.DriverGUID)
+
+		if guid == "" || guid == kickedGUID {
+			continue
+		}
+
+		_, driverPresentInDisconnectedList := rc.DisconnectedDrivers.Get(guid)
+		_, driverPresentInConnectedList := rc.ConnectedDrivers.Get(guid)
+
+		if driverPresentInConnectedList || driverPresentInDisconnectedList {
+			continue
+		}
+
+		driver := NewRaceControlDriver(udp.SessionCarInfo{
+			CarID:      udp.CarID(result.CarID),
+			DriverName: result.DriverName,
+			DriverGUID: guid,
+			CarModel:   result.CarModel,
+			CarName:    prettifyName(result.CarModel, true),
+		})
+
+		driver.TeamName = results.GetTeamName(result.DriverGUID)
+
+		car := driver.CurrentCar()
+
+		for _, lap := range results.Laps {
+			if lap.CarID != result.CarID || lap.DriverGUID != result.DriverGUID {
+				continue
+			}
+
+			lapTime := ParseLapTime(lap.LapTime)
+
+			car.NumLaps++
+			car.TotalLapTime += lapTime
+			car.LastLap = lapTime
+			car.LastLapValid = lap.Cuts == 0
+
+			if lap.Cuts == 0 {
+				car.NumValidLaps++
+
+				if car.BestLap == 0 || lapTime < car.BestLap {
+					car.BestLap = lapTime
+				}
+			}
+		}
+
+		driver.TotalNumLaps = car.NumLaps
+
+		rc.DisconnectedDrivers.Add(guid, driver)
+
+		numSeeded++
+	}
+
+	logrus.Infof("Seeded %d disconnected drivers from results file: %s", numSeeded, resultsFile)
+
+	_, err = rc.broadcaster.Send(rc)
+
+	return err
+}
+
+// defaultMaxRaceRestarts is the number of times a race session is restarted in each event for having too few
+// drivers, if MaxRaceRestarts is not set.
+const defaultMaxRaceRestarts = 3
+
+// defaultMinRaceRestartDelay is the minimum time between restarts of a race session for having too few drivers, if
+// MinRaceRestartDelay is not set.
+const defaultMinRaceRestartDelay = time.Minute
+
+// raceRestartLimits returns the maximum number of restarts of a race session for having too few drivers in each
+// event, and the minimum time between them.
+func (rc *RaceControl) raceRestartLimits() (maxRestarts int, minDelay time.Duration) {
+	opts := rc.cachedServerOptions()
+
+	maxRestarts = opts.MaxRaceRestarts
+
+	if maxRestarts <= 0 {
+		maxRestarts = defaultMaxRaceRestarts
+	}
+
+	minDelay = time.Duration(opts.MinRaceRestartDelay) * time.Second
+
+	if minDelay <= 0 {
+		minDelay = defaultMinRaceRestartDelay
+	}
+
+	return maxRestarts, minDelay
+}
+
+// scheduleRaceStartCheck checks that the minimum number of drivers are connected once the wait time for a race
+// session has elapsed, or once the minimum delay since the last restart has elapsed if that is later. Any previously
+// scheduled check is cancelled.
+func (rc *RaceControl) scheduleRaceStartCheck(sessionInfo udp.SessionInfo) {
+	rc.raceStartCheckTimerMutex.Lock()
+	defer rc.raceStartCheckTimerMutex.Unlock()
+
+	if rc.raceStartCheckTimer != nil {
+		rc.raceStartCheckTimer.Stop()
+		rc.raceStartCheckTimer = nil
+	}
+
+	minimumDrivers := rc.cachedServerOptions().MinimumRaceDrivers
+
+	if sessionInfo.Type != udp.SessionTypeRace || minimumDrivers <= 0 {
+		return
+	}
+
+	maxRestarts, minDelay := rc.raceRestartLimits()
+
+	if rc.numRaceRestarts >= maxRestarts {
+		return
+	}
+
+	delay := time.Duration(sessionInfo.WaitTime) * time.Second
+
+	if !rc.lastRaceRestart.IsZero() {
+		if untilNextRestart := time.Until(rc.lastRaceRestart.Add(minDelay)); untilNextRestart > delay {
+			delay = untilNextRestart
+		}
+	}
+
+	rc.raceStartCheckTimer = time.AfterFunc(delay, func() {
+		if _, err := rc.restartRaceIfTooFewDrivers(minimumDrivers); err != nil {
+			logrus.WithError(err).Error("Could not check number of drivers at race start")
+		}
+	})
+}
+
+// numLoadedDrivers returns the number of connected drivers who have loaded into the server and not yet disconnected.
+func (rc *RaceControl) numLoadedDrivers() int {
+	numDrivers := 0
+
+	_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
+
+		if !driver.LoadedTime.IsZero() && !driver.Stale {
+			numDrivers++
+		}
+
+		return nil
+	})
+
+	return numDrivers
+}
+
+// restartRaceIfTooFewDrivers restarts the race session if fewer than minimumDrivers have loaded into the server,
+// notifying drivers in chat. The session is not restarted if no drivers are connected, or if it has already been
+// restarted MaxRaceRestarts times in this event. It returns true if the session was restarted.
+func (rc *RaceControl) restartRaceIfTooFewDrivers(minimumDrivers int) (bool, error) {
+	numDrivers := rc.numLoadedDrivers()
+
+	if numDrivers >= minimumDrivers || numDrivers == 0 {
+		return false, nil
+	}
+
+	maxRestarts, _ := rc.raceRestartLimits()
+
+	rc.raceStartCheckTimerMutex.Lock()
+
+	if rc.numRaceRestarts >= maxRestarts {
+		rc.raceStartCheckTimerMutex.Unlock()
+
+		logrus.Infof("Only %d of %d required drivers are connected at race start, but the race has already been restarted %d times. Starting anyway", numDrivers, minimumDrivers, maxRestarts)
+
+		return false, nil
+	}
+
+	rc.numRaceRestarts++
+	rc.lastRaceRestart = time.Now()
+	numRestarts := rc.numRaceRestarts
+
+	rc.raceStartCheckTimerMutex.Unlock()
+
+	logrus.Infof("Only %d of %d required drivers are connected at race start, restarting the session (restart %d of %d)", numDrivers, minimumDrivers, numRestarts, maxRestarts)
+
+	err := rc.splitAndBroadcastChat(fmt.Sprintf("Only %d of the %d drivers needed to start are connected. The race will be restarted to give more drivers time to join (restart %d of %d).", numDrivers, minimumDrivers, numRestarts, maxRestarts), nil)
+
+	if err != nil {
+		return false, err
+	}
+
+	if err := rc.process.SendUDPMessage(&udp.RestartSession{}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SessionClock is sent when the session clock is waiting for drivers before a race, and when it starts running.
+type SessionClock struct {
+	Waiting           bool          `json:"Waiting"`
+	WaitTimeRemaining time.Duration `json:"WaitTimeRemaining"`
+}
+
+func (SessionClock) Event() udp.Event {
+	return EventSessionClock
+}
+
+// SessionClock works out whether the current session is waiting for drivers before the start of a race.
+func (rc *RaceControl) SessionClock() SessionClock {
+	if rc.SessionInfo.Type != udp.SessionTypeRace {
+		return SessionClock{}
+	}
+
+	waitTimeRemaining := time.Until(rc.SessionStartTime.Add(time.Duration(rc.SessionInfo.WaitTime) * time.Second))
+
+	if waitTimeRemaining <= 0 {
+		return SessionClock{}
+	}
+
+	return SessionClock{
+		Waiting:           true,
+		WaitTimeRemaining: waitTimeRemaining.Round(time.Second),
+	}
+}
+
+// scheduleSessionClockStart broadcasts the session clock at the start of a session, and again when the wait time of
+// a race has elapsed. Any previously scheduled broadcast is cancelled.
+func (rc *RaceControl) scheduleSessionClockStart(sessionInfo udp.SessionInfo) {
+	rc.sessionClockTimerMutex.Lock()
+	defer rc.sessionClockTimerMutex.Unlock()
+
+	if rc.sessionClockTimer != nil {
+		rc.sessionClockTimer.Stop()
+		rc.sessionClockTimer = nil
+	}
+
+	rc.broadcastSessionClock()
+
+	if sessionInfo.Type != udp.SessionTypeRace || sessionInfo.WaitTime == 0 {
+		return
+	}
+
+	rc.sessionClockTimer = time.AfterFunc(time.Duration(sessionInfo.WaitTime)*time.Second, rc.broadcastSessionClock)
+}
+
+func (rc *RaceControl) broadcastSessionClock() {
+	if _, err := rc.broadcaster.Send(rc.SessionClock()); err != nil {
+		logrus.WithError(err).Errorf("Could not broadcast session clock")
+	}
+}
+
+// scheduleNextSessionReminder sends a reminder about the next session NextSessionReminder minutes before the end of
+// a timed session. Any previously scheduled reminder is cancelled.
+func (rc *RaceControl) scheduleNextSessionReminder(sessionInfo udp.SessionInfo) {
+	rc.nextSessionReminderTimerMutex.Lock()
+	defer rc.nextSessionReminderTimerMutex.Unlock()
+
+	if rc.nextSessionReminderTimer != nil {
+		rc.nextSessionReminderTimer.Stop()
+		rc.nextSessionReminderTimer = nil
+	}
+
+	leadTime := time.Duration(rc.cachedServerOptions().NextSessionReminder) * time.Minute
+
+	if leadTime <= 0 || sessionInfo.Time == 0 {
+		return
+	}
+
+	delay := time.Duration(sessionInfo.WaitTime)*time.Second + time.Duration(sessionInfo.Time)*time.Minute - leadTime
+
+	if delay <= 0 {
+		return
+	}
+
+	rc.nextSessionReminderTimer = time.AfterFunc(delay, func() {
+		reminder, ok := rc.nextSessionReminder(leadTime)
+
+		if !ok {
+			return
+		}
+
+		if err := rc.splitAndBroadcastChat(reminder, nil); err != nil {
+			logrus.WithError(err).Error("Could not send next session reminder")
+		}
+	})
+}
+
+// nextSessionReminder describes the session after the current one, given the time remaining in the current session.
+// If the current session is the last of the event, no reminder is given.
+func (rc *RaceControl) nextSessionReminder(timeRemaining time.Duration) (string, bool) {
+	raceConfig := rc.process.Event().GetRaceConfig()
+	sessions, sessionTypes := raceConfig.Sessions.AsSliceWithSessionTypes()
+
+	nextSessionIndex := int(rc.SessionInfo.CurrentSessionIndex) + 1
+
+	if nextSessionIndex >= len(sessions) {
+		return "", false
+	}
+
+	nextSession := sessions[nextSessionIndex]
+	startsIn := timeRemaining + time.Duration(raceConfig.ResultScreenTime)*time.Second
+
+	var length string
+
+	if nextSession.Laps > 0 {
+		length = fmt.Sprintf("%d laps", nextSession.Laps)
+	} else {
+		length = fmt.Sprintf("%d minutes", nextSession.Time)
+	}
+
+	return fmt.Sprintf("%s (%s) starts in about %d minutes. Stay connected to take part!", sessionTypes[nextSessionIndex].String(), length, int(startsIn.Round(time.Minute).Minutes())), true
+}
+
+// scheduleQualifyingExtension checks for drivers on a flying lap when the clock runs out in a timed qualifying
+// session, if QualifyingExtension is set. Any previously scheduled check is cancelled.
+func (rc *RaceControl) scheduleQualifyingExtension(sessionInfo udp.SessionInfo) {
+	rc.qualifyingExtensionTimerMutex.Lock()
+	defer rc.qualifyingExtensionTimerMutex.Unlock()
+
+	if rc.qualifyingExtensionTimer != nil {
+		rc.qualifyingExtensionTimer.Stop()
+		rc.qualifyingExtensionTimer = nil
+	}
+
+	if rc.cachedServerOptions().QualifyingExtension <= 0 || sessionInfo.Type != udp.SessionTypeQualifying || sessionInfo.Time == 0 {
+		return
+	}
+
+	rc.qualifyingExtensionTimer = time.AfterFunc(time.Duration(sessionInfo.Time)*time.Minute, rc.extendQualifying)
+}
+
+// extendQualifying lets drivers know that the session has been extended so that a lap started before the clock ran
+// out can be completed. Assetto Corsa has no admin command to add time to a session, so the extension is only
+// announced in chat.
+func (rc *RaceControl) extendQualifying() {
+	extension := rc.qualifyingExtension(time.Now())
+
+	if extension <= 0 {
+		return
+	}
+
+	logrus.Infof("Qualifying clock has run out with drivers on a flying lap, extending by %s", extension)
+
+	message := fmt.Sprintf("Time is up! Drivers on a flying lap have %s to complete it.", extension)
+
+	if err := rc.splitAndBroadcastChat(message, nil); err != nil {
+		logrus.WithError(err).Error("Could not send qualifying extension message")
+	}
+}
+
+// qualifyingExtension works out how long qualifying should be extended by for drivers who are on a flying lap to
+// complete it. Each driver is expected to finish their lap in their best lap time, and the extension is capped at
+// QualifyingExtension. If no drivers are on a flying lap, the session is not extended.
+func (rc *RaceControl) qualifyingExtension(now time.Time) time.Duration {
+	maxExtension := time.Duration(rc.cachedServerOptions().QualifyingExtension) * time.Second
+
+	var extension time.Duration
+
+	_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
+
+		if driver.LapPhase != LapPhaseFlying {
+			return nil
+		}
+
+		car := driver.CurrentCar()
+		remaining := maxExtension
+
+		if car.BestLap > 0 && !car.LastLapCompletedTime.IsZero() {
+			remaining = car.BestLap - now.Sub(car.LastLapCompletedTime)
+		}
+
+		if remaining > extension {
+			extension = remaining
+		}
+
+		return nil
+	})
+
+	if extension > maxExtension {
+		extension = maxExtension
+	}
+
+	return extension.Round(time.Second)
+}
+
+// isUnlimitedSession is true if a session has neither a number of laps nor a time limit, so it will only end when
+// it is manually stopped or skipped.
+func isUnlimitedSession(sessionInfo udp.SessionInfo) bool {
+	return sessionInfo.Laps == 0 && sessionInfo.Time == 0
+}
+
+// RaceRemaining is how much of the current race session is left, in laps for the leader or time.
+type RaceRemaining struct {
+	Laps      int           `json:"Laps"`
+	Time      time.Duration `json:"Time"`
+	Unlimited bool          `json:"Unlimited"`
+}
+
+// RaceRemaining works out how much of the current session is left. Sessions with no laps or time configured are
+// Unlimited, as they run until they are manually ended.
+func (rc *RaceControl) RaceRemaining() RaceRemaining {
+	return rc.raceRemaining(rc.SessionInfo)
+}
+
+// raceRemaining works out how much of a session is left from its session info, e.g. a snapshot taken by the session
+// countdown.
+func (rc *RaceControl) raceRemaining(sessionInfo udp.SessionInfo) RaceRemaining {
+	if isUnlimitedSession(sessionInfo) {
+		return RaceRemaining{Unlimited: true}
+	}
+
+	if sessionInfo.Laps > 0 {
+		lapsRemaining := int(sessionInfo.Laps) - rc.leaderNumLaps()
+
+		if lapsRemaining < 0 {
+			lapsRemaining = 0
+		}
+
+		return RaceRemaining{Laps: lapsRemaining}
+	}
+
+	timeRemaining := time.Duration(sessionInfo.Time)*time.Minute - time.Duration(sessionInfo.ElapsedMilliseconds)*time.Millisecond
+
+	if timeRemaining < 0 {
+		timeRemaining = 0
+	}
+
+	return RaceRemaining{Time: timeRemaining}
+}
+
+// RaceComplete is true once all of the laps or time of the current session have been completed. Unlimited sessions
+// are never complete.
+func (rc *RaceControl) RaceComplete() bool {
+	remaining := rc.RaceRemaining()
+
+	return !remaining.Unlimited && remaining.Laps == 0 && remaining.Time == 0
+}
+
+// RaceProgress is an estimate of how much of the current race is left, and when it will finish, based on the
+// leader's average lap time so far.
+type RaceProgress struct {
+	LeaderAverageLap       time.Duration `json:"LeaderAverageLap"`
+	EstimatedLapsRemaining int           `json:"EstimatedLapsRemaining"`
+	EstimatedFinishTime    time.Time     `json:"EstimatedFinishTime" ts:"date"`
+
+	// OverTimeEnds is the latest time that the other drivers can finish after the leader, if the race has an over
+	// time configured.
+	OverTimeEnds time.Time `json:"OverTimeEnds" ts:"date"`
+}
+
+// RaceProgress estimates the progress of the current race. It is nil if the current session is not a race, is
+// unlimited, or the leader has not completed a lap yet.
+func (rc *RaceControl) RaceProgress() *RaceProgress {
+	return rc.raceProgress(rc.process.Event().GetRaceConfig())
+}
+
+func (rc *RaceControl) raceProgress(raceConfig CurrentRaceConfig) *RaceProgress {
+	rc.sessionInfoMutex.RLock()
+	sessionInfo, sessionInfoUpdatedAt := rc.SessionInfo, rc.sessionInfoUpdatedAt
+	rc.sessionInfoMutex.RUnlock()
+
+	if sessionInfo.Type != udp.SessionTypeRace || isUnlimitedSession(sessionInfo) {
+		return nil
+	}
+
+	var leaderCar *RaceControlCarLapInfo
+
+	_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
+
+		if driver.Position == 1 {
+			car := *driver.CurrentCar()
+			leaderCar = &car
+		}
+
+		return nil
+	})
+
+	if leaderCar == nil || leaderCar.NumLaps == 0 {
+		return nil
+	}
+
+	averageLap := leaderCar.TotalLapTime / time.Duration(leaderCar.NumLaps)
+
+	if averageLap <= 0 {
+		return nil
+	}
+
+	// estimates are made from the time the leader last crossed the line.
+	lastCrossing := leaderCar.LastLapCompletedTime
+
+	var lapsRemaining int
+
+	if sessionInfo.Laps > 0 {
+		lapsRemaining = int(sessionInfo.Laps) - leaderCar.NumLaps
+	} else {
+		clockEnds := sessionClockEnds(sessionInfo, sessionInfoUpdatedAt)
+
+		switch {
+		case lastCrossing.Before(clockEnds):
+			// the leader finishes the first time they cross the line after the clock has run out.
+			lapsRemaining = int(math.Ceil(float64(clockEnds.Sub(lastCrossing)) / float64(averageLap)))
+
+			if raceConfig.RaceExtraLap == 1 {
+				lapsRemaining++
+			}
+		case raceConfig.RaceExtraLap == 1 && lastCrossing.Add(-leaderCar.LastLap).Before(clockEnds):
+			// the leader has crossed the line once since the clock ran out, and must complete the extra lap.
+			lapsRemaining = 1
+		}
+	}
+
+	if lapsRemaining < 0 {
+		lapsRemaining = 0
+	}
+
+	progress := &RaceProgress{
+		LeaderAverageLap:       averageLap,
+		EstimatedLapsRemaining: lapsRemaining,
+		EstimatedFinishTime:    lastCrossing.Add(time.Duration(lapsRemaining) * averageLap),
+	}
+
+	if raceConfig.RaceOverTime > 0 {
+		progress.OverTimeEnds = progress.EstimatedFinishTime.Add(time.Duration(raceConfig.RaceOverTime) * time.Second)
+	}
+
+	return progress
+}
+
+// sessionClockEnds is when the clock runs out in a timed session. It is worked out from the elapsed time in the last
+// session info from the server, so that it stays the same as time passes (unlike the time remaining, which stops at 0).
+func sessionClockEnds(sessionInfo udp.SessionInfo, sessionInfoUpdatedAt time.Time) time.Time {
+	clockStarted := sessionInfoUpdatedAt.Add(-time.Duration(sessionInfo.ElapsedMilliseconds) * time.Millisecond)
+
+	return clockStarted.Add(time.Duration(sessionInfo.Time) * time.Minute)
+}
+
+// leaderNumLaps is the number of laps completed by the connected driver in first place.
+func (rc *RaceControl) leaderNumLaps() int {
+	numLaps := 0
+
+	_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
+
+		if driver.Position == 1 {
+			numLaps = driver.CurrentCar().NumLaps
+		}
+
+		return nil
+	})
+
+	return numLaps
+}
+
+const defaultSessionCountdownInterval = time.Second * 5
+
+// SessionCountdown is broadcast every defaultSessionCountdownInterval with how much of the current session is left, so that
+// Live Timings clients stay in sync without each working it out from the SessionInfo.
+type SessionCountdown struct {
+	Elapsed       time.Duration `json:"Elapsed"`
+	TimeRemaining time.Duration `json:"TimeRemaining"`
+	LapsRemaining int           `json:"LapsRemaining"`
+	Unlimited     bool          `json:"Unlimited"`
+}
+
+func (SessionCountdown) Event() udp.Event {
+	return EventSessionCountdown
+}
+
+// sessionCountdown works out how much of the current session is left at a given time. The elapsed time reported by
+// the server is only updated each time session info is requested, so the time since the last update is added to it.
+func (rc *RaceControl) sessionCountdown(now time.Time) SessionCountdown {
+	rc.sessionInfoMutex.RLock()
+	sessionInfo, sessionInfoUpdatedAt := rc.SessionInfo, rc.sessionInfoUpdatedAt
+	rc.sessionInfoMutex.RUnlock()
+
+	elapsed := time.Duration(sessionInfo.ElapsedMilliseconds) * time.Millisecond
+
+	if !sessionInfoUpdatedAt.IsZero() {
+		elapsed += now.Sub(sessionInfoUpdatedAt)
+	}
+
+	if elapsed < 0 {
+		// the session is still waiting to start
+		elapsed = 0
+	}
+
+	countdown := SessionCountdown{Elapsed: elapsed.Round(time.Second)}
+
+	switch {
+	case isUnlimitedSession(sessionInfo):
+		countdown.Unlimited = true
+	case sessionInfo.Laps > 0:
+		countdown.LapsRemaining = rc.raceRemaining(sessionInfo).Laps
+	default:
+		countdown.TimeRemaining = (time.Duration(sessionInfo.Time)*time.Minute - elapsed).Round(time.Second)
+
+		if countdown.TimeRemaining < 0 {
+			countdown.TimeRemaining = 0
+		}
+	}
+
+	return countdown
+}
+
+// startSessionCountdown begins broadcasting the session countdown every rc.sessionCountdownInterval, stopping any
+// previous countdown.
+func (rc *RaceControl) startSessionCountdown() {
+	rc.sessionCountdownMutex.Lock()
+	defer rc.sessionCountdownMutex.Unlock()
+
+	if rc.sessionCountdownCfn != nil {
+		rc.sessionCountdownCfn()
+	}
+
+	ctx, cfn := context.WithCancel(context.Background())
+	rc.sessionCountdownCfn = cfn
+	interval := rc.sessionCountdownInterval
+
+	go panicCapture(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if _, err := rc.broadcaster.Send(rc.sessionCountdown(now)); err != nil {
+					logrus.WithError(err).Errorf("Could not broadcast session countdown")
+				}
+			}
+		}
+	})
+}
+
+// stopSessionCountdown stops broadcasting the session countdown.
+func (rc *RaceControl) stopSessionCountdown() {
+	rc.sessionCountdownMutex.Lock()
+	defer rc.sessionCountdownMutex.Unlock()
+
+	if rc.sessionCountdownCfn != nil {
+		rc.sessionCountdownCfn()
+		rc.sessionCountdownCfn = nil
+	}
+}
+
+// isRaceSession is true if the current session is a race.
+func (rc *RaceControl) isRaceSession() bool {
+	return rc.SessionInfo.Type == udp.SessionTypeRace
+}
+
+// clearAllDrivers removes all known information about connected and disconnected drivers from RaceControl
+func (rc *RaceControl) clearAllDrivers() {
+	rc.ConnectedDrivers = NewDriverMap(ConnectedDrivers, rc.SortDrivers)
+	rc.ConnectedDrivers.recordPositionHistory = rc.isRaceSession
+	rc.DisconnectedDrivers = NewDriverMap(DisconnectedDrivers, rc.SortDrivers)
+	rc.carIDToGUIDMutex.Lock()
+	rc.CarIDToGUID = make(map[udp.CarID]udp.DriverGUID)
+	rc.carIDToGUIDMutex.Unlock()
+}
+
+const (
+	// sessionInfoRequestInterval is used if SessionInfoInterval is not set. Configured intervals shorter than
+	// minSessionInfoRequestInterval are raised to it, to avoid flooding the server with requests.
+	sessionInfoRequestInterval    = time.Second * 30
+	minSessionInfoRequestInterval = time.Second * 5
+
+	// if the UDP connection is unavailable, session info requests back off (doubling the interval each time) up to
+	// defaultSessionInfoRequestMaxBackoff, then keep retrying at that interval until the server process stops.
+	defaultSessionInfoRequestMaxBackoff = time.Minute * 5
+)
+
+// updateSessionInfoInterval sets the session info request interval from the server options, and tells the request loop
+// if it has changed.
+func (rc *RaceControl) updateSessionInfoInterval() {
+	interval := sessionInfoRequestInterval
+
+	if seconds := rc.cachedServerOptions().SessionInfoInterval; seconds > 0 {
+		interval = time.Duration(seconds) * time.Second
+
+		if interval < minSessionInfoRequestInterval {
+			logrus.Warnf("Session info interval of %s is too short, using %s", interval, minSessionInfoRequestInterval)
+			interval = minSessionInfoRequestInterval
+		}
+	}
+
+	rc.sessionInfoIntervalMutex.Lock()
+	changed := interval != rc.sessionInfoInterval
+	rc.sessionInfoInterval = interval
+	rc.sessionInfoIntervalMutex.Unlock()
+
+	if changed {
+		select {
+		case rc.sessionInfoIntervalChanged <- struct{}{}:
+		default:
+			// the request loop has already been told about a change, which it will pick up the latest interval from.
+		}
+	}
+}
+
+// currentSessionInfoInterval is how often session info is currently requested from the server.
+func (rc *RaceControl) currentSessionInfoInterval() time.Duration {
+	rc.sessionInfoIntervalMutex.Lock()
+	defer rc.sessionInfoIntervalMutex.Unlock()
+
+	if rc.sessionInfoInterval <= 0 {
+		return sessionInfoRequestInterval
+	}
+
+	return rc.sessionInfoInterval
+}
+
+// requestSessionInfo sends a request every session info interval to get information about temps, etc in the session.
+func (rc *RaceControl) requestSessionInfo() {
+	interval := rc.currentSessionInfoInterval()
+	sessionInfoTicker := time.NewTicker(interval)
+	numFailedRequests := 0
+
+	for {
+		select {
+		case <-sessionInfoTicker.C:
+			err := rc.process.SendUDPMessage(udp.GetSessionInfo{})
+
+			if err == ErrNoOpenUDPConnection {
+				numFailedRequests++
+
+				if interval >= rc.sessionInfoMaxBackoff {
+					logrus.WithError(err).Warnf("Couldn't send session info udp request after %d attempts. Retrying in %s", numFailedRequests, interval)
+					continue
+				}
+
+				interval *= 2
+
+				if interval > rc.sessionInfoMaxBackoff {
+					interval = rc.sessionInfoMaxBackoff
+				}
+
+				logrus.WithError(err).Warnf("Couldn't send session info udp request. Retrying in %s", interval)
+
+				sessionInfoTicker.Stop()
+				sessionInfoTicker = time.NewTicker(interval)
+			} else if err != nil {
+				logrus.WithError(err).Errorf("Couldn't send session info udp request")
+			} else if numFailedRequests > 0 {
+				numFailedRequests = 0
+				interval = rc.currentSessionInfoInterval()
+
+				logrus.Infof("UDP connection recovered, restarting session info requests every %s", interval)
+
+				sessionInfoTicker.Stop()
+				sessionInfoTicker = time.NewTicker(interval)
+			}
+
+		case <-rc.sessionInfoIntervalChanged:
+			if numFailedRequests > 0 {
+				// the new interval is picked up when the UDP connection recovers.
+				continue
+			}
+
+			interval = rc.currentSessionInfoInterval()
+
+			logrus.Debugf("Requesting session info every %s", interval)
+
+			sessionInfoTicker.Stop()
+			sessionInfoTicker = time.NewTicker(interval)
+
+		case <-rc.stopped:
+			sessionInfoTicker.Stop()
+
+			return
+
+		case <-rc.serverProcessStopped:
+			logrus.Debugf("Assetto Process completed. Disconnecting all connected drivers. Session done.")
+			sessionInfoTicker.Stop()
+			rc.closeLapCSV()
+
+			var drivers []*RaceControlDriver
+
+			rc.persistTimingData()
+
+			// the server has just stopped. send disconnect messages for all connected cars.
+			_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+				// Each takes a read lock, so we cannot call disconnectDriver (which takes a write lock) from inside it.
+				// we must instead append them to a slice and disconnect them outside the Each call.
+				drivers = append(drivers, driver)
+
+				return nil
+			})
+
+			for _, driver := range drivers {
+				// disconnect the driver
+				err := rc.disconnectDriver(driver)
+
+				if err != nil {
+					logrus.WithError(err).Errorf("Could not disconnect driver: %s (%s)", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID)
+					continue
+				}
+			}
+
+			if _, err := rc.broadcaster.Send(rc); err != nil {
+				logrus.WithError(err).Errorf("Couldn't broadcast race control")
+			}
+
+			return
+		}
+	}
+}
+
+func (rc *RaceControl) disconnectDriver(driver *RaceControlDriver) error {
+	driver.mutex.Lock()
+	carInfo := driver.CarInfo
+	carInfo.EventType = udp.EventConnectionClosed
+	driver.mutex.Unlock()
+
+	return rc.OnClientDisconnect(carInfo)
+}
+
+// ForceDisconnect disconnects a connected driver, e.g. a driver who is stuck in Live Timings because the server never
+// sent a connection closed message for them. Drivers in their disconnect grace period are moved straight away.
+// Disconnecting a driver takes a write lock on ConnectedDrivers, so ForceDisconnect must not be called from within
+// ConnectedDrivers.Each.
+func (rc *RaceControl) ForceDisconnect(driverGUID udp.DriverGUID) error {
+	driver, ok := rc.ConnectedDrivers.Get(driverGUID)
+
+	if !ok {
+		return fmt.Errorf("racecontrol: driver is not connected: %s", driverGUID)
+	}
+
+	logrus.Infof("Forcing driver: %s (%s) to disconnect", driver.CarInfo.DriverName, driverGUID)
+
+	if !driver.Copy().Stale {
+		if err := rc.disconnectDriver(driver); err != nil {
+			return err
+		}
+	}
+
+	if rc.finishPendingDisconnect(driverGUID) {
+		// the driver was in their grace period, and the updated drivers have already been broadcast
+		return nil
+	}
+
+	_, err := rc.broadcaster.Send(rc)
+
+	return err
+}
+
+// SetManualPosition pins a driver to a position in Live Timings until ClearManualPositions is called or the session
+// ends, e.g. while race control resolves a dispute. The other drivers are sorted around them. Manual positions only
+// change what is shown in Live Timings, they are not applied to the session results.
+func (rc *RaceControl) SetManualPosition(driverGUID udp.DriverGUID, position int) error {
+	if position < 1 {
+		return fmt.Errorf("racecontrol: manual position must be at least 1, got %d", position)
+	}
+
+	for _, driverMap := range []*DriverMap{rc.ConnectedDrivers, rc.DisconnectedDrivers} {
+		driver, ok := driverMap.Get(driverGUID)
+
+		if !ok {
+			continue
+		}
+
+		logrus.Infof("Setting manual position of driver: %s (%s) to %d", driver.CarInfo.DriverName, driverGUID, position)
+
+		driver.mutex.Lock()
+		driver.ManualPosition = &position
+		driver.mutex.Unlock()
+
+		driverMap.sortByPosition()
+
+		_, err := rc.broadcaster.Send(rc)
+
+		return err
+	}
+
+	return fmt.Errorf("racecontrol: driver not found: %s", driverGUID)
+}
+
+// ClearManualPositions removes all manual positions set by SetManualPosition, returning Live Timings to automatic
+// sorting.
+func (rc *RaceControl) ClearManualPositions() error {
+	for _, driverMap := range []*DriverMap{rc.ConnectedDrivers, rc.DisconnectedDrivers} {
+		_ = driverMap.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+			driver.mutex.Lock()
+			defer driver.mutex.Unlock()
+
+			driver.ManualPosition = nil
+
+			return nil
+		})
+
+		driverMap.sortByPosition()
+	}
+
+	_, err := rc.broadcaster.Send(rc)
+
+	return err
+}
+
+// OnSessionUpdate is called every sessionRequestInterval.
+func (rc *RaceControl) OnSessionUpdate(sessionInfo udp.SessionInfo) (bool, error) {
+	oldSessionInfo := rc.SessionInfo
+
+	// we can't just copy over the session information, we must copy individual
+	// parts of it, as the session type is incorrect.
+	rc.sessionInfoMutex.Lock()
+	rc.SessionInfo.AmbientTemp = sessionInfo.AmbientTemp
+	rc.SessionInfo.RoadTemp = sessionInfo.RoadTemp
+	rc.SessionInfo.WeatherGraphics = sessionInfo.WeatherGraphics
+	rc.SessionInfo.ElapsedMilliseconds = sessionInfo.ElapsedMilliseconds
+	rc.sessionInfoUpdatedAt = time.Now()
+	rc.sessionInfoMutex.Unlock()
+
+	rc.recordTemperatures(sessionInfo)
+
+	sessionHasChanged := oldSessionInfo.AmbientTemp != rc.SessionInfo.AmbientTemp || oldSessionInfo.RoadTemp != rc.SessionInfo.RoadTemp || oldSessionInfo.WeatherGraphics != rc.SessionInfo.WeatherGraphics
+
+	return sessionHasChanged, nil
+}
+
+// OnEndSession is called at the end of every session.
+func (rc *RaceControl) OnEndSession(sessionFile udp.EndSession) error {
+	filename := filepath.Base(string(sessionFile))
+	logrus.Infof("End Session, file outputted at: %s", filename)
+
+	rc.stopSessionCountdown()
+	rc.sendWebhook(WebhookEventEndSession, WebhookEndSession{SessionInfo: rc.SessionInfo, ResultsFile: filename})
+
+	config := rc.process.Event().GetRaceConfig()
+
+	if config.DriverSwapEnabled == 1 {
+		rc.cancelDriverSwaps()
+
+		if config.DriverSwapMinimumNumberOfSwaps > 0 && config.DriverSwapEnabledForSession(sessionTypeFromUDP(rc.SessionInfo.Type)) {
+			results, err := LoadResult(filename, LoadResultWithoutPluginFire)
+
+			if err != nil {
+				logrus.WithError(err).Errorf("Could not load results file to check min driver swaps")
+			} else {
+				for _, result := range results.Result {
+					numSwaps := results.NumberOfDriverSwaps(result.CarID)
+
+					if numSwaps < config.DriverSwapMinimumNumberOfSwaps {
+						penaltyTime := time.Duration((config.DriverSwapMinimumNumberOfSwaps-numSwaps)*config.DriverSwapNotEnoughSwapsPenalty) * time.Second
+
+						rc.AddSessionPenalty(udp.DriverGUID(result.DriverGUID), result.CarModel, penaltyTime)
+					}
+				}
+			}
+		}
+	}
+
+	if err := rc.applySessionPenalties(filename); err != nil {
+		logrus.WithError(err).Errorf("Could not apply session penalties to results file: %s", filename)
+	}
+
+	if rc.SessionInfo.Type == udp.SessionTypeQualifying {
+		rc.announcePolePosition()
+	}
+
+	if rc.currentTimeAttackEvent != nil && Premium() {
+		filename := filepath.Base(string(sessionFile))
+
+		err := rc.addFileToTimeAttackEvent(filename)
+
+		if err != nil {
+			return err
+		}
+
+		logrus.Infof("Time Attack Event (%s) Finished, results files have been combined and saved as %s", rc.currentTimeAttackEvent.EventName(), filename)
+	}
+
+	return nil
+}
+
+// FrontRowEntry is a driver who qualified on the front row of the grid.
+type FrontRowEntry struct {
+	DriverGUID udp.DriverGUID `json:"DriverGUID"`
+	DriverName string         `json:"DriverName"`
+	CarName    string         `json:"CarName"`
+	BestLap    time.Duration  `json:"BestLap"`
+	GapToPole  time.Duration  `json:"GapToPole"`
+}
+
+// PoleAnnouncement is sent at the end of a qualifying session, with the drivers who qualified on the front row.
+type PoleAnnouncement struct {
+	FrontRow []FrontRowEntry `json:"FrontRow"`
+}
+
+func (PoleAnnouncement) Event() udp.Event {
+	return EventPole
+}
+
+func (p PoleAnnouncement) String() string {
+	if len(p.FrontRow) == 0 {
+		return ""
+	}
+
+	pole := p.FrontRow[0]
+	announcement := fmt.Sprintf("Pole position: %s, %s.", pole.DriverName, formatDuration(pole.BestLap, true))
+
+	if len(p.FrontRow) > 1 {
+		announcement += fmt.Sprintf(" Also on the front row: %s, +%.3fs.", p.FrontRow[1].DriverName, p.FrontRow[1].GapToPole.Seconds())
+	}
+
+	return announcement
+}
+
+// PoleAnnouncement builds the front row of the grid from the qualifying classification of all drivers in the session.
+func (rc *RaceControl) PoleAnnouncement() PoleAnnouncement {
+	minValidLaps := rc.cachedServerOptions().QualifyingMinValidLaps
+
+	var drivers []*RaceControlDriver
+
+	for _, driver := range rc.AllLapTimes() {
+		if qualifyingBestLap(driver.CurrentCar(), minValidLaps) > 0 {
+			drivers = append(drivers, driver)
+		}
+	}
+
+	sort.SliceStable(drivers, func(i, j int) bool {
+		return sortDriversForSessionType(udp.SessionTypeQualifying, minValidLaps, ConnectedDrivers, drivers[i], drivers[j])
+	})
+
+	announcement := PoleAnnouncement{}
+
+	for i, driver := range drivers {
+		if i >= 2 {
+			break
+		}
+
+		bestLap := driver.CurrentCar().BestLap
+
+		entry := FrontRowEntry{
+			DriverGUID: driver.CarInfo.DriverGUID,
+			DriverName: driver.CarInfo.DriverName,
+			CarName:    driver.CurrentCar().CarName,
+			BestLap:    bestLap,
+		}
+
+		if i > 0 {
+			entry.GapToPole = bestLap - announcement.FrontRow[0].BestLap
+		}
+
+		announcement.FrontRow = append(announcement.FrontRow, entry)
+	}
+
+	return announcement
+}
+
+// announcePolePosition broadcasts the front row of the grid to Live Timings and, if enabled and it is not within the
+// quiet hours, in chat.
+func (rc *RaceControl) announcePolePosition() {
+	announcement := rc.PoleAnnouncement()
+
+	if len(announcement.FrontRow) == 0 {
+		return
+	}
+
+	if _, err := rc.broadcaster.Send(announcement); err != nil {
+		logrus.WithError(err).Errorf("Could not broadcast pole announcement")
+	}
+
+	if !rc.cachedServerOptions().AnnouncePolePosition || rc.inQuietHours(time.Now()) {
+		return
+	}
+
+	if err := rc.splitAndBroadcastChat(announcement.String(), nil); err != nil {
+		logrus.WithError(err).Errorf("Could not send pole announcement in chat")
+	}
+}
+
+const timeAttackSuffix = "-time-attack"
+
+func (rc *RaceControl) addFileToTimeAttackEvent(file string) error {
+	logrus.Info("Time Attack event completed, combining with any previous results")
+
+	results, err := LoadResult(file)
+
+	if err != nil {
+		logrus.WithError(err).Errorf("Could not read session results: %s", file)
+		return err
+	}
+
+	var resultsArray []*SessionResults
+
+	resultsArray = append(resultsArray, results)
+
+	if rc.currentTimeAttackEvent.TimeAttackCombinedResultFile != "" {
+		result, err := LoadResult(rc.currentTimeAttackEvent.TimeAttackCombinedResultFile + ".json")
+
+		if err != nil {
+			logrus.WithError(err).Errorf("Could not read session results: %s", file)
+			return err
+		}
+
+		resultsArray = append(resultsArray, result)
+	}
+
+	results = combineResults(resultsArray)
+
+	results.FallBackSort()
+	results.ClearKickedGUIDs()
+	results.NormaliseCarIDs()
+
+	if !strings.HasSuffix(results.SessionFile, timeAttackSuffix) {
+		results.SessionFile = results.SessionFile + timeAttackSuffix
+	}
+
+	results.Date = time.Now()
+
+	err = saveResults(results.SessionFile+".json", results)
+
+	if err != nil {
+		return err
+	}
+
+	rc.currentTimeAttackEvent.TimeAttackCombinedResultFile = results.SessionFile
+
+	return rc.store.UpsertCustomRace(rc.currentTimeAttackEvent)
+}
+
+// OnClientConnect stores CarID -> DriverGUID mappings. if a driver is known to have previously been in this event,
+// they will be moved from DisconnectedDrivers to ConnectedDrivers.
+func (rc *RaceControl) OnClientConnect(client udp.SessionCarInfo) error {
+	rc.carIDToGUIDMutex.Lock()
+	rc.CarIDToGUID[client.CarID] = client.DriverGUID
+	rc.carIDToGUIDMutex.Unlock()
+
+	client.DriverInitials = driverInitials(client.DriverName)
+	client.DriverName = driverName(client.DriverName)
+	client.CarName = prettifyName(client.CarModel, true)
+
+	rc.sendWebhook(WebhookEventClientConnect, client)
+
+	// a driver who reconnects in their disconnect grace period is still in the connected drivers. Any other driver
+	// waiting out their grace period in this car has been replaced, so they are moved straight away.
+	reconnectedInGracePeriod := rc.cancelPendingDisconnect(client.DriverGUID)
+	rc.finishPendingDisconnectsForCar(client.CarID)
+
+	var driver *RaceControlDriver
+
+	// a driver who is already connected keeps the car they connected in, so that a change of car without
+	// disconnecting can be detected.
+	alreadyConnected := false
+
+	if disconnectedDriver, ok := rc.DisconnectedDrivers.Get(client.DriverGUID); ok {
+		driver = disconnectedDriver
+		logrus.Debugf("Driver %s (%s) reconnected in %s (car id: %d)", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, driver.CarInfo.CarModel, client.CarID)
+		rc.DisconnectedDrivers.Del(client.DriverGUID)
+	} else {
+		if connectedDriver, ok := rc.ConnectedDrivers.Get(client.DriverGUID); ok && reconnectedInGracePeriod {
+			driver = connectedDriver
+			logrus.Debugf("Driver %s (%s) reconnected within their disconnect grace period in %s (car id: %d)", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, driver.CarInfo.CarModel, client.CarID)
+		} else if ok {
+			driver = connectedDriver
+			alreadyConnected = true
+			logrus.Debugf("Driver %s (%s) reconnected (but was already connected...) in %s (car id: %d)", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, driver.CarInfo.CarModel, client.CarID)
+		} else if namedDriver, ok := rc.findDisconnectedDriverByName(client.DriverName, client.CarID); ok && rc.cachedServerOptions().ReconnectDriversByName {
+			driver = namedDriver
+			logrus.Infof("Driver %s reconnected with a new GUID (%s, was %s), restoring their laps (car id: %d)", client.DriverName, client.DriverGUID, driver.CarInfo.DriverGUID, client.CarID)
+			rc.DisconnectedDrivers.Del(driver.CarInfo.DriverGUID)
+		} else {
+			driver = NewRaceControlDriver(client)
+			logrus.Debugf("Driver %s (%s) connected in %s (car id: %d)", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, driver.CarInfo.CarModel, client.CarID)
+		}
+	}
+
+	driver.mutex.Lock()
+	driver.CarInfo = client
+	driver.Stale = false
+	driver.LapsDownAtDisconnect = 0
+
+	if !alreadyConnected || driver.establishedCarModel == "" {
+		driver.establishedCarModel = client.CarModel
+	}
+
+	driver.addCar(driver.CarInfo.CarModel)
+	rc.setDeltaToRecord(driver)
+
+	driver.PersonalTrackBest = rc.personalTrackBest(client.DriverGUID, client.CarModel)
+	driver.updateBeatPersonalTrackBest()
+
+	driver.ConnectedTime = time.Now()
+	driver.LastSeen = time.Time{}
+	driver.CurrentCar().LastLapCompletedTime = time.Now()
+	driver.ModelMismatch = rc.isCarModelMismatch(client.CarModel)
+	driver.TeamName = rc.teamNameForCar(client.CarID)
+
+	connectedTime := driver.ConnectedTime
+
+	rc.ConnectedDrivers.add(driver.CarInfo.DriverGUID, driver)
+	driver.mutex.Unlock()
+
+	// the drivers are sorted once the driver's lock has been released, as sorting takes each driver's lock.
+	rc.ConnectedDrivers.sortByPosition()
+
+	if serverOptions := rc.cachedServerOptions(); serverOptions.JoinSpamMaxConnections > 0 {
+		window := time.Duration(serverOptions.JoinSpamWindowMinutes) * time.Minute
+
+		if window <= 0 {
+			window = defaultJoinSpamWindow
+		}
+
+		if rc.recordConnection(client.DriverGUID, connectedTime, window) > serverOptions.JoinSpamMaxConnections {
+			rc.handleJoinSpam(client, serverOptions.JoinSpamAction)
+		}
+	}
+
+	_, err := rc.broadcaster.Send(client)
+
+	return err
+}
+
+// lapPhase determines the phase of the lap that a driver is currently on. A driver is on an out lap until they
+// complete a lap after leaving the pits, then on a flying lap. Once the session is over (for them), they are on
+// an in lap. The caller must hold the driver's lock.
+func (rc *RaceControl) lapPhase(driver *RaceControlDriver) LapPhase {
+	switch {
+	case driver.pitExitTime.IsZero():
+		return LapPhaseOnTrack
+	case rc.sessionOverForDriver(driver):
+		return LapPhaseInLap
+	case driver.lapsSincePitExit == 0:
+		return LapPhaseOutLap
+	default:
+		return LapPhaseFlying
+	}
+}
+
+// sessionOverForDriver determines whether a driver has finished the current session, either by completing all of
+// its laps or by starting a lap after its time has run out. The caller must hold the driver's lock.
+func (rc *RaceControl) sessionOverForDriver(driver *RaceControlDriver) bool {
+	if isUnlimitedSession(rc.SessionInfo) {
+		return false
+	}
+
+	if rc.SessionInfo.Laps > 0 {
+		return driver.TotalNumLaps >= int(rc.SessionInfo.Laps)
+	}
+
+	if rc.SessionStartTime.IsZero() {
+		return false
+	}
+
+	sessionEnd := rc.SessionStartTime.Add(time.Duration(rc.SessionInfo.Time) * time.Minute)
+
+	if rc.SessionInfo.Type == udp.SessionTypeRace {
+		sessionEnd = sessionEnd.Add(time.Duration(rc.SessionInfo.WaitTime) * time.Second)
+	}
+
+	return time.Now().After(sessionEnd)
+}
+
+// checkForWrongCar flags a driver who has completed a lap in a different car to the one they connected in, and
+// gives them a penalty if one is configured. The caller must hold the driver's lock.
+func (rc *RaceControl) checkForWrongCar(driver *RaceControlDriver) {
+	serverOptions := rc.cachedServerOptions()
+
+	if !serverOptions.DetectCarContentSwaps || driver.WrongCar || driver.CarInfo.CarModel == driver.establishedCarModel {
+		return
+	}
+
+	driver.WrongCar = true
+
+	logrus.Warnf("Driver: %s (%s) completed a lap in %s, but connected in %s", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, driver.CarInfo.CarModel, driver.establishedCarModel)
+
+	if serverOptions.CarContentSwapPenalty <= 0 {
+		return
+	}
+
+	penalty := time.Duration(serverOptions.CarContentSwapPenalty) * time.Second
+
+	rc.AddSessionPenalty(driver.CarInfo.DriverGUID, driver.CarInfo.CarModel, penalty)
+
+	sendChat, err := udp.NewSendChat(driver.CarInfo.CarID, fmt.Sprintf("You have been given a %s penalty for changing car without reconnecting", penalty))
+
+	if err == nil {
+		err = rc.process.SendUDPMessage(sendChat)
+	}
+
+	if err != nil {
+		logrus.WithError(err).Errorf("Unable to send wrong car penalty message to: %s", driver.CarInfo.DriverName)
+	}
+}
+
+// defaultCutPenalty is the time penalty given for reaching MaxCutsBeforePenalty, if CutPenalty is not set.
+const defaultCutPenalty = 5 * time.Second
+
+// checkCuts gives a driver a penalty each time their total cuts in their current car reach a multiple of
+// MaxCutsBeforePenalty, and warns them when they are one cut away from a penalty. lapCuts is the number of cuts in
+// the lap the driver has just completed. The caller must hold the driver's lock.
+func (rc *RaceControl) checkCuts(driver *RaceControlDriver, lapCuts int) {
+	serverOptions := rc.cachedServerOptions()
+
+	if serverOptions.MaxCutsBeforePenalty <= 0 || lapCuts <= 0 {
+		return
+	}
+
+	currentCar := driver.CurrentCar()
+
+	var message string
+
+	if numPenalties := currentCar.TotalCuts / serverOptions.MaxCutsBeforePenalty; numPenalties > currentCar.cutPenalties {
+		penalty := time.Duration(serverOptions.CutPenalty) * time.Second
+
+		if penalty <= 0 {
+			penalty = defaultCutPenalty
+		}
+
+		// a lap with a lot of cuts could reach more than one multiple, but it is only penalised once.
+		currentCar.cutPenalties = numPenalties
+
+		logrus.Infof("Driver: %s (%s) has %d cuts, giving a %s penalty", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, currentCar.TotalCuts, penalty)
+
+		rc.AddSessionPenalty(driver.CarInfo.DriverGUID, driver.CarInfo.CarModel, penalty)
+
+		message = fmt.Sprintf("You have been given a %s penalty for exceeding track limits (%d cuts)", penalty, currentCar.TotalCuts)
+	} else if serverOptions.MaxCutsBeforePenalty > 1 && currentCar.TotalCuts%serverOptions.MaxCutsBeforePenalty == serverOptions.MaxCutsBeforePenalty-1 {
+		message = fmt.Sprintf("Warning: you have %d cuts, your next cut will be penalised", currentCar.TotalCuts)
+	} else {
+		return
+	}
+
+	sendChat, err := udp.NewSendChat(driver.CarInfo.CarID, message)
+
+	if err == nil {
+		err = rc.process.SendUDPMessage(sendChat)
+	}
+
+	if err != nil {
+		logrus.WithError(err).Errorf("Unable to send track limits message to: %s", driver.CarInfo.DriverName)
+	}
+}
+
+// checkConsecutiveInvalidLaps warns a driver in chat each time their consecutive invalid laps reach a multiple of
+// MaxConsecutiveInvalidLaps.
+func (rc *RaceControl) checkConsecutiveInvalidLaps(driver *RaceControlDriver) {
+	maxInvalidLaps := rc.cachedServerOptions().MaxConsecutiveInvalidLaps
+
+	if maxInvalidLaps <= 0 || driver.ConsecutiveInvalidLaps == 0 || driver.ConsecutiveInvalidLaps%maxInvalidLaps != 0 {
+		return
+	}
+
+	message := fmt.Sprintf("Warning: your last %d laps have been invalid, please respect track limits", driver.ConsecutiveInvalidLaps)
+
+	sendChat, err := udp.NewSendChat(driver.CarInfo.CarID, message)
+
+	if err == nil {
+		err = rc.process.SendUDPMessage(sendChat)
+	}
+
+	if err != nil {
+		logrus.WithError(err).Errorf("Unable to send invalid laps message to: %s", driver.CarInfo.DriverName)
+	}
+}
+
+// isCarModelMismatch determines whether a car model is not one of the cars configured for the current event.
+// With a locked entry list the server only accepts configured cars, so there can be no mismatch.
+func (rc *RaceControl) isCarModelMismatch(carModel string) bool {
+	raceConfig := rc.process.Event().GetRaceConfig()
+
+	if raceConfig.LockedEntryList == 1 || raceConfig.Cars == "" {
+		return false
+	}
+
+	for _, car := range strings.Split(raceConfig.Cars, ";") {
+		if car == carModel {
+			return false
+		}
+	}
+
+	return true
+}
+
+// teamNameForCar finds the team of the entrant in the given car in the event's entry list. Car IDs are the index of
+// the car in the entry list.
+func (rc *RaceControl) teamNameForCar(carID udp.CarID) string {
+	entrant, ok := rc.process.Event().GetEntryList()[fmt.Sprintf("CAR_%d", carID)]
+
+	if !ok {
+		return ""
+	}
+
+	return entrant.Team
+}
+
+const defaultJoinSpamWindow = time.Minute * 5
+
+// recordConnection records that a driver connected at connectedTime, and returns the number of times the driver
+// has connected within the window up to connectedTime.
+func (rc *RaceControl) recordConnection(driverGUID udp.DriverGUID, connectedTime time.Time, window time.Duration) int {
+	rc.connectionTimesMutex.Lock()
+	defer rc.connectionTimesMutex.Unlock()
+
+	var connectionTimes []time.Time
+
+	for _, t := range rc.connectionTimes[driverGUID] {
+		if connectedTime.Sub(t) < window {
+			connectionTimes = append(connectionTimes, t)
+		}
+	}
+
+	connectionTimes = append(connectionTimes, connectedTime)
+	rc.connectionTimes[driverGUID] = connectionTimes
+
+	return len(connectionTimes)
+}
+
+// handleJoinSpam takes the configured action against a driver who is repeatedly connecting to the server.
+func (rc *RaceControl) handleJoinSpam(client udp.SessionCarInfo, action JoinSpamAction) {
+	logrus.Warnf("Driver %s (%s) is repeatedly connecting to the server (join spam)", client.DriverName, client.DriverGUID)
+
+	if action != JoinSpamActionKick {
+		return
+	}
+
+	command, err := udp.NewAdminCommand(fmt.Sprintf("/kick_id %d", client.CarID))
+
+	if err != nil {
+		logrus.WithError(err).Errorf("Could not build kick command for join spam")
+		return
+	}
+
+	if err := rc.process.SendUDPMessage(command); err != nil {
+		logrus.WithError(err).Errorf("Could not kick driver %s (%s) for join spam", client.DriverName, client.DriverGUID)
+	}
+}
+
+// OnClientDisconnect moves a client from ConnectedDrivers to DisconnectedDrivers.
+func (rc *RaceControl) OnClientDisconnect(client udp.SessionCarInfo) error {
+	if ch, ok := rc.carUpdaters[client.CarID]; ok && ch != nil {
+		delete(rc.carUpdaters, client.CarID)
+	}
+
+	driver, ok := rc.ConnectedDrivers.Get(client.DriverGUID)
+
+	if !ok {
+		return fmt.Errorf("racecontrol: client disconnected without ever being connected: %s (%s)", client.DriverName, client.DriverGUID)
+	}
+
+	rc.sendWebhook(WebhookEventClientDisconnect, client)
+
+	// the leader's laps are read before the driver's lock is taken, since every connected driver's lock is taken to
+	// find the leader.
+	leaderNumLaps := rc.leaderNumLaps()
+
+	driver.mutex.Lock()
+
+	logrus.Debugf("Driver %s (%s) disconnected", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID)
+
+	driver.endActiveInterval(time.Now())
+	driver.LoadedTime = time.Time{}
+
+	config := rc.process.Event().GetRaceConfig()
+	driverSwap := config.DriverSwapEnabledForSession(sessionTypeFromUDP(rc.SessionInfo.Type))
+	gracePeriod := time.Duration(rc.cachedServerOptions().DisconnectGracePeriod) * time.Second
+
+	moved := false
+
+	if gracePeriod > 0 && driver.TotalNumLaps > 0 && !driverSwap {
+		// keep the driver where they are in the standings for now, in case they are having connection problems.
+		driver.Stale = true
+		rc.schedulePendingDisconnect(driver.CarInfo.DriverGUID, driver.CarInfo.CarID, gracePeriod)
+	} else {
+		rc.moveToDisconnectedDrivers(driver, leaderNumLaps)
+		moved = true
+	}
+
+	// if this race has driver swaps enabled we should initialise one now
+	if driverSwap {
+		if rc.currentPitLaneArea() != nil && !driver.InPits {
+			// the car must be in the pits for a driver swap, not just stationary on track
+			logrus.Infof("Driver: %s disconnected outside of the pit lane, not starting a driver swap", driver.CarInfo.DriverGUID)
+		} else {
+			ctx := rc.registerDriverSwap(client.CarID, driver)
+
+			ticker := time.NewTicker(time.Second)
+
+			go rc.handleDriverSwap(ctx, ticker, config, client, driver)
+		}
+	}
+
+	driver.mutex.Unlock()
+
+	if moved {
+		rc.sortAfterDisconnect()
+	}
+
+	_, err := rc.broadcaster.Send(client)
+
+	return err
+}
+
+// sessionTypeFromUDP converts a session type reported by the server plugin into its equivalent SessionType.
+func sessionTypeFromUDP(sessionType udp.SessionType) SessionType {
+	for _, session := range AvailableSessions {
+		if session.String() == sessionType.String() {
+			return session
+		}
+	}
+
+	return SessionType(strings.ToUpper(sessionType.String()))
+}
+
+// moveToDisconnectedDrivers removes a driver from the connected drivers. Drivers who have completed laps are added to
+// the disconnected drivers. leaderNumLaps is the number of laps completed by the leader, read before the driver's lock
+// was taken. The caller must hold the driver's lock, and call sortAfterDisconnect once it has been released.
+func (rc *RaceControl) moveToDisconnectedDrivers(driver *RaceControlDriver, leaderNumLaps int) {
+	driver.LapsDownAtDisconnect = lapsDownToLeader(driver, leaderNumLaps)
+	rc.ConnectedDrivers.del(driver.CarInfo.DriverGUID, driver)
+
+	if driver.TotalNumLaps > 0 {
+		rc.DisconnectedDrivers.add(driver.CarInfo.DriverGUID, driver)
+	}
+}
+
+// sortAfterDisconnect sorts the connected and disconnected drivers after a driver has been moved between them by
+// moveToDisconnectedDrivers, and trims the disconnected drivers to MaxDisconnectedDrivers. The caller must not hold
+// the lock of any driver.
+func (rc *RaceControl) sortAfterDisconnect() {
+	rc.ConnectedDrivers.sortByPosition()
+	rc.DisconnectedDrivers.sortByPosition()
+	rc.trimDisconnectedDrivers(rc.cachedServerOptions().MaxDisconnectedDrivers)
+}
+
+// lapsDownToLeader is how many laps the driver's current car has completed fewer than the leader of the connected
+// drivers, who has completed leaderNumLaps. The leader's laps must be read before the driver's lock is taken, see
+// leaderNumLaps. The caller must hold the driver's lock.
+func lapsDownToLeader(driver *RaceControlDriver, leaderNumLaps int) int {
+	if driver.Position == 1 {
+		return 0
+	}
+
+	if lapsDown := leaderNumLaps - driver.CurrentCar().NumLaps; lapsDown > 0 {
+		return lapsDown
+	}
+
+	return 0
+}
+
+// pendingDisconnect is a driver in their disconnect grace period.
+type pendingDisconnect struct {
+	timer *time.Timer
+	carID udp.CarID
+}
+
+// schedulePendingDisconnect moves a driver to the disconnected drivers once their grace period has passed, unless
+// they reconnect first.
+func (rc *RaceControl) schedulePendingDisconnect(driverGUID udp.DriverGUID, carID udp.CarID, gracePeriod time.Duration) {
+	rc.pendingDisconnectsMutex.Lock()
+	defer rc.pendingDisconnectsMutex.Unlock()
+
+	if existing, ok := rc.pendingDisconnects[driverGUID]; ok {
+		existing.timer.Stop()
+	}
+
+	rc.pendingDisconnects[driverGUID] = &pendingDisconnect{
+		timer: time.AfterFunc(gracePeriod, func() {
+			rc.finishPendingDisconnect(driverGUID)
+		}),
+		carID: carID,
+	}
+}
+
+// cancelPendingDisconnect stops a driver's disconnect grace period, returning true if they were in one.
+func (rc *RaceControl) cancelPendingDisconnect(driverGUID udp.DriverGUID) bool {
+	rc.pendingDisconnectsMutex.Lock()
+	defer rc.pendingDisconnectsMutex.Unlock()
+
+	pending, ok := rc.pendingDisconnects[driverGUID]
+
+	if !ok {
+		return false
+	}
+
+	pending.timer.Stop()
+	delete(rc.pendingDisconnects, driverGUID)
+
+	return true
+}
+
+// finishPendingDisconnect ends a driver's disconnect grace period, moving them to the disconnected drivers. It returns
+// true if the driver was moved.
+func (rc *RaceControl) finishPendingDisconnect(driverGUID udp.DriverGUID) bool {
+	if !rc.cancelPendingDisconnect(driverGUID) {
+		// the driver has reconnected, or has already been moved
+		return false
+	}
+
+	driver, ok := rc.ConnectedDrivers.Get(driverGUID)
+
+	if !ok {
+		return false
+	}
+
+	leaderNumLaps := rc.leaderNumLaps()
+
+	driver.mutex.Lock()
+
+	if !driver.Stale {
+		driver.mutex.Unlock()
+		return false
+	}
+
+	logrus.Debugf("Driver %s (%s) did not reconnect within their disconnect grace period", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID)
+
+	driver.Stale = false
+	rc.moveToDisconnectedDrivers(driver, leaderNumLaps)
+
+	driver.mutex.Unlock()
+
+	rc.sortAfterDisconnect()
+
+	if _, err := rc.broadcaster.Send(rc); err != nil {
+		logrus.WithError(err).Error("Could not broadcast driver disconnect message")
+	}
+
+	return true
+}
+
+// finishPendingDisconnectsForCar ends the disconnect grace period of any drivers who disconnected from the car.
+func (rc *RaceControl) finishPendingDisconnectsForCar(carID udp.CarID) {
+	var driverGUIDs []udp.DriverGUID
+
+	rc.pendingDisconnectsMutex.Lock()
+
+	for driverGUID, pending := range rc.pendingDisconnects {
+		if pending.carID == carID {
+			driverGUIDs = append(driverGUIDs, driverGUID)
+		}
+	}
+
+	rc.pendingDisconnectsMutex.Unlock()
+
+	for _, driverGUID := range driverGUIDs {
+		rc.finishPendingDisconnect(driverGUID)
+	}
+}
+
+// disconnectedDriverActivity is when a disconnected driver was last active, for trimDisconnectedDrivers.
+type disconnectedDriverActivity struct {
+	driverGUID udp.DriverGUID
+	driverName string
+	lastActive time.Time
+}
+
+// trimDisconnectedDrivers removes the least recently active disconnected drivers until there are at most limit
+// disconnected drivers. Drivers who have set a time in a qualifying session are never removed. The caller must not
+// hold the lock of any driver.
+func (rc *RaceControl) trimDisconnectedDrivers(limit int) {
+	if limit <= 0 || rc.DisconnectedDrivers.Len() <= limit {
+		return
+	}
+
+	// the candidates are copied under their locks, so that they can be sorted without holding them.
+	var candidates []disconnectedDriverActivity
+
+	_ = rc.DisconnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
+
+		if rc.SessionInfo.Type == udp.SessionTypeQualifying && driver.CurrentCar().BestLap > 0 {
+			return nil
+		}
+
+		candidates = append(candidates, disconnectedDriverActivity{
+			driverGUID: driver.CarInfo.DriverGUID,
+			driverName: driver.CarInfo.DriverName,
+			lastActive: driver.lastActive(),
+		})
+
+		return nil
+	})
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].lastActive.Before(candidates[j].lastActive)
+	})
+
+	numToRemove := rc.DisconnectedDrivers.Len() - limit
+
+	for i := 0; i < numToRemove && i < len(candidates); i++ {
+		logrus.Debugf("Removing driver: %s (%s) from disconnected drivers, limit of %d reached", candidates[i].driverName, candidates[i].driverGUID, limit)
+
+		rc.DisconnectedDrivers.Del(candidates[i].driverGUID)
+	}
+}
+
+type sessionPenalty struct {
+	penalty  time.Duration
+	carModel string
+
+	disqualified           bool
+	disqualificationReason string
+	disqualificationTime   time.Time
+}
+
+// AddSessionPenalty gives a driver a time penalty, which is added to any other penalties they receive this session.
+// Session penalties are applied to the results file at the end of the session.
+func (rc *RaceControl) AddSessionPenalty(driverGUID udp.DriverGUID, carModel string, penalty time.Duration) {
+	rc.sessionPenaltiesMutex.Lock()
+	defer rc.sessionPenaltiesMutex.Unlock()
+
+	if rc.sessionPenalties == nil {
+		rc.sessionPenalties = make(map[udp.DriverGUID]*sessionPenalty)
+	}
+
+	if existingPenalty, ok := rc.sessionPenalties[driverGUID]; ok {
+		existingPenalty.penalty += penalty
+	} else {
+		rc.sessionPenalties[driverGUID] = &sessionPenalty{
+			penalty:  penalty,
+			carModel: carModel,
+		}
+	}
+}
+
+// AddSessionDisqualification disqualifies a driver from the session, with the reason and the time that it happened.
+// The disqualification is written to the results file at the end of the session, in place of any time penalties.
+func (rc *RaceControl) AddSessionDisqualification(driverGUID udp.DriverGUID, carModel string, reason string) {
+	rc.sessionPenaltiesMutex.Lock()
+	defer rc.sessionPenaltiesMutex.Unlock()
+
+	if rc.sessionPenalties == nil {
+		rc.sessionPenalties = make(map[udp.DriverGUID]*sessionPenalty)
+	}
+
+	penalty, ok := rc.sessionPenalties[driverGUID]
+
+	if !ok {
+		penalty = &sessionPenalty{carModel: carModel}
+		rc.sessionPenalties[driverGUID] = penalty
+	}
+
+	if penalty.disqualified {
+		// keep the reason for the first disqualification
+		return
+	}
+
+	penalty.disqualified = true
+	penalty.disqualificationReason = reason
+	penalty.disqualificationTime = time.Now()
+}
+
+// applySessionPenalties applies all of the penalties accrued this session to the results file in one pass.
+func (rc *RaceControl) applySessionPenalties(filename string) error {
+	rc.sessionPenaltiesMutex.Lock()
+	defer rc.sessionPenaltiesMutex.Unlock()
+
+	if len(rc.sessionPenalties) == 0 {
+		return nil
+	}
+
+	var penalties []resultPenalty
+
+	for guid, penalty := range rc.sessionPenalties {
+		if penalty.disqualified {
+			penalties = append(penalties, resultPenalty{
+				GUID:     string(guid),
+				CarModel: penalty.carModel,
+				Add:      true,
+				Reason:   penalty.disqualificationReason,
+				Time:     penalty.disqualificationTime,
+			})
+
+			continue
+		}
+
+		penalties = append(penalties, resultPenalty{
+			GUID:     string(guid),
+			CarModel: penalty.carModel,
+			Penalty:  penalty.penalty.Seconds(),
+			Add:      true,
+		})
+	}
+
+	return rc.penaltiesManager.applyPenalties(filename, penalties)
+}
+
+// registerDriverSwap records that a driver swap is starting for a car, cancelling any swap that is already running
+// for it so that only one driver swap can run per car. Swaps are only ever cancelled with the driver's driverSwapCfn.
+// The returned context is the driver's driverSwapContext, which must be passed to handleDriverSwap.
+func (rc *RaceControl) registerDriverSwap(carID udp.CarID, driver *RaceControlDriver) context.Context {
+	rc.driverSwapsMutex.Lock()
+	defer rc.driverSwapsMutex.Unlock()
+
+	if previousDriver, ok := rc.driverSwaps[carID]; ok {
+		logrus.Infof("Cancelling active driver swap for car: %d. Reason: A new driver swap has started", carID)
+		previousDriver.driverSwapCfn()
+	}
+
+	if driver.driverSwapCfn != nil {
+		// the driver may already be swapping out of another car.
+		driver.driverSwapCfn()
+
+		for otherCarID, otherDriver := range rc.driverSwaps {
+			if otherDriver == driver {
+				delete(rc.driverSwaps, otherCarID)
+			}
+		}
+	}
+
+	driver.driverSwapContext, driver.driverSwapCfn = context.WithCancel(context.Background())
+	rc.driverSwaps[carID] = driver
+
+	return driver.driverSwapContext
+}
+
+// finishDriverSwap cancels the driver's swap and removes it from the active driver swaps, unless another swap has
+// since replaced it.
+func (rc *RaceControl) finishDriverSwap(carID udp.CarID, driver *RaceControlDriver, ctx context.Context) {
+	rc.driverSwapsMutex.Lock()
+	defer rc.driverSwapsMutex.Unlock()
+
+	if driver.driverSwapContext != ctx {
+		// a newer swap has replaced this one, and cancelled it when doing so.
+		return
+	}
+
+	driver.driverSwapCfn()
+	driver.driverSwapContext, driver.driverSwapCfn = nil, nil
+
+	if rc.driverSwaps[carID] == driver {
+		delete(rc.driverSwaps, carID)
+	}
+}
+
+// cancelDriverSwaps stops every driver swap in progress, e.g. at the end of a session.
+func (rc *RaceControl) cancelDriverSwaps() {
+	rc.driverSwapsMutex.Lock()
+	defer rc.driverSwapsMutex.Unlock()
+
+	for _, driver := range rc.driverSwaps {
+		logrus.Infof("Cancelling active driver swap for driver: %s. Reason: Session ended", driver.CarInfo.DriverGUID)
+		driver.driverSwapCfn()
+	}
+}
+
+func (rc *RaceControl) handleDriverSwap(ctx context.Context, ticker *time.Ticker, config CurrentRaceConfig, client udp.SessionCarInfo, driver *RaceControlDriver) {
+	defer func() {
+		ticker.Stop()
+		rc.finishDriverSwap(client.CarID, driver, ctx)
+	}()
+
+	var (
+		totalTime           time.Duration
+		newDriverConnected  bool
+		firstPositionUpdate bool
+		resumeSwap          bool
+	)
+
+	completeTime := time.Second * time.Duration(config.DriverSwapMinTime)
+	initialGUID := client.DriverGUID
+	currentDriver := driver
+	position := currentDriver.LastPos
+
+	logrus.Infof(
+		"Driver: %s has initiated a driver swap, disconnected in position: %.2f, %.2f, %.2f. Next driver is expected to connect in the same position for a driver swap!",
+		currentDriver.CarInfo.DriverGUID,
+		currentDriver.LastPos.X,
+		currentDriver.LastPos.Y,
+		currentDriver.LastPos.Z,
+	)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			totalTime += time.Second
+
+			countdown := completeTime - totalTime
 
 			if !newDriverConnected {
 				reconnect := false
 
-				_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
-					if driver.CarInfo.CarID == currentDriver.CarInfo.CarID {
-						if driver.CarInfo.DriverGUID != currentDriver.CarInfo.DriverGUID {
-							if !driver.LoadedTime.IsZero() {
-								// new driver has connected in the same car
-								currentDriver = driver
-								newDriverConnected = true
+				_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+					if driver.CarInfo.CarID == currentDriver.CarInfo.CarID {
+						if driver.CarInfo.DriverGUID != currentDriver.CarInfo.DriverGUID {
+							if !driver.LoadedTime.IsZero() {
+								// new driver has connected in the same car
+								currentDriver = driver
+								newDriverConnected = true
+
+								logrus.Infof("Driver: %d (%s) has connected", currentDriver.CarInfo.CarID, currentDriver.CarInfo.DriverGUID)
+							}
+						} else {
+							// same driver reconnected
+							if resumeSwap {
+								logrus.Infof("Driver: %s has reconnected, driver swap still in progress", driver.CarInfo.DriverGUID)
+
+								currentDriver = driver
+								newDriverConnected = true
+								resumeSwap = false
+							} else {
+								logrus.Infof("Driver: %s has reconnected, driver swap aborted", initialGUID)
+								reconnect = true
+							}
+						}
+					}
+
+					return nil
+				})
+
+				if reconnect {
+					ticker.Stop()
+					return
+				}
+			} else {
+				if totalTime.Seconds() >= completeTime.Seconds() {
+					sendChat, err := udp.NewSendChat(currentDriver.CarInfo.CarID, "You are clear to leave the pits, go go go!")
+
+					if err == nil {
+						err := rc.process.SendUDPMessage(sendChat)
+
+						if err != nil {
+							logrus.WithError(err).Errorf("Unable to send driver swap clear to leave message to: %s", currentDriver.CarInfo.DriverName)
+						}
+					} else {
+						logrus.WithError(err).Errorf("Unable to build driver swap clear to leave message to: %s", currentDriver.CarInfo.DriverName)
+					}
+
+					logrus.Infof("Driver: %d has successfully completed their driver swap and is free to leave the pits", currentDriver.CarInfo.CarID)
+
+					ticker.Stop()
+					return
+				}
+
+				if !firstPositionUpdate {
+					nilVec := udp.Vec{X: 0, Y: 0, Z: 0}
+
+					if currentDriver.LastPos != nilVec {
+						sendChat, err := udp.NewSendChat(
+							currentDriver.CarInfo.CarID,
+							fmt.Sprintf(
+								"Hi! You are mid way through a driver swap, please wait %s before leaving the pits",
+								countdown.String(),
+							),
+						)
+
+						if err == nil {
+							err := rc.process.SendUDPMessage(sendChat)
+
+							if err != nil {
+								logrus.WithError(err).Errorf("Unable to send driver swap welcome message to: %s", currentDriver.CarInfo.DriverName)
+							}
+						} else {
+							logrus.WithError(err).Errorf("Unable to build driver swap welcome message to: %s", currentDriver.CarInfo.DriverName)
+						}
+
+						firstPositionUpdate = true
+					}
+				}
+
+				// if driver has moved
+				if positionHasChanged(position, currentDriver.LastPos, config.DriverSwapPositionThreshold()) && firstPositionUpdate {
+					// if the time is within the disqualify window
+					if countdown >= (time.Second * time.Duration(config.DriverSwapDisqualifyTime)) {
+						sendChat, err := udp.NewSendChat(
+							currentDriver.CarInfo.CarID,
+							fmt.Sprintf(
+								"You have been kicked from the session for leaving the pits %s early during a driver swap",
+								countdown.String(),
+							),
+						)
+
+						if err == nil {
+							err := rc.process.SendUDPMessage(sendChat)
+
+							if err != nil {
+								logrus.WithError(err).Errorf("Unable to send driver swap kicked message to: %s", currentDriver.CarInfo.DriverName)
+							}
+						} else {
+							logrus.WithError(err).Errorf("Unable to build driver swap kicked message to: %s", currentDriver.CarInfo.DriverName)
+						}
+
+						time.Sleep(5 * time.Second)
+
+						err = rc.process.SendUDPMessage(udp.NewKickUser(uint8(currentDriver.CarInfo.CarID)))
+
+						if err != nil {
+							logrus.WithError(err).Errorf("Unable to send kick command (driver swaps)")
+						} else {
+							logrus.Infof("Driver: %d has been kicked for leaving the pits %s early during a driver swap", currentDriver.CarInfo.CarID, countdown.String())
+						}
+
+						if rc.cachedServerOptions().DriverSwapDQInResults {
+							rc.AddSessionDisqualification(
+								currentDriver.CarInfo.DriverGUID,
+								currentDriver.CarInfo.CarModel,
+								fmt.Sprintf("Left the pits %s early during a driver swap", countdown.String()),
+							)
+						}
+
+						// don't stop the ticker, when the driver reconnects they should still have to wait
+						firstPositionUpdate = false
+						newDriverConnected = false
+						resumeSwap = true
+						currentDriver.LastPos = udp.Vec{X: 0, Y: 0, Z: 0}
+					} else if countdown >= (time.Second * time.Duration(config.DriverSwapPenaltyTime)) {
+
+						rc.AddSessionPenalty(currentDriver.CarInfo.DriverGUID, currentDriver.CarInfo.CarModel, countdown+(time.Second*5))
+
+						sendChat, err := udp.NewSendChat(
+							currentDriver.CarInfo.CarID,
+							fmt.Sprintf(
+								"You have been given a %s second penalty for leaving the pits %s early during a driver swap",
+								(countdown+(time.Second*5)).String(),
+								countdown.String(),
+							),
+						)
+
+						if err == nil {
+							err := rc.process.SendUDPMessage(sendChat)
+
+							if err != nil {
+								logrus.WithError(err).Errorf("Unable to send driver swap penalty message to: %s", currentDriver.CarInfo.DriverName)
+							}
+						} else {
+							logrus.WithError(err).Errorf("Unable to build driver swap penalty message to: %s", currentDriver.CarInfo.DriverName)
+						}
+
+						logrus.Infof(
+							"Driver: %d has been given a %s second penalty for leaving the pits %s early during a driver swap",
+							currentDriver.CarInfo.CarID,
+							(countdown + (time.Second * 5)).String(),
+							countdown.String(),
+						)
+
+						ticker.Stop()
+						return
+					}
+				}
+
+				// send countdown messages
+				countdownIntervals, countdownEvery := rc.driverSwapCountdown()
+
+				if firstPositionUpdate && shouldAnnounceDriverSwapCountdown(countdown, countdownIntervals, countdownEvery) {
+					sendChat, err := udp.NewSendChat(currentDriver.CarInfo.CarID, fmt.Sprintf("Free to leave pits in %s", countdown.String()))
+
+					if err == nil {
+						err := rc.process.SendUDPMessage(sendChat)
+
+						if err != nil {
+							logrus.WithError(err).Errorf("Unable to send driver swap countdown message to: %s", currentDriver.CarInfo.DriverName)
+						}
+					} else {
+						logrus.WithError(err).Errorf("Unable to build driver swap countdown message to: %s", currentDriver.CarInfo.DriverName)
+					}
+				}
+			}
+		}
+	}
+}
+
+const defaultDriverSwapMovementThreshold = 10.0
+
+const defaultDriverSwapCountdownIntervals = "60,30,10,5,3,2,1"
+
+// parseDriverSwapCountdownIntervals parses a comma separated list of seconds remaining in a driver swap at which
+// the countdown should be announced to the new driver. Invalid values are ignored, and an empty list uses the defaults.
+func parseDriverSwapCountdownIntervals(intervals string) map[time.Duration]bool {
+	if strings.TrimSpace(intervals) == "" {
+		intervals = defaultDriverSwapCountdownIntervals
+	}
+
+	out := make(map[time.Duration]bool)
+
+	for _, interval := range strings.Split(intervals, ",") {
+		seconds, err := strconv.Atoi(strings.TrimSpace(interval))
+
+		if err != nil || seconds <= 0 {
+			logrus.Warnf("Ignoring invalid driver swap countdown interval: %q", interval)
+			continue
+		}
+
+		out[time.Duration(seconds)*time.Second] = true
+	}
+
+	return out
+}
+
+// shouldAnnounceDriverSwapCountdown reports whether the countdown of a driver swap should be sent to the new driver,
+// either because it is one of the intervals or because it is a multiple of every (if every is set).
+func shouldAnnounceDriverSwapCountdown(countdown time.Duration, intervals map[time.Duration]bool, every time.Duration) bool {
+	if countdown <= 0 {
+		return false
+	}
+
+	return intervals[countdown] || (every > 0 && countdown%every == 0)
+}
+
+// positionHasChanged reports whether a car has moved at least threshold along any axis.
+func positionHasChanged(initialPosition, currentPosition udp.Vec, threshold float64) bool {
+	logrus.Debugf("initial position: %.2f, %.2f, %.2f", initialPosition.X, initialPosition.Y, initialPosition.Z)
+	logrus.Debugf("current position: %.2f, %.2f, %.2f", currentPosition.X, currentPosition.Y, currentPosition.Z)
+
+	return math.Abs(float64(initialPosition.X-currentPosition.X)) >= threshold ||
+		math.Abs(float64(initialPosition.Y-currentPosition.Y)) >= threshold ||
+		math.Abs(float64(initialPosition.Z-currentPosition.Z)) >= threshold
+}
+
+// findConnectedDriverByCarID looks for a driver in ConnectedDrivers by their CarID. This is the only place CarID
+// is used for a look-up, and it uses the CarIDToGUID map to perform the lookup.
+func (rc *RaceControl) findConnectedDriverByCarID(carID udp.CarID) (*RaceControlDriver, error) {
+	rc.carIDToGUIDMutex.RLock()
+	driverGUID, ok := rc.CarIDToGUID[carID]
+	rc.carIDToGUIDMutex.RUnlock()
+
+	if !ok {
+		return nil, unknownDriverError{carID: carID, message: fmt.Sprintf("racecontrol: could not find DriverGUID for CarID: %d", carID)}
+	}
+
+	driver, ok := rc.ConnectedDrivers.Get(driverGUID)
+
+	if !ok {
+		return nil, unknownDriverError{carID: carID, message: fmt.Sprintf("racecontrol: could not find connected driver for DriverGUID: %s", driverGUID)}
+	}
+
+	return driver, nil
+}
+
+// unknownDriverError is returned when a CarID can not be resolved to a connected driver.
+type unknownDriverError struct {
+	carID   udp.CarID
+	message string
+}
+
+func (e unknownDriverError) Error() string {
+	return e.message
+}
+
+const (
+	// unknownDriverResyncThreshold is the number of messages for an unknown CarID after which a resync is requested.
+	unknownDriverResyncThreshold = 10
+
+	// unknownDriverResyncInterval is the minimum time between resync requests for a CarID.
+	unknownDriverResyncInterval = time.Second * 30
+)
+
+// onUnknownDriver counts messages for CarIDs which could not be resolved to a connected driver. With
+// StrictDriverResolution enabled, repeated messages for an unknown CarID request the car's information
+// from the server, so that a missed connection can be recovered in OnCarInfo.
+func (rc *RaceControl) onUnknownDriver(err error) {
+	unknownDriver, ok := err.(unknownDriverError)
+
+	if !ok || !rc.cachedServerOptions().StrictDriverResolution {
+		return
+	}
+
+	rc.unknownCarIDsMutex.Lock()
+
+	rc.unknownCarIDs[unknownDriver.carID]++
+
+	if rc.unknownCarIDs[unknownDriver.carID] < unknownDriverResyncThreshold || time.Since(rc.lastResync[unknownDriver.carID]) < unknownDriverResyncInterval {
+		rc.unknownCarIDsMutex.Unlock()
+		return
+	}
+
+	rc.unknownCarIDs[unknownDriver.carID] = 0
+	rc.lastResync[unknownDriver.carID] = time.Now()
+
+	rc.unknownCarIDsMutex.Unlock()
+
+	logrus.Warnf("Received %d messages for unknown CarID: %d, requesting resync", unknownDriverResyncThreshold, unknownDriver.carID)
+
+	if err := rc.process.SendUDPMessage(udp.GetSessionInfo{}); err != nil {
+		logrus.WithError(err).Errorf("Could not request session info")
+	}
+
+	if err := rc.process.SendUDPMessage(udp.NewGetCarInfo(unknownDriver.carID)); err != nil {
+		logrus.WithError(err).Errorf("Could not request car info for CarID: %d", unknownDriver.carID)
+	}
+}
+
+// OnCarInfo occurs in response to a udp.GetCarInfo request. If the car has a driver which Race Control does not know
+// about, the driver is connected.
+func (rc *RaceControl) OnCarInfo(carInfo udp.CarInfo) error {
+	if !carInfo.IsConnected || carInfo.DriverGUID == "" {
+		return nil
+	}
+
+	if driver, err := rc.findConnectedDriverByCarID(carInfo.CarID); err == nil && driver.CarInfo.DriverGUID == carInfo.DriverGUID {
+		return nil
+	}
+
+	rc.unknownCarIDsMutex.Lock()
+	delete(rc.unknownCarIDs, carInfo.CarID)
+	rc.unknownCarIDsMutex.Unlock()
+
+	logrus.Infof("Resynchronising driver %s (%s) in CarID: %d", carInfo.DriverName, carInfo.DriverGUID, carInfo.CarID)
+
+	return rc.OnClientConnect(udp.SessionCarInfo{
+		CarID:      carInfo.CarID,
+		DriverName: carInfo.DriverName,
+		DriverGUID: carInfo.DriverGUID,
+		CarModel:   carInfo.CarModel,
+		CarSkin:    carInfo.CarSkin,
+		EventType:  udp.EventNewConnection,
+	})
+}
+
+// findDisconnectedDriverByName looks for a disconnected driver with the given (display) name. A driver is only
+// returned if exactly one disconnected driver has the name, and they were in the car that is connecting, so that a
+// different driver who happens to share their name doesn't take over their laps.
+func (rc *RaceControl) findDisconnectedDriverByName(name string, carID udp.CarID) (*RaceControlDriver, bool) {
+	name = strings.TrimSpace(name)
+
+	var (
+		matches []*RaceControlDriver
+		sameCar bool
+	)
+
+	_ = rc.DisconnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
+
+		if strings.EqualFold(strings.TrimSpace(driver.CarInfo.DriverName), name) {
+			matches = append(matches, driver)
+			sameCar = driver.CarInfo.CarID == carID
+		}
+
+		return nil
+	})
+
+	if len(matches) != 1 || !sameCar {
+		return nil, false
+	}
+
+	return matches[0], true
+}
+
+// isFirstLapThisSession records that the driver has completed a lap this session, and reports whether it was their
+// first.
+func (rc *RaceControl) isFirstLapThisSession(driverGUID udp.DriverGUID) bool {
+	rc.firstLapGUIDsMutex.Lock()
+	defer rc.firstLapGUIDsMutex.Unlock()
+
+	if rc.firstLapGUIDs[driverGUID] {
+		return false
+	}
+
+	rc.firstLapGUIDs[driverGUID] = true
+
+	return true
+}
+
+// OnClientLoaded marks a connected client as having loaded in.
+func (rc *RaceControl) OnClientLoaded(loadedCar udp.ClientLoaded) error {
+	driver, err := rc.findConnectedDriverByCarID(udp.CarID(loadedCar))
+
+	if err != nil {
+		return err
+	}
+
+	if err := rc.sendWelcomeMessage(driver); err != nil {
+		return err
+	}
+
+	if err := rc.sendChampionshipPlayerSummaryMessage(driver); err != nil {
+		logrus.WithError(err).Errorf("Couldn't send championship welcome message to driver: %s", driver.CarInfo.DriverName)
+	}
+
+	if driver.ModelMismatch {
+		rc.sendCarModelMismatchWarning(driver)
+	}
+
+	logrus.Debugf("Driver: %s (%s) loaded", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID)
+
+	driver.LoadedTime = time.Now()
+	driver.startActiveInterval(driver.LoadedTime)
+
+	driver.pitExitTime = driver.LoadedTime
+	driver.lapsSincePitExit = 0
+	driver.LapPhase = rc.lapPhase(driver)
+
+	_, err = rc.broadcaster.Send(loadedCar)
+
+	return err
+}
+
+// sendCarModelMismatchWarning tells a driver that their car is not configured for the event, if enabled.
+func (rc *RaceControl) sendCarModelMismatchWarning(driver *RaceControlDriver) {
+	if !rc.cachedServerOptions().WarnCarModelMismatch {
+		return
+	}
+
+	logrus.Infof("Driver %s (%s) joined in a car which is not configured for the event: %s", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, driver.CarInfo.CarModel)
+
+	message := fmt.Sprintf("Your car (%s) is not one of the cars configured for this event. Please check with the event organisers before racing.", driver.CarInfo.CarName)
+
+	chat, err := udp.NewSendChat(driver.CarInfo.CarID, message)
+
+	if err == nil {
+		err := rc.process.SendUDPMessage(chat)
+
+		if err != nil {
+			logrus.WithError(err).Errorf("Unable to send car model mismatch warning to: %s", driver.CarInfo.DriverName)
+		}
+	} else {
+		logrus.WithError(err).Errorf("Unable to build car model mismatch warning to: %s", driver.CarInfo.DriverName)
+	}
+}
+
+// SendWelcome re-sends the welcome message to a connected driver.
+func (rc *RaceControl) SendWelcome(driverGUID udp.DriverGUID) error {
+	driver, ok := rc.ConnectedDrivers.Get(driverGUID)
+
+	if !ok {
+		return fmt.Errorf("racecontrol: could not find connected driver for DriverGUID: %s", driverGUID)
+	}
+
+	return rc.sendWelcomeMessage(driver)
+}
+
+// WelcomeMessageTemplateData is the information which can be used in the Welcome Template server option.
+type WelcomeMessageTemplateData struct {
+	DriverName  string
+	ServerName  string
+	JoinMessage string
+	SolWarning  string
+	LiveLink    string
+	CarModel    string
+	CarName     string
+	TrackName   string
+	SessionType string
+}
+
+// buildWelcomeMessage builds the message that is sent to a driver when they join the server.
+func (rc *RaceControl) buildWelcomeMessage(driver *RaceControlDriver) (string, error) {
+	serverConfig := rc.cachedServerOptions()
+
+	data := WelcomeMessageTemplateData{
+		DriverName:  driver.CarInfo.DriverName,
+		ServerName:  serverConfig.GetName(),
+		JoinMessage: serverConfig.ServerJoinMessage,
+		CarModel:    driver.CarInfo.CarModel,
+		CarName:     driver.CarInfo.CarName,
+		TrackName:   prettifyName(rc.SessionInfo.Track, false),
+		SessionType: rc.SessionInfo.Type.String(),
+	}
+
+	if rc.process.Event().GetRaceConfig().IsSol == 1 && rc.shouldShowSolWarning(serverConfig.SolWarningMode, driver.CarInfo.DriverGUID) {
+		data.SolWarning = "This server is running Sol. For the best experience please install Sol, and remember the other drivers may be driving in night conditions."
+	}
+
+	if config != nil && config.HTTP.BaseURL != "" {
+		data.LiveLink = liveTimingLinkMessage()
+	}
+
+	if serverConfig.WelcomeTemplate != "" {
+		message, err := executeWelcomeTemplate(serverConfig.WelcomeTemplate, data)
+
+		if err == nil {
+			return message, nil
+		}
+
+		logrus.WithError(err).Errorf("Could not use welcome template, falling back to the default welcome message")
+	}
+
+	return fmt.Sprintf(
+		"Hi, %s! Welcome to the %s server! %s %s Make this race count! %s\n",
+		data.DriverName,
+		data.ServerName,
+		data.JoinMessage,
+		data.SolWarning,
+		data.LiveLink,
+	), nil
+}
+
+func executeWelcomeTemplate(welcomeTemplate string, data WelcomeMessageTemplateData) (string, error) {
+	t, err := template.New("welcome").Parse(welcomeTemplate)
+
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+
+	if err := t.Execute(buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// sendWelcomeMessage builds the welcome message for a driver and sends it to them in chat.
+func (rc *RaceControl) sendWelcomeMessage(driver *RaceControlDriver) error {
+	message, err := rc.buildWelcomeMessage(driver)
+
+	if err != nil {
+		return err
+	}
+
+	wrapped := strings.Split(wordwrap.WrapString(message, 60), "\n")
+
+	for _, msg := range wrapped {
+		welcomeMessage, err := udp.NewSendChat(driver.CarInfo.CarID, msg)
+
+		if err == nil {
+			err := rc.process.SendUDPMessage(welcomeMessage)
+
+			if err != nil {
+				logrus.WithError(err).Errorf("Unable to send welcome message to: %s", driver.CarInfo.DriverName)
+			}
+		} else {
+			logrus.WithError(err).Errorf("Unable to build welcome message to: %s", driver.CarInfo.DriverName)
+		}
+	}
+
+	return nil
+}
+
+// shouldShowSolWarning determines whether a driver should be shown the Sol warning in their welcome message.
+func (rc *RaceControl) shouldShowSolWarning(mode SolWarningMode, driverGUID udp.DriverGUID) bool {
+	switch mode {
+	case SolWarningModeNever:
+		return false
+	case SolWarningModeFirstJoin:
+		rc.solWarningGUIDsMutex.Lock()
+		defer rc.solWarningGUIDsMutex.Unlock()
+
+		if rc.solWarningGUIDs[driverGUID] {
+			return false
+		}
+
+		rc.solWarningGUIDs[driverGUID] = true
+
+		return true
+	default:
+		return true
+	}
+}
+
+func (rc *RaceControl) sendChampionshipPlayerSummaryMessage(driver *RaceControlDriver) error {
+	var championshipID uuid.UUID
+
+	if championship, ok := rc.process.Event().(*ActiveChampionship); ok {
+		championshipID = championship.ChampionshipID
+	} else if raceWeekend, ok := rc.process.Event().(*ActiveRaceWeekend); ok {
+		championshipID = raceWeekend.ChampionshipID
+	} else {
+		return nil
+	}
+
+	if championshipID == uuid.Nil {
+		return nil
+	}
+
+	championship, err := rc.store.LoadChampionship(championshipID.String())
+
+	if err != nil {
+		return err
+	}
+
+	championshipText := " Championship"
+
+	if strings.HasSuffix(strings.ToLower(championship.Name), "championship") {
+		championshipText = ""
+	}
+
+	visitServer := ""
+
+	if config != nil && config.HTTP.BaseURL != "" {
+		visitServer = fmt.Sprintf(" You can check out the results of this championship in detail at %s.", config.HTTP.BaseURL+"/championship/"+championship.ID.String())
+	}
+
+	wrapped := strings.Split(wordwrap.WrapString(
+		fmt.Sprintf(
+			"This event is part of the %s%s! %s%s\n",
+			championship.Name,
+			championshipText,
+			championship.GetPlayerSummary(string(driver.CarInfo.DriverGUID)),
+			visitServer,
+		),
+		60,
+	), "\n")
+
+	for _, msg := range wrapped {
+		welcomeMessage, err := udp.NewSendChat(driver.CarInfo.CarID, msg)
+
+		if err == nil {
+			err := rc.process.SendUDPMessage(welcomeMessage)
+
+			if err != nil {
+				logrus.WithError(err).Errorf("Unable to send welcome message to: %s", driver.CarInfo.DriverName)
+			}
+		} else {
+			logrus.WithError(err).Errorf("Unable to build welcome message to: %s", driver.CarInfo.DriverName)
+		}
+	}
+
+	return nil
+}
+
+// OnLapCompleted occurs every time a driver crosses the line. Lap information is collected for the driver
+// and best lap time and top speed are calculated. OnLapCompleted also remembers the car the lap was completed in
+// a PreviousCars map on the driver. This is so that lap times between different cars can be compared.
+func (rc *RaceControl) OnLapCompleted(lap udp.LapCompleted) error {
+	driver, err := rc.findConnectedDriverByCarID(lap.CarID)
+
+	if err != nil {
+		return err
+	}
+
+	// timing data is persisted once the driver's mutex has been released, since persisting copies every driver.
+	defer rc.persistTimingData()
+
+	brokeTrackRecord := false
+	var newFastestLap *SessionFastestLap
+	var firstLap *FirstLap
+	var invalidLap *InvalidLap
+
+	// other drivers' deltas to the track record are updated once this driver's mutex has been released.
+	defer func() {
+		if brokeTrackRecord {
+			rc.updateDeltasToRecord()
+		}
+
+		if newFastestLap != nil {
+			rc.announceFastestLap(*newFastestLap)
+
+			if _, err := rc.broadcaster.Send(*newFastestLap); err != nil {
+				logrus.WithError(err).Error("Could not broadcast session fastest lap")
+			}
+
+			rc.sendWebhook(WebhookEventSessionFastestLap, *newFastestLap)
+		}
+
+		if firstLap != nil {
+			if _, err := rc.broadcaster.Send(*firstLap); err != nil {
+				logrus.WithError(err).Error("Could not broadcast first lap")
+			}
+		}
+
+		if invalidLap != nil {
+			if _, err := rc.broadcaster.Send(*invalidLap); err != nil {
+				logrus.WithError(err).Error("Could not broadcast invalid lap")
+			}
+		}
+	}()
+
+	// the drivers are sorted once this driver's mutex has been released, since sorting takes every driver's lock.
+	defer rc.updateSplits(driver)
+
+	driver.mutex.Lock()
+	defer driver.mutex.Unlock()
+
+	lapDuration := ParseLapTime(int(lap.LapTime))
+
+	logrus.Debugf("Lap completed by driver: %s (%s), %s", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, lapDuration)
+
+	driver.TotalNumLaps++
+	driver.lapsSincePitExit++
+	driver.CleanStreak++
+	currentCar := driver.CurrentCar()
+
+	// TotalNumLaps is kept across looped sessions and restored from persisted live timings, so it can't be used to
+	// tell whether this is the driver's first lap of the session.
+	if rc.isFirstLapThisSession(driver.CarInfo.DriverGUID) && rc.cachedServerOptions().BroadcastFirstLaps {
+		firstLap = &FirstLap{
+			DriverGUID: driver.CarInfo.DriverGUID,
+			DriverName: driver.CarInfo.DriverName,
+			CarModel:   driver.CarInfo.CarModel,
+			CarName:    currentCar.CarName,
+			LapTime:    lapDuration,
+		}
+	}
+
+	currentCar.TotalLapTime += lapDuration
+	currentCar.LastLap = lapDuration
+	currentCar.LastLapValid = lap.Cuts == 0
+	currentCar.LastLapAnomalous = lapDuration > rc.maxPlausibleLapTime()
+	currentCar.NumLaps++
+
+	currentCar.TotalCuts += int(lap.Cuts)
+	driver.sessionLaps++
+
+	if lap.Cuts == 0 {
+		currentCar.NumValidLaps++
+		driver.sessionCleanLaps++
+		driver.ConsecutiveInvalidLaps = 0
+	} else {
+		driver.ConsecutiveInvalidLaps++
+
+		invalidLap = &InvalidLap{
+			DriverGUID:             driver.CarInfo.DriverGUID,
+			DriverName:             driver.CarInfo.DriverName,
+			CarID:                  driver.CarInfo.CarID,
+			CarModel:               driver.CarInfo.CarModel,
+			LapTime:                lapDuration,
+			Cuts:                   int(lap.Cuts),
+			ConsecutiveInvalidLaps: driver.ConsecutiveInvalidLaps,
+		}
+	}
+
+	driver.CleanLapPercentage = 100 * float64(driver.sessionCleanLaps) / float64(driver.sessionLaps)
+
+	currentCar.LastLapCompletedTime = time.Now()
+
+	if currentCar.LastLapAnomalous {
+		logrus.Debugf("Lap by driver: %s (%s) of %s is longer than the maximum plausible lap time, treating it as an in/out lap", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, lapDuration)
+
+		currentCar.NumAnomalousLaps++
+		currentCar.AnomalousLapTime += lapDuration
+	}
+
+	if currentCar.NumLaps == 1 && !currentCar.LastLapAnomalous {
+		currentCar.firstLapTime = lapDuration
+	}
+
+	currentCar.updateAverageLap(rc.cachedServerOptions().AverageLapSkipsFirstLap)
+
+	if lap.Cuts == 0 && !currentCar.LastLapAnomalous {
+		currentCar.cleanLaps = append(currentCar.cleanLaps, lapDuration)
+
+		if len(currentCar.cleanLaps) > consistencyLaps {
+			currentCar.cleanLaps = currentCar.cleanLaps[len(currentCar.cleanLaps)-consistencyLaps:]
+		}
+
+		currentCar.Consistency = lapTimeStandardDeviation(currentCar.cleanLaps)
+	}
+
+	if lap.Cuts == 0 && !currentCar.LastLapAnomalous && (lapDuration < currentCar.BestLap || currentCar.BestLap == 0) {
+		currentCar.BestLap = lapDuration
+		currentCar.TopSpeedBestLap = currentCar.TopSpeedThisLap
+	}
+
+	currentCar.TopSpeedThisLap = 0
+
+	if lap.Cuts == 0 && !currentCar.LastLapAnomalous {
+		brokeTrackRecord = rc.updateTrackRecord(driver.CarInfo.CarModel, driver.CarInfo.DriverGUID, lapDuration)
+
+		fastestLap := SessionFastestLap{
+			CarModel:   driver.CarInfo.CarModel,
+			CarName:    currentCar.CarName,
+			DriverGUID: driver.CarInfo.DriverGUID,
+			DriverName: driver.CarInfo.DriverName,
+			LapTime:    lapDuration,
+		}
+
+		if rc.updateSessionFastestLap(fastestLap) {
+			newFastestLap = &fastestLap
+		}
+	}
+
+	rc.setDeltaToRecord(driver)
+	driver.updateBeatPersonalTrackBest()
+	rc.checkForWrongCar(driver)
+	rc.checkCuts(driver, int(lap.Cuts))
+	rc.checkConsecutiveInvalidLaps(driver)
+	driver.LapPhase = rc.lapPhase(driver)
+
+	lapLogEntry := &LapLogEntry{
+		DriverGUID:  driver.CarInfo.DriverGUID,
+		DriverName:  driver.CarInfo.DriverName,
+		CarModel:    driver.CarInfo.CarModel,
+		LapTime:     lapDuration,
+		Cuts:        int(lap.Cuts),
+		Time:        currentCar.LastLapCompletedTime,
+		Track:       rc.SessionInfo.Track,
+		TrackLayout: rc.SessionInfo.TrackConfig,
+		SessionType: rc.SessionInfo.Type,
+		SessionName: rc.SessionInfo.Name,
+	}
 
-								logrus.Infof("Driver: %d (%s) has connected", currentDriver.CarInfo.CarID, currentDriver.CarInfo.DriverGUID)
-							}
-						} else {
-							// same driver reconnected
-							if resumeSwap {
-								logrus.Infof("Driver: %s has reconnected, driver swap still in progress", driver.CarInfo.DriverGUID)
+	if rc.cachedServerOptions().LogAllLaps {
+		rc.appendLapInBackground(lapLogEntry)
+	}
 
-								currentDriver = driver
-								newDriverConnected = true
-								resumeSwap = false
-							} else {
-								logrus.Infof("Driver: %s has reconnected, driver swap aborted", initialGUID)
-								reconnect = true
-							}
-						}
-					}
+	rc.writeLapToCSV(lapLogEntry)
 
-					return nil
-				})
+	return nil
+}
 
-				if reconnect {
-					ticker.Stop()
-					return
-				}
-			} else {
-				if totalTime.Seconds() >= completeTime.Seconds() {
-					sendChat, err := udp.NewSendChat(currentDriver.CarInfo.CarID, "You are clear to leave the pits, go go go!")
+// appendLapInBackground adds a lap to the lap log without blocking the caller, who may be holding a driver's lock.
+func (rc *RaceControl) appendLapInBackground(lap *LapLogEntry) {
+	rc.pendingLapsMutex.Lock()
+	rc.pendingLaps = append(rc.pendingLaps, lap)
+	rc.pendingLapsMutex.Unlock()
+
+	rc.goBackground(func() {
+		rc.lapLogWriteMutex.Lock()
+		defer rc.lapLogWriteMutex.Unlock()
+
+		// any laps queued before this one are written first, by whichever goroutine gets here first.
+		rc.pendingLapsMutex.Lock()
+		laps := rc.pendingLaps
+		rc.pendingLaps = nil
+		rc.pendingLapsMutex.Unlock()
+
+		for _, lap := range laps {
+			if err := rc.store.AppendLap(lap); err != nil {
+				logrus.WithError(err).Errorf("Could not add lap to the lap log")
+			}
+		}
+	})
+}
 
-					if err == nil {
-						err := rc.process.SendUDPMessage(sendChat)
+// updateSplits sorts the connected drivers, then updates the gaps between the driver who has just completed a lap and
+// the drivers ahead of them. In sessions other than races, every driver's gap is updated.
+func (rc *RaceControl) updateSplits(driver *RaceControlDriver) {
+	rc.ConnectedDrivers.sortByPosition()
 
-						if err != nil {
-							logrus.WithError(err).Errorf("Unable to send driver swap clear to leave message to: %s", currentDriver.CarInfo.DriverName)
-						}
-					} else {
-						logrus.WithError(err).Errorf("Unable to build driver swap clear to leave message to: %s", currentDriver.CarInfo.DriverName)
-					}
+	if rc.SessionInfo.Type == udp.SessionTypeRace {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
 
-					logrus.Infof("Driver: %d has successfully completed their driver swap and is free to leave the pits", currentDriver.CarInfo.CarID)
+		// calculate split
+		driver.LappedTrackGap = 0
 
-					ticker.Stop()
-					return
+		if driver.Position == 1 {
+			driver.Split = time.Duration(0).String()
+			driver.GapToLeader = time.Duration(0).String()
+			driver.LapsLed++
+		} else {
+			showLappedCarTrackGap := rc.cachedServerOptions().ShowLappedCarTrackGap
+
+			_ = rc.ConnectedDrivers.Each(func(otherDriverGUID udp.DriverGUID, otherDriver *RaceControlDriver) error {
+				if otherDriver.Position == 1 {
+					driver.GapToLeader = raceSplit(driver.CurrentCar(), otherDriver.CurrentCar())
+
+					if showLappedCarTrackGap && otherDriver.CurrentCar().NumLaps > driver.CurrentCar().NumLaps && otherDriver.hasSplinePos && driver.hasSplinePos {
+						driver.LappedTrackGap = trackGap(otherDriver.lastSplinePos, driver.lastSplinePos, driver.CurrentCar().LastLap)
+					}
 				}
 
-				if !firstPositionUpdate {
-					nilVec := udp.Vec{X: 0, Y: 0, Z: 0}
+				if otherDriver.Position == driver.Position-1 {
+					driver.Split = raceSplit(driver.CurrentCar(), otherDriver.CurrentCar())
+				}
 
-					if currentDriver.LastPos != nilVec {
-						sendChat, err := udp.NewSendChat(
-							currentDriver.CarInfo.CarID,
-							fmt.Sprintf(
-								"Hi! You are mid way through a driver swap, please wait %s before leaving the pits",
-								countdown.String(),
-							),
-						)
+				return nil
+			})
+		}
+	} else {
+		var previousCar *RaceControlCarLapInfo
 
-						if err == nil {
-							err := rc.process.SendUDPMessage(sendChat)
+		// gaps are calculated vs best lap
+		_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+			driver.mutex.Lock()
+			defer driver.mutex.Unlock()
 
-							if err != nil {
-								logrus.WithError(err).Errorf("Unable to send driver swap welcome message to: %s", currentDriver.CarInfo.DriverName)
-							}
-						} else {
-							logrus.WithError(err).Errorf("Unable to build driver swap welcome message to: %s", currentDriver.CarInfo.DriverName)
-						}
+			driver.GapToLeader = ""
 
-						firstPositionUpdate = true
-					}
+			if previousCar == nil {
+				driver.Split = "0s"
+			} else {
+				car := driver.CurrentCar()
+
+				if car.BestLap >= previousCar.BestLap && car.BestLap != 0 {
+					driver.Split = (car.BestLap - previousCar.BestLap).String()
+				} else {
+					driver.Split = ""
 				}
+			}
 
-				// if driver has moved
-				if rc.positionHasChanged(position, currentDriver.LastPos) && firstPositionUpdate {
-					// if the time is within the disqualify window
-					if countdown >= (time.Second * time.Duration(config.DriverSwapDisqualifyTime)) {
-						sendChat, err := udp.NewSendChat(
-							currentDriver.CarInfo.CarID,
-							fmt.Sprintf(
-								"You have been kicked from the session for leaving the pits %s early during a driver swap",
-								countdown.String(),
-							),
-						)
+			previousCar = driver.CurrentCar()
 
-						if err == nil {
-							err := rc.process.SendUDPMessage(sendChat)
+			return nil
+		})
+	}
+}
 
-							if err != nil {
-								logrus.WithError(err).Errorf("Unable to send driver swap kicked message to: %s", currentDriver.CarInfo.DriverName)
-							}
-						} else {
-							logrus.WithError(err).Errorf("Unable to build driver swap kicked message to: %s", currentDriver.CarInfo.DriverName)
-						}
+// raceSplit is the gap between a car and the car ahead of it in a race, in laps if the car ahead has completed more
+// laps. The order of the cars can briefly be out of date (e.g. just after a driver un-laps themselves), so a gap
+// which would be negative is shown as zero.
+func raceSplit(car, carAhead *RaceControlCarLapInfo) string {
+	lapDifference := carAhead.NumLaps - car.NumLaps
 
-						time.Sleep(5 * time.Second)
+	switch {
+	case lapDifference <= 0:
+		gap := car.TotalLapTime - carAhead.TotalLapTime
 
-						err = rc.process.SendUDPMessage(udp.NewKickUser(uint8(currentDriver.CarInfo.CarID)))
+		if gap < 0 {
+			gap = 0
+		}
 
-						if err != nil {
-							logrus.WithError(err).Errorf("Unable to send kick command (driver swaps)")
-						} else {
-							logrus.Infof("Driver: %d has been kicked for leaving the pits %s early during a driver swap", currentDriver.CarInfo.CarID, countdown.String())
-						}
+		return gap.Round(time.Millisecond).String()
+	case lapDifference == 1:
+		return "1 lap"
+	default:
+		return fmt.Sprintf("%d laps", lapDifference)
+	}
+}
 
-						// don't stop the ticker, when the driver reconnects they should still have to wait
-						firstPositionUpdate = false
-						newDriverConnected = false
-						resumeSwap = true
-						currentDriver.LastPos = udp.Vec{X: 0, Y: 0, Z: 0}
-					} else if countdown >= (time.Second * time.Duration(config.DriverSwapPenaltyTime)) {
+const (
+	chatMessageLimit  = 50
+	chatCommandPrefix = "/"
+)
 
-						rc.driverSwapPenaltiesMutex.Lock()
-						{
-							if _, ok := rc.driverSwapPenalties[currentDriver.CarInfo.DriverGUID]; ok {
-								rc.driverSwapPenalties[currentDriver.CarInfo.DriverGUID].penalty += countdown + (time.Second * 5)
-							} else {
-								rc.driverSwapPenalties[currentDriver.CarInfo.DriverGUID] = &driverSwapPenalty{
-									penalty:  countdown + (time.Second * 5),
-									carModel: currentDriver.CarInfo.CarModel,
-								}
-							}
-						}
-						rc.driverSwapPenaltiesMutex.Unlock()
+func (rc *RaceControl) OnChatMessage(chat udp.Chat) error {
+	if strings.HasPrefix(chat.Message, chatCommandPrefix) {
+		return nil
+	}
 
-						sendChat, err := udp.NewSendChat(
-							currentDriver.CarInfo.CarID,
-							fmt.Sprintf(
-								"You have been given a %s second penalty for leaving the pits %s early during a driver swap",
-								(countdown+(time.Second*5)).String(),
-								countdown.String(),
-							),
-						)
+	_, err := rc.broadcaster.Send(chat)
 
-						if err == nil {
-							err := rc.process.SendUDPMessage(sendChat)
+	if err != nil {
+		return err
+	}
+
+	rc.ChatMessagesMutex.Lock()
+
+	rc.ChatMessages = append(rc.ChatMessages, chat)
+
+	if len(rc.ChatMessages) > chatMessageLimit {
+		rc.ChatMessages = rc.ChatMessages[len(rc.ChatMessages)-chatMessageLimit:]
+	}
+
+	rc.ChatMessagesMutex.Unlock()
+
+	if config.Lua.Enabled && Premium() {
+		go func() {
+			err := chatMessagePlugin(chat)
+
+			if err != nil {
+				logrus.WithError(err).Error("chat message plugin script failed")
+			}
+		}()
+	}
+
+	return nil
+}
+
+const (
+	liveTimingChatCommand = chatCommandPrefix + "timing"
+	readyChatCommand      = chatCommandPrefix + "ready"
+	whereAmIChatCommand   = chatCommandPrefix + "whereami"
+	bestLapChatCommand    = chatCommandPrefix + "best"
+	positionChatCommand   = chatCommandPrefix + "pos"
+)
+
+// chatCommandFunc handles a chat command sent by a connected driver.
+type chatCommandFunc func(rc *RaceControl, driver *RaceControlDriver) error
+
+// chatCommands are the chat commands which drivers can use, keyed by the (lower case) command. Messages which start
+// with the chat command prefix but are not in chatCommands are ignored.
+var chatCommands = map[string]chatCommandFunc{
+	liveTimingChatCommand: (*RaceControl).sendLiveTimingLink,
+	readyChatCommand:      (*RaceControl).OnDriverReady,
+	whereAmIChatCommand:   (*RaceControl).sendWhereAmI,
+	bestLapChatCommand:    (*RaceControl).sendBestLap,
+	positionChatCommand:   (*RaceControl).sendPosition,
+}
+
+// ReadyCount is the number of connected drivers who have said they are ready for the race to start.
+type ReadyCount struct {
+	Ready int `json:"Ready"`
+	Total int `json:"Total"`
+}
+
+func (ReadyCount) Event() udp.Event {
+	return EventReadyCount
+}
+
+// OnDriverReady marks a driver as ready for the race to start, and broadcasts the number of ready drivers.
+func (rc *RaceControl) OnDriverReady(driver *RaceControlDriver) error {
+	rc.readyGUIDsMutex.Lock()
+	rc.readyGUIDs[driver.CarInfo.DriverGUID] = true
+	rc.readyGUIDsMutex.Unlock()
+
+	readyCount := rc.ReadyCount()
+
+	logrus.Debugf("Driver: %s (%s) is ready (%d/%d drivers ready)", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, readyCount.Ready, readyCount.Total)
+
+	err := rc.splitAndBroadcastChat(fmt.Sprintf("%s is ready (%d/%d drivers ready)", driver.CarInfo.DriverName, readyCount.Ready, readyCount.Total), nil)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = rc.broadcaster.Send(readyCount)
+
+	return err
+}
+
+// ReadyCount returns the number of connected drivers who are ready, out of the total number of connected drivers.
+func (rc *RaceControl) ReadyCount() ReadyCount {
+	rc.readyGUIDsMutex.Lock()
+	defer rc.readyGUIDsMutex.Unlock()
+
+	readyCount := ReadyCount{}
+
+	_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		readyCount.Total++
+
+		if rc.readyGUIDs[driverGUID] {
+			readyCount.Ready++
+		}
+
+		return nil
+	})
+
+	return readyCount
+}
+
+func liveTimingLinkMessage() string {
+	return fmt.Sprintf("You can view live timings for this event at %s", config.HTTP.BaseURL+"/live-timing")
+}
+
+// sendLiveTimingLink sends a driver a link to the live timings page, if a base URL is configured.
+func (rc *RaceControl) sendLiveTimingLink(driver *RaceControlDriver) error {
+	if config == nil || config.HTTP.BaseURL == "" {
+		return nil
+	}
+
+	sendChat, err := udp.NewSendChat(driver.CarInfo.CarID, liveTimingLinkMessage())
+
+	if err != nil {
+		return err
+	}
+
+	return rc.process.SendUDPMessage(sendChat)
+}
+
+// StandingsTableRow is a driver's entry in the current standings, as posted to Discord.
+type StandingsTableRow struct {
+	Position int
+	Name     string
+	Gap      string
+	BestLap  time.Duration
+}
+
+// StandingsTable lists the connected drivers in their current positions, with their gap to the driver ahead. Drivers
+// are copied so that they are read under their locks.
+func (rc *RaceControl) StandingsTable() []StandingsTableRow {
+	var rows []StandingsTableRow
+
+	_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver = driver.Copy()
+
+		rows = append(rows, StandingsTableRow{
+			Position: driver.Position,
+			Name:     driver.CarInfo.DriverName,
+			Gap:      driver.Split,
+			BestLap:  driver.CurrentCar().BestLap,
+		})
+
+		return nil
+	})
+
+	return rows
+}
+
+// sendWhereAmI tells a driver their position in the race and the gaps to the cars directly ahead and behind them.
+func (rc *RaceControl) sendWhereAmI(driver *RaceControlDriver) error {
+	sendChat, err := udp.NewSendChat(driver.CarInfo.CarID, rc.whereAmIMessage(driver))
+
+	if err != nil {
+		return err
+	}
 
-							if err != nil {
-								logrus.WithError(err).Errorf("Unable to send driver swap penalty message to: %s", currentDriver.CarInfo.DriverName)
-							}
-						} else {
-							logrus.WithError(err).Errorf("Unable to build driver swap penalty message to: %s", currentDriver.CarInfo.DriverName)
-						}
+	return rc.process.SendUDPMessage(sendChat)
+}
 
-						logrus.Infof(
-							"Driver: %d has been given a %s second penalty for leaving the pits %s early during a driver swap",
-							currentDriver.CarInfo.CarID,
-							(countdown + (time.Second * 5)).String(),
-							countdown.String(),
-						)
+// whereAmIMessage describes a driver's position from the current standings and splits. The split of each driver
+// is their gap to the car ahead, so the gap behind is the split of the driver one position further back. Drivers
+// are copied so that their positions and splits are read under their locks.
+func (rc *RaceControl) whereAmIMessage(driver *RaceControlDriver) string {
+	if rc.SessionInfo.Type != udp.SessionTypeRace {
+		return "Position information is only available during a race."
+	}
 
-						ticker.Stop()
-						return
-					}
-				}
+	driver = driver.Copy()
 
-				// send countdown messages
-				if firstPositionUpdate {
-					sendChat, err := udp.NewSendChat(currentDriver.CarInfo.CarID, fmt.Sprintf("Free to leave pits in %s", countdown.String()))
+	var driverAhead, driverBehind *RaceControlDriver
 
-					if err == nil {
-						err := rc.process.SendUDPMessage(sendChat)
+	_ = rc.ConnectedDrivers.Each(func(otherDriverGUID udp.DriverGUID, otherDriver *RaceControlDriver) error {
+		otherDriver = otherDriver.Copy()
 
-						if err != nil {
-							logrus.WithError(err).Errorf("Unable to send driver swap countdown message to: %s", currentDriver.CarInfo.DriverName)
-						}
-					} else {
-						logrus.WithError(err).Errorf("Unable to build driver swap countdown message to: %s", currentDriver.CarInfo.DriverName)
-					}
-				}
-			}
+		switch otherDriver.Position {
+		case driver.Position - 1:
+			driverAhead = otherDriver
+		case driver.Position + 1:
+			driverBehind = otherDriver
 		}
+
+		return nil
+	})
+
+	message := fmt.Sprintf("You are P%d of %d.", driver.Position, rc.ConnectedDrivers.Len())
+
+	if driverAhead != nil {
+		message += fmt.Sprintf(" Gap ahead: %s (%s).", whereAmIGap(driver.Split), driverAhead.CarInfo.DriverName)
 	}
-}
 
-const allowedDriverSwapPositionDifference = 10.0
+	if driverBehind != nil {
+		message += fmt.Sprintf(" Gap behind: %s (%s).", whereAmIGap(driverBehind.Split), driverBehind.CarInfo.DriverName)
+	}
 
-func (rc *RaceControl) positionHasChanged(initialPosition, currentPosition udp.Vec) bool {
-	logrus.Debugf("initial position: %.2f, %.2f, %.2f", initialPosition.X, initialPosition.Y, initialPosition.Z)
-	logrus.Debugf("current position: %.2f, %.2f, %.2f", currentPosition.X, currentPosition.Y, currentPosition.Z)
+	return message
+}
 
-	return math.Abs(float64(initialPosition.X-currentPosition.X)) >= allowedDriverSwapPositionDifference ||
-		math.Abs(float64(initialPosition.Y-currentPosition.Y)) >= allowedDriverSwapPositionDifference ||
-		math.Abs(float64(initialPosition.Z-currentPosition.Z)) >= allowedDriverSwapPositionDifference
+func whereAmIGap(split string) string {
+	if split == "" {
+		return "unknown"
+	}
+
+	return split
 }
 
-// findConnectedDriverByCarID looks for a driver in ConnectedDrivers by their CarID. This is the only place CarID
-// is used for a look-up, and it uses the CarIDToGUID map to perform the lookup.
-func (rc *RaceControl) findConnectedDriverByCarID(carID udp.CarID) (*RaceControlDriver, error) {
-	rc.carIDToGUIDMutex.RLock()
-	driverGUID, ok := rc.CarIDToGUID[carID]
-	rc.carIDToGUIDMutex.RUnlock()
+// sendBestLap tells a driver their best lap of the session in their current car.
+func (rc *RaceControl) sendBestLap(driver *RaceControlDriver) error {
+	sendChat, err := udp.NewSendChat(driver.CarInfo.CarID, bestLapMessage(driver))
 
-	if !ok {
-		return nil, fmt.Errorf("racecontrol: could not find DriverGUID for CarID: %d", carID)
+	if err != nil {
+		return err
 	}
 
-	driver, ok := rc.ConnectedDrivers.Get(driverGUID)
+	return rc.process.SendUDPMessage(sendChat)
+}
 
-	if !ok {
-		return nil, fmt.Errorf("racecontrol: could not find connected driver for DriverGUID: %s", driverGUID)
+func bestLapMessage(driver *RaceControlDriver) string {
+	driver.mutex.Lock()
+	defer driver.mutex.Unlock()
+
+	bestLap := driver.CurrentCar().BestLap
+
+	if bestLap == 0 {
+		return "You have not set a lap time yet."
 	}
 
-	return driver, nil
+	return fmt.Sprintf("Your best lap is %s.", formatDuration(bestLap, true))
 }
 
-// OnClientLoaded marks a connected client as having loaded in.
-func (rc *RaceControl) OnClientLoaded(loadedCar udp.ClientLoaded) error {
-	driver, err := rc.findConnectedDriverByCarID(udp.CarID(loadedCar))
+// sendPosition tells a driver their current position, and their split to the car ahead.
+func (rc *RaceControl) sendPosition(driver *RaceControlDriver) error {
+	sendChat, err := udp.NewSendChat(driver.CarInfo.CarID, rc.positionMessage(driver))
 
 	if err != nil {
 		return err
 	}
 
-	serverConfig, err := rc.store.LoadServerOptions()
+	return rc.process.SendUDPMessage(sendChat)
+}
+
+func (rc *RaceControl) positionMessage(driver *RaceControlDriver) string {
+	numDrivers := rc.ConnectedDrivers.Len()
+
+	driver.mutex.Lock()
+	defer driver.mutex.Unlock()
+
+	if driver.Position == 0 {
+		return "Your position is not known yet."
+	}
+
+	message := fmt.Sprintf("You are P%d of %d.", driver.Position, numDrivers)
+
+	if driver.Position > 1 && driver.Split != "" {
+		message += fmt.Sprintf(" Split to the car ahead: %s.", driver.Split)
+	}
+
+	return message
+}
+
+func chatMessagePlugin(chat udp.Chat) error {
+	p := NewLuaPlugin()
+
+	p.Inputs(chat)
+
+	err := p.Call("./plugins/race-control.lua", "onChat")
 
 	if err != nil {
 		return err
 	}
 
-	solWarning := ""
-	liveLink := ""
+	return nil
+}
+
+// SortDrivers determines whether driverA should be listed before driverB in the given sort mode. Drivers who have
+// not set the value being sorted by (e.g. a best lap) are listed last.
+func (rc *RaceControl) SortDrivers(mode SortMode, driverGroup RaceControlDriverGroup, driverA, driverB *RaceControlDriver) bool {
+	driverACar := driverA.CurrentCar()
+	driverBCar := driverB.CurrentCar()
 
-	if rc.process.Event().GetRaceConfig().IsSol == 1 {
-		solWarning = "This server is running Sol. For the best experience please install Sol, and remember the other drivers may be driving in night conditions."
+	switch mode {
+	case SortByBestLap:
+		return lessNonZeroDuration(driverACar.BestLap, driverBCar.BestLap)
+	case SortByLastLap:
+		return lessNonZeroDuration(driverACar.LastLap, driverBCar.LastLap)
+	case SortByTopSpeed:
+		return driverACar.TopSpeedBestLap > driverBCar.TopSpeedBestLap
+	case SortByName:
+		return strings.ToLower(driverA.CarInfo.DriverName) < strings.ToLower(driverB.CarInfo.DriverName)
+	default:
+		return sortDriversForSessionType(rc.SessionInfo.Type, rc.cachedServerOptions().QualifyingMinValidLaps, driverGroup, driverA, driverB)
 	}
+}
 
-	if config != nil && config.HTTP.BaseURL != "" {
-		liveLink = fmt.Sprintf("You can view live timings for this event at %s", config.HTTP.BaseURL+"/live-timing")
+// lessNonZeroDuration compares two durations, where a zero duration (i.e. one which is not set) is greater than any
+// other.
+func lessNonZeroDuration(a, b time.Duration) bool {
+	if a == 0 {
+		return false
+	} else if b == 0 {
+		return true
 	}
 
-	wrapped := strings.Split(wordwrap.WrapString(
-		fmt.Sprintf(
-			"Hi, %s! Welcome to the %s server! %s %s Make this race count! %s\n",
-			driver.CarInfo.DriverName,
-			serverConfig.GetName(),
-			serverConfig.ServerJoinMessage,
-			solWarning,
-			liveLink,
-		),
-		60,
-	), "\n")
+	return a < b
+}
 
-	for _, msg := range wrapped {
-		welcomeMessage, err := udp.NewSendChat(driver.CarInfo.CarID, msg)
+// SortedFor returns copies of the connected drivers, sorted as they would be in a session of the given type,
+// regardless of the type of the current session. e.g. this can be used to preview a qualifying order during practice.
+func (rc *RaceControl) SortedFor(sessionType udp.SessionType) []*RaceControlDriver {
+	var drivers []*RaceControlDriver
 
-		if err == nil {
-			err := rc.process.SendUDPMessage(welcomeMessage)
+	_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		drivers = append(drivers, driver.Copy())
 
-			if err != nil {
-				logrus.WithError(err).Errorf("Unable to send welcome message to: %s", driver.CarInfo.DriverName)
+		return nil
+	})
+
+	sort.SliceStable(drivers, func(i, j int) bool {
+		return sortDriversForSessionType(sessionType, rc.cachedServerOptions().QualifyingMinValidLaps, ConnectedDrivers, drivers[i], drivers[j])
+	})
+
+	return drivers
+}
+
+// sortDriversForSessionType sorts drivers by the rules of the given session type. In qualifying, a driver's best
+// lap only counts once they have completed qualifyingMinValidLaps valid laps.
+func sortDriversForSessionType(sessionType udp.SessionType, qualifyingMinValidLaps int, driverGroup RaceControlDriverGroup, driverA, driverB *RaceControlDriver) bool {
+	driverACar := driverA.CurrentCar()
+	driverBCar := driverB.CurrentCar()
+
+	if sessionType == udp.SessionTypeRace {
+		if driverGroup == ConnectedDrivers {
+			if driverACar.NumLaps == driverBCar.NumLaps {
+				return driverACar.TotalLapTime < driverBCar.TotalLapTime
 			}
+
+			return driverACar.NumLaps > driverBCar.NumLaps
+		} else if driverGroup == DisconnectedDrivers {
+			return driverACar.LastLapCompletedTime.After(driverBCar.LastLapCompletedTime)
 		} else {
-			logrus.WithError(err).Errorf("Unable to build welcome message to: %s", driver.CarInfo.DriverName)
+			panic("unknown driver group")
 		}
-	}
+	} else {
+		driverABestLap, driverBBestLap := driverACar.BestLap, driverBCar.BestLap
 
-	if err := rc.sendChampionshipPlayerSummaryMessage(driver); err != nil {
-		logrus.WithError(err).Errorf("Couldn't send championship welcome message to driver: %s", driver.CarInfo.DriverName)
-	}
+		if sessionType == udp.SessionTypeQualifying {
+			driverABestLap = qualifyingBestLap(driverACar, qualifyingMinValidLaps)
+			driverBBestLap = qualifyingBestLap(driverBCar, qualifyingMinValidLaps)
+		}
 
-	logrus.Debugf("Driver: %s (%s) loaded", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID)
+		if driverABestLap == 0 && driverBBestLap == 0 {
+			if driverACar.NumLaps == driverBCar.NumLaps {
+				return driverACar.LastLapCompletedTime.Before(driverBCar.LastLapCompletedTime)
+			}
 
-	driver.LoadedTime = time.Now()
+			return driverACar.NumLaps > driverBCar.NumLaps
+		}
 
-	_, err = rc.broadcaster.Send(loadedCar)
+		if driverABestLap == 0 {
+			return false
+		} else if driverBBestLap == 0 {
+			return true
+		}
 
-	return err
+		return driverABestLap < driverBBestLap
+	}
 }
 
-func (rc *RaceControl) sendChampionshipPlayerSummaryMessage(driver *RaceControlDriver) error {
-	var championshipID uuid.UUID
+// consistencyLaps is the number of recent clean laps that a driver's consistency is calculated from.
+const consistencyLaps = 10
 
-	if championship, ok := rc.process.Event().(*ActiveChampionship); ok {
-		championshipID = championship.ChampionshipID
-	} else if raceWeekend, ok := rc.process.Event().(*ActiveRaceWeekend); ok {
-		championshipID = raceWeekend.ChampionshipID
-	} else {
-		return nil
+// lapTimeStandardDeviation is the (population) standard deviation of a set of lap times. It is 0 for fewer than two
+// laps.
+func lapTimeStandardDeviation(laps []time.Duration) time.Duration {
+	if len(laps) < 2 {
+		return 0
 	}
 
-	if championshipID == uuid.Nil {
-		return nil
+	var mean float64
+
+	for _, lap := range laps {
+		mean += float64(lap)
 	}
 
-	championship, err := rc.store.LoadChampionship(championshipID.String())
+	mean /= float64(len(laps))
 
-	if err != nil {
-		return err
+	var variance float64
+
+	for _, lap := range laps {
+		variance += math.Pow(float64(lap)-mean, 2)
 	}
 
-	championshipText := " Championship"
+	variance /= float64(len(laps))
 
-	if strings.HasSuffix(strings.ToLower(championship.Name), "championship") {
-		championshipText = ""
+	return time.Duration(math.Sqrt(variance)).Round(time.Millisecond)
+}
+
+// qualifyingBestLap is the best lap of a car which counts in qualifying, or 0 if the car has not completed
+// minValidLaps valid laps.
+func qualifyingBestLap(car *RaceControlCarLapInfo, minValidLaps int) time.Duration {
+	if car.NumValidLaps < minValidLaps {
+		return 0
 	}
 
-	visitServer := ""
+	return car.BestLap
+}
 
-	if config != nil && config.HTTP.BaseURL != "" {
-		visitServer = fmt.Sprintf(" You can check out the results of this championship in detail at %s.", config.HTTP.BaseURL+"/championship/"+championship.ID.String())
+func metersPerSecondToKilometersPerHour(mps float64) float64 {
+	return mps * 3.6
+}
+
+// kilometersPerMile is the number of kilometres in a mile.
+const kilometersPerMile = 1.609344
+
+func metersPerSecondToMilesPerHour(mps float64) float64 {
+	return metersPerSecondToKilometersPerHour(mps) / kilometersPerMile
+}
+
+// defaultMaxPlausibleImpactSpeed is the highest impact speed (in km/h) recorded for a collision, if
+// MaxPlausibleImpactSpeed is not set.
+const defaultMaxPlausibleImpactSpeed = 500
+
+// impactSpeed converts a collision's impact speed to km/h. Corrupted packets can report absurd speeds, so speeds
+// above the maximum plausible impact speed are clamped to it, and invalid speeds are recorded as 0.
+func (rc *RaceControl) impactSpeed(driver *RaceControlDriver, mps float32) float64 {
+	maxSpeed := float64(rc.cachedServerOptions().MaxPlausibleImpactSpeed)
+
+	if maxSpeed <= 0 {
+		maxSpeed = defaultMaxPlausibleImpactSpeed
 	}
 
-	wrapped := strings.Split(wordwrap.WrapString(
-		fmt.Sprintf(
-			"This event is part of the %s%s! %s%s\n",
-			championship.Name,
-			championshipText,
-			championship.GetPlayerSummary(string(driver.CarInfo.DriverGUID)),
-			visitServer,
-		),
-		60,
-	), "\n")
+	speed := metersPerSecondToKilometersPerHour(float64(mps))
 
-	for _, msg := range wrapped {
-		welcomeMessage, err := udp.NewSendChat(driver.CarInfo.CarID, msg)
+	switch {
+	case math.IsNaN(speed) || speed < 0:
+		logrus.Warnf("Invalid impact speed for driver: %s (%s): %f, recording as 0", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, speed)
 
-		if err == nil {
-			err := rc.process.SendUDPMessage(welcomeMessage)
+		return 0
+	case speed > maxSpeed:
+		logrus.Warnf("Impact speed for driver: %s (%s) of %.2f km/h is not plausible, clamping to %.2f km/h", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, speed, maxSpeed)
 
-			if err != nil {
-				logrus.WithError(err).Errorf("Unable to send welcome message to: %s", driver.CarInfo.DriverName)
-			}
-		} else {
-			logrus.WithError(err).Errorf("Unable to build welcome message to: %s", driver.CarInfo.DriverName)
-		}
+		return maxSpeed
+	default:
+		return speed
 	}
+}
 
-	return nil
+// belowMinCollisionSpeed reports whether a collision's impact speed (in km/h) is below MinCollisionSpeed, in which case
+// the collision should not be recorded or broadcast.
+func (rc *RaceControl) belowMinCollisionSpeed(speed float64) bool {
+	return speed < float64(rc.cachedServerOptions().MinCollisionSpeed)
 }
 
-// OnLapCompleted occurs every time a driver crosses the line. Lap information is collected for the driver
-// and best lap time and top speed are calculated. OnLapCompleted also remembers the car the lap was completed in
-// a PreviousCars map on the driver. This is so that lap times between different cars can be compared.
-func (rc *RaceControl) OnLapCompleted(lap udp.LapCompleted) error {
-	driver, err := rc.findConnectedDriverByCarID(lap.CarID)
+// OnCollisionWithCar registers a driver's collision with another car.
+func (rc *RaceControl) OnCollisionWithCar(collision udp.CollisionWithCar) error {
+	driver, err := rc.findConnectedDriverByCarID(collision.CarID)
 
 	if err != nil {
 		return err
@@ -1080,224 +4952,368 @@ func (rc *RaceControl) OnLapCompleted(lap udp.LapCompleted) error {
 	driver.mutex.Lock()
 	defer driver.mutex.Unlock()
 
-	lapDuration := lapToDuration(int(lap.LapTime))
+	speed := rc.impactSpeed(driver, collision.ImpactSpeed)
 
-	logrus.Debugf("Lap completed by driver: %s (%s), %s", driver.CarInfo.DriverName, driver.CarInfo.DriverGUID, lapDuration)
+	if rc.belowMinCollisionSpeed(speed) {
+		return nil
+	}
 
-	driver.TotalNumLaps++
-	currentCar := driver.CurrentCar()
+	speedUnit := rc.cachedServerOptions().SpeedUnit
 
-	currentCar.TotalLapTime += lapDuration
-	currentCar.LastLap = lapDuration
-	currentCar.NumLaps++
-	currentCar.LastLapCompletedTime = time.Now()
+	c := Collision{
+		ID:         uuid.New().String(),
+		Type:       CollisionWithCar,
+		Time:       time.Now(),
+		DriverGUID: driver.CarInfo.DriverGUID,
+		DriverName: driver.CarInfo.DriverName,
+		Speed:      speedUnit.FromKilometersPerHour(speed),
+		SpeedUnit:  speedUnit.Label(),
+		WorldPos:   collision.WorldPos,
+	}
 
-	if lap.Cuts == 0 && (lapDuration < currentCar.BestLap || currentCar.BestLap == 0) {
-		currentCar.BestLap = lapDuration
-		currentCar.TopSpeedBestLap = currentCar.TopSpeedThisLap
+	collision.ImpactSpeed = float32(speed / 3.6)
+
+	otherDriver, err := rc.findConnectedDriverByCarID(collision.OtherCarID)
+
+	if err == nil {
+		c.OtherDriverGUID = otherDriver.CarInfo.DriverGUID
+		c.OtherDriverName = otherDriver.CarInfo.DriverName
 	}
 
-	currentCar.TopSpeedThisLap = 0
+	driver.Collisions = append(driver.Collisions, c)
+	driver.CleanStreak = 0
+
+	rc.checkIncidentRate(c.Time)
+	rc.persistCollision(c)
+
+	if otherDriver != nil {
+		warningSpeed := rc.cachedServerOptions().CollisionChatWarningSpeed
+
+		if warningSpeed > 0 && speed >= float64(warningSpeed) {
+			rc.sendCollisionWarning(c, driver.CarInfo.CarID, otherDriver.CarInfo.CarID)
+		}
+	}
+
+	_, err = rc.broadcaster.Send(collision)
+
+	return err
+}
+
+// persistCollision saves a collision to the store in the background, so that the collisions of a session can be
+// reviewed after it has finished. Collision handlers are called frequently during incidents, so they should not wait
+// for the store.
+func (rc *RaceControl) persistCollision(collision Collision) {
+	sessionID := rc.SessionID
+
+	rc.goBackground(func() {
+		if err := rc.store.UpsertCollision(sessionID, collision); err != nil {
+			logrus.WithError(err).Errorf("Could not persist collision: %s", collision.ID)
+		}
+	})
+}
+
+// sendCollisionWarning sends a neutral chat message to both drivers involved in a collision, noting the time at which
+// the incident occurred.
+func (rc *RaceControl) sendCollisionWarning(collision Collision, carIDs ...udp.CarID) {
+	message := fmt.Sprintf(
+		"Incident noted at %s between %s and %s (%.0f %s)",
+		collision.Time.Format("15:04:05"),
+		collision.DriverName,
+		collision.OtherDriverName,
+		collision.Speed,
+		collision.SpeedUnit,
+	)
+
+	for _, carID := range carIDs {
+		sendChat, err := udp.NewSendChat(carID, message)
+
+		if err == nil {
+			err := rc.process.SendUDPMessage(sendChat)
+
+			if err != nil {
+				logrus.WithError(err).Errorf("Unable to send collision warning message to car: %d", carID)
+			}
+		} else {
+			logrus.WithError(err).Errorf("Unable to build collision warning message to car: %d", carID)
+		}
+	}
+}
+
+const (
+	// defaultIncidentAlertWindow is the window in which collisions are counted for an incident alert, if
+	// IncidentAlertWindow is not set.
+	defaultIncidentAlertWindow = 10 * time.Second
+
+	// incidentAlertCooldown is the minimum time between incident alerts.
+	incidentAlertCooldown = time.Minute
+
+	incidentAlertMessage = "Multiple incidents on track, take care!"
+)
 
-	rc.ConnectedDrivers.sort()
+// checkIncidentRate records a collision, and warns all drivers in chat if the number of collisions in the incident
+// alert window reaches IncidentAlertCollisions. Alerts are sent at most once every incidentAlertCooldown, and not
+// within the quiet hours.
+func (rc *RaceControl) checkIncidentRate(collisionTime time.Time) {
+	serverOptions := rc.cachedServerOptions()
 
-	if rc.SessionInfo.Type == udp.SessionTypeRace {
-		// calculate split
-		if driver.Position == 1 {
-			driver.Split = time.Duration(0).String()
-		} else {
-			_ = rc.ConnectedDrivers.Each(func(otherDriverGUID udp.DriverGUID, otherDriver *RaceControlDriver) error {
-				if otherDriver.Position == driver.Position-1 {
-					driverCar := driver.CurrentCar()
-					otherDriverCar := otherDriver.CurrentCar()
+	if serverOptions.IncidentAlertCollisions <= 0 {
+		return
+	}
 
-					lapDifference := otherDriverCar.NumLaps - driverCar.NumLaps
+	window := time.Duration(serverOptions.IncidentAlertWindow) * time.Second
 
-					if lapDifference <= 0 {
-						driver.Split = (driverCar.TotalLapTime - otherDriverCar.TotalLapTime).Round(time.Millisecond).String()
-					} else if lapDifference == 1 {
-						driver.Split = "1 lap"
-					} else {
-						driver.Split = fmt.Sprintf("%d laps", lapDifference)
-					}
-				}
+	if window <= 0 {
+		window = defaultIncidentAlertWindow
+	}
 
-				return nil
-			})
-		}
-	} else {
-		var previousCar *RaceControlCarLapInfo
+	rc.recentCollisionsMutex.Lock()
+	defer rc.recentCollisionsMutex.Unlock()
 
-		// gaps are calculated vs best lap
-		_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
-			if previousCar == nil {
-				driver.Split = "0s"
-			} else {
-				car := driver.CurrentCar()
+	rc.recentCollisions = append(rc.recentCollisions, collisionTime)
 
-				if car.BestLap >= previousCar.BestLap && car.BestLap != 0 {
-					driver.Split = (car.BestLap - previousCar.BestLap).String()
-				} else {
-					driver.Split = ""
-				}
-			}
+	for len(rc.recentCollisions) > 0 && collisionTime.Sub(rc.recentCollisions[0]) > window {
+		rc.recentCollisions = rc.recentCollisions[1:]
+	}
 
-			previousCar = driver.CurrentCar()
+	if len(rc.recentCollisions) < serverOptions.IncidentAlertCollisions {
+		return
+	}
 
-			return nil
-		})
+	if !rc.lastIncidentAlert.IsZero() && collisionTime.Sub(rc.lastIncidentAlert) < incidentAlertCooldown {
+		return
 	}
 
-	rc.persistTimingData()
+	if rc.inQuietHours(collisionTime) {
+		return
+	}
 
-	return nil
-}
+	rc.lastIncidentAlert = collisionTime
 
-const (
-	chatMessageLimit  = 50
-	chatCommandPrefix = "/"
-)
+	logrus.Infof("%d collisions in the last %s, warning drivers", len(rc.recentCollisions), window)
 
-func (rc *RaceControl) OnChatMessage(chat udp.Chat) error {
-	if strings.HasPrefix(chat.Message, chatCommandPrefix) {
-		return nil
+	if err := rc.splitAndBroadcastChat(incidentAlertMessage, nil); err != nil {
+		logrus.WithError(err).Error("Could not send incident alert")
 	}
+}
 
-	_, err := rc.broadcaster.Send(chat)
+// OnCollisionWithEnvironment registers a driver's collision with the environment.
+func (rc *RaceControl) OnCollisionWithEnvironment(collision udp.CollisionWithEnvironment) error {
+	driver, err := rc.findConnectedDriverByCarID(collision.CarID)
 
 	if err != nil {
 		return err
 	}
 
-	rc.ChatMessagesMutex.Lock()
+	driver.mutex.Lock()
+	defer driver.mutex.Unlock()
 
-	rc.ChatMessages = append(rc.ChatMessages, chat)
+	speed := rc.impactSpeed(driver, collision.ImpactSpeed)
 
-	if len(rc.ChatMessages) > chatMessageLimit {
-		rc.ChatMessages = rc.ChatMessages[len(rc.ChatMessages)-chatMessageLimit:]
+	if rc.belowMinCollisionSpeed(speed) {
+		return nil
 	}
 
-	rc.ChatMessagesMutex.Unlock()
-
-	if config.Lua.Enabled && Premium() {
-		go func() {
-			err := chatMessagePlugin(chat)
+	speedUnit := rc.cachedServerOptions().SpeedUnit
 
-			if err != nil {
-				logrus.WithError(err).Error("chat message plugin script failed")
-			}
-		}()
+	c := Collision{
+		ID:         uuid.New().String(),
+		Type:       CollisionWithEnvironment,
+		Time:       time.Now(),
+		DriverGUID: driver.CarInfo.DriverGUID,
+		DriverName: driver.CarInfo.DriverName,
+		Speed:      speedUnit.FromKilometersPerHour(speed),
+		SpeedUnit:  speedUnit.Label(),
+		WorldPos:   collision.WorldPos,
 	}
 
-	return nil
+	driver.Collisions = append(driver.Collisions, c)
+	driver.CleanStreak = 0
+
+	rc.checkIncidentRate(c.Time)
+	rc.persistCollision(c)
+
+	collision.ImpactSpeed = float32(speed / 3.6)
+
+	_, err = rc.broadcaster.Send(collision)
+
+	return err
 }
 
-func chatMessagePlugin(chat udp.Chat) error {
-	p := NewLuaPlugin()
+// CleanestDrivers returns the connected drivers with the longest clean streak (laps since their last collision), or
+// nil if no driver has completed a clean lap.
+func (rc *RaceControl) CleanestDrivers() []udp.DriverGUID {
+	var (
+		cleanest   []udp.DriverGUID
+		bestStreak int
+	)
 
-	p.Inputs(chat)
+	_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
 
-	err := p.Call("./plugins/race-control.lua", "onChat")
+		switch {
+		case driver.CleanStreak == 0 || driver.CleanStreak < bestStreak:
+			return nil
+		case driver.CleanStreak > bestStreak:
+			bestStreak = driver.CleanStreak
+			cleanest = nil
+		}
 
-	if err != nil {
-		return err
-	}
+		cleanest = append(cleanest, driverGUID)
 
-	return nil
+		return nil
+	})
+
+	return cleanest
 }
 
-func (rc *RaceControl) SortDrivers(driverGroup RaceControlDriverGroup, driverA, driverB *RaceControlDriver) bool {
-	driverACar := driverA.CurrentCar()
-	driverBCar := driverB.CurrentCar()
+// CollisionsBetween returns all collisions (for both connected and disconnected drivers) which occurred within
+// the time range from -> to (inclusive), sorted by the time they occurred.
+func (rc *RaceControl) CollisionsBetween(from, to time.Time) []Collision {
+	var collisions []Collision
 
-	if rc.SessionInfo.Type == udp.SessionTypeRace {
-		if driverGroup == ConnectedDrivers {
-			if driverACar.NumLaps == driverBCar.NumLaps {
-				return driverACar.TotalLapTime < driverBCar.TotalLapTime
-			}
+	collectCollisions := func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
 
-			return driverACar.NumLaps > driverBCar.NumLaps
-		} else if driverGroup == DisconnectedDrivers {
-			return driverACar.LastLapCompletedTime.After(driverBCar.LastLapCompletedTime)
-		} else {
-			panic("unknown driver group")
-		}
-	} else {
-		if driverACar.BestLap == 0 && driverBCar.BestLap == 0 {
-			if driverACar.NumLaps == driverBCar.NumLaps {
-				return driverACar.LastLapCompletedTime.Before(driverBCar.LastLapCompletedTime)
+		for _, collision := range driver.Collisions {
+			if collision.Time.Before(from) || collision.Time.After(to) {
+				continue
 			}
 
-			return driverACar.NumLaps > driverBCar.NumLaps
-		}
+			// collisions loaded from previously persisted data may not know which driver they belong to.
+			collision.DriverGUID = driver.CarInfo.DriverGUID
+			collision.DriverName = driver.CarInfo.DriverName
 
-		if driverACar.BestLap == 0 {
-			return false
-		} else if driverBCar.BestLap == 0 {
-			return true
+			collisions = append(collisions, collision)
 		}
 
-		return driverACar.BestLap < driverBCar.BestLap
+		return nil
 	}
+
+	_ = rc.ConnectedDrivers.Each(collectCollisions)
+	_ = rc.DisconnectedDrivers.Each(collectCollisions)
+
+	sort.SliceStable(collisions, func(i, j int) bool {
+		return collisions[i].Time.Before(collisions[j].Time)
+	})
+
+	return collisions
 }
 
-func metersPerSecondToKilometersPerHour(mps float64) float64 {
-	return mps * 3.6
+const (
+	// defaultPileupWindow is the time between collisions for them to be grouped into the same pileup, if
+	// PileupWindowSeconds is not set.
+	defaultPileupWindow = 5 * time.Second
+
+	// pileupMaxDistance is the distance (in metres) a collision can be from the rest of a pileup to be part of it.
+	pileupMaxDistance = 100.0
+
+	// pileupMinDrivers is the number of drivers which must be involved in an incident for it to be a pileup.
+	pileupMinDrivers = 3
+)
+
+// Pileup is a group of collisions which occurred close together, both in time and on track.
+type Pileup struct {
+	Start      time.Time      `json:"Start" ts:"date"`
+	End        time.Time      `json:"End" ts:"date"`
+	Drivers    []PileupDriver `json:"Drivers"`
+	Collisions []Collision    `json:"Collisions"`
 }
 
-// OnCollisionWithCar registers a driver's collision with another car.
-func (rc *RaceControl) OnCollisionWithCar(collision udp.CollisionWithCar) error {
-	driver, err := rc.findConnectedDriverByCarID(collision.CarID)
+type PileupDriver struct {
+	DriverGUID udp.DriverGUID `json:"DriverGUID"`
+	DriverName string         `json:"DriverName"`
+}
 
-	if err != nil {
-		return err
+func (p *Pileup) addDriver(driverGUID udp.DriverGUID, driverName string) {
+	if driverGUID == "" {
+		return
 	}
 
-	c := Collision{
-		ID:    uuid.New().String(),
-		Type:  CollisionWithCar,
-		Time:  time.Now(),
-		Speed: metersPerSecondToKilometersPerHour(float64(collision.ImpactSpeed)),
+	for _, driver := range p.Drivers {
+		if driver.DriverGUID == driverGUID {
+			return
+		}
 	}
 
-	driver.mutex.Lock()
-	defer driver.mutex.Unlock()
+	p.Drivers = append(p.Drivers, PileupDriver{DriverGUID: driverGUID, DriverName: driverName})
+}
 
-	otherDriver, err := rc.findConnectedDriverByCarID(collision.OtherCarID)
+// isPartOf checks whether a collision belongs to a pileup. Collisions loaded from previously persisted data may not
+// have a position, in which case only the time of the collision is considered.
+func (p *Pileup) isPartOf(collision Collision, window time.Duration) bool {
+	if collision.Time.Sub(p.End) > window {
+		return false
+	}
 
-	if err == nil {
-		c.OtherDriverGUID = otherDriver.CarInfo.DriverGUID
-		c.OtherDriverName = otherDriver.CarInfo.DriverName
+	nilVec := udp.Vec{X: 0, Y: 0, Z: 0}
+
+	if collision.WorldPos == nilVec {
+		return true
 	}
 
-	driver.Collisions = append(driver.Collisions, c)
+	for _, other := range p.Collisions {
+		if other.WorldPos == nilVec || vecDistance(collision.WorldPos, other.WorldPos) <= pileupMaxDistance {
+			return true
+		}
+	}
 
-	_, err = rc.broadcaster.Send(collision)
+	return false
+}
 
-	return err
+func vecDistance(a, b udp.Vec) float64 {
+	x, y, z := float64(a.X-b.X), float64(a.Y-b.Y), float64(a.Z-b.Z)
+
+	return math.Sqrt(x*x + y*y + z*z)
 }
 
-// OnCollisionWithEnvironment registers a driver's collision with the environment.
-func (rc *RaceControl) OnCollisionWithEnvironment(collision udp.CollisionWithEnvironment) error {
-	driver, err := rc.findConnectedDriverByCarID(collision.CarID)
+// Pileups groups the collisions in the session into pileups, i.e. incidents involving at least three drivers where
+// each collision happened within the pileup window of the previous one, and near to the rest of the incident.
+func (rc *RaceControl) Pileups() []Pileup {
+	window := time.Duration(rc.cachedServerOptions().PileupWindowSeconds) * time.Second
 
-	if err != nil {
-		return err
+	if window <= 0 {
+		window = defaultPileupWindow
 	}
 
-	driver.mutex.Lock()
-	defer driver.mutex.Unlock()
+	return groupPileups(rc.CollisionsBetween(time.Time{}, time.Now()), window)
+}
 
-	driver.Collisions = append(driver.Collisions, Collision{
-		ID:    uuid.New().String(),
-		Type:  CollisionWithEnvironment,
-		Time:  time.Now(),
-		Speed: metersPerSecondToKilometersPerHour(float64(collision.ImpactSpeed)),
-	})
+// groupPileups groups collisions (sorted by time) into pileups.
+func groupPileups(collisions []Collision, window time.Duration) []Pileup {
+	var incidents []*Pileup
 
-	_, err = rc.broadcaster.Send(collision)
+	for _, collision := range collisions {
+		var incident *Pileup
 
-	return err
+		for _, existing := range incidents {
+			if existing.isPartOf(collision, window) {
+				incident = existing
+				break
+			}
+		}
+
+		if incident == nil {
+			incident = &Pileup{Start: collision.Time}
+			incidents = append(incidents, incident)
+		}
+
+		incident.End = collision.Time
+		incident.Collisions = append(incident.Collisions, collision)
+		incident.addDriver(collision.DriverGUID, collision.DriverName)
+		incident.addDriver(collision.OtherDriverGUID, collision.OtherDriverName)
+	}
+
+	var pileups []Pileup
+
+	for _, incident := range incidents {
+		if len(incident.Drivers) >= pileupMinDrivers {
+			pileups = append(pileups, *incident)
+		}
+	}
+
+	return pileups
 }
 
 type LiveTimingsPersistedData struct {
@@ -1309,7 +5325,50 @@ type LiveTimingsPersistedData struct {
 	Drivers map[udp.DriverGUID]*RaceControlDriver
 }
 
+// LapLogEntry is a record of a single completed lap, kept in the lap log if LogAllLaps is enabled.
+type LapLogEntry struct {
+	DriverGUID  udp.DriverGUID  `json:"DriverGUID"`
+	DriverName  string          `json:"DriverName"`
+	CarModel    string          `json:"CarModel"`
+	LapTime     time.Duration   `json:"LapTime"`
+	Cuts        int             `json:"Cuts"`
+	Time        time.Time       `json:"Time"`
+	Track       string          `json:"Track"`
+	TrackLayout string          `json:"TrackLayout"`
+	SessionType udp.SessionType `json:"SessionType"`
+	SessionName string          `json:"SessionName"`
+}
+
+// defaultMaxPlausibleLapTime is the lap time above which a lap is treated as an in/out lap, if MaxPlausibleLapTime
+// is not set. It is long enough that no normal lap should exceed it.
+const defaultMaxPlausibleLapTime = 20 * time.Minute
+
+func (rc *RaceControl) maxPlausibleLapTime() time.Duration {
+	maxLapTime := time.Duration(rc.cachedServerOptions().MaxPlausibleLapTime) * time.Second
+
+	if maxLapTime <= 0 {
+		return defaultMaxPlausibleLapTime
+	}
+
+	return maxLapTime
+}
+
+// defaultPersistTimingsMinDrivers is the minimum number of connected drivers for timing data to be persisted, if
+// PersistTimingsMinDrivers is not set.
+const defaultPersistTimingsMinDrivers = 1
+
 func (rc *RaceControl) persistTimingData() {
+	minDrivers := rc.cachedServerOptions().PersistTimingsMinDrivers
+
+	if minDrivers <= 0 {
+		minDrivers = defaultPersistTimingsMinDrivers
+	}
+
+	if numDrivers := rc.ConnectedDrivers.Len(); numDrivers < minDrivers {
+		logrus.Debugf("Only %d drivers connected (minimum %d), not persisting live timing data", numDrivers, minDrivers)
+		return
+	}
+
 	rc.persistStoreDataMutex.Lock()
 	defer rc.persistStoreDataMutex.Unlock()
 
@@ -1331,17 +5390,19 @@ func (rc *RaceControl) persistTimingData() {
 	logrus.Debug("successfully persisted live timing data")
 }
 
+// AllLapTimes returns a copy of all connected and disconnected drivers. The drivers are deep copied, so that they
+// can be safely serialised while the live drivers continue to be updated.
 func (rc *RaceControl) AllLapTimes() map[udp.DriverGUID]*RaceControlDriver {
 	out := make(map[udp.DriverGUID]*RaceControlDriver)
 
 	_ = rc.DisconnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
-		out[driverGUID] = driver
+		out[driverGUID] = driver.Copy()
 
 		return nil
 	})
 
 	_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
-		out[driverGUID] = driver
+		out[driverGUID] = driver.Copy()
 
 		return nil
 	})
@@ -1349,6 +5410,156 @@ func (rc *RaceControl) AllLapTimes() map[udp.DriverGUID]*RaceControlDriver {
 	return out
 }
 
+// RaceControlTeam is the drivers who have shared a car in the current session, e.g. in an endurance race with
+// driver swaps.
+type RaceControlTeam struct {
+	CarID        udp.CarID            `json:"CarID"`
+	TeamName     string               `json:"TeamName"`
+	Drivers      []*RaceControlDriver `json:"Drivers"`
+	TotalNumLaps int                  `json:"TotalNumLaps"`
+	BestLap      time.Duration        `json:"BestLap"`
+}
+
+// raceControlTeamKey identifies a team by its entry list team name and the car it is driving.
+type raceControlTeamKey struct {
+	TeamName string
+	CarID    udp.CarID
+}
+
+// AllLapTimesByTeam groups all drivers by their entry list team and the car they have driven in this session, so
+// that each team can be shown as a single row. The driver swap process keeps a team in the same car, so drivers of
+// the same team sharing a CarID are treated as a team. Drivers without a team in the entry list are never grouped,
+// as an unrelated driver may join in the same car later on. Teams are in the order of their highest placed driver,
+// with connected drivers first.
+func (rc *RaceControl) AllLapTimesByTeam() []*RaceControlTeam {
+	allLapTimes := rc.AllLapTimes()
+
+	var teams []*RaceControlTeam
+
+	teamsByKey := make(map[raceControlTeamKey]*RaceControlTeam)
+	added := make(map[udp.DriverGUID]bool)
+
+	addToTeam := func(driverGUID udp.DriverGUID, _ *RaceControlDriver) error {
+		driver, ok := allLapTimes[driverGUID]
+
+		if !ok || added[driverGUID] {
+			return nil
+		}
+
+		added[driverGUID] = true
+
+		key := raceControlTeamKey{TeamName: driver.TeamName, CarID: driver.CarInfo.CarID}
+		team, ok := teamsByKey[key]
+
+		if !ok {
+			team = &RaceControlTeam{CarID: driver.CarInfo.CarID, TeamName: driver.TeamName}
+			teams = append(teams, team)
+
+			if driver.TeamName != "" {
+				teamsByKey[key] = team
+			}
+		}
+
+		team.Drivers = append(team.Drivers, driver)
+		team.TotalNumLaps += driver.TotalNumLaps
+
+		for _, car := range driver.Cars {
+			if car.BestLap > 0 && (team.BestLap == 0 || car.BestLap < team.BestLap) {
+				team.BestLap = car.BestLap
+			}
+		}
+
+		return nil
+	}
+
+	_ = rc.ConnectedDrivers.Each(addToTeam)
+	_ = rc.DisconnectedDrivers.Each(addToTeam)
+
+	return teams
+}
+
+// LiveTimingsFilter restricts the drivers in a LiveTimingsSnapshot. Zero values do not filter.
+type LiveTimingsFilter struct {
+	// CarModel only includes drivers who have used the given car model this session.
+	CarModel string
+	// MinLaps only includes drivers who have completed at least this many laps.
+	MinLaps int
+}
+
+func (f LiveTimingsFilter) includes(driver *RaceControlDriver) bool {
+	if f.CarModel != "" {
+		if _, ok := driver.Cars[f.CarModel]; !ok {
+			return false
+		}
+	}
+
+	return driver.TotalNumLaps >= f.MinLaps
+}
+
+// LiveTimingsSnapshot is a point in time copy of the drivers in Live Timings.
+type LiveTimingsSnapshot struct {
+	SessionInfo         udp.SessionInfo      `json:"SessionInfo"`
+	ConnectedDrivers    []*RaceControlDriver `json:"ConnectedDrivers"`
+	DisconnectedDrivers []*RaceControlDriver `json:"DisconnectedDrivers"`
+
+	// RaceProgress is only set during a race, once the leader has completed a lap.
+	RaceProgress *RaceProgress `json:"RaceProgress,omitempty"`
+
+	// SessionFastestLap is only set once a valid lap has been completed in the session.
+	SessionFastestLap *SessionFastestLap `json:"SessionFastestLap,omitempty"`
+
+	// SpeedUnit is the unit of the drivers' top speeds and collision speeds, e.g. "km/h".
+	SpeedUnit string `json:"SpeedUnit"`
+}
+
+// Snapshot returns copies of the connected and disconnected drivers which match the filter, each in the order that
+// they are shown in Live Timings.
+func (rc *RaceControl) Snapshot(filter LiveTimingsFilter, sortMode SortMode) LiveTimingsSnapshot {
+	allLapTimes := rc.AllLapTimes()
+	speedUnit := rc.cachedServerOptions().SpeedUnit
+
+	// the snapshot is taken outside of the UDP callback, so the session info may be updated while it is read.
+	rc.sessionInfoMutex.RLock()
+	sessionInfo := rc.SessionInfo
+	rc.sessionInfoMutex.RUnlock()
+
+	collect := func(driverMap *DriverMap) []*RaceControlDriver {
+		var guids []udp.DriverGUID
+
+		_ = driverMap.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+			if driverCopy, ok := allLapTimes[driverGUID]; ok && filter.includes(driverCopy) {
+				guids = append(guids, driverGUID)
+			}
+
+			return nil
+		})
+
+		if sortMode != SortByPosition {
+			// the drivers are already in positional order, including any manual positions.
+			guids = driverMap.sort(sortMode, guids, allLapTimes, nil)
+		}
+
+		drivers := make([]*RaceControlDriver, 0, len(guids))
+
+		for _, guid := range guids {
+			driverCopy := allLapTimes[guid]
+			driverCopy.convertSpeeds(speedUnit)
+			drivers = append(drivers, driverCopy)
+		}
+
+		return drivers
+	}
+
+	return LiveTimingsSnapshot{
+		SessionInfo:         sessionInfo,
+		ConnectedDrivers:    collect(rc.ConnectedDrivers),
+		DisconnectedDrivers: collect(rc.DisconnectedDrivers),
+		RaceProgress:        rc.RaceProgress(),
+		SessionFastestLap:   rc.FastestLapOfSession(),
+		SpeedUnit:           speedUnit.Label(),
+	}
+}
+
 func (rc *RaceControl) LuaBroadcastChat(L *lua.LState) int {
 	message := L.ToString(1)
 
@@ -1453,8 +5664,16 @@ func (rc *RaceControl) splitAndSendChat(message, guid string) error {
 	return nil
 }
 
-func lapToDuration(i int) time.Duration {
-	d, _ := time.ParseDuration(fmt.Sprintf("%dms", i))
+// maxLapTimeMilliseconds is the longest time in milliseconds which can be represented by a time.Duration.
+const maxLapTimeMilliseconds = int64(math.MaxInt64 / time.Millisecond)
+
+// ParseLapTime converts a time in milliseconds, as used for lap and sector times by the Assetto Corsa server, to a
+// time.Duration. It gives the same result as parsing the milliseconds as a duration string, which results used to do:
+// negative times are negative durations, and times too long in either direction for a time.Duration are 0.
+func ParseLapTime(ms int) time.Duration {
+	if int64(ms) > maxLapTimeMilliseconds || int64(ms) < -maxLapTimeMilliseconds {
+		return 0
+	}
 
-	return d
+	return time.Duration(ms) * time.Millisecond
 }
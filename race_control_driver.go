@@ -2,6 +2,7 @@ package servermanager
 
 import (
 	"context"
+	"fmt"
 	"sort"
 	"sync"
 	"time"
@@ -13,16 +14,31 @@ import (
 
 func NewRaceControlDriver(carInfo udp.SessionCarInfo) *RaceControlDriver {
 	driver := &RaceControlDriver{
-		CarInfo:  carInfo,
-		Cars:     make(map[string]*RaceControlCarLapInfo),
-		LastSeen: time.Now(),
+		CarInfo:       carInfo,
+		Cars:          make(map[string]*RaceControlCarLapInfo),
+		LastSeen:      time.Now(),
+		TrackPosition: -1,
+		LapPhase:      LapPhaseOnTrack,
+
+		establishedCarModel: carInfo.CarModel,
 	}
 
-	driver.Cars[carInfo.CarModel] = NewRaceControlCarLapInfo(carInfo.CarModel)
+	driver.addCar(carInfo.CarModel)
 
 	return driver
 }
 
+// LapPhase describes the lap a driver is on.
+type LapPhase string
+
+const (
+	// LapPhaseOnTrack is used when the phase of the driver's lap can't be determined.
+	LapPhaseOnTrack LapPhase = "OnTrack"
+	LapPhaseOutLap  LapPhase = "OutLap"
+	LapPhaseFlying  LapPhase = "Flying"
+	LapPhaseInLap   LapPhase = "InLap"
+)
+
 func NewRaceControlCarLapInfo(carModel string) *RaceControlCarLapInfo {
 	return &RaceControlCarLapInfo{
 		CarName: prettifyName(carModel, true),
@@ -41,14 +57,121 @@ type RaceControlDriver struct {
 	LastSeen time.Time `json:"LastSeen" ts:"date"`
 	LastPos  udp.Vec   `json:"LastPos"`
 
+	// ManualPosition is set by race control (e.g. while resolving a dispute) to pin the driver to a position in Live
+	// Timings, with the other drivers sorted around them. It only affects Live Timings, not the session results.
+	ManualPosition *int `json:"ManualPosition"`
+
+	// GapToLeader is the gap between the driver and the leader of a race, in laps if the leader has completed more
+	// laps. It is empty outside of races.
+	GapToLeader string `json:"GapToLeader"`
+
+	// LapsDownAtDisconnect is how many laps the driver was behind the leader when they were moved to the disconnected
+	// drivers, to help explain the standings after retirements. It is reset when the driver reconnects.
+	LapsDownAtDisconnect int `json:"LapsDownAtDisconnect"`
+
+	// TrackPosition is the raw normalised spline position from the driver's latest car update, i.e. how far around
+	// the lap they are from 0 to 1. It is -1 until the first car update is received, and for tracks with no map data.
+	TrackPosition float64 `json:"TrackPosition"`
+
+	// LappedTrackGap is how far behind the leader on track a lapped driver is, if ShowLappedCarTrackGap is enabled.
+	LappedTrackGap time.Duration `json:"LappedTrackGap"`
+
 	Collisions []Collision `json:"Collisions"`
 
+	// ModelMismatch is true if the driver joined in a car which is not configured for the event.
+	ModelMismatch bool `json:"ModelMismatch"`
+
+	// DeltaToRecord is the gap between the best lap in the driver's current car and the track record for that car.
+	// HoldsRecord is true if the driver set the track record.
+	DeltaToRecord time.Duration `json:"DeltaToRecord"`
+	HoldsRecord   bool          `json:"HoldsRecord"`
+
+	// PersonalTrackBest is the driver's fastest lap in their current car at this track before this session.
+	// BeatPersonalTrackBest is true if they have gone faster this session.
+	PersonalTrackBest     time.Duration `json:"PersonalTrackBest"`
+	BeatPersonalTrackBest bool          `json:"BeatPersonalTrackBest"`
+
+	// TeamName is the team of the driver's car in the entry list.
+	TeamName string `json:"TeamName"`
+
+	// CleanStreak is the number of laps the driver has completed since their last collision.
+	CleanStreak int `json:"CleanStreak"`
+
+	// CleanLapPercentage is the percentage of the laps the driver has completed this session without any cuts.
+	// sessionLaps and sessionCleanLaps are the number of laps and laps without cuts that it is calculated from.
+	CleanLapPercentage float64 `json:"CleanLapPercentage"`
+	sessionLaps        int
+	sessionCleanLaps   int
+
+	// ConsecutiveInvalidLaps is the number of laps with cuts the driver has completed in a row this session.
+	ConsecutiveInvalidLaps int `json:"ConsecutiveInvalidLaps"`
+
+	// LapPhase is what the driver's current lap is, e.g. an out lap or a flying lap.
+	LapPhase LapPhase `json:"LapPhase"`
+
+	// Stale is true if the driver has disconnected, but is being kept in the connected drivers for the disconnect
+	// grace period in case they reconnect.
+	Stale bool `json:"Stale"`
+
+	// InPits is true if the driver's last position was within the pit lane area of the track. It is always false if
+	// no pit lane area is configured for the track.
+	InPits bool `json:"InPits"`
+
+	// PitStops is the number of times the driver has left the pit lane this session after completing a lap since their
+	// last pit stop. lapsAtLastPitStop is the driver's TotalNumLaps when their last pit stop was counted, so that
+	// leaving the pits at the start of the session or driving back in and out again are not counted as stops.
+	PitStops          int `json:"PitStops"`
+	lapsAtLastPitStop int
+
+	// LapsLed is the number of laps of the race the driver has completed in the lead. PositionHistory is how long
+	// the driver has held each position of the race, where PositionHistory[i] is the time spent in position i+1.
+	// historyPosition is the position that the driver has held since positionSince.
+	LapsLed         int             `json:"LapsLed"`
+	PositionHistory []time.Duration `json:"PositionHistory"`
+	historyPosition int
+	positionSince   time.Time
+
+	// Stationary is true if the driver's car has not moved on track for StationaryCarTime. stationarySince is when
+	// the car was first seen not moving.
+	Stationary      bool `json:"Stationary"`
+	stationarySince time.Time
+
+	// WrongCar is true if the driver has completed a lap in a different car to the one they connected in, without
+	// disconnecting first (i.e. the car's content was swapped mid-session).
+	WrongCar bool `json:"WrongCar"`
+
+	// establishedCarModel is the car model the driver was in when they last connected.
+	establishedCarModel string
+
+	// pitExitTime is when the driver last left the pits, and lapsSincePitExit is the number of laps they have
+	// completed since. The UDP plugin does not report pit stops, so the driver is in the pits when they load in and
+	// at the start of each session.
+	pitExitTime      time.Time
+	lapsSincePitExit int
+
+	// driverSwapContext is cancelled by driverSwapCfn to stop the driver swap the driver is in, if any. Both are
+	// guarded by RaceControl.driverSwapsMutex rather than the driver's mutex.
 	driverSwapContext context.Context
 	driverSwapCfn     context.CancelFunc
 
+	// activeSince is the start of the driver's current connected and loaded interval, if they are loaded.
+	// activeDuration is the total length of their previous intervals this session.
+	activeSince    time.Time
+	activeDuration time.Duration
+
+	// lastSplinePos is the most recent NormalisedSplinePos received for the driver, if hasSplinePos is true.
+	lastSplinePos float32
+	hasSplinePos  bool
+
+	// lastCarUpdateBroadcast is the time that the driver's most recent car update was sent to Live Timings.
+	lastCarUpdateBroadcast time.Time
+
 	// Cars is a map of CarModel to the information for that car.
 	Cars map[string]*RaceControlCarLapInfo `json:"Cars"`
 
+	// carModelsUsed is the keys of Cars, in the order that they were added.
+	carModelsUsed []string
+
 	mutex sync.Mutex
 }
 
@@ -61,15 +184,317 @@ func (rcd *RaceControlDriver) CurrentCar() *RaceControlCarLapInfo {
 	return &RaceControlCarLapInfo{}
 }
 
+// addCar adds lap information for the given car model, if the driver has not already used it.
+func (rcd *RaceControlDriver) addCar(carModel string) {
+	if _, ok := rcd.Cars[carModel]; ok {
+		return
+	}
+
+	rcd.Cars[carModel] = NewRaceControlCarLapInfo(carModel)
+	rcd.carModelsUsed = append(rcd.carModelsUsed, carModel)
+}
+
+// CarsUsed is the car models that the driver has used, in the order they first used them.
+func (rcd *RaceControlDriver) CarsUsed() []string {
+	carsUsed := make([]string, 0, len(rcd.Cars))
+	seen := make(map[string]bool, len(rcd.Cars))
+
+	for _, model := range rcd.carModelsUsed {
+		if _, ok := rcd.Cars[model]; ok && !seen[model] {
+			carsUsed = append(carsUsed, model)
+			seen[model] = true
+		}
+	}
+
+	// drivers loaded from persisted timing data have no record of the order their cars were used in.
+	var unordered []string
+
+	for model := range rcd.Cars {
+		if !seen[model] {
+			unordered = append(unordered, model)
+		}
+	}
+
+	sort.Strings(unordered)
+
+	return append(carsUsed, unordered...)
+}
+
+// updateBeatPersonalTrackBest checks whether the driver's best lap this session is faster than their personal best.
+func (rcd *RaceControlDriver) updateBeatPersonalTrackBest() {
+	bestLap := rcd.CurrentCar().BestLap
+
+	rcd.BeatPersonalTrackBest = rcd.PersonalTrackBest > 0 && bestLap > 0 && bestLap < rcd.PersonalTrackBest
+}
+
+// lastActive is the most recent time that the driver was seen on track or completed a lap.
+func (rcd *RaceControlDriver) lastActive() time.Time {
+	lastActive := rcd.ConnectedTime
+
+	if rcd.LastSeen.After(lastActive) {
+		lastActive = rcd.LastSeen
+	}
+
+	if lastLap := rcd.CurrentCar().LastLapCompletedTime; lastLap.After(lastActive) {
+		lastActive = lastLap
+	}
+
+	return lastActive
+}
+
+// ActiveDuration is the total time that the driver has been connected and loaded in this session, across all of
+// their connections.
+func (rcd *RaceControlDriver) ActiveDuration() time.Duration {
+	activeDuration := rcd.activeDuration
+
+	if !rcd.activeSince.IsZero() {
+		activeDuration += time.Since(rcd.activeSince)
+	}
+
+	return activeDuration
+}
+
+// startActiveInterval marks the driver as having loaded into the session at the given time.
+func (rcd *RaceControlDriver) startActiveInterval(t time.Time) {
+	if rcd.activeSince.IsZero() {
+		rcd.activeSince = t
+	}
+}
+
+// endActiveInterval adds the driver's current loaded interval (if any) to their active duration.
+func (rcd *RaceControlDriver) endActiveInterval(t time.Time) {
+	if rcd.activeSince.IsZero() {
+		return
+	}
+
+	rcd.activeDuration += t.Sub(rcd.activeSince)
+	rcd.activeSince = time.Time{}
+}
+
+// resetActiveDuration clears the driver's active duration at the start of a new session. Drivers who are loaded
+// are counted as active from the start of the session.
+func (rcd *RaceControlDriver) resetActiveDuration(sessionStart time.Time) {
+	rcd.activeDuration = 0
+
+	if !rcd.activeSince.IsZero() {
+		rcd.activeSince = sessionStart
+	}
+}
+
+// resetSessionStats clears the driver's cuts, clean lap percentage and consistency, e.g. at the start of a session.
+func (rcd *RaceControlDriver) resetSessionStats() {
+	for _, car := range rcd.Cars {
+		car.TotalCuts = 0
+		car.cutPenalties = 0
+		car.cleanLaps = nil
+		car.Consistency = 0
+	}
+
+	rcd.CleanLapPercentage = 0
+	rcd.sessionLaps = 0
+	rcd.sessionCleanLaps = 0
+	rcd.ConsecutiveInvalidLaps = 0
+
+	rcd.LapsLed = 0
+	rcd.PositionHistory = nil
+	rcd.historyPosition = 0
+	rcd.positionSince = time.Time{}
+
+	rcd.PitStops = 0
+	rcd.lapsAtLastPitStop = rcd.TotalNumLaps
+
+	rcd.ManualPosition = nil
+}
+
+// onPitLaneExit counts a pit stop when the driver leaves the pit lane, if they have completed a lap since their last
+// counted pit stop.
+func (rcd *RaceControlDriver) onPitLaneExit() {
+	if rcd.TotalNumLaps <= rcd.lapsAtLastPitStop {
+		return
+	}
+
+	rcd.PitStops++
+	rcd.lapsAtLastPitStop = rcd.TotalNumLaps
+}
+
+// updatePositionHistory adds the time since the driver's last position update to the position they held, then
+// starts timing the given position. A position of 0 stops timing, e.g. when the driver disconnects. now should come
+// from time.Now, so that its monotonic clock reading is used to measure the time in position.
+func (rcd *RaceControlDriver) updatePositionHistory(position int, now time.Time) {
+	if rcd.historyPosition > 0 && !rcd.positionSince.IsZero() {
+		for len(rcd.PositionHistory) < rcd.historyPosition {
+			rcd.PositionHistory = append(rcd.PositionHistory, 0)
+		}
+
+		rcd.PositionHistory[rcd.historyPosition-1] += now.Sub(rcd.positionSince)
+	}
+
+	rcd.historyPosition = position
+	rcd.positionSince = now
+}
+
+// Copy creates a deep copy of the RaceControlDriver, suitable for serialisation without racing concurrent updates.
+// The caller must not hold the driver's mutex.
+func (rcd *RaceControlDriver) Copy() *RaceControlDriver {
+	rcd.mutex.Lock()
+	defer rcd.mutex.Unlock()
+
+	driver := &RaceControlDriver{
+		CarInfo:       rcd.CarInfo,
+		TotalNumLaps:  rcd.TotalNumLaps,
+		ConnectedTime: rcd.ConnectedTime,
+		LoadedTime:    rcd.LoadedTime,
+		Position:      rcd.Position,
+		Split:         rcd.Split,
+		GapToLeader:   rcd.GapToLeader,
+		LastSeen:      rcd.LastSeen,
+		LastPos:       rcd.LastPos,
+		ModelMismatch: rcd.ModelMismatch,
+		DeltaToRecord: rcd.DeltaToRecord,
+		HoldsRecord:   rcd.HoldsRecord,
+		Cars:          make(map[string]*RaceControlCarLapInfo, len(rcd.Cars)),
+
+		LappedTrackGap:         rcd.LappedTrackGap,
+		TrackPosition:          rcd.TrackPosition,
+		PersonalTrackBest:      rcd.PersonalTrackBest,
+		BeatPersonalTrackBest:  rcd.BeatPersonalTrackBest,
+		WrongCar:               rcd.WrongCar,
+		LapPhase:               rcd.LapPhase,
+		InPits:                 rcd.InPits,
+		PitStops:               rcd.PitStops,
+		Stationary:             rcd.Stationary,
+		Stale:                  rcd.Stale,
+		CleanStreak:            rcd.CleanStreak,
+		CleanLapPercentage:     rcd.CleanLapPercentage,
+		ConsecutiveInvalidLaps: rcd.ConsecutiveInvalidLaps,
+		LapsDownAtDisconnect:   rcd.LapsDownAtDisconnect,
+		TeamName:               rcd.TeamName,
+		LapsLed:                rcd.LapsLed,
+
+		activeSince:    rcd.activeSince,
+		activeDuration: rcd.activeDuration,
+
+		establishedCarModel: rcd.establishedCarModel,
+		pitExitTime:         rcd.pitExitTime,
+		lapsSincePitExit:    rcd.lapsSincePitExit,
+		sessionLaps:         rcd.sessionLaps,
+		sessionCleanLaps:    rcd.sessionCleanLaps,
+		lapsAtLastPitStop:   rcd.lapsAtLastPitStop,
+		historyPosition:     rcd.historyPosition,
+		positionSince:       rcd.positionSince,
+		stationarySince:     rcd.stationarySince,
+	}
+
+	if rcd.Collisions != nil {
+		driver.Collisions = make([]Collision, len(rcd.Collisions))
+		copy(driver.Collisions, rcd.Collisions)
+	}
+
+	for model, car := range rcd.Cars {
+		carCopy := *car
+
+		if car.cleanLaps != nil {
+			carCopy.cleanLaps = make([]time.Duration, len(car.cleanLaps))
+			copy(carCopy.cleanLaps, car.cleanLaps)
+		}
+
+		driver.Cars[model] = &carCopy
+	}
+
+	if rcd.ManualPosition != nil {
+		manualPosition := *rcd.ManualPosition
+		driver.ManualPosition = &manualPosition
+	}
+
+	if rcd.PositionHistory != nil {
+		driver.PositionHistory = make([]time.Duration, len(rcd.PositionHistory))
+		copy(driver.PositionHistory, rcd.PositionHistory)
+	}
+
+	if rcd.carModelsUsed != nil {
+		driver.carModelsUsed = make([]string, len(rcd.carModelsUsed))
+		copy(driver.carModelsUsed, rcd.carModelsUsed)
+	}
+
+	return driver
+}
+
+// convertSpeeds converts the top speeds and speed trap speeds of a copy of a driver from km/h to the unit, e.g. before
+// it is sent to Live Timings.
+func (rcd *RaceControlDriver) convertSpeeds(unit SpeedUnit) {
+	for _, car := range rcd.Cars {
+		car.TopSpeedThisLap = unit.FromKilometersPerHour(car.TopSpeedThisLap)
+		car.TopSpeedBestLap = unit.FromKilometersPerHour(car.TopSpeedBestLap)
+		car.SpeedTrapLast = unit.FromKilometersPerHour(car.SpeedTrapLast)
+		car.SpeedTrapBest = unit.FromKilometersPerHour(car.SpeedTrapBest)
+	}
+}
+
+// anonymise replaces the DriverGUIDs of a copy of a driver, and of the drivers they collided with, with
+// anonymise(DriverGUID).
+func (rcd *RaceControlDriver) anonymise(anonymise func(udp.DriverGUID) udp.DriverGUID) {
+	rcd.CarInfo.DriverGUID = anonymise(rcd.CarInfo.DriverGUID)
+
+	for i := range rcd.Collisions {
+		rcd.Collisions[i].DriverGUID = anonymise(rcd.Collisions[i].DriverGUID)
+		rcd.Collisions[i].OtherDriverGUID = anonymise(rcd.Collisions[i].OtherDriverGUID)
+	}
+}
+
 type RaceControlCarLapInfo struct {
+	// top speeds and speed trap speeds are stored in km/h, and converted to the configured SpeedUnit when they are
+	// sent to Live Timings.
 	TopSpeedThisLap      float64       `json:"TopSpeedThisLap"`
 	TopSpeedBestLap      float64       `json:"TopSpeedBestLap"`
+	SpeedTrapLast        float64       `json:"SpeedTrapLast"`
+	SpeedTrapBest        float64       `json:"SpeedTrapBest"`
 	BestLap              time.Duration `json:"BestLap"`
 	NumLaps              int           `json:"NumLaps"`
+	NumValidLaps         int           `json:"NumValidLaps"`
 	LastLap              time.Duration `json:"LastLap"`
+	LastLapValid         bool          `json:"LastLapValid"`
 	LastLapCompletedTime time.Time     `json:"LastLapCompletedTime" ts:"date"`
 	TotalLapTime         time.Duration `json:"TotalLapTime"`
 	CarName              string        `json:"CarName"`
+
+	// Laps longer than the maximum plausible lap time (e.g. in/out laps, or laps with a spin) are anomalous. They are
+	// counted in NumLaps and TotalLapTime, but are not considered for BestLap or AverageLap.
+	LastLapAnomalous bool          `json:"LastLapAnomalous"`
+	NumAnomalousLaps int           `json:"NumAnomalousLaps"`
+	AnomalousLapTime time.Duration `json:"AnomalousLapTime"`
+	AverageLap       time.Duration `json:"AverageLap"`
+
+	// firstLapTime is the time of the first lap in the car, if it was not anomalous. It is left out of AverageLap if
+	// AverageLapSkipsFirstLap is enabled, since a standing start skews the average.
+	firstLapTime time.Duration
+
+	// TotalCuts is the number of cuts (times the car left the track) across all of the laps completed in the car
+	// this session.
+	TotalCuts int `json:"TotalCuts"`
+
+	// cutPenalties is the number of penalties given for reaching MaxCutsBeforePenalty in the car this session.
+	cutPenalties int
+
+	// Consistency is the standard deviation of the car's recent clean laps (the last consistencyLaps laps without
+	// cuts which were not anomalous). The lower it is, the more consistent the driver.
+	Consistency time.Duration `json:"Consistency"`
+	cleanLaps   []time.Duration
+}
+
+// updateAverageLap calculates the average of the car's laps, leaving out anomalous laps and (if excludeFirstLap
+// is set) the first lap. The average is not changed if there are no laps to calculate it from.
+func (c *RaceControlCarLapInfo) updateAverageLap(excludeFirstLap bool) {
+	numLaps := c.NumLaps - c.NumAnomalousLaps
+	lapTime := c.TotalLapTime - c.AnomalousLapTime
+
+	if excludeFirstLap && c.firstLapTime > 0 {
+		numLaps--
+		lapTime -= c.firstLapTime
+	}
+
+	if numLaps > 0 {
+		c.AverageLap = lapTime / time.Duration(numLaps)
+	}
 }
 
 type DriverMap struct {
@@ -79,6 +504,10 @@ type DriverMap struct {
 	driverSortLessFunc driverSortLessFunc
 	driverGroup        RaceControlDriverGroup
 
+	// recordPositionHistory reports whether the time drivers spend in each position should be recorded when they are
+	// sorted. If it is nil, position history is not recorded.
+	recordPositionHistory func() bool
+
 	rwMutex sync.RWMutex
 }
 
@@ -89,7 +518,32 @@ const (
 	DisconnectedDrivers RaceControlDriverGroup = 1
 )
 
-type driverSortLessFunc func(group RaceControlDriverGroup, driverA, driverB *RaceControlDriver) bool
+// SortMode is the order in which drivers are listed in Live Timings.
+type SortMode string
+
+const (
+	// SortByPosition lists drivers in their positions in the session. This is by laps and total time in a race, or
+	// by best lap in other sessions.
+	SortByPosition SortMode = "position"
+	SortByBestLap  SortMode = "best-lap"
+	SortByLastLap  SortMode = "last-lap"
+	SortByTopSpeed SortMode = "top-speed"
+	SortByName     SortMode = "name"
+)
+
+// parseSortMode reads a SortMode, e.g. from a query parameter. If mode is empty, drivers are sorted by position.
+func parseSortMode(mode string) (SortMode, error) {
+	switch sortMode := SortMode(mode); sortMode {
+	case "":
+		return SortByPosition, nil
+	case SortByPosition, SortByBestLap, SortByLastLap, SortByTopSpeed, SortByName:
+		return sortMode, nil
+	default:
+		return "", fmt.Errorf("racecontrol: unknown sort mode: %s", mode)
+	}
+}
+
+type driverSortLessFunc func(mode SortMode, group RaceControlDriverGroup, driverA, driverB *RaceControlDriver) bool
 
 func NewDriverMap(driverGroup RaceControlDriverGroup, driverSortLessFunc driverSortLessFunc) *DriverMap {
 	return &DriverMap{
@@ -129,10 +583,18 @@ func (d *DriverMap) Get(driverGUID udp.DriverGUID) (*RaceControlDriver, bool) {
 	return driver, ok
 }
 
+// Add adds a driver to the DriverMap and sorts the drivers. The caller must not hold the lock of the driver, or of any
+// other driver in the DriverMap.
 func (d *DriverMap) Add(driverGUID udp.DriverGUID, driver *RaceControlDriver) {
+	d.add(driverGUID, driver)
+	d.sortByPosition()
+}
+
+// add adds a driver to the DriverMap without sorting the drivers, so that it can be called while holding the driver's
+// lock. The caller must call sortByPosition once the lock has been released.
+func (d *DriverMap) add(driverGUID udp.DriverGUID, driver *RaceControlDriver) {
 	d.rwMutex.Lock()
 	defer d.rwMutex.Unlock()
-	defer d.sort()
 
 	d.Drivers[driverGUID] = driver
 
@@ -145,38 +607,159 @@ func (d *DriverMap) Add(driverGUID udp.DriverGUID, driver *RaceControlDriver) {
 	d.GUIDsInPositionalOrder = append(d.GUIDsInPositionalOrder, driverGUID)
 }
 
-func (d *DriverMap) sort() {
-	sort.Slice(d.GUIDsInPositionalOrder, func(i, j int) bool {
-		driverA, ok := d.Drivers[d.GUIDsInPositionalOrder[i]]
+// sort orders guids in the given mode, looking each driver up in drivers. Manual positions are only applied when
+// sorting by position. Drivers are passed in rather than read from the DriverMap so that copies of them can be sorted,
+// e.g. for a snapshot of Live Timings. The caller must make sure that the drivers are not modified while sorting.
+func (d *DriverMap) sort(mode SortMode, guids []udp.DriverGUID, drivers map[udp.DriverGUID]*RaceControlDriver, manualPositions map[udp.DriverGUID]int) []udp.DriverGUID {
+	sort.SliceStable(guids, func(i, j int) bool {
+		driverA, ok := drivers[guids[i]]
 
 		if !ok {
 			return false
 		}
 
-		driverB, ok := d.Drivers[d.GUIDsInPositionalOrder[j]]
+		driverB, ok := drivers[guids[j]]
 
 		if !ok {
 			return false
 		}
 
-		return d.driverSortLessFunc(d.driverGroup, driverA, driverB)
+		return d.driverSortLessFunc(mode, d.driverGroup, driverA, driverB)
 	})
 
-	// correct positions
-	for pos, guid := range d.GUIDsInPositionalOrder {
-		driver, ok := d.Drivers[guid]
+	if mode == SortByPosition {
+		guids = applyManualPositions(guids, manualPositions)
+	}
 
-		if !ok {
+	return guids
+}
+
+// sortByPosition sorts the drivers by position and updates their positions. Each driver's lock is taken in turn, so
+// the caller must not hold the DriverMap's lock, or the lock of any driver in it. Otherwise, two goroutines which
+// each hold a driver's lock could wait for each other's.
+func (d *DriverMap) sortByPosition() {
+	manualPositions := d.manualPositions()
+
+	d.rwMutex.Lock()
+	d.GUIDsInPositionalOrder = d.sort(SortByPosition, d.GUIDsInPositionalOrder, d.Drivers, manualPositions)
+
+	drivers := make([]*RaceControlDriver, 0, len(d.GUIDsInPositionalOrder))
+
+	for _, guid := range d.GUIDsInPositionalOrder {
+		if driver, ok := d.Drivers[guid]; ok {
+			drivers = append(drivers, driver)
+		}
+	}
+	d.rwMutex.Unlock()
+
+	// positions are updated once the DriverMap's lock has been released, since each driver's lock is taken.
+	recordPositionHistory := d.recordingPositionHistory()
+	now := time.Now()
+
+	for index, driver := range drivers {
+		driver.mutex.Lock()
+		driver.Position = index + 1
+
+		if recordPositionHistory {
+			driver.updatePositionHistory(driver.Position, now)
+		}
+		driver.mutex.Unlock()
+	}
+}
+
+// manualPositions returns the ManualPosition of each driver who has one.
+func (d *DriverMap) manualPositions() map[udp.DriverGUID]int {
+	manualPositions := make(map[udp.DriverGUID]int)
+
+	_ = d.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		driver.mutex.Lock()
+		defer driver.mutex.Unlock()
+
+		if driver.ManualPosition != nil {
+			manualPositions[driverGUID] = *driver.ManualPosition
+		}
+
+		return nil
+	})
+
+	return manualPositions
+}
+
+// applyManualPositions moves drivers with a manual position to that position, keeping the other drivers in their
+// sorted order around them. If two drivers are pinned to the same position, the second is moved to the next free one.
+func applyManualPositions(guids []udp.DriverGUID, manualPositions map[udp.DriverGUID]int) []udp.DriverGUID {
+	var pinned, unpinned []udp.DriverGUID
+
+	for _, guid := range guids {
+		if _, ok := manualPositions[guid]; ok {
+			pinned = append(pinned, guid)
+		} else {
+			unpinned = append(unpinned, guid)
+		}
+	}
+
+	if len(pinned) == 0 {
+		return guids
+	}
+
+	sort.SliceStable(pinned, func(i, j int) bool {
+		return manualPositions[pinned[i]] < manualPositions[pinned[j]]
+	})
+
+	order := make([]udp.DriverGUID, len(guids))
+	filled := make([]bool, len(order))
+
+	for _, guid := range pinned {
+		index := manualPositions[guid] - 1
+
+		if index < 0 {
+			index = 0
+		} else if index >= len(order) {
+			index = len(order) - 1
+		}
+
+		for filled[index] {
+			index = (index + 1) % len(order)
+		}
+
+		order[index] = guid
+		filled[index] = true
+	}
+
+	for index := range order {
+		if filled[index] {
 			continue
 		}
 
-		driver.Position = pos + 1
+		order[index] = unpinned[0]
+		unpinned = unpinned[1:]
 	}
+
+	return order
+}
+
+func (d *DriverMap) recordingPositionHistory() bool {
+	return d.recordPositionHistory != nil && d.recordPositionHistory()
 }
 
+// Del removes a driver from the DriverMap and sorts the remaining drivers. The caller must not hold the lock of the
+// driver, or of any other driver in the DriverMap.
 func (d *DriverMap) Del(driverGUID udp.DriverGUID) {
+	if driver, ok := d.Get(driverGUID); ok {
+		driver.mutex.Lock()
+		d.del(driverGUID, driver)
+		driver.mutex.Unlock()
+	}
+
+	d.sortByPosition()
+}
+
+// del removes a driver from the DriverMap without sorting the remaining drivers, so that it can be called while
+// holding the driver's lock. The caller must hold the driver's lock, and call sortByPosition once it has been released.
+func (d *DriverMap) del(driverGUID udp.DriverGUID, driver *RaceControlDriver) {
 	d.rwMutex.Lock()
-	defer d.rwMutex.Unlock()
+
+	_, ok := d.Drivers[driverGUID]
 
 	delete(d.Drivers, driverGUID)
 
@@ -187,7 +770,38 @@ func (d *DriverMap) Del(driverGUID udp.DriverGUID) {
 		}
 	}
 
-	d.sort()
+	d.rwMutex.Unlock()
+
+	if ok && d.recordingPositionHistory() {
+		// stop timing the driver's position until they are added again
+		driver.updatePositionHistory(0, time.Now())
+	}
+}
+
+// copyForLiveTimings returns a copy of the DriverMap and its drivers, with their speeds converted to the unit and
+// each DriverGUID replaced with anonymise(DriverGUID).
+func (d *DriverMap) copyForLiveTimings(unit SpeedUnit, anonymise func(udp.DriverGUID) udp.DriverGUID) *DriverMap {
+	d.rwMutex.RLock()
+	defer d.rwMutex.RUnlock()
+
+	driverMap := &DriverMap{
+		Drivers:                make(map[udp.DriverGUID]*RaceControlDriver, len(d.Drivers)),
+		GUIDsInPositionalOrder: make([]udp.DriverGUID, len(d.GUIDsInPositionalOrder)),
+	}
+
+	for i, driverGUID := range d.GUIDsInPositionalOrder {
+		driverMap.GUIDsInPositionalOrder[i] = anonymise(driverGUID)
+	}
+
+	for driverGUID, driver := range d.Drivers {
+		driverCopy := driver.Copy()
+		driverCopy.convertSpeeds(unit)
+		driverCopy.anonymise(anonymise)
+
+		driverMap.Drivers[anonymise(driverGUID)] = driverCopy
+	}
+
+	return driverMap
 }
 
 func (d *DriverMap) Len() int {
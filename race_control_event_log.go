@@ -0,0 +1,169 @@
+package servermanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/JustaPenguin/assetto-server-manager/pkg/udp"
+	"github.com/JustaPenguin/assetto-server-manager/pkg/udp/replay"
+)
+
+// eventLogQueueSize is the number of UDP messages which can be waiting to be written to the event log. Messages are
+// dropped when the queue is full, so that a slow store never holds up the handling of UDP messages.
+const eventLogQueueSize = 1000
+
+// eventLogWriter appends UDP messages to the event log from a background worker, in the order they were received.
+type eventLogWriter struct {
+	store Store
+	queue chan *replay.Entry
+
+	startOnce sync.Once
+}
+
+func newEventLogWriter(store Store) *eventLogWriter {
+	return &eventLogWriter{
+		store: store,
+		queue: make(chan *replay.Entry, eventLogQueueSize),
+	}
+}
+
+// Append queues a message to be added to the event log, timestamped with the time it was received.
+func (w *eventLogWriter) Append(message udp.Message) {
+	w.startOnce.Do(func() {
+		go panicCapture(w.run)
+	})
+
+	entry := &replay.Entry{
+		Received:  time.Now(),
+		EventType: message.Event(),
+		Data:      message,
+	}
+
+	select {
+	case w.queue <- entry:
+	default:
+		logrus.Warnf("Event log queue is full, dropping UDP message: %d", entry.EventType)
+	}
+}
+
+func (w *eventLogWriter) run() {
+	for entry := range w.queue {
+		if err := w.store.AppendEventLog(entry); err != nil {
+			logrus.WithError(err).Errorf("Could not add UDP message to the event log")
+		}
+	}
+}
+
+// logUDPMessage adds a message to the event log, if LogUDPEvents is enabled.
+func (rc *RaceControl) logUDPMessage(message udp.Message) {
+	if !rc.cachedServerOptions().LogUDPEvents {
+		return
+	}
+
+	rc.eventLog.Append(message)
+}
+
+// LoadEventLog replays the UDP messages in the event log which were received between from and to (inclusive) into a
+// new RaceControl, in the order they were received and without waiting between them, so that a session can be
+// reproduced deterministically. The new RaceControl has its own temporary store with a copy of the server options, so
+// the replay never loads or changes live timings, and it never sends anything to the server, to Live Timings or to the
+// webhook. The new RaceControl is returned once the replay has finished, along with a func which stops it and removes
+// its temporary store. The func must be called once the new RaceControl is no longer needed.
+func (rc *RaceControl) LoadEventLog(from, to time.Time) (*RaceControl, func(), error) {
+	entries, err := rc.store.ListEventLog(from, to)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dir, err := ioutil.TempDir("", "event-log-replay")
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	store := NewJSONStore(filepath.Join(dir, "store"), filepath.Join(dir, "shared"))
+
+	serverOptions := *rc.cachedServerOptions()
+	serverOptions.LogUDPEvents = false
+	serverOptions.LiveLapCSV = false
+	serverOptions.WebhookURL = ""
+
+	if err := store.UpsertServerOptions(&serverOptions); err != nil {
+		_ = os.RemoveAll(dir)
+
+		return nil, nil, err
+	}
+
+	process := &eventLogReplayProcess{ServerProcess: rc.process}
+
+	replayed := NewRaceControl(NilBroadcaster{}, rc.trackDataGateway, process, store, NewPenaltiesManager(store))
+	replayed.synchronousCarUpdates = true
+
+	entries.Replay(0, replayed.UDPCallback, 0)
+
+	stop := func() {
+		_ = process.Stop()
+		replayed.Stop()
+
+		if err := os.RemoveAll(dir); err != nil {
+			logrus.WithError(err).Errorf("Could not remove event log replay store: %s", dir)
+		}
+	}
+
+	return replayed, stop, nil
+}
+
+// eventLogReplayProcess is the server process of a RaceControl which is replaying the event log. It reports the event
+// running on the server, but never sends messages to the server or changes its state.
+type eventLogReplayProcess struct {
+	ServerProcess
+
+	notifyDoneChs []chan struct{}
+	stopped       bool
+	mutex         sync.Mutex
+}
+
+func (*eventLogReplayProcess) Start(RaceEvent, string, int, string, int) error {
+	return nil
+}
+
+// Stop closes the channels passed to NotifyDone, as the replayed server has finished.
+func (p *eventLogReplayProcess) Stop() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.stopped {
+		return nil
+	}
+
+	p.stopped = true
+
+	for _, doneCh := range p.notifyDoneChs {
+		close(doneCh)
+	}
+
+	return nil
+}
+
+func (*eventLogReplayProcess) Restart() error {
+	return nil
+}
+
+func (*eventLogReplayProcess) UDPCallback(udp.Message) {}
+
+func (*eventLogReplayProcess) SendUDPMessage(udp.Message) error {
+	return nil
+}
+
+func (p *eventLogReplayProcess) NotifyDone(ch chan struct{}) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.notifyDoneChs = append(p.notifyDoneChs, ch)
+}
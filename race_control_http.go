@@ -1,6 +1,8 @@
 package servermanager
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"strconv"
@@ -52,10 +54,13 @@ type RaceControlHub struct {
 	clients   map[*raceControlClient]bool
 	broadcast chan []byte
 	register  chan *raceControlClient
+
+	// anonymiser, if set, is applied to every message before it is encoded and sent to clients.
+	anonymiser func(message udp.Message) udp.Message
 }
 
 func (h *RaceControlHub) Send(message udp.Message) ([]byte, error) {
-	encoded, err := encodeRaceControlMessage(message)
+	encoded, err := h.encode(message)
 
 	if err != nil {
 		return nil, err
@@ -66,6 +71,158 @@ func (h *RaceControlHub) Send(message udp.Message) ([]byte, error) {
 	return encoded, nil
 }
 
+func (h *RaceControlHub) encode(message udp.Message) ([]byte, error) {
+	if h.anonymiser != nil {
+		message = h.anonymiser(message)
+	}
+
+	return encodeRaceControlMessage(message)
+}
+
+// anonymiseDriverGUID replaces a DriverGUID with an opaque token. The token is a hash of the GUID and salt, so it is
+// stable for as long as the salt is unchanged, e.g. when a driver reconnects.
+func anonymiseDriverGUID(guid udp.DriverGUID, salt string) udp.DriverGUID {
+	hash := sha256.Sum256([]byte(salt + string(guid)))
+
+	return udp.DriverGUID(hex.EncodeToString(hash[:])[:16])
+}
+
+// driverGUIDAnonymiser returns a function which replaces a DriverGUID with its anonymised token, or nil if
+// AnonymiseDriverGUIDs is not enabled.
+func (rc *RaceControl) driverGUIDAnonymiser() func(udp.DriverGUID) udp.DriverGUID {
+	serverOptions := rc.cachedServerOptions()
+
+	if !serverOptions.AnonymiseDriverGUIDs {
+		return nil
+	}
+
+	return func(driverGUID udp.DriverGUID) udp.DriverGUID {
+		if driverGUID == "" {
+			return driverGUID
+		}
+
+		return anonymiseDriverGUID(driverGUID, serverOptions.AnonymiseGUIDSalt)
+	}
+}
+
+// anonymisedRaceControl is race control with its DriverGUIDs anonymised when it is encoded.
+type anonymisedRaceControl struct {
+	rc        *RaceControl
+	anonymise func(udp.DriverGUID) udp.DriverGUID
+}
+
+func (anonymisedRaceControl) Event() udp.Event {
+	return EventRaceControl
+}
+
+func (a anonymisedRaceControl) MarshalJSON() ([]byte, error) {
+	return a.rc.marshalJSON(a.anonymise)
+}
+
+// anonymiseDriverGUIDs returns a copy of a Live Timings message with its DriverGUIDs replaced by opaque tokens, if
+// AnonymiseDriverGUIDs is enabled. Messages without DriverGUIDs are returned as they are.
+func (rc *RaceControl) anonymiseDriverGUIDs(message udp.Message) udp.Message {
+	anonymise := rc.driverGUIDAnonymiser()
+
+	if anonymise == nil {
+		return message
+	}
+
+	switch m := message.(type) {
+	case *RaceControl:
+		return anonymisedRaceControl{rc: m, anonymise: anonymise}
+	case udp.SessionCarInfo:
+		m.DriverGUID = anonymise(m.DriverGUID)
+		return m
+	case udp.CarInfo:
+		m.DriverGUID = anonymise(m.DriverGUID)
+		return m
+	case udp.Chat:
+		m.DriverGUID = anonymise(m.DriverGUID)
+		return m
+	case SessionFastestLap:
+		m.DriverGUID = anonymise(m.DriverGUID)
+		return m
+	case FirstLap:
+		m.DriverGUID = anonymise(m.DriverGUID)
+		return m
+	case InvalidLap:
+		m.DriverGUID = anonymise(m.DriverGUID)
+		return m
+	case PitLane:
+		m.DriverGUID = anonymise(m.DriverGUID)
+		return m
+	case Stationary:
+		m.DriverGUID = anonymise(m.DriverGUID)
+		return m
+	case SpeedTrapLeaderboard:
+		leaderboard := make(SpeedTrapLeaderboard, len(m))
+
+		for i, entry := range m {
+			entry.DriverGUID = anonymise(entry.DriverGUID)
+			leaderboard[i] = entry
+		}
+
+		return leaderboard
+	case PoleAnnouncement:
+		frontRow := make([]FrontRowEntry, len(m.FrontRow))
+
+		for i, entry := range m.FrontRow {
+			entry.DriverGUID = anonymise(entry.DriverGUID)
+			frontRow[i] = entry
+		}
+
+		m.FrontRow = frontRow
+		return m
+	default:
+		return message
+	}
+}
+
+// anonymiseSnapshot replaces the DriverGUIDs in a Live Timings snapshot with opaque tokens, if AnonymiseDriverGUIDs
+// is enabled. The snapshot's drivers are already copies, so they are changed in place.
+func (rc *RaceControl) anonymiseSnapshot(snapshot LiveTimingsSnapshot) LiveTimingsSnapshot {
+	anonymise := rc.driverGUIDAnonymiser()
+
+	if anonymise == nil {
+		return snapshot
+	}
+
+	for _, drivers := range [][]*RaceControlDriver{snapshot.ConnectedDrivers, snapshot.DisconnectedDrivers} {
+		for _, driver := range drivers {
+			driver.anonymise(anonymise)
+		}
+	}
+
+	if snapshot.SessionFastestLap != nil {
+		snapshot.SessionFastestLap.DriverGUID = anonymise(snapshot.SessionFastestLap.DriverGUID)
+	}
+
+	return snapshot
+}
+
+// resolveDriverGUID finds the DriverGUID of a connected driver from either their DriverGUID or, if
+// AnonymiseDriverGUIDs is enabled, their anonymised token.
+func (rc *RaceControl) resolveDriverGUID(guidOrToken string) udp.DriverGUID {
+	serverOptions := rc.cachedServerOptions()
+
+	if !serverOptions.AnonymiseDriverGUIDs {
+		return udp.DriverGUID(guidOrToken)
+	}
+
+	resolved := udp.DriverGUID(guidOrToken)
+
+	_ = rc.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
+		if string(anonymiseDriverGUID(driverGUID, serverOptions.AnonymiseGUIDSalt)) == guidOrToken {
+			resolved = driverGUID
+		}
+
+		return nil
+	})
+
+	return resolved
+}
+
 func newRaceControlHub() *RaceControlHub {
 	return &RaceControlHub{
 		broadcast: make(chan []byte, 1000),
@@ -244,6 +401,43 @@ func (rch *RaceControlHandler) liveTiming(w http.ResponseWriter, r *http.Request
 	})
 }
 
+// liveTimingJSON returns a snapshot of Live Timings, optionally filtered by the "car" and "min-laps" query parameters,
+// and sorted by the "sort" query parameter.
+func (rch *RaceControlHandler) liveTimingJSON(w http.ResponseWriter, r *http.Request) {
+	filter := LiveTimingsFilter{
+		CarModel: r.URL.Query().Get("car"),
+	}
+
+	if minLaps := r.URL.Query().Get("min-laps"); minLaps != "" {
+		var err error
+
+		filter.MinLaps, err = strconv.Atoi(minLaps)
+
+		if err != nil {
+			http.Error(w, "min-laps must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	sortMode, err := parseSortMode(r.URL.Query().Get("sort"))
+
+	if err != nil {
+		http.Error(w, "sort must be one of position, best-lap, last-lap, top-speed or name", http.StatusBadRequest)
+		return
+	}
+
+	encoded, err := json.Marshal(rch.raceControl.anonymiseSnapshot(rch.raceControl.Snapshot(filter, sortMode)))
+
+	if err != nil {
+		logrus.WithError(err).Errorf("could not encode live timing snapshot")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(encoded)
+}
+
 func deleteEmpty(s []string) []string {
 	var r []string
 	for _, str := range s {
@@ -295,7 +489,7 @@ func (rch *RaceControlHandler) websocket(w http.ResponseWriter, r *http.Request)
 	rch.raceControl.ChatMessagesMutex.Lock()
 
 	for _, message := range rch.raceControl.ChatMessages {
-		encoded, err := encodeRaceControlMessage(message)
+		encoded, err := rch.raceControlHub.encode(message)
 
 		if err != nil {
 			continue
@@ -348,6 +542,8 @@ func (rch *RaceControlHandler) kickUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	guid = string(rch.raceControl.resolveDriverGUID(guid))
+
 	err := rch.raceControl.ConnectedDrivers.Each(func(driverGUID udp.DriverGUID, driver *RaceControlDriver) error {
 		if string(driverGUID) != guid {
 			return nil
@@ -367,6 +563,57 @@ func (rch *RaceControlHandler) kickUser(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// forceDisconnect removes a driver from the connected drivers in Live Timings, for drivers who are stuck as connected
+// after leaving the server.
+func (rch *RaceControlHandler) forceDisconnect(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		return
+	}
+
+	guid := r.FormValue("force-disconnect")
+
+	if (guid == "") || (guid == "default-driver-spacer") {
+		return
+	}
+
+	err := rch.raceControl.ForceDisconnect(rch.raceControl.resolveDriverGUID(guid))
+
+	if err != nil {
+		logrus.WithError(err).Errorf("Unable to force driver to disconnect: %s", guid)
+		http.Error(w, "The driver could not be disconnected, they may not be connected", http.StatusNotFound)
+	}
+}
+
+// manualPosition pins a driver to a position in Live Timings, e.g. while resolving a dispute.
+func (rch *RaceControlHandler) manualPosition(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		return
+	}
+
+	guid := r.FormValue("manual-position-user")
+
+	if (guid == "") || (guid == "default-driver-spacer") {
+		return
+	}
+
+	position, err := strconv.Atoi(r.FormValue("manual-position"))
+
+	if err == nil {
+		err = rch.raceControl.SetManualPosition(rch.raceControl.resolveDriverGUID(guid), position)
+	}
+
+	if err != nil {
+		logrus.WithError(err).Errorf("Unable to set manual position for driver: %s", guid)
+		http.Error(w, "The manual position could not be set", http.StatusBadRequest)
+	}
+}
+
+func (rch *RaceControlHandler) clearManualPositions(w http.ResponseWriter, r *http.Request) {
+	if err := rch.raceControl.ClearManualPositions(); err != nil {
+		logrus.WithError(err).Errorf("Unable to clear manual positions")
+	}
+}
+
 func (rch *RaceControlHandler) sendChat(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		return
@@ -378,6 +625,8 @@ func (rch *RaceControlHandler) sendChat(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	guid = string(rch.raceControl.resolveDriverGUID(guid))
+
 	err := rch.raceControl.splitAndSendChat(r.FormValue("send-chat"), guid)
 
 	if err != nil {
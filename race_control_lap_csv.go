@@ -0,0 +1,152 @@
+package servermanager
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/JustaPenguin/assetto-server-manager/pkg/udp"
+)
+
+var lapCSVHeaders = []string{"Time", "Driver GUID", "Driver Name", "Car Model", "Lap Time", "Lap Time (ms)", "Cuts", "Track", "Track Layout", "Session Type", "Session Name"}
+
+// lapCSVWriter writes laps to a CSV file as they are completed. Each lap is flushed to the file immediately, so
+// that the file is complete up to the most recent lap even if Server Manager stops unexpectedly.
+type lapCSVWriter struct {
+	file   *os.File
+	writer *csv.Writer
+
+	mutex sync.Mutex
+}
+
+func lapCSVDirectory() string {
+	return filepath.Join(ServerInstallPath, "logs", "laps")
+}
+
+// newLapCSVWriter creates a new CSV file for the laps of a session.
+func newLapCSVWriter(sessionInfo udp.SessionInfo) (*lapCSVWriter, error) {
+	if err := os.MkdirAll(lapCSVDirectory(), 0755); err != nil {
+		return nil, err
+	}
+
+	filename := fmt.Sprintf("laps_%s_%s_%s.csv", time.Now().Format("2006-01-02_15-04-05"), sessionInfo.Track, sessionInfo.Type.String())
+
+	file, err := os.Create(filepath.Join(lapCSVDirectory(), filename))
+
+	if err != nil {
+		return nil, err
+	}
+
+	w := &lapCSVWriter{
+		file:   file,
+		writer: csv.NewWriter(file),
+	}
+
+	if err := w.write(lapCSVHeaders); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *lapCSVWriter) write(record []string) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.writer.Write(record); err != nil {
+		return err
+	}
+
+	w.writer.Flush()
+
+	return w.writer.Error()
+}
+
+// WriteLap adds a lap to the end of the CSV file.
+func (w *lapCSVWriter) WriteLap(lap *LapLogEntry) error {
+	return w.write([]string{
+		lap.Time.Format(time.RFC3339),
+		string(lap.DriverGUID),
+		lap.DriverName,
+		lap.CarModel,
+		lap.LapTime.String(),
+		strconv.FormatInt(lap.LapTime.Milliseconds(), 10),
+		strconv.Itoa(lap.Cuts),
+		lap.Track,
+		lap.TrackLayout,
+		lap.SessionType.String(),
+		lap.SessionName,
+	})
+}
+
+// Name is the path of the CSV file.
+func (w *lapCSVWriter) Name() string {
+	return w.file.Name()
+}
+
+func (w *lapCSVWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.writer.Flush()
+
+	return w.file.Close()
+}
+
+// rotateLapCSV closes the CSV file for the previous session and, if LiveLapCSV is enabled, opens a new one for the
+// given session.
+func (rc *RaceControl) rotateLapCSV(sessionInfo udp.SessionInfo) {
+	rc.closeLapCSV()
+
+	if !rc.cachedServerOptions().LiveLapCSV {
+		return
+	}
+
+	lapCSV, err := newLapCSVWriter(sessionInfo)
+
+	if err != nil {
+		logrus.WithError(err).Errorf("Could not create live lap CSV file")
+		return
+	}
+
+	rc.lapCSVMutex.Lock()
+	rc.lapCSV = lapCSV
+	rc.lapCSVMutex.Unlock()
+}
+
+// closeLapCSV closes the CSV file for the current session, if there is one.
+func (rc *RaceControl) closeLapCSV() {
+	rc.lapCSVMutex.Lock()
+	defer rc.lapCSVMutex.Unlock()
+
+	if rc.lapCSV == nil {
+		return
+	}
+
+	if err := rc.lapCSV.Close(); err != nil {
+		logrus.WithError(err).Errorf("Could not close live lap CSV file")
+	}
+
+	rc.lapCSV = nil
+}
+
+// writeLapToCSV writes a lap to the CSV file for the current session, if there is one.
+func (rc *RaceControl) writeLapToCSV(lap *LapLogEntry) {
+	rc.lapCSVMutex.Lock()
+	defer rc.lapCSVMutex.Unlock()
+
+	if rc.lapCSV == nil {
+		return
+	}
+
+	if err := rc.lapCSV.WriteLap(lap); err != nil {
+		logrus.WithError(err).Errorf("Could not write lap to live lap CSV file")
+	}
+}
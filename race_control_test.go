@@ -1,13 +1,29 @@
 package servermanager
 
 import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/JustaPenguin/assetto-server-manager/pkg/udp"
+	"github.com/JustaPenguin/assetto-server-manager/pkg/udp/replay"
 )
 
 var testStore = NewJSONStore(filepath.Join(os.TempDir(), "asm-race-store"), filepath.Join(os.TempDir(), "asm-race-store-shared"))
@@ -279,6 +295,154 @@ func (nilTrackData) TrackMap(name, layout string) (*TrackMapData, error) {
 	return &TrackMapData{}, nil
 }
 
+// recordingServerProcess is a dummyServerProcess which records all UDP messages sent to it. It can optionally be
+// given a RaceEvent to return as the current event.
+type recordingServerProcess struct {
+	dummyServerProcess
+
+	event RaceEvent
+
+	messages      []udp.Message
+	messagesMutex sync.Mutex
+}
+
+func (p *recordingServerProcess) Event() RaceEvent {
+	if p.event != nil {
+		return p.event
+	}
+
+	return p.dummyServerProcess.Event()
+}
+
+func (p *recordingServerProcess) SendUDPMessage(message udp.Message) error {
+	p.messagesMutex.Lock()
+	defer p.messagesMutex.Unlock()
+
+	p.messages = append(p.messages, message)
+
+	return nil
+}
+
+// chatMessagesTo returns the (decoded) chat messages which were sent to a given CarID.
+func (p *recordingServerProcess) chatMessagesTo(carID udp.CarID) []string {
+	p.messagesMutex.Lock()
+	defer p.messagesMutex.Unlock()
+
+	var out []string
+
+	for _, message := range p.messages {
+		sendChat, ok := message.(*udp.SendChat)
+
+		if !ok || sendChat.CarID != uint8(carID) {
+			continue
+		}
+
+		out = append(out, decodeUTF32Chat(sendChat.UTF32Encoded))
+	}
+
+	return out
+}
+
+// broadcastChatMessages returns the (decoded) chat messages which were broadcast to all drivers.
+func (p *recordingServerProcess) broadcastChatMessages() []string {
+	p.messagesMutex.Lock()
+	defer p.messagesMutex.Unlock()
+
+	var out []string
+
+	for _, message := range p.messages {
+		broadcastChat, ok := message.(*udp.BroadcastChat)
+
+		if !ok {
+			continue
+		}
+
+		out = append(out, decodeUTF32Chat(broadcastChat.UTF32Encoded))
+	}
+
+	return out
+}
+
+// countingBroadcaster counts the number of messages sent for each udp.Event.
+type countingBroadcaster struct {
+	counts map[udp.Event]int
+	mutex  sync.Mutex
+}
+
+func (b *countingBroadcaster) Send(message udp.Message) ([]byte, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.counts == nil {
+		b.counts = make(map[udp.Event]int)
+	}
+
+	b.counts[message.Event()]++
+
+	return nil, nil
+}
+
+func (b *countingBroadcaster) count(event udp.Event) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.counts[event]
+}
+
+// decodeUTF32Chat decodes little endian UTF32 encoded ascii chat messages.
+func decodeUTF32Chat(encoded []byte) string {
+	var out strings.Builder
+
+	for i := 0; i < len(encoded); i += 4 {
+		out.WriteByte(encoded[i])
+	}
+
+	return out.String()
+}
+
+// newIsolatedTestStore creates a store in its own temporary directory, with the default server options modified by fn
+// (if set), so that a test's server options and the data it persists (e.g. live timings) can't leak into other tests.
+// Tests which need non-default server options should use it rather than modifying the testStore. The returned func
+// removes the store.
+func newIsolatedTestStore(t *testing.T, fn func(opts *GlobalServerConfig)) (Store, func()) {
+	dir, err := ioutil.TempDir("", "asm-race-store")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewJSONStore(filepath.Join(dir, "store"), filepath.Join(dir, "shared"))
+
+	opts := ConfigIniDefault().GlobalServerConfig
+
+	if fn != nil {
+		fn(&opts)
+	}
+
+	if err := store.UpsertServerOptions(&opts); err != nil {
+		t.Fatal(err)
+	}
+
+	return store, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+// updateServerOptions modifies the server options saved in a store, e.g. one created by newIsolatedTestStore.
+func updateServerOptions(t *testing.T, store Store, fn func(opts *GlobalServerConfig)) {
+	opts, err := store.LoadServerOptions()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fn(opts)
+
+	if err := store.UpsertServerOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestRaceControl_OnNewSession(t *testing.T) {
 	t.Run("New session, no previous data", func(t *testing.T) {
 		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
@@ -894,7 +1058,7 @@ func TestRaceControl_SortDrivers(t *testing.T) {
 
 		rc.ConnectedDrivers.Add(d2.CarInfo.DriverGUID, d2)
 
-		rc.ConnectedDrivers.sort()
+		rc.ConnectedDrivers.sortByPosition()
 
 		if rc.ConnectedDrivers.GUIDsInPositionalOrder[0] != drivers[1].DriverGUID {
 			t.Error("Driver 1 should be in first")
@@ -938,7 +1102,7 @@ func TestRaceControl_SortDrivers(t *testing.T) {
 
 			rc.ConnectedDrivers.Add(d3.CarInfo.DriverGUID, d3)
 
-			rc.ConnectedDrivers.sort()
+			rc.ConnectedDrivers.sortByPosition()
 
 			if rc.ConnectedDrivers.GUIDsInPositionalOrder[0] != drivers[1].DriverGUID {
 				t.Error("Driver 1 should be in first")
@@ -978,7 +1142,7 @@ func TestRaceControl_SortDrivers(t *testing.T) {
 		d3.CurrentCar().LastLapCompletedTime = time.Now()
 		rc.DisconnectedDrivers.Add(d3.CarInfo.DriverGUID, d3)
 
-		rc.DisconnectedDrivers.sort()
+		rc.DisconnectedDrivers.sortByPosition()
 
 		if rc.DisconnectedDrivers.GUIDsInPositionalOrder[0] != drivers[2].DriverGUID {
 			t.Error("Driver 2 should be in first")
@@ -1017,7 +1181,7 @@ func TestRaceControl_SortDrivers(t *testing.T) {
 		d3.CurrentCar().BestLap = 0
 		rc.DisconnectedDrivers.Add(d3.CarInfo.DriverGUID, d3)
 
-		rc.DisconnectedDrivers.sort()
+		rc.DisconnectedDrivers.sortByPosition()
 
 		if rc.DisconnectedDrivers.GUIDsInPositionalOrder[0] != drivers[1].DriverGUID {
 			t.Error("Driver 1 should be in first")
@@ -1118,3 +1282,7294 @@ func TestRaceControl_Event(t *testing.T) {
 		return
 	}
 }
+
+func TestRaceControl_CollisionsBetween(t *testing.T) {
+	rc := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	start := time.Now()
+
+	d0 := NewRaceControlDriver(drivers[0])
+	d0.Collisions = []Collision{
+		{ID: "a", Type: CollisionWithCar, Time: start.Add(10 * time.Second)},
+		{ID: "b", Type: CollisionWithEnvironment, Time: start.Add(40 * time.Second)},
+	}
+	rc.ConnectedDrivers.Add(d0.CarInfo.DriverGUID, d0)
+
+	d1 := NewRaceControlDriver(drivers[1])
+	d1.Collisions = []Collision{
+		{ID: "c", Type: CollisionWithCar, Time: start.Add(5 * time.Second)},
+		{ID: "d", Type: CollisionWithCar, Time: start.Add(20 * time.Second)},
+	}
+	rc.DisconnectedDrivers.Add(d1.CarInfo.DriverGUID, d1)
+
+	t.Run("All collisions", func(t *testing.T) {
+		collisions := rc.CollisionsBetween(start, start.Add(time.Minute))
+
+		if len(collisions) != 4 {
+			t.Errorf("Expected 4 collisions, got: %d", len(collisions))
+			return
+		}
+
+		for i, id := range []string{"c", "a", "d", "b"} {
+			if collisions[i].ID != id {
+				t.Errorf("Expected collision %d to be %s, was: %s", i, id, collisions[i].ID)
+			}
+		}
+	})
+
+	t.Run("Collisions within range", func(t *testing.T) {
+		collisions := rc.CollisionsBetween(start.Add(10*time.Second), start.Add(30*time.Second))
+
+		if len(collisions) != 2 {
+			t.Errorf("Expected 2 collisions, got: %d", len(collisions))
+			return
+		}
+
+		if collisions[0].ID != "a" || collisions[0].DriverGUID != drivers[0].DriverGUID {
+			t.Errorf("Expected first collision to be 'a' involving driver 0, got: %s (%s)", collisions[0].ID, collisions[0].DriverGUID)
+		}
+
+		if collisions[1].ID != "d" || collisions[1].DriverGUID != drivers[1].DriverGUID {
+			t.Errorf("Expected second collision to be 'd' involving driver 1, got: %s (%s)", collisions[1].ID, collisions[1].DriverGUID)
+		}
+	})
+
+	t.Run("No collisions in range", func(t *testing.T) {
+		collisions := rc.CollisionsBetween(start.Add(time.Hour), start.Add(2*time.Hour))
+
+		if len(collisions) != 0 {
+			t.Errorf("Expected no collisions, got: %d", len(collisions))
+		}
+	})
+}
+
+func TestRaceControl_SolWarning(t *testing.T) {
+	solEvent := &ActiveChampionship{RaceConfig: CurrentRaceConfig{IsSol: 1}}
+
+	countSolWarnings := func(messages []string) int {
+		return strings.Count(strings.Join(messages, " "), "This server is running Sol.")
+	}
+
+	loadDriverTwice := func(t *testing.T, process *recordingServerProcess, solWarningMode SolWarningMode) {
+		store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+			opts.SolWarningMode = solWarningMode
+		})
+		defer cleanup()
+
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+		for i := 0; i < 2; i++ {
+			if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := raceControl.OnClientLoaded(udp.ClientLoaded(drivers[0].CarID)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	t.Run("Always (default)", func(t *testing.T) {
+		process := &recordingServerProcess{event: solEvent}
+		loadDriverTwice(t, process, SolWarningModeAlways)
+
+		if count := countSolWarnings(process.chatMessagesTo(drivers[0].CarID)); count != 2 {
+			t.Errorf("Expected 2 Sol warnings, got: %d", count)
+		}
+	})
+
+	t.Run("First join only", func(t *testing.T) {
+		process := &recordingServerProcess{event: solEvent}
+		loadDriverTwice(t, process, SolWarningModeFirstJoin)
+
+		if count := countSolWarnings(process.chatMessagesTo(drivers[0].CarID)); count != 1 {
+			t.Errorf("Expected 1 Sol warning, got: %d", count)
+		}
+	})
+
+	t.Run("Suppressed", func(t *testing.T) {
+		process := &recordingServerProcess{event: solEvent}
+		loadDriverTwice(t, process, SolWarningModeNever)
+
+		messages := process.chatMessagesTo(drivers[0].CarID)
+
+		if len(messages) == 0 {
+			t.Error("Expected welcome messages to be sent")
+		}
+
+		if count := countSolWarnings(messages); count != 0 {
+			t.Errorf("Expected no Sol warnings, got: %d", count)
+		}
+	})
+}
+
+func TestRaceControl_LiveTimingChatCommand(t *testing.T) {
+	originalBaseURL := config.HTTP.BaseURL
+	defer func() { config.HTTP.BaseURL = originalBaseURL }()
+
+	process := &recordingServerProcess{}
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Base URL configured", func(t *testing.T) {
+		config.HTTP.BaseURL = "https://example.com"
+
+		raceControl.UDPCallback(udp.Chat{CarID: drivers[0].CarID, Message: "/timing"})
+
+		messages := process.chatMessagesTo(drivers[0].CarID)
+
+		if len(messages) != 1 {
+			t.Errorf("Expected 1 chat message, got: %d", len(messages))
+			return
+		}
+
+		if !strings.Contains(messages[0], "https://example.com/live-timing") {
+			t.Errorf("Expected live timing link in message, got: %s", messages[0])
+		}
+	})
+
+	t.Run("No base URL configured", func(t *testing.T) {
+		config.HTTP.BaseURL = ""
+
+		raceControl.UDPCallback(udp.Chat{CarID: drivers[0].CarID, Message: "/timing"})
+
+		if messages := process.chatMessagesTo(drivers[0].CarID); len(messages) != 1 {
+			t.Errorf("Expected no further chat messages, got: %d", len(messages)-1)
+		}
+	})
+}
+
+func TestRaceControl_CollisionChatWarning(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.CollisionChatWarningSpeed = 50
+	})
+	defer cleanup()
+
+	setup := func(t *testing.T) (*RaceControl, *recordingServerProcess) {
+		process := &recordingServerProcess{}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+		for _, driver := range drivers[:2] {
+			if err := raceControl.OnClientConnect(driver); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return raceControl, process
+	}
+
+	t.Run("Above threshold", func(t *testing.T) {
+		raceControl, process := setup(t)
+
+		err := raceControl.OnCollisionWithCar(udp.CollisionWithCar{
+			CarID:       drivers[0].CarID,
+			OtherCarID:  drivers[1].CarID,
+			ImpactSpeed: 20, // 72 km/h
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, driver := range drivers[:2] {
+			if messages := process.chatMessagesTo(driver.CarID); len(messages) != 1 {
+				t.Errorf("Expected driver %s to be sent 1 collision warning, got: %d", driver.DriverGUID, len(messages))
+			}
+		}
+	})
+
+	t.Run("Below threshold", func(t *testing.T) {
+		raceControl, process := setup(t)
+
+		err := raceControl.OnCollisionWithCar(udp.CollisionWithCar{
+			CarID:       drivers[0].CarID,
+			OtherCarID:  drivers[1].CarID,
+			ImpactSpeed: 5, // 18 km/h
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, driver := range drivers[:2] {
+			if messages := process.chatMessagesTo(driver.CarID); len(messages) != 0 {
+				t.Errorf("Expected driver %s to be sent no collision warnings, got: %d", driver.DriverGUID, len(messages))
+			}
+		}
+	})
+}
+
+// flakyUDPServerProcess fails to send the first numFailures UDP messages, as if there were no open UDP connection.
+type flakyUDPServerProcess struct {
+	recordingServerProcess
+
+	numFailures      int
+	numFailuresMutex sync.Mutex
+}
+
+func (p *flakyUDPServerProcess) SendUDPMessage(message udp.Message) error {
+	p.numFailuresMutex.Lock()
+
+	if p.numFailures > 0 {
+		p.numFailures--
+		p.numFailuresMutex.Unlock()
+
+		return ErrNoOpenUDPConnection
+	}
+
+	p.numFailuresMutex.Unlock()
+
+	return p.recordingServerProcess.SendUDPMessage(message)
+}
+
+// newSessionInfoRequestTest creates a RaceControl which requests session info every 5ms, backing off to at most 20ms.
+func newSessionInfoRequestTest(process ServerProcess) *RaceControl {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, testStore, NewPenaltiesManager(testStore))
+	raceControl.sessionInfoMaxBackoff = time.Millisecond * 20
+
+	raceControl.sessionInfoIntervalMutex.Lock()
+	raceControl.sessionInfoInterval = time.Millisecond * 5
+	raceControl.sessionInfoIntervalMutex.Unlock()
+
+	return raceControl
+}
+
+func TestRaceControl_RequestSessionInfoRecovers(t *testing.T) {
+	process := &flakyUDPServerProcess{numFailures: 3}
+	raceControl := newSessionInfoRequestTest(process)
+
+	done := make(chan struct{})
+
+	go func() {
+		raceControl.requestSessionInfo()
+		close(done)
+	}()
+
+	timeout := time.After(time.Second * 5)
+
+	for {
+		process.messagesMutex.Lock()
+		numRequests := len(process.messages)
+		process.messagesMutex.Unlock()
+
+		if numRequests >= 2 {
+			break
+		}
+
+		select {
+		case <-done:
+			t.Fatal("Session info request loop stopped, expected it to recover")
+		case <-timeout:
+			t.Fatalf("Session info requests did not recover, only %d requests sent", numRequests)
+		case <-time.After(time.Millisecond * 5):
+		}
+	}
+
+	raceControl.serverProcessStopped <- struct{}{}
+	<-done
+}
+
+func TestRaceControl_RequestSessionInfoRetriesUntilStopped(t *testing.T) {
+	const numFailures = 1000
+
+	process := &flakyUDPServerProcess{numFailures: numFailures}
+	raceControl := newSessionInfoRequestTest(process)
+
+	done := make(chan struct{})
+
+	go func() {
+		raceControl.requestSessionInfo()
+		close(done)
+	}()
+
+	numFailedRequests := func() int {
+		process.numFailuresMutex.Lock()
+		defer process.numFailuresMutex.Unlock()
+
+		return numFailures - process.numFailures
+	}
+
+	timeout := time.After(time.Second * 5)
+
+	// keep going well past the point at which the backoff is capped.
+	for numFailedRequests() < 15 {
+		select {
+		case <-done:
+			t.Fatalf("Session info request loop stopped after %d failed requests, expected it to keep retrying", numFailedRequests())
+		case <-timeout:
+			t.Fatalf("Session info requests were not retried, only %d requests sent", numFailedRequests())
+		case <-time.After(time.Millisecond * 5):
+		}
+	}
+
+	raceControl.serverProcessStopped <- struct{}{}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Session info request loop did not stop when the server process stopped")
+	}
+}
+
+func TestRaceControl_SessionInfoInterval(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		seconds  int
+		expected time.Duration
+	}{
+		{name: "Not set", seconds: 0, expected: sessionInfoRequestInterval},
+		{name: "Configured", seconds: 60, expected: time.Minute},
+		{name: "Below the minimum", seconds: 2, expected: minSessionInfoRequestInterval},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			store, cleanup := newIsolatedTestStore(t, nil)
+			defer cleanup()
+
+			raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+			// drain the change from NewRaceControl, so that only the new session's change is seen.
+			select {
+			case <-raceControl.sessionInfoIntervalChanged:
+			default:
+			}
+
+			updateServerOptions(t, store, func(opts *GlobalServerConfig) {
+				opts.SessionInfoInterval = testCase.seconds
+			})
+
+			if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 30}); err != nil {
+				t.Fatal(err)
+			}
+
+			if interval := raceControl.currentSessionInfoInterval(); interval != testCase.expected {
+				t.Errorf("Expected session info interval of %s, got: %s", testCase.expected, interval)
+			}
+
+			changed := false
+
+			select {
+			case <-raceControl.sessionInfoIntervalChanged:
+				changed = true
+			default:
+			}
+
+			if expectChange := testCase.expected != sessionInfoRequestInterval; changed != expectChange {
+				t.Errorf("Expected interval change to be signalled: %t, got: %t", expectChange, changed)
+			}
+		})
+	}
+}
+
+// TestRaceControl_PersistTimingDataConcurrentUpdates should be run with the race detector enabled.
+func TestRaceControl_PersistTimingDataConcurrentUpdates(t *testing.T) {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+	raceControl.SessionInfo.Type = udp.SessionTypePractice
+
+	for _, entrant := range drivers {
+		if err := raceControl.OnClientConnect(entrant); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for _, entrant := range drivers {
+		wg.Add(1)
+
+		go func(carID udp.CarID) {
+			defer wg.Done()
+
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				err := raceControl.handleCarUpdate(udp.CarUpdate{
+					CarID:    carID,
+					Pos:      udp.Vec{X: float32(i), Y: 0, Z: float32(i)},
+					Velocity: udp.Vec{X: float32(i % 100), Y: 0, Z: 10},
+				})
+
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(entrant.CarID)
+	}
+
+	for i := 0; i < 50; i++ {
+		err := raceControl.OnLapCompleted(udp.LapCompleted{
+			CarID:   drivers[i%len(drivers)].CarID,
+			LapTime: uint32(rand.Intn(100000)),
+		})
+
+		if err != nil {
+			t.Error(err)
+			break
+		}
+	}
+
+	close(done)
+	wg.Wait()
+
+	lapTimes := raceControl.AllLapTimes()
+
+	for guid, driver := range lapTimes {
+		liveDriver, ok := raceControl.ConnectedDrivers.Get(guid)
+
+		if !ok {
+			t.Errorf("Driver %s not found in connected drivers", guid)
+			continue
+		}
+
+		if driver == liveDriver || driver.CurrentCar() == liveDriver.CurrentCar() {
+			t.Errorf("Expected AllLapTimes to return copies of drivers, not live drivers")
+		}
+	}
+}
+
+func TestRaceControl_LastLapValid(t *testing.T) {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	driver, ok := raceControl.ConnectedDrivers.Get(drivers[0].DriverGUID)
+
+	if !ok {
+		t.Fatal("Driver not found in connected drivers")
+	}
+
+	for _, lap := range []struct {
+		Cuts          uint8
+		ExpectedValid bool
+	}{
+		{Cuts: 0, ExpectedValid: true},
+		{Cuts: 2, ExpectedValid: false},
+		{Cuts: 0, ExpectedValid: true},
+	} {
+		err := raceControl.OnLapCompleted(udp.LapCompleted{
+			CarID:   drivers[0].CarID,
+			LapTime: 90000,
+			Cuts:    lap.Cuts,
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if driver.CurrentCar().LastLapValid != lap.ExpectedValid {
+			t.Errorf("Expected last lap with %d cuts to have validity: %t", lap.Cuts, lap.ExpectedValid)
+		}
+	}
+}
+
+func TestRaceControl_TrimDisconnectedDrivers(t *testing.T) {
+	addDisconnectedDrivers := func(rc *RaceControl) {
+		for i, entrant := range drivers[:4] {
+			driver := NewRaceControlDriver(entrant)
+			driver.TotalNumLaps = 1
+			driver.LastSeen = time.Now().Add(-time.Duration(i) * time.Minute)
+
+			if i%2 == 0 {
+				driver.CurrentCar().BestLap = time.Minute
+			}
+
+			rc.DisconnectedDrivers.Add(entrant.DriverGUID, driver)
+		}
+	}
+
+	t.Run("Least recently active drivers are removed", func(t *testing.T) {
+		rc := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+		rc.SessionInfo.Type = udp.SessionTypeRace
+
+		addDisconnectedDrivers(rc)
+
+		rc.trimDisconnectedDrivers(2)
+
+		if rc.DisconnectedDrivers.Len() != 2 {
+			t.Fatalf("Expected 2 disconnected drivers, got: %d", rc.DisconnectedDrivers.Len())
+		}
+
+		for _, entrant := range drivers[:2] {
+			if _, ok := rc.DisconnectedDrivers.Get(entrant.DriverGUID); !ok {
+				t.Errorf("Expected recently active driver %s to be kept", entrant.DriverGUID)
+			}
+		}
+	})
+
+	t.Run("Qualifying times are kept", func(t *testing.T) {
+		rc := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+		rc.SessionInfo.Type = udp.SessionTypeQualifying
+
+		addDisconnectedDrivers(rc)
+
+		rc.trimDisconnectedDrivers(2)
+
+		if rc.DisconnectedDrivers.Len() != 2 {
+			t.Fatalf("Expected 2 disconnected drivers, got: %d", rc.DisconnectedDrivers.Len())
+		}
+
+		for _, entrant := range []udp.SessionCarInfo{drivers[0], drivers[2]} {
+			if _, ok := rc.DisconnectedDrivers.Get(entrant.DriverGUID); !ok {
+				t.Errorf("Expected driver %s with a qualifying time to be kept", entrant.DriverGUID)
+			}
+		}
+	})
+
+	t.Run("No limit", func(t *testing.T) {
+		rc := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+		addDisconnectedDrivers(rc)
+
+		rc.trimDisconnectedDrivers(0)
+
+		if rc.DisconnectedDrivers.Len() != 4 {
+			t.Errorf("Expected 4 disconnected drivers, got: %d", rc.DisconnectedDrivers.Len())
+		}
+	})
+}
+
+func TestRaceControl_SendWelcome(t *testing.T) {
+	process := &recordingServerProcess{}
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := raceControl.OnClientLoaded(udp.ClientLoaded(drivers[0].CarID)); err != nil {
+		t.Fatal(err)
+	}
+
+	loadedMessages := process.chatMessagesTo(drivers[0].CarID)
+
+	if len(loadedMessages) == 0 {
+		t.Fatal("Expected welcome message to be sent on load")
+	}
+
+	if err := raceControl.SendWelcome(drivers[0].DriverGUID); err != nil {
+		t.Fatal(err)
+	}
+
+	resentMessages := process.chatMessagesTo(drivers[0].CarID)[len(loadedMessages):]
+
+	if strings.Join(loadedMessages, "\n") != strings.Join(resentMessages, "\n") {
+		t.Errorf("Expected re-sent welcome message to match original. Original: %v, re-sent: %v", loadedMessages, resentMessages)
+	}
+
+	t.Run("Unknown driver", func(t *testing.T) {
+		if err := raceControl.SendWelcome(drivers[1].DriverGUID); err == nil {
+			t.Error("Expected an error sending welcome message to unknown driver, got nil")
+		}
+	})
+}
+
+func TestRaceControl_SpeedTrap(t *testing.T) {
+	driveThroughSpeedTrap := func(t *testing.T, rc *RaceControl, carID udp.CarID, splinePositions []float32, velocity float32) {
+		for _, splinePos := range splinePositions {
+			err := rc.handleCarUpdate(udp.CarUpdate{
+				CarID:               carID,
+				Velocity:            udp.Vec{X: velocity},
+				NormalisedSplinePos: splinePos,
+			})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	t.Run("Speeds are recorded at the speed trap", func(t *testing.T) {
+		store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+			opts.SpeedTrapSplinePosition = 0.5
+		})
+		defer cleanup()
+
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+		for _, entrant := range drivers[:2] {
+			if err := raceControl.OnClientConnect(entrant); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// speeds are taken from the first update after the trap, so driver 0 is recorded at the slower speed.
+		driveThroughSpeedTrap(t, raceControl, drivers[0].CarID, []float32{0.4, 0.49}, 80)
+		driveThroughSpeedTrap(t, raceControl, drivers[0].CarID, []float32{0.51, 0.6}, 20)
+
+		// driver 1 speeds up after passing the trap, which should not count.
+		driveThroughSpeedTrap(t, raceControl, drivers[1].CarID, []float32{0.45, 0.52}, 50)
+		driveThroughSpeedTrap(t, raceControl, drivers[1].CarID, []float32{0.53}, 100)
+
+		leaderboard := raceControl.SpeedTrapLeaderboard()
+
+		if len(leaderboard) != 2 {
+			t.Fatalf("Expected 2 speed trap entries, got %d", len(leaderboard))
+		}
+
+		// the leaderboard is fastest first, so driver 1 leads it.
+		if leaderboard[0].DriverGUID != drivers[1].DriverGUID || leaderboard[1].DriverGUID != drivers[0].DriverGUID {
+			t.Errorf("Speed trap leaderboard is in the incorrect order")
+		}
+
+		if leaderboard[0].Speed != metersPerSecondToKilometersPerHour(50) {
+			t.Errorf("Expected driver 1 speed trap speed to be the speed as they passed the trap, got %.2f", leaderboard[0].Speed)
+		}
+
+		if leaderboard[1].Speed != metersPerSecondToKilometersPerHour(20) {
+			t.Errorf("Expected driver 0 speed trap speed to be the speed as they passed the trap, got %.2f", leaderboard[1].Speed)
+		}
+	})
+
+	t.Run("Speed trap at the start/finish line", func(t *testing.T) {
+		store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+			opts.SpeedTrapSplinePosition = 1
+		})
+		defer cleanup()
+
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+		if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		driveThroughSpeedTrap(t, raceControl, drivers[0].CarID, []float32{0.95, 0.99, 0.02}, 60)
+
+		leaderboard := raceControl.SpeedTrapLeaderboard()
+
+		if len(leaderboard) != 1 || leaderboard[0].Speed != metersPerSecondToKilometersPerHour(60) {
+			t.Errorf("Expected a speed trap entry for the start/finish line, got: %v", leaderboard)
+		}
+	})
+
+	t.Run("No speed trap configured", func(t *testing.T) {
+		store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+			opts.SpeedTrapSplinePosition = 0
+		})
+		defer cleanup()
+
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+		if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		driveThroughSpeedTrap(t, raceControl, drivers[0].CarID, []float32{0.1, 0.5, 0.9, 0.1}, 60)
+
+		if leaderboard := raceControl.SpeedTrapLeaderboard(); len(leaderboard) != 0 {
+			t.Errorf("Expected no speed trap entries, got: %v", leaderboard)
+		}
+	})
+}
+
+func TestRaceControl_SpeedUnit(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.SpeedUnit = SpeedUnitMPH
+		opts.SpeedTrapSplinePosition = 0.5
+		opts.CollisionChatWarningSpeed = 50
+	})
+	defer cleanup()
+
+	process := &recordingServerProcess{}
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+	for _, driver := range drivers[:2] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if encoded, err := json.Marshal(raceControl); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(string(encoded), `"SpeedUnit":"mph"`) {
+		t.Errorf("Expected race control speed unit to be mph, got: %s", encoded)
+	}
+
+	t.Run("Speed trap speeds are converted", func(t *testing.T) {
+		for _, splinePos := range []float32{0.4, 0.6} {
+			err := raceControl.handleCarUpdate(udp.CarUpdate{
+				CarID:               drivers[0].CarID,
+				Velocity:            udp.Vec{X: 20},
+				NormalisedSplinePos: splinePos,
+			})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		leaderboard := raceControl.SpeedTrapLeaderboard()
+
+		if len(leaderboard) != 1 {
+			t.Fatalf("Expected 1 speed trap entry, got %d", len(leaderboard))
+		}
+
+		if leaderboard[0].Speed != metersPerSecondToMilesPerHour(20) || leaderboard[0].SpeedUnit != "mph" {
+			t.Errorf("Expected speed trap speed of %.2f mph, got %.2f %s", metersPerSecondToMilesPerHour(20), leaderboard[0].Speed, leaderboard[0].SpeedUnit)
+		}
+	})
+
+	t.Run("Top speeds are stored in km/h, and converted for Live Timings", func(t *testing.T) {
+		driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if topSpeed := driver.Copy().CurrentCar().TopSpeedThisLap; math.Abs(topSpeed-metersPerSecondToKilometersPerHour(20)) > 0.001 {
+			t.Errorf("Expected top speed to be stored as %.2f km/h, got %.2f", metersPerSecondToKilometersPerHour(20), topSpeed)
+		}
+
+		snapshot := raceControl.Snapshot(LiveTimingsFilter{}, SortByPosition)
+
+		if len(snapshot.ConnectedDrivers) != 2 {
+			t.Fatalf("Expected 2 connected drivers in the snapshot, got %d", len(snapshot.ConnectedDrivers))
+		}
+
+		if topSpeed := snapshot.ConnectedDrivers[0].CurrentCar().TopSpeedThisLap; math.Abs(topSpeed-metersPerSecondToMilesPerHour(20)) > 0.001 || snapshot.SpeedUnit != "mph" {
+			t.Errorf("Expected snapshot top speed of %.2f mph, got %.2f %s", metersPerSecondToMilesPerHour(20), topSpeed, snapshot.SpeedUnit)
+		}
+
+		encoded, err := json.Marshal(raceControl)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded struct {
+			ConnectedDrivers struct {
+				Drivers map[udp.DriverGUID]*RaceControlDriver
+			}
+		}
+
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatal(err)
+		}
+
+		if topSpeed := decoded.ConnectedDrivers.Drivers[drivers[0].DriverGUID].CurrentCar().TopSpeedThisLap; math.Abs(topSpeed-metersPerSecondToMilesPerHour(20)) > 0.001 {
+			t.Errorf("Expected encoded top speed of %.2f mph, got %.2f", metersPerSecondToMilesPerHour(20), topSpeed)
+		}
+	})
+
+	t.Run("Collision speeds are converted, but thresholds stay in km/h", func(t *testing.T) {
+		err := raceControl.OnCollisionWithCar(udp.CollisionWithCar{
+			CarID:       drivers[0].CarID,
+			OtherCarID:  drivers[1].CarID,
+			ImpactSpeed: 20, // 72 km/h, 44.7 mph
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(driver.Collisions) != 1 {
+			t.Fatalf("Expected 1 collision, got %d", len(driver.Collisions))
+		}
+
+		if collision := driver.Collisions[0]; math.Abs(collision.Speed-metersPerSecondToMilesPerHour(20)) > 0.001 || collision.SpeedUnit != "mph" {
+			t.Errorf("Expected collision speed of %.2f mph, got %.2f %s", metersPerSecondToMilesPerHour(20), collision.Speed, collision.SpeedUnit)
+		}
+
+		messages := process.chatMessagesTo(drivers[0].CarID)
+
+		if len(messages) != 1 || !strings.HasSuffix(messages[0], "(45 mph)") {
+			t.Errorf("Expected a collision warning in mph, got: %v", messages)
+		}
+	})
+}
+
+func TestRaceControl_RestartRaceIfTooFewDrivers(t *testing.T) {
+	numRestarts := func(process *recordingServerProcess) int {
+		process.messagesMutex.Lock()
+		defer process.messagesMutex.Unlock()
+
+		num := 0
+
+		for _, message := range process.messages {
+			if _, ok := message.(*udp.RestartSession); ok {
+				num++
+			}
+		}
+
+		return num
+	}
+
+	// setup creates a race control with three connected and loaded drivers, using a store with the server options
+	// modified by fn. The returned func removes the store.
+	setup := func(t *testing.T, fn func(opts *GlobalServerConfig)) (*RaceControl, *recordingServerProcess, func()) {
+		store, cleanup := newIsolatedTestStore(t, fn)
+
+		process := &recordingServerProcess{}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+		for _, driver := range drivers[:3] {
+			if err := raceControl.OnClientConnect(driver); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := raceControl.OnClientLoaded(udp.ClientLoaded(driver.CarID)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return raceControl, process, cleanup
+	}
+
+	stopRaceStartCheck := func(raceControl *RaceControl) {
+		raceControl.raceStartCheckTimerMutex.Lock()
+		defer raceControl.raceStartCheckTimerMutex.Unlock()
+
+		if raceControl.raceStartCheckTimer != nil {
+			raceControl.raceStartCheckTimer.Stop()
+		}
+	}
+
+	t.Run("Below threshold", func(t *testing.T) {
+		raceControl, process, cleanup := setup(t, nil)
+		defer cleanup()
+
+		restarted, err := raceControl.restartRaceIfTooFewDrivers(4)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !restarted || numRestarts(process) != 1 {
+			t.Errorf("Expected the race to be restarted")
+		}
+
+		if len(process.broadcastChatMessages()) == 0 {
+			t.Errorf("Expected drivers to be told about the restart in chat")
+		}
+	})
+
+	t.Run("Above threshold", func(t *testing.T) {
+		raceControl, process, cleanup := setup(t, nil)
+		defer cleanup()
+
+		restarted, err := raceControl.restartRaceIfTooFewDrivers(3)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if restarted || numRestarts(process) != 0 {
+			t.Errorf("Expected the race not to be restarted")
+		}
+
+		if len(process.broadcastChatMessages()) != 0 {
+			t.Errorf("Expected no chat messages to be sent")
+		}
+	})
+
+	t.Run("No drivers connected", func(t *testing.T) {
+		store, cleanup := newIsolatedTestStore(t, nil)
+		defer cleanup()
+
+		process := &recordingServerProcess{}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+		restarted, err := raceControl.restartRaceIfTooFewDrivers(4)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if restarted || numRestarts(process) != 0 {
+			t.Errorf("Expected the race not to be restarted with no drivers connected")
+		}
+	})
+
+	t.Run("Stale and loading drivers are not counted", func(t *testing.T) {
+		raceControl, process, cleanup := setup(t, nil)
+		defer cleanup()
+
+		loading, ok := raceControl.ConnectedDrivers.Get(drivers[1].DriverGUID)
+
+		if !ok {
+			t.Fatal("Expected driver to be connected")
+		}
+
+		loading.mutex.Lock()
+		loading.LoadedTime = time.Time{}
+		loading.mutex.Unlock()
+
+		stale, ok := raceControl.ConnectedDrivers.Get(drivers[2].DriverGUID)
+
+		if !ok {
+			t.Fatal("Expected driver to be connected")
+		}
+
+		stale.mutex.Lock()
+		stale.Stale = true
+		stale.mutex.Unlock()
+
+		restarted, err := raceControl.restartRaceIfTooFewDrivers(2)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !restarted || numRestarts(process) != 1 {
+			t.Errorf("Expected the race to be restarted")
+		}
+
+		chat := process.broadcastChatMessages()
+
+		if len(chat) == 0 || !strings.Contains(chat[0], "Only 1 of the 2 drivers") {
+			t.Errorf("Expected only the loaded driver to be counted, got chat: %v", chat)
+		}
+	})
+
+	t.Run("Repeated restarts are limited", func(t *testing.T) {
+		raceControl, process, cleanup := setup(t, func(opts *GlobalServerConfig) {
+			opts.MaxRaceRestarts = 2
+		})
+		defer cleanup()
+
+		for i := 0; i < 4; i++ {
+			if _, err := raceControl.restartRaceIfTooFewDrivers(4); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if numRestarts(process) != 2 {
+			t.Errorf("Expected the race to be restarted twice, got %d restarts", numRestarts(process))
+		}
+
+		if len(process.broadcastChatMessages()) != 2 {
+			t.Errorf("Expected drivers to be told about each restart in chat, got: %v", process.broadcastChatMessages())
+		}
+
+		// once the limit is reached, race starts are no longer checked.
+		err := raceControl.OnNewSession(udp.SessionInfo{
+			Track: "ks_laguna_seca",
+			Name:  "Race",
+			Type:  udp.SessionTypeRace,
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		raceControl.raceStartCheckTimerMutex.Lock()
+		scheduled := raceControl.raceStartCheckTimer != nil
+		raceControl.raceStartCheckTimerMutex.Unlock()
+
+		if scheduled {
+			t.Errorf("Expected no race start check to be scheduled once the restart limit is reached")
+		}
+	})
+
+	t.Run("Zero wait time waits for the minimum delay between restarts", func(t *testing.T) {
+		raceControl, process, cleanup := setup(t, func(opts *GlobalServerConfig) {
+			opts.MinimumRaceDrivers = 4
+		})
+		defer cleanup()
+		defer stopRaceStartCheck(raceControl)
+
+		sessionInfo := udp.SessionInfo{
+			Track:    "ks_laguna_seca",
+			Name:     "Race",
+			Type:     udp.SessionTypeRace,
+			WaitTime: 0,
+		}
+
+		// the restarted session is reported as a new session, which must not be restarted again straight away.
+		for i := 0; i < 3; i++ {
+			if err := raceControl.OnNewSession(sessionInfo); err != nil {
+				t.Fatal(err)
+			}
+
+			time.Sleep(time.Millisecond * 50)
+		}
+
+		if numRestarts(process) != 1 {
+			t.Errorf("Expected the race to be restarted once, got %d restarts", numRestarts(process))
+		}
+	})
+
+	t.Run("Checked when a race session starts", func(t *testing.T) {
+		raceControl, process, cleanup := setup(t, func(opts *GlobalServerConfig) {
+			opts.MinimumRaceDrivers = 4
+		})
+		defer cleanup()
+
+		err := raceControl.OnNewSession(udp.SessionInfo{
+			Track:    "ks_laguna_seca",
+			Name:     "Race",
+			Type:     udp.SessionTypeRace,
+			WaitTime: 0,
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(time.Millisecond * 50)
+
+		if numRestarts(process) != 1 {
+			t.Errorf("Expected the race to be restarted once, got %d restarts", numRestarts(process))
+		}
+	})
+}
+
+func TestRaceControl_SortedFor(t *testing.T) {
+	rc := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+	rc.SessionInfo.Type = udp.SessionTypePractice
+
+	// d0 has completed the most laps, d1 has the fastest lap.
+	d0 := NewRaceControlDriver(drivers[0])
+	d0.CurrentCar().NumLaps = 10
+	d0.CurrentCar().TotalLapTime = 1000
+	d0.CurrentCar().BestLap = 95
+
+	rc.ConnectedDrivers.Add(d0.CarInfo.DriverGUID, d0)
+
+	d1 := NewRaceControlDriver(drivers[1])
+	d1.CurrentCar().NumLaps = 5
+	d1.CurrentCar().TotalLapTime = 470
+	d1.CurrentCar().BestLap = 88
+
+	rc.ConnectedDrivers.Add(d1.CarInfo.DriverGUID, d1)
+
+	d2 := NewRaceControlDriver(drivers[2])
+	d2.CurrentCar().NumLaps = 8
+	d2.CurrentCar().TotalLapTime = 800
+	d2.CurrentCar().BestLap = 91
+
+	rc.ConnectedDrivers.Add(d2.CarInfo.DriverGUID, d2)
+
+	for _, testCase := range []struct {
+		SessionType   udp.SessionType
+		ExpectedOrder []udp.DriverGUID
+	}{
+		{
+			SessionType:   udp.SessionTypeRace,
+			ExpectedOrder: []udp.DriverGUID{drivers[0].DriverGUID, drivers[2].DriverGUID, drivers[1].DriverGUID},
+		},
+		{
+			SessionType:   udp.SessionTypeQualifying,
+			ExpectedOrder: []udp.DriverGUID{drivers[1].DriverGUID, drivers[2].DriverGUID, drivers[0].DriverGUID},
+		},
+	} {
+		t.Run(testCase.SessionType.String(), func(t *testing.T) {
+			sorted := rc.SortedFor(testCase.SessionType)
+
+			if len(sorted) != len(testCase.ExpectedOrder) {
+				t.Fatalf("Expected %d drivers, got %d", len(testCase.ExpectedOrder), len(sorted))
+			}
+
+			for i, driver := range sorted {
+				if driver.CarInfo.DriverGUID != testCase.ExpectedOrder[i] {
+					t.Errorf("Expected %s in position %d, got %s", testCase.ExpectedOrder[i], i+1, driver.CarInfo.DriverGUID)
+				}
+			}
+		})
+	}
+
+	if rc.SessionInfo.Type != udp.SessionTypePractice {
+		t.Errorf("SortedFor should not change the current session type")
+	}
+}
+
+func TestRaceControl_LapLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asm-lap-log")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	setup := func(t *testing.T, logAllLaps bool) (*RaceControl, Store) {
+		store := NewJSONStore(filepath.Join(dir, t.Name()), filepath.Join(dir, t.Name()+"-shared"))
+
+		opts := ConfigIniDefault().GlobalServerConfig
+		opts.LogAllLaps = logAllLaps
+
+		if err := store.UpsertServerOptions(&opts); err != nil {
+			t.Fatal(err)
+		}
+
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+		raceControl.SessionInfo.Track = "ks_laguna_seca"
+		raceControl.SessionInfo.Type = udp.SessionTypePractice
+
+		for _, driver := range drivers[:2] {
+			if err := raceControl.OnClientConnect(driver); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return raceControl, store
+	}
+
+	completeLaps := func(t *testing.T, raceControl *RaceControl) {
+		for _, lap := range []udp.LapCompleted{
+			{CarID: drivers[0].CarID, LapTime: 90000},
+			{CarID: drivers[1].CarID, LapTime: 91000, Cuts: 2},
+			{CarID: drivers[0].CarID, LapTime: 89000},
+		} {
+			if err := raceControl.OnLapCompleted(lap); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// laps are appended to the lap log in the background.
+		raceControl.background.Wait()
+	}
+
+	t.Run("Laps are appended to the lap log", func(t *testing.T) {
+		start := time.Now()
+		raceControl, store := setup(t, true)
+
+		completeLaps(t, raceControl)
+
+		laps, err := store.ListLaps(start, time.Now())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(laps) != 3 {
+			t.Fatalf("Expected 3 laps in the lap log, got %d", len(laps))
+		}
+
+		if laps[1].DriverGUID != drivers[1].DriverGUID || laps[1].LapTime != 91*time.Second || laps[1].Cuts != 2 || laps[1].Track != "ks_laguna_seca" {
+			t.Errorf("Lap log entry does not match completed lap: %v", laps[1])
+		}
+
+		if laps[2].LapTime != 89*time.Second {
+			t.Errorf("Expected laps in the order they were completed")
+		}
+
+		laps, err = store.ListLaps(time.Now().Add(time.Minute), time.Now().Add(time.Hour))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(laps) != 0 {
+			t.Errorf("Expected no laps outside of the time range, got %d", len(laps))
+		}
+	})
+
+	t.Run("Lap log disabled", func(t *testing.T) {
+		start := time.Now()
+		raceControl, store := setup(t, false)
+
+		completeLaps(t, raceControl)
+
+		laps, err := store.ListLaps(start, time.Now())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(laps) != 0 {
+			t.Errorf("Expected no laps in the lap log, got %d", len(laps))
+		}
+	})
+}
+
+func TestRaceControl_JoinSpam(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.JoinSpamMaxConnections = 3
+		opts.JoinSpamWindowMinutes = 5
+		opts.JoinSpamAction = JoinSpamActionKick
+	})
+	defer cleanup()
+
+	numKicks := func(process *recordingServerProcess) int {
+		process.messagesMutex.Lock()
+		defer process.messagesMutex.Unlock()
+
+		num := 0
+
+		for _, message := range process.messages {
+			if _, ok := message.(*udp.AdminCommand); ok {
+				num++
+			}
+		}
+
+		return num
+	}
+
+	reconnect := func(t *testing.T, raceControl *RaceControl, times int) {
+		for i := 0; i < times; i++ {
+			if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := raceControl.OnClientDisconnect(drivers[0]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	t.Run("Join spammer is kicked", func(t *testing.T) {
+		process := &recordingServerProcess{}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+		reconnect(t, raceControl, 4)
+
+		if numKicks(process) != 1 {
+			t.Errorf("Expected join spammer to be kicked once, got %d kicks", numKicks(process))
+		}
+	})
+
+	t.Run("Normal reconnect", func(t *testing.T) {
+		process := &recordingServerProcess{}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+		reconnect(t, raceControl, 2)
+
+		if numKicks(process) != 0 {
+			t.Errorf("Expected driver not to be kicked for reconnecting, got %d kicks", numKicks(process))
+		}
+	})
+
+	t.Run("Connections outside the window are not counted", func(t *testing.T) {
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+		now := time.Now()
+
+		for i := 0; i < 3; i++ {
+			raceControl.recordConnection(drivers[0].DriverGUID, now.Add(-time.Hour), time.Minute*5)
+		}
+
+		if num := raceControl.recordConnection(drivers[0].DriverGUID, now, time.Minute*5); num != 1 {
+			t.Errorf("Expected 1 connection within the window, got %d", num)
+		}
+	})
+}
+
+func TestRaceControl_FastestLapPerModel(t *testing.T) {
+	rc := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	bestLaps := []time.Duration{
+		time.Second * 95, // ford_gt
+		time.Second * 91, // ferrari_fxxk
+		time.Second * 89, // ferrari_fxxk
+		0,                // car_model3, no valid lap
+	}
+
+	for i, bestLap := range bestLaps {
+		driver := NewRaceControlDriver(drivers[i])
+		driver.CurrentCar().BestLap = bestLap
+
+		rc.ConnectedDrivers.Add(drivers[i].DriverGUID, driver)
+	}
+
+	// a disconnected driver who has also driven another car
+	disconnectedDriver := NewRaceControlDriver(drivers[4])
+	disconnectedDriver.CurrentCar().BestLap = 0
+	disconnectedDriver.Cars["ford_gt"] = NewRaceControlCarLapInfo("ford_gt")
+	disconnectedDriver.Cars["ford_gt"].BestLap = time.Second * 94
+
+	rc.DisconnectedDrivers.Add(drivers[4].DriverGUID, disconnectedDriver)
+
+	fastestLaps := rc.FastestLapPerModel()
+
+	if len(fastestLaps) != 2 {
+		t.Fatalf("Expected fastest laps for 2 car models, got %d", len(fastestLaps))
+	}
+
+	if _, ok := fastestLaps["car_model3"]; ok {
+		t.Errorf("Car model with no valid laps should not be included")
+	}
+
+	for model, expected := range map[string]struct {
+		DriverGUID udp.DriverGUID
+		LapTime    time.Duration
+	}{
+		"ford_gt":      {DriverGUID: drivers[4].DriverGUID, LapTime: time.Second * 94},
+		"ferrari_fxxk": {DriverGUID: drivers[2].DriverGUID, LapTime: time.Second * 89},
+	} {
+		fastestLap := fastestLaps[model]
+
+		if fastestLap.DriverGUID != expected.DriverGUID || fastestLap.LapTime != expected.LapTime {
+			t.Errorf("Expected fastest lap in %s to be %s by %s, got %s by %s", model, expected.LapTime, expected.DriverGUID, fastestLap.LapTime, fastestLap.DriverGUID)
+		}
+	}
+}
+
+func TestRaceControl_CarModelMismatch(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.WarnCarModelMismatch = true
+	})
+	defer cleanup()
+
+	connectAndLoad := func(t *testing.T, event RaceEvent, entrant udp.SessionCarInfo) (*RaceControlDriver, *recordingServerProcess) {
+		process := &recordingServerProcess{event: event}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+		if err := raceControl.OnClientConnect(entrant); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnClientLoaded(udp.ClientLoaded(entrant.CarID)); err != nil {
+			t.Fatal(err)
+		}
+
+		driver, ok := raceControl.ConnectedDrivers.Get(entrant.DriverGUID)
+
+		if !ok {
+			t.Fatal("Driver not found in connected drivers")
+		}
+
+		return driver, process
+	}
+
+	countMismatchWarnings := func(messages []string) int {
+		return strings.Count(strings.Join(messages, " "), "is not one of the cars configured for this event")
+	}
+
+	event := &ActiveChampionship{RaceConfig: CurrentRaceConfig{Cars: "ford_gt;ferrari_fxxk"}}
+
+	t.Run("Matching model", func(t *testing.T) {
+		driver, process := connectAndLoad(t, event, drivers[0])
+
+		if driver.ModelMismatch {
+			t.Errorf("Expected driver in a configured car not to be flagged")
+		}
+
+		if count := countMismatchWarnings(process.chatMessagesTo(drivers[0].CarID)); count != 0 {
+			t.Errorf("Expected no mismatch warnings, got: %d", count)
+		}
+	})
+
+	t.Run("Mismatching model", func(t *testing.T) {
+		driver, process := connectAndLoad(t, event, drivers[3])
+
+		if !driver.ModelMismatch {
+			t.Errorf("Expected driver in an unconfigured car to be flagged")
+		}
+
+		if count := countMismatchWarnings(process.chatMessagesTo(drivers[3].CarID)); count != 1 {
+			t.Errorf("Expected 1 mismatch warning, got: %d", count)
+		}
+	})
+
+	t.Run("Locked entry list", func(t *testing.T) {
+		lockedEvent := &ActiveChampionship{RaceConfig: CurrentRaceConfig{Cars: "ford_gt;ferrari_fxxk", LockedEntryList: 1}}
+
+		driver, _ := connectAndLoad(t, lockedEvent, drivers[3])
+
+		if driver.ModelMismatch {
+			t.Errorf("Expected no mismatch to be flagged with a locked entry list")
+		}
+	})
+}
+
+func TestRaceControlDriver_ActiveDuration(t *testing.T) {
+	t.Run("Multiple connection intervals", func(t *testing.T) {
+		driver := NewRaceControlDriver(drivers[0])
+
+		start := time.Now().Add(-time.Hour)
+
+		driver.startActiveInterval(start)
+		driver.endActiveInterval(start.Add(time.Minute * 10))
+
+		// disconnecting again without loading does not count
+		driver.endActiveInterval(start.Add(time.Minute * 15))
+
+		driver.startActiveInterval(start.Add(time.Minute * 20))
+		driver.endActiveInterval(start.Add(time.Minute * 25))
+
+		if activeDuration := driver.ActiveDuration(); activeDuration != time.Minute*15 {
+			t.Errorf("Expected active duration of 15m, got %s", activeDuration)
+		}
+
+		driver.startActiveInterval(time.Now().Add(-time.Minute * 5))
+
+		if activeDuration := driver.ActiveDuration(); activeDuration < time.Minute*20 || activeDuration > time.Minute*21 {
+			t.Errorf("Expected active duration to include the current interval, got %s", activeDuration)
+		}
+	})
+
+	t.Run("Connect, load and disconnect", func(t *testing.T) {
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+		if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		driver, ok := raceControl.ConnectedDrivers.Get(drivers[0].DriverGUID)
+
+		if !ok {
+			t.Fatal("Driver not found in connected drivers")
+		}
+
+		if driver.ActiveDuration() != 0 {
+			t.Errorf("Expected driver who has not loaded to have no active duration")
+		}
+
+		if err := raceControl.OnClientLoaded(udp.ClientLoaded(drivers[0].CarID)); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(time.Millisecond * 20)
+
+		if err := raceControl.OnClientDisconnect(drivers[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		activeDuration := driver.ActiveDuration()
+
+		if activeDuration < time.Millisecond*20 {
+			t.Errorf("Expected active duration of at least 20ms, got %s", activeDuration)
+		}
+
+		time.Sleep(time.Millisecond * 20)
+
+		if driver.ActiveDuration() != activeDuration {
+			t.Errorf("Expected active duration not to increase while disconnected")
+		}
+	})
+
+	t.Run("Reset at new session", func(t *testing.T) {
+		driver := NewRaceControlDriver(drivers[0])
+		driver.startActiveInterval(time.Now().Add(-time.Hour))
+		driver.activeDuration = time.Hour
+
+		driver.resetActiveDuration(time.Now())
+
+		if activeDuration := driver.ActiveDuration(); activeDuration > time.Second {
+			t.Errorf("Expected active duration to be reset, got %s", activeDuration)
+		}
+
+		if driver.activeSince.IsZero() {
+			t.Errorf("Expected loaded driver to still be active after reset")
+		}
+	})
+}
+
+func TestRaceControl_GridPenalties(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asm-grid-penalties")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	buildEntryList := func() RaceWeekendEntryList {
+		var entryList RaceWeekendEntryList
+
+		for _, driver := range drivers {
+			entryList.Add(NewRaceWeekendSessionEntrant(uuid.New(), &SessionCar{Driver: SessionDriver{GUID: string(driver.DriverGUID)}}, nil, nil))
+		}
+
+		return entryList
+	}
+
+	gridOrder := func(entryList RaceWeekendEntryList) []udp.DriverGUID {
+		sort.Slice(entryList, func(i, j int) bool {
+			return entryList[i].PitBox < entryList[j].PitBox
+		})
+
+		var out []udp.DriverGUID
+
+		for _, entrant := range entryList {
+			out = append(out, udp.DriverGUID(entrant.Car.GetGUID()))
+		}
+
+		return out
+	}
+
+	checkGridOrder := func(t *testing.T, entryList RaceWeekendEntryList, expected []udp.DriverGUID) {
+		order := gridOrder(entryList)
+
+		for i := range expected {
+			if order[i] != expected[i] {
+				t.Errorf("Expected %s in grid position %d, got %s", expected[i], i+1, order[i])
+			}
+		}
+	}
+
+	t.Run("Penalties move drivers back the grid", func(t *testing.T) {
+		entryList := buildEntryList()
+
+		applied := entryList.ApplyGridPenalties(map[udp.DriverGUID]int{
+			drivers[0].DriverGUID: 2,
+			drivers[3].DriverGUID: 10,
+		})
+
+		if len(applied) != 2 {
+			t.Errorf("Expected 2 grid penalties to be applied, got %d", len(applied))
+		}
+
+		checkGridOrder(t, entryList, []udp.DriverGUID{
+			drivers[1].DriverGUID,
+			drivers[2].DriverGUID,
+			drivers[0].DriverGUID,
+			drivers[4].DriverGUID,
+			drivers[3].DriverGUID,
+		})
+	})
+
+	t.Run("Penalties are applied to a reversed grid", func(t *testing.T) {
+		entryList := buildEntryList()
+		reverseEntrants(-1, entryList)
+
+		for i, entrant := range entryList {
+			entrant.PitBox = i
+		}
+
+		entryList.ApplyGridPenalties(map[udp.DriverGUID]int{
+			drivers[4].DriverGUID: 1,
+		})
+
+		checkGridOrder(t, entryList, []udp.DriverGUID{
+			drivers[3].DriverGUID,
+			drivers[4].DriverGUID,
+			drivers[2].DriverGUID,
+			drivers[1].DriverGUID,
+			drivers[0].DriverGUID,
+		})
+	})
+
+	t.Run("Recorded penalties are applied once", func(t *testing.T) {
+		store := NewJSONStore(filepath.Join(dir, "store"), filepath.Join(dir, "shared"))
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+		if err := raceControl.AddGridPenalty(drivers[0].DriverGUID, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.AddGridPenalty(drivers[0].DriverGUID, 2); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.AddGridPenalty(drivers[1].DriverGUID, 0); err == nil {
+			t.Errorf("Expected an error for a grid penalty of 0 places")
+		}
+
+		entryList := buildEntryList()
+
+		if err := applyGridPenalties(store, entryList); err != nil {
+			t.Fatal(err)
+		}
+
+		if order := gridOrder(entryList); order[3] != drivers[0].DriverGUID {
+			t.Errorf("Expected driver 0 to start 4th after a 3 place penalty, got order: %v", order)
+		}
+
+		gridPenalties, err := loadGridPenalties(store)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(gridPenalties) != 0 {
+			t.Errorf("Expected applied grid penalties to be removed, got: %v", gridPenalties)
+		}
+
+		entryList = buildEntryList()
+
+		if err := applyGridPenalties(store, entryList); err != nil {
+			t.Fatal(err)
+		}
+
+		if order := gridOrder(entryList); order[0] != drivers[0].DriverGUID {
+			t.Errorf("Expected grid penalties to only be applied once")
+		}
+	})
+}
+
+func TestRaceControl_LiveLapCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asm-lap-csv")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	oldServerInstallPath := ServerInstallPath
+	ServerInstallPath = dir
+	defer func() { ServerInstallPath = oldServerInstallPath }()
+
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.LiveLapCSV = true
+	})
+	defer cleanup()
+
+	readCSV := func(t *testing.T, filename string) [][]string {
+		f, err := os.Open(filename)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer f.Close()
+
+		records, err := csv.NewReader(f).ReadAll()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return records
+	}
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Name: "Practice", Type: udp.SessionTypePractice}); err != nil {
+		t.Fatal(err)
+	}
+
+	if raceControl.lapCSV == nil {
+		t.Fatal("Expected a lap CSV file to be created for the session")
+	}
+
+	practiceCSV := raceControl.lapCSV.Name()
+
+	if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, lapTime := range []uint32{90000, 89500} {
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: lapTime}); err != nil {
+			t.Fatal(err)
+		}
+
+		// laps should be in the file as soon as they are completed
+		if records := readCSV(t, practiceCSV); len(records) != i+2 {
+			t.Errorf("Expected %d rows in the lap CSV after lap %d, got %d", i+2, i+1, len(records))
+		}
+	}
+
+	records := readCSV(t, practiceCSV)
+
+	if records[2][1] != string(drivers[0].DriverGUID) || records[2][5] != "89500" {
+		t.Errorf("Lap CSV row does not match the completed lap: %v", records[2])
+	}
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Name: "Qualify", Type: udp.SessionTypeQualifying}); err != nil {
+		t.Fatal(err)
+	}
+
+	if raceControl.lapCSV == nil || raceControl.lapCSV.Name() == practiceCSV {
+		t.Fatal("Expected a new lap CSV file to be created for the new session")
+	}
+
+	if records := readCSV(t, raceControl.lapCSV.Name()); len(records) != 1 {
+		t.Errorf("Expected only the header row in the new session's lap CSV, got %d rows", len(records))
+	}
+
+	raceControl.closeLapCSV()
+}
+
+func TestRaceControl_PruneStaleCarIDs(t *testing.T) {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	for _, driver := range drivers[:2] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a driver whose disconnect was missed, and a connected driver who has since moved to a different car.
+	raceControl.carIDToGUIDMutex.Lock()
+	raceControl.CarIDToGUID[50] = "ghost-driver-guid"
+	raceControl.CarIDToGUID[51] = drivers[1].DriverGUID
+	raceControl.carIDToGUIDMutex.Unlock()
+
+	if pruned := raceControl.pruneStaleCarIDs(); len(pruned) != 0 {
+		t.Errorf("Expected no CarIDs to be pruned on the first check, got: %v", pruned)
+	}
+
+	pruned := raceControl.pruneStaleCarIDs()
+
+	if len(pruned) != 2 {
+		t.Errorf("Expected 2 stale CarIDs to be pruned, got: %v", pruned)
+	}
+
+	for _, carID := range []udp.CarID{50, 51} {
+		if _, err := raceControl.findConnectedDriverByCarID(carID); err == nil || !strings.Contains(err.Error(), "could not find DriverGUID") {
+			t.Errorf("Expected stale CarID %d to be removed, got err: %v", carID, err)
+		}
+	}
+
+	for _, driver := range drivers[:2] {
+		if _, err := raceControl.findConnectedDriverByCarID(driver.CarID); err != nil {
+			t.Errorf("Expected connected driver's CarID to be kept, got err: %s", err)
+		}
+	}
+
+	t.Run("Stale entry is updated before being pruned", func(t *testing.T) {
+		raceControl.carIDToGUIDMutex.Lock()
+		raceControl.CarIDToGUID[52] = "ghost-driver-guid"
+		raceControl.carIDToGUIDMutex.Unlock()
+
+		raceControl.pruneStaleCarIDs()
+
+		if err := raceControl.OnClientConnect(udp.SessionCarInfo{CarID: 52, DriverGUID: "new-driver-guid", DriverName: "New Driver", CarModel: "ford_gt"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if pruned := raceControl.pruneStaleCarIDs(); len(pruned) != 0 {
+			t.Errorf("Expected a newly connected driver's CarID not to be pruned, got: %v", pruned)
+		}
+	})
+}
+
+func TestRaceControl_QualifyingMinValidLaps(t *testing.T) {
+	for _, testCase := range []struct {
+		MinValidLaps  int
+		ExpectedOrder []udp.DriverGUID
+	}{
+		{MinValidLaps: 1, ExpectedOrder: []udp.DriverGUID{drivers[0].DriverGUID, drivers[1].DriverGUID}},
+		{MinValidLaps: 2, ExpectedOrder: []udp.DriverGUID{drivers[1].DriverGUID, drivers[0].DriverGUID}},
+	} {
+		t.Run(fmt.Sprintf("Minimum %d valid laps", testCase.MinValidLaps), func(t *testing.T) {
+			store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+				opts.QualifyingMinValidLaps = testCase.MinValidLaps
+			})
+			defer cleanup()
+
+			raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+			raceControl.SessionInfo.Type = udp.SessionTypeQualifying
+
+			for _, driver := range drivers[:2] {
+				if err := raceControl.OnClientConnect(driver); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// driver 0 sets a single fast lap, driver 1 sets two slower laps.
+			laps := []udp.LapCompleted{
+				{CarID: drivers[0].CarID, LapTime: 88000},
+				{CarID: drivers[1].CarID, LapTime: 91000},
+				{CarID: drivers[1].CarID, LapTime: 90000},
+				{CarID: drivers[0].CarID, LapTime: 87000, Cuts: 3},
+			}
+
+			for _, lap := range laps {
+				if err := raceControl.OnLapCompleted(lap); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for i, guid := range testCase.ExpectedOrder {
+				if raceControl.ConnectedDrivers.GUIDsInPositionalOrder[i] != guid {
+					t.Errorf("Expected %s in position %d, got %s", guid, i+1, raceControl.ConnectedDrivers.GUIDsInPositionalOrder[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRaceControl_ReadyCount(t *testing.T) {
+	process := &recordingServerProcess{}
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, testStore, NewPenaltiesManager(testStore))
+
+	for _, driver := range drivers[:3] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	raceControl.UDPCallback(udp.Chat{CarID: drivers[0].CarID, Message: "/ready"})
+	raceControl.UDPCallback(udp.Chat{CarID: drivers[1].CarID, Message: " /READY "})
+
+	// saying ready twice only counts once
+	raceControl.UDPCallback(udp.Chat{CarID: drivers[1].CarID, Message: "/ready"})
+
+	if readyCount := raceControl.ReadyCount(); readyCount.Ready != 2 || readyCount.Total != 3 {
+		t.Errorf("Expected 2/3 drivers ready, got %d/%d", readyCount.Ready, readyCount.Total)
+	}
+
+	messages := process.broadcastChatMessages()
+
+	if len(messages) == 0 || !strings.Contains(messages[len(messages)-1], "(2/3 drivers ready)") {
+		t.Errorf("Expected ready count to be broadcast in chat, got: %v", messages)
+	}
+
+	t.Run("Disconnected drivers are not counted", func(t *testing.T) {
+		if err := raceControl.OnClientDisconnect(drivers[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		if readyCount := raceControl.ReadyCount(); readyCount.Ready != 1 || readyCount.Total != 2 {
+			t.Errorf("Expected 1/2 drivers ready, got %d/%d", readyCount.Ready, readyCount.Total)
+		}
+	})
+
+	t.Run("Reset at new session", func(t *testing.T) {
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace}); err != nil {
+			t.Fatal(err)
+		}
+
+		if readyCount := raceControl.ReadyCount(); readyCount.Ready != 0 {
+			t.Errorf("Expected no drivers to be ready in a new session, got %d", readyCount.Ready)
+		}
+	})
+}
+
+func TestRaceControl_PoleAnnouncement(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.AnnouncePolePosition = true
+	})
+	defer cleanup()
+
+	process := &recordingServerProcess{}
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+	raceControl.SessionInfo.Type = udp.SessionTypeQualifying
+
+	for _, driver := range drivers[:3] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	laps := []udp.LapCompleted{
+		{CarID: drivers[0].CarID, LapTime: 89000},
+		{CarID: drivers[1].CarID, LapTime: 88500},
+		{CarID: drivers[2].CarID, LapTime: 90250},
+		{CarID: drivers[0].CarID, LapTime: 88850},
+	}
+
+	for _, lap := range laps {
+		if err := raceControl.OnLapCompleted(lap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	announcement := raceControl.PoleAnnouncement()
+
+	if len(announcement.FrontRow) != 2 {
+		t.Fatalf("Expected two drivers on the front row, got %d", len(announcement.FrontRow))
+	}
+
+	if announcement.FrontRow[0].DriverGUID != drivers[1].DriverGUID || announcement.FrontRow[1].DriverGUID != drivers[0].DriverGUID {
+		t.Errorf("Expected front row of %s, %s; got %s, %s", drivers[1].DriverGUID, drivers[0].DriverGUID, announcement.FrontRow[0].DriverGUID, announcement.FrontRow[1].DriverGUID)
+	}
+
+	if announcement.FrontRow[1].GapToPole != 350*time.Millisecond {
+		t.Errorf("Expected gap to pole of 350ms, got %s", announcement.FrontRow[1].GapToPole)
+	}
+
+	expected := "Pole position: Test 2, 01:28.500. Also on the front row: Test 1, +0.350s."
+
+	if announcement.String() != expected {
+		t.Errorf("Expected announcement %q, got %q", expected, announcement.String())
+	}
+
+	raceControl.announcePolePosition()
+
+	chat := strings.Join(process.broadcastChatMessages(), " ")
+
+	if chat != "(Server) "+expected {
+		t.Errorf("Expected announcement to be broadcast in chat, got: %q", chat)
+	}
+
+	t.Run("No announcement without lap times", func(t *testing.T) {
+		if announcement := (PoleAnnouncement{}).String(); announcement != "" {
+			t.Errorf("Expected empty announcement, got %q", announcement)
+		}
+	})
+}
+
+func TestShouldBroadcastCarUpdate(t *testing.T) {
+	now := time.Now()
+
+	for _, testCase := range []struct {
+		Name          string
+		LastBroadcast time.Time
+		Interval      time.Duration
+		Expected      bool
+	}{
+		{Name: "Downsampling disabled", LastBroadcast: now, Interval: 0, Expected: true},
+		{Name: "First update", LastBroadcast: time.Time{}, Interval: 100 * time.Millisecond, Expected: true},
+		{Name: "Within interval", LastBroadcast: now.Add(-50 * time.Millisecond), Interval: 100 * time.Millisecond, Expected: false},
+		{Name: "Interval elapsed", LastBroadcast: now.Add(-100 * time.Millisecond), Interval: 100 * time.Millisecond, Expected: true},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			if actual := shouldBroadcastCarUpdate(testCase.LastBroadcast, now, testCase.Interval); actual != testCase.Expected {
+				t.Errorf("Expected %t, got %t", testCase.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestRaceControl_CarUpdateDownsampling(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.CarUpdateBroadcastMs = 10000
+	})
+	defer cleanup()
+
+	broadcaster := &countingBroadcaster{}
+	raceControl := NewRaceControl(broadcaster, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+	for _, driver := range drivers[:2] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		for _, driver := range drivers[:2] {
+			err := raceControl.handleCarUpdate(udp.CarUpdate{CarID: driver.CarID, Pos: udp.Vec{X: float32(i)}})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if count := broadcaster.count(udp.EventCarUpdate); count != 2 {
+		t.Errorf("Expected one car update to be broadcast per car, got %d", count)
+	}
+
+	// car updates are still tracked internally at full rate
+	driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if driver.LastPos.X != 4 {
+		t.Errorf("Expected driver's last position to be the most recent update, got %f", driver.LastPos.X)
+	}
+}
+
+func TestRaceControlDriver_CarsUsed(t *testing.T) {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	entrant := drivers[0]
+
+	for _, carModel := range []string{"ks_mazda_mx5_cup", "abarth500", "ks_mazda_mx5_cup"} {
+		entrant.CarModel = carModel
+
+		if err := raceControl.OnClientConnect(entrant); err != nil {
+			t.Fatal(err)
+		}
+
+		// drivers without any laps are removed on disconnect rather than kept in the disconnected drivers.
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: entrant.CarID, LapTime: 90000}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnClientDisconnect(entrant); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	driver, ok := raceControl.DisconnectedDrivers.Get(entrant.DriverGUID)
+
+	if !ok {
+		t.Fatal("Expected driver to be disconnected")
+	}
+
+	expected := []string{"ks_mazda_mx5_cup", "abarth500"}
+
+	if carsUsed := driver.Copy().CarsUsed(); strings.Join(carsUsed, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected cars used to be %v, got %v", expected, carsUsed)
+	}
+}
+
+func TestRaceControl_StrictDriverResolution(t *testing.T) {
+	sendUnknownLaps := func(raceControl *RaceControl, numLaps int) {
+		for i := 0; i < numLaps; i++ {
+			raceControl.UDPCallback(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 90000})
+		}
+	}
+
+	resyncRequests := func(process *recordingServerProcess) (numSessionInfo, numCarInfo int) {
+		process.messagesMutex.Lock()
+		defer process.messagesMutex.Unlock()
+
+		for _, message := range process.messages {
+			switch m := message.(type) {
+			case udp.GetSessionInfo:
+				numSessionInfo++
+			case udp.GetCarInfo:
+				if m.CarID == drivers[0].CarID {
+					numCarInfo++
+				}
+			}
+		}
+
+		return numSessionInfo, numCarInfo
+	}
+
+	t.Run("Strict mode disabled", func(t *testing.T) {
+		process := &recordingServerProcess{}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, testStore, NewPenaltiesManager(testStore))
+
+		sendUnknownLaps(raceControl, unknownDriverResyncThreshold*2)
+
+		if numSessionInfo, numCarInfo := resyncRequests(process); numSessionInfo != 0 || numCarInfo != 0 {
+			t.Errorf("Expected no resync requests, got %d session info, %d car info", numSessionInfo, numCarInfo)
+		}
+	})
+
+	t.Run("Repeated unknown drivers trigger a resync", func(t *testing.T) {
+		store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+			opts.StrictDriverResolution = true
+		})
+		defer cleanup()
+
+		process := &recordingServerProcess{}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+		sendUnknownLaps(raceControl, unknownDriverResyncThreshold-1)
+
+		if numSessionInfo, numCarInfo := resyncRequests(process); numSessionInfo != 0 || numCarInfo != 0 {
+			t.Errorf("Expected no resync requests below the threshold, got %d session info, %d car info", numSessionInfo, numCarInfo)
+		}
+
+		// the resync is only requested once within unknownDriverResyncInterval
+		sendUnknownLaps(raceControl, unknownDriverResyncThreshold+1)
+
+		if numSessionInfo, numCarInfo := resyncRequests(process); numSessionInfo != 1 || numCarInfo != 1 {
+			t.Errorf("Expected one resync request, got %d session info, %d car info", numSessionInfo, numCarInfo)
+		}
+
+		raceControl.UDPCallback(udp.CarInfo{
+			CarID:       drivers[0].CarID,
+			IsConnected: true,
+			CarModel:    drivers[0].CarModel,
+			DriverName:  drivers[0].DriverName,
+			DriverGUID:  drivers[0].DriverGUID,
+		})
+
+		driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if driver.CarInfo.DriverGUID != drivers[0].DriverGUID {
+			t.Errorf("Expected resynced driver to be %s, got %s", drivers[0].DriverGUID, driver.CarInfo.DriverGUID)
+		}
+	})
+}
+
+func TestRaceControl_SessionStats(t *testing.T) {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace, AmbientTemp: 20, RoadTemp: 30}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, temps := range [][2]uint8{{22, 31}, {24, 35}} {
+		if _, err := raceControl.OnSessionUpdate(udp.SessionInfo{AmbientTemp: temps[0], RoadTemp: temps[1]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, driver := range drivers[:3] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	laps := []udp.LapCompleted{
+		{CarID: drivers[0].CarID, LapTime: 90000},
+		{CarID: drivers[0].CarID, LapTime: 89000},
+		{CarID: drivers[1].CarID, LapTime: 91000},
+		{CarID: drivers[2].CarID, LapTime: 92000},
+	}
+
+	for _, lap := range laps {
+		if err := raceControl.OnLapCompleted(lap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := raceControl.OnCollisionWithEnvironment(udp.CollisionWithEnvironment{CarID: drivers[1].CarID, ImpactSpeed: 20}); err != nil {
+		t.Fatal(err)
+	}
+
+	// disconnected drivers are still counted
+	if err := raceControl.OnClientDisconnect(drivers[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := raceControl.SessionStats()
+
+	if stats.NumDrivers != 3 {
+		t.Errorf("Expected 3 drivers, got %d", stats.NumDrivers)
+	}
+
+	if stats.NumLaps != 4 {
+		t.Errorf("Expected 4 laps, got %d", stats.NumLaps)
+	}
+
+	if stats.NumCollisions != 1 {
+		t.Errorf("Expected 1 collision, got %d", stats.NumCollisions)
+	}
+
+	if stats.AverageAmbientTemp != 22 || stats.AverageRoadTemp != 32 {
+		t.Errorf("Expected average temperatures of 22/32, got %f/%f", stats.AverageAmbientTemp, stats.AverageRoadTemp)
+	}
+}
+
+func TestRaceControl_ClearDisconnectedOnLoop(t *testing.T) {
+	loopedSession := udp.SessionInfo{
+		Track:     "ks_laguna_seca",
+		Name:      "Test Looped Practice Session",
+		Type:      udp.SessionTypePractice,
+		EventType: udp.EventNewSession,
+	}
+
+	for _, clearDisconnected := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Clear disconnected drivers: %t", clearDisconnected), func(t *testing.T) {
+			store, removeStore := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+				opts.ClearDisconnectedOnLoop = clearDisconnected
+			})
+			defer removeStore()
+
+			raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+			if err := raceControl.OnNewSession(loopedSession); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, driver := range drivers[:3] {
+				if err := raceControl.OnClientConnect(driver); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// drivers without any laps are removed on disconnect rather than kept in the disconnected drivers.
+			if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[2].CarID, LapTime: 90000}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := raceControl.OnClientDisconnect(drivers[2]); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := raceControl.OnNewSession(loopedSession); err != nil {
+				t.Fatal(err)
+			}
+
+			expectedDisconnected := 1
+
+			if clearDisconnected {
+				expectedDisconnected = 0
+			}
+
+			if raceControl.ConnectedDrivers.Len() != 2 {
+				t.Errorf("Expected connected drivers to be kept, got %d", raceControl.ConnectedDrivers.Len())
+			}
+
+			if raceControl.DisconnectedDrivers.Len() != expectedDisconnected {
+				t.Errorf("Expected %d disconnected drivers, got %d", expectedDisconnected, raceControl.DisconnectedDrivers.Len())
+			}
+		})
+	}
+}
+
+func TestRaceControl_DeltaToRecord(t *testing.T) {
+	const track = "delta_to_record_track"
+
+	err := testStore.AppendLap(&LapLogEntry{
+		DriverGUID: "previous-record-holder",
+		CarModel:   drivers[1].CarModel,
+		LapTime:    88 * time.Second,
+		Time:       time.Now().Add(-time.Hour * 24),
+		Track:      track,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: track, Type: udp.SessionTypePractice}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, driver := range drivers[1:3] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	getDriver := func(t *testing.T, carID udp.CarID) *RaceControlDriver {
+		driver, err := raceControl.findConnectedDriverByCarID(carID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return driver.Copy()
+	}
+
+	t.Run("Slower than the record", func(t *testing.T) {
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[1].CarID, LapTime: 89000}); err != nil {
+			t.Fatal(err)
+		}
+
+		driver := getDriver(t, drivers[1].CarID)
+
+		if driver.DeltaToRecord != time.Second || driver.HoldsRecord {
+			t.Errorf("Expected delta to record of 1s, got %s (holds record: %t)", driver.DeltaToRecord, driver.HoldsRecord)
+		}
+	})
+
+	t.Run("Record broken", func(t *testing.T) {
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[2].CarID, LapTime: 87500}); err != nil {
+			t.Fatal(err)
+		}
+
+		recordHolder := getDriver(t, drivers[2].CarID)
+
+		if recordHolder.DeltaToRecord != 0 || !recordHolder.HoldsRecord {
+			t.Errorf("Expected driver to hold the record, got delta %s (holds record: %t)", recordHolder.DeltaToRecord, recordHolder.HoldsRecord)
+		}
+
+		// other drivers in the same car are compared to the new record
+		driver := getDriver(t, drivers[1].CarID)
+
+		if driver.DeltaToRecord != 1500*time.Millisecond || driver.HoldsRecord {
+			t.Errorf("Expected delta to record of 1.5s, got %s (holds record: %t)", driver.DeltaToRecord, driver.HoldsRecord)
+		}
+	})
+}
+
+func TestRaceControl_GroupStandingsByCar(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.GroupPracticeByCar = true
+	})
+	defer cleanup()
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+	raceControl.SessionInfo.Type = udp.SessionTypePractice
+
+	for _, driver := range drivers {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	laps := []udp.LapCompleted{
+		{CarID: drivers[0].CarID, LapTime: 92000},
+		{CarID: drivers[1].CarID, LapTime: 90000},
+		{CarID: drivers[2].CarID, LapTime: 89000},
+		{CarID: drivers[3].CarID, LapTime: 91000},
+		{CarID: drivers[4].CarID, LapTime: 88000},
+	}
+
+	for _, lap := range laps {
+		raceControl.UDPCallback(lap)
+	}
+
+	expected := []CarStandings{
+		{CarModel: drivers[4].CarModel, DriverGUIDs: []udp.DriverGUID{drivers[4].DriverGUID, drivers[3].DriverGUID}},
+		{CarModel: drivers[2].CarModel, DriverGUIDs: []udp.DriverGUID{drivers[2].DriverGUID, drivers[1].DriverGUID}},
+		{CarModel: drivers[0].CarModel, DriverGUIDs: []udp.DriverGUID{drivers[0].DriverGUID}},
+	}
+
+	if len(raceControl.StandingsByCar) != len(expected) {
+		t.Fatalf("Expected standings for %d cars, got %d", len(expected), len(raceControl.StandingsByCar))
+	}
+
+	for i, carStandings := range raceControl.StandingsByCar {
+		if carStandings.CarModel != expected[i].CarModel {
+			t.Errorf("Expected car %d to be %s, got %s", i, expected[i].CarModel, carStandings.CarModel)
+		}
+
+		if fmt.Sprint(carStandings.DriverGUIDs) != fmt.Sprint(expected[i].DriverGUIDs) {
+			t.Errorf("Expected %s standings to be %v, got %v", carStandings.CarModel, expected[i].DriverGUIDs, carStandings.DriverGUIDs)
+		}
+	}
+
+	t.Run("Not grouped outside of practice", func(t *testing.T) {
+		raceControl.SessionInfo.Type = udp.SessionTypeQualifying
+		raceControl.UDPCallback(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 91000})
+
+		if raceControl.StandingsByCar != nil {
+			t.Errorf("Expected standings not to be grouped by car, got %v", raceControl.StandingsByCar)
+		}
+	})
+}
+
+func TestRaceControlDriver_UpdatePositionHistory(t *testing.T) {
+	driver := NewRaceControlDriver(drivers[0])
+	now := time.Now()
+
+	for _, update := range []struct {
+		Position int
+		After    time.Duration
+	}{
+		{Position: 2, After: 0},
+		{Position: 1, After: 10 * time.Second},
+		{Position: 1, After: 15 * time.Second},
+		// disconnected, so the time until the driver is next sorted is not counted
+		{Position: 0, After: 20 * time.Second},
+		{Position: 3, After: 60 * time.Second},
+		{Position: 3, After: 61 * time.Second},
+	} {
+		driver.updatePositionHistory(update.Position, now.Add(update.After))
+	}
+
+	expected := []time.Duration{10 * time.Second, 10 * time.Second, time.Second}
+
+	if fmt.Sprint(driver.PositionHistory) != fmt.Sprint(expected) {
+		t.Errorf("Expected position history %v, got %v", expected, driver.PositionHistory)
+	}
+}
+
+func TestRaceControl_PositionHistory(t *testing.T) {
+	setup := func(t *testing.T, sessionType udp.SessionType) *RaceControl {
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: sessionType, Laps: 10}); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, driver := range drivers[:2] {
+			if err := raceControl.OnClientConnect(driver); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, lap := range []udp.LapCompleted{
+			{CarID: drivers[0].CarID, LapTime: 90000},
+			{CarID: drivers[0].CarID, LapTime: 90000},
+			{CarID: drivers[1].CarID, LapTime: 89000},
+		} {
+			if err := raceControl.OnLapCompleted(lap); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return raceControl
+	}
+
+	getDriver := func(t *testing.T, raceControl *RaceControl, guid udp.DriverGUID) *RaceControlDriver {
+		driver, ok := raceControl.ConnectedDrivers.Get(guid)
+
+		if !ok {
+			t.Fatalf("Could not find driver: %s", guid)
+		}
+
+		return driver.Copy()
+	}
+
+	t.Run("Race", func(t *testing.T) {
+		raceControl := setup(t, udp.SessionTypeRace)
+
+		leader, second := getDriver(t, raceControl, drivers[0].DriverGUID), getDriver(t, raceControl, drivers[1].DriverGUID)
+
+		if leader.LapsLed != 2 || second.LapsLed != 0 {
+			t.Errorf("Expected laps led to be 2 and 0, got %d and %d", leader.LapsLed, second.LapsLed)
+		}
+
+		if len(leader.PositionHistory) == 0 || len(second.PositionHistory) == 0 {
+			t.Errorf("Expected position history to be recorded, got %v and %v", leader.PositionHistory, second.PositionHistory)
+		}
+
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace, Laps: 10}); err != nil {
+			t.Fatal(err)
+		}
+
+		if leader := getDriver(t, raceControl, drivers[0].DriverGUID); leader.LapsLed != 0 || len(leader.PositionHistory) > 1 {
+			t.Errorf("Expected laps led and position history to be reset, got %d and %v", leader.LapsLed, leader.PositionHistory)
+		}
+	})
+
+	t.Run("Not recorded outside of a race", func(t *testing.T) {
+		raceControl := setup(t, udp.SessionTypePractice)
+
+		if leader := getDriver(t, raceControl, drivers[0].DriverGUID); leader.LapsLed != 0 || leader.PositionHistory != nil {
+			t.Errorf("Expected no position history outside of a race, got %d laps led and %v", leader.LapsLed, leader.PositionHistory)
+		}
+	})
+}
+
+func TestRaceControl_SessionFastestLap(t *testing.T) {
+	broadcaster := &countingBroadcaster{}
+	raceControl := NewRaceControl(broadcaster, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, driver := range drivers[:2] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, lap := range []udp.LapCompleted{
+		{CarID: drivers[0].CarID, LapTime: 90000},
+		{CarID: drivers[1].CarID, LapTime: 89500},
+		// slower and invalid laps do not change the fastest lap
+		{CarID: drivers[0].CarID, LapTime: 90500},
+		{CarID: drivers[0].CarID, LapTime: 85000, Cuts: 2},
+	} {
+		if err := raceControl.OnLapCompleted(lap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if count := broadcaster.count(EventSessionFastestLap); count != 2 {
+		t.Errorf("Expected the session fastest lap to be broadcast twice, got %d", count)
+	}
+
+	fastestLap := raceControl.FastestLapOfSession()
+
+	if fastestLap == nil || fastestLap.LapTime != 89500*time.Millisecond || fastestLap.DriverGUID != drivers[1].DriverGUID || fastestLap.CarModel != drivers[1].CarModel {
+		t.Fatalf("Expected the fastest lap to be 1:29.500 by %s, got %+v", drivers[1].DriverGUID, fastestLap)
+	}
+
+	t.Run("Kept when the driver disconnects", func(t *testing.T) {
+		if err := raceControl.OnClientDisconnect(drivers[1]); err != nil {
+			t.Fatal(err)
+		}
+
+		if fastestLap := raceControl.FastestLapOfSession(); fastestLap == nil || fastestLap.DriverName != drivers[1].DriverName {
+			t.Errorf("Expected the fastest lap to be kept, got %+v", fastestLap)
+		}
+
+		if snapshot := raceControl.Snapshot(LiveTimingsFilter{}, SortByPosition); snapshot.SessionFastestLap == nil {
+			t.Error("Expected the fastest lap to be in the snapshot")
+		}
+	})
+
+	t.Run("Reset in a new session", func(t *testing.T) {
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeQualifying, Time: 10}); err != nil {
+			t.Fatal(err)
+		}
+
+		if fastestLap := raceControl.FastestLapOfSession(); fastestLap != nil {
+			t.Errorf("Expected the fastest lap to be reset, got %+v", fastestLap)
+		}
+	})
+}
+
+func TestRaceControl_AnnounceFastestLap(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.AnnounceFastestLap = true
+	})
+	defer cleanup()
+
+	process := &recordingServerProcess{}
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+	raceControl.SessionInfo.Type = udp.SessionTypePractice
+
+	for _, driver := range drivers[:2] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 90000}); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := process.broadcastChatMessages()
+
+	if len(messages) != 1 || !strings.Contains(messages[0], "New fastest lap: Test 1, 01:30.000") {
+		t.Fatalf("Expected new fastest lap to be announced, got: %v", messages)
+	}
+
+	// allow the next announcement
+	raceControl.lastFastestLapAnnouncement = time.Time{}
+
+	// slower and invalid laps are not announced
+	for _, lap := range []udp.LapCompleted{
+		{CarID: drivers[1].CarID, LapTime: 91000},
+		{CarID: drivers[1].CarID, LapTime: 85000, Cuts: 1},
+	} {
+		if err := raceControl.OnLapCompleted(lap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if messages := process.broadcastChatMessages(); len(messages) != 1 {
+		t.Errorf("Expected no further announcements, got: %v", messages)
+	}
+
+	if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[1].CarID, LapTime: 89500}); err != nil {
+		t.Fatal(err)
+	}
+
+	messages = process.broadcastChatMessages()
+
+	if len(messages) != 2 || !strings.Contains(messages[1], "New fastest lap: Test 2, 01:29.500") {
+		t.Errorf("Expected new fastest lap to be announced, got: %v", messages)
+	}
+
+	t.Run("Announcements are rate limited", func(t *testing.T) {
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 89000}); err != nil {
+			t.Fatal(err)
+		}
+
+		if messages := process.broadcastChatMessages(); len(messages) != 2 {
+			t.Errorf("Expected announcement to be rate limited, got: %v", messages)
+		}
+	})
+}
+
+func TestTrackGap(t *testing.T) {
+	for _, testCase := range []struct {
+		Ahead, Behind float32
+		Expected      time.Duration
+	}{
+		{Ahead: 0.5, Behind: 0.25, Expected: 15 * time.Second},
+		{Ahead: 0.25, Behind: 0.5, Expected: 45 * time.Second},
+		{Ahead: 0.5, Behind: 0.5, Expected: 0},
+	} {
+		t.Run(fmt.Sprintf("%.2f to %.2f", testCase.Behind, testCase.Ahead), func(t *testing.T) {
+			if gap := trackGap(testCase.Ahead, testCase.Behind, time.Minute); gap != testCase.Expected {
+				t.Errorf("Expected gap of %s, got %s", testCase.Expected, gap)
+			}
+		})
+	}
+}
+
+func TestRaceControl_GapToLeader(t *testing.T) {
+	setup := func(t *testing.T, sessionType udp.SessionType) *RaceControl {
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+		raceControl.SessionInfo.Type = sessionType
+
+		for _, driver := range drivers[:4] {
+			if err := raceControl.OnClientConnect(driver); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, lap := range []udp.LapCompleted{
+			{CarID: drivers[0].CarID, LapTime: 60000},
+			{CarID: drivers[1].CarID, LapTime: 61000},
+			{CarID: drivers[2].CarID, LapTime: 62000},
+			{CarID: drivers[0].CarID, LapTime: 60000},
+			{CarID: drivers[1].CarID, LapTime: 61000},
+			{CarID: drivers[2].CarID, LapTime: 62000},
+			{CarID: drivers[3].CarID, LapTime: 70000},
+		} {
+			if err := raceControl.OnLapCompleted(lap); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return raceControl
+	}
+
+	getDriver := func(t *testing.T, raceControl *RaceControl, carID udp.CarID) *RaceControlDriver {
+		driver, err := raceControl.findConnectedDriverByCarID(carID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return driver.Copy()
+	}
+
+	t.Run("Race", func(t *testing.T) {
+		raceControl := setup(t, udp.SessionTypeRace)
+
+		for _, testCase := range []struct {
+			CarID               udp.CarID
+			ExpectedSplit       string
+			ExpectedGapToLeader string
+		}{
+			{CarID: drivers[0].CarID, ExpectedSplit: "0s", ExpectedGapToLeader: "0s"},
+			{CarID: drivers[1].CarID, ExpectedSplit: "2s", ExpectedGapToLeader: "2s"},
+			{CarID: drivers[2].CarID, ExpectedSplit: "2s", ExpectedGapToLeader: "4s"},
+			{CarID: drivers[3].CarID, ExpectedSplit: "1 lap", ExpectedGapToLeader: "1 lap"},
+		} {
+			driver := getDriver(t, raceControl, testCase.CarID)
+
+			if driver.Split != testCase.ExpectedSplit || driver.GapToLeader != testCase.ExpectedGapToLeader {
+				t.Errorf("Expected car %d to have split %s and gap to leader %s, got %s and %s", testCase.CarID, testCase.ExpectedSplit, testCase.ExpectedGapToLeader, driver.Split, driver.GapToLeader)
+			}
+		}
+	})
+
+	t.Run("Empty outside of a race", func(t *testing.T) {
+		raceControl := setup(t, udp.SessionTypeQualifying)
+
+		for _, driver := range drivers[:4] {
+			if gap := getDriver(t, raceControl, driver.CarID).GapToLeader; gap != "" {
+				t.Errorf("Expected no gap to leader for car %d, got %s", driver.CarID, gap)
+			}
+		}
+	})
+}
+
+func TestRaceControl_LappedTrackGap(t *testing.T) {
+	for _, showTrackGap := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Show lapped car track gap: %t", showTrackGap), func(t *testing.T) {
+			store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+				opts.ShowLappedCarTrackGap = showTrackGap
+			})
+			defer cleanup()
+
+			raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+			raceControl.SessionInfo.Type = udp.SessionTypeRace
+
+			leader, lappedDriver := drivers[0], drivers[1]
+
+			for _, driver := range []udp.SessionCarInfo{leader, lappedDriver} {
+				if err := raceControl.OnClientConnect(driver); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, lap := range []udp.LapCompleted{
+				{CarID: leader.CarID, LapTime: 60000},
+				{CarID: leader.CarID, LapTime: 60000},
+			} {
+				if err := raceControl.OnLapCompleted(lap); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, update := range []udp.CarUpdate{
+				{CarID: leader.CarID, NormalisedSplinePos: 0.25},
+				{CarID: lappedDriver.CarID, NormalisedSplinePos: 0},
+			} {
+				if err := raceControl.handleCarUpdate(update); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: lappedDriver.CarID, LapTime: 80000}); err != nil {
+				t.Fatal(err)
+			}
+
+			driver, err := raceControl.findConnectedDriverByCarID(lappedDriver.CarID)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			driver = driver.Copy()
+
+			if driver.Split != "1 lap" {
+				t.Errorf("Expected lapped driver split to be 1 lap, got %s", driver.Split)
+			}
+
+			expectedGap := time.Duration(0)
+
+			if showTrackGap {
+				expectedGap = 20 * time.Second
+			}
+
+			if driver.LappedTrackGap != expectedGap {
+				t.Errorf("Expected lapped driver to be %s behind the leader on track, got %s", expectedGap, driver.LappedTrackGap)
+			}
+		})
+	}
+}
+
+func TestRaceControl_RaceRemaining(t *testing.T) {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	for _, driver := range drivers[:2] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("No laps or time configured", func(t *testing.T) {
+		raceControl.SessionInfo = udp.SessionInfo{Type: udp.SessionTypeRace, ElapsedMilliseconds: 3600000}
+
+		if remaining := raceControl.RaceRemaining(); !remaining.Unlimited {
+			t.Errorf("Expected session to be unlimited, got %+v", remaining)
+		}
+
+		if raceControl.RaceComplete() {
+			t.Error("Expected unlimited session never to be complete")
+		}
+	})
+
+	t.Run("Time", func(t *testing.T) {
+		raceControl.SessionInfo = udp.SessionInfo{Type: udp.SessionTypeRace, Time: 10, ElapsedMilliseconds: 240000}
+
+		if remaining := raceControl.RaceRemaining(); remaining.Unlimited || remaining.Time != 6*time.Minute {
+			t.Errorf("Expected 6 minutes remaining, got %+v", remaining)
+		}
+
+		raceControl.SessionInfo.ElapsedMilliseconds = 660000
+
+		if !raceControl.RaceComplete() {
+			t.Error("Expected timed session to be complete")
+		}
+	})
+
+	t.Run("Laps", func(t *testing.T) {
+		raceControl.SessionInfo = udp.SessionInfo{Type: udp.SessionTypeRace, Laps: 2}
+
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 90000}); err != nil {
+			t.Fatal(err)
+		}
+
+		if remaining := raceControl.RaceRemaining(); remaining.Laps != 1 || raceControl.RaceComplete() {
+			t.Errorf("Expected 1 lap remaining, got %+v", remaining)
+		}
+
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 90000}); err != nil {
+			t.Fatal(err)
+		}
+
+		if !raceControl.RaceComplete() {
+			t.Error("Expected lap race to be complete")
+		}
+	})
+}
+
+func TestRaceControl_PersonalTrackBest(t *testing.T) {
+	const track = "personal_track_best_track"
+
+	for _, lap := range []*LapLogEntry{
+		{DriverGUID: drivers[0].DriverGUID, CarModel: drivers[0].CarModel, LapTime: 90 * time.Second},
+		{DriverGUID: drivers[0].DriverGUID, CarModel: drivers[0].CarModel, LapTime: 89 * time.Second},
+		{DriverGUID: drivers[0].DriverGUID, CarModel: drivers[0].CarModel, LapTime: 80 * time.Second, Cuts: 4},
+		{DriverGUID: drivers[0].DriverGUID, CarModel: "another_car", LapTime: 70 * time.Second},
+		{DriverGUID: drivers[1].DriverGUID, CarModel: drivers[1].CarModel, LapTime: 88 * time.Second},
+	} {
+		lap.Track = track
+		lap.Time = time.Now().Add(-time.Hour)
+
+		if err := testStore.AppendLap(lap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: track, Type: udp.SessionTypePractice}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, driver := range drivers[:2] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, lap := range []udp.LapCompleted{
+		{CarID: drivers[0].CarID, LapTime: 88500},
+		{CarID: drivers[1].CarID, LapTime: 88500},
+	} {
+		if err := raceControl.OnLapCompleted(lap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, testCase := range []struct {
+		Name              string
+		Driver            udp.SessionCarInfo
+		PersonalTrackBest time.Duration
+		Beat              bool
+	}{
+		{Name: "Personal best beaten", Driver: drivers[0], PersonalTrackBest: 89 * time.Second, Beat: true},
+		{Name: "Personal best not beaten", Driver: drivers[1], PersonalTrackBest: 88 * time.Second, Beat: false},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			driver, ok := raceControl.ConnectedDrivers.Get(testCase.Driver.DriverGUID)
+
+			if !ok {
+				t.Fatal("Expected driver to be connected")
+			}
+
+			driver = driver.Copy()
+
+			if driver.PersonalTrackBest != testCase.PersonalTrackBest {
+				t.Errorf("Expected personal track best of %s, got %s", testCase.PersonalTrackBest, driver.PersonalTrackBest)
+			}
+
+			if driver.BeatPersonalTrackBest != testCase.Beat {
+				t.Errorf("Expected beat personal track best to be %t, got %t", testCase.Beat, driver.BeatPersonalTrackBest)
+			}
+		})
+	}
+}
+
+func TestRaceControl_ReconnectDriversByName(t *testing.T) {
+	// reconnectWithNewGUID returns a race control in which the first of the drivers has reconnected with a new GUID,
+	// and a func which removes its store.
+	reconnectWithNewGUID := func(t *testing.T, reconnectByName bool, disconnect []udp.SessionCarInfo) (*RaceControl, func()) {
+		store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+			opts.ReconnectDriversByName = reconnectByName
+		})
+
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+		raceControl.SessionInfo.Type = udp.SessionTypePractice
+
+		for _, driver := range disconnect {
+			if err := raceControl.OnClientConnect(driver); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: driver.CarID, LapTime: 90000}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := raceControl.OnClientDisconnect(driver); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		reconnected := disconnect[0]
+		reconnected.DriverGUID = "new-platform-guid"
+
+		if err := raceControl.OnClientConnect(reconnected); err != nil {
+			t.Fatal(err)
+		}
+
+		return raceControl, cleanup
+	}
+
+	reconnectedLaps := func(t *testing.T, raceControl *RaceControl) int {
+		driver, ok := raceControl.ConnectedDrivers.Get("new-platform-guid")
+
+		if !ok {
+			t.Fatal("Expected driver to be connected with their new GUID")
+		}
+
+		return driver.Copy().TotalNumLaps
+	}
+
+	t.Run("Laps restored by name", func(t *testing.T) {
+		raceControl, cleanup := reconnectWithNewGUID(t, true, drivers[:2])
+		defer cleanup()
+
+		if laps := reconnectedLaps(t, raceControl); laps != 1 {
+			t.Errorf("Expected driver's lap to be restored, got %d laps", laps)
+		}
+
+		if _, ok := raceControl.DisconnectedDrivers.Get(drivers[0].DriverGUID); ok {
+			t.Error("Expected restored driver to be removed from disconnected drivers")
+		}
+	})
+
+	t.Run("Option disabled", func(t *testing.T) {
+		raceControl, cleanup := reconnectWithNewGUID(t, false, drivers[:2])
+		defer cleanup()
+
+		if laps := reconnectedLaps(t, raceControl); laps != 0 {
+			t.Errorf("Expected driver not to be restored, got %d laps", laps)
+		}
+
+		if _, ok := raceControl.DisconnectedDrivers.Get(drivers[0].DriverGUID); !ok {
+			t.Error("Expected original driver to still be disconnected")
+		}
+	})
+
+	t.Run("Ambiguous names are not restored", func(t *testing.T) {
+		// drivers 2 and 3 have the same name
+		raceControl, cleanup := reconnectWithNewGUID(t, true, drivers[2:4])
+		defer cleanup()
+
+		if laps := reconnectedLaps(t, raceControl); laps != 0 {
+			t.Errorf("Expected driver not to be restored, got %d laps", laps)
+		}
+	})
+
+	t.Run("A driver with the same name in a different car is new", func(t *testing.T) {
+		store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+			opts.ReconnectDriversByName = true
+		})
+		defer cleanup()
+
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+		raceControl.SessionInfo.Type = udp.SessionTypePractice
+
+		if err := raceControl.OnClientConnect(drivers[2]); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[2].CarID, LapTime: 90000}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnClientDisconnect(drivers[2]); err != nil {
+			t.Fatal(err)
+		}
+
+		// drivers 2 and 3 have the same name, but are in different cars
+		if err := raceControl.OnClientConnect(drivers[3]); err != nil {
+			t.Fatal(err)
+		}
+
+		driver, ok := raceControl.ConnectedDrivers.Get(drivers[3].DriverGUID)
+
+		if !ok {
+			t.Fatal("Expected driver to be connected")
+		}
+
+		if laps := driver.Copy().TotalNumLaps; laps != 0 {
+			t.Errorf("Expected driver not to be restored, got %d laps", laps)
+		}
+
+		if _, ok := raceControl.DisconnectedDrivers.Get(drivers[2].DriverGUID); !ok {
+			t.Error("Expected original driver to still be disconnected")
+		}
+	})
+}
+
+func TestRaceControl_SessionCountdown(t *testing.T) {
+	now := time.Now()
+
+	t.Run("Timed session", func(t *testing.T) {
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+		raceControl.SessionInfo = udp.SessionInfo{Type: udp.SessionTypePractice, Time: 20, ElapsedMilliseconds: 60000}
+		raceControl.sessionInfoUpdatedAt = now.Add(-30 * time.Second)
+
+		// the elapsed time is brought forward from the last session info update
+		if countdown := raceControl.sessionCountdown(now); countdown.Elapsed != 90*time.Second || countdown.TimeRemaining != 18*time.Minute+30*time.Second {
+			t.Errorf("Expected 1m30s elapsed and 18m30s remaining, got %+v", countdown)
+		}
+
+		if countdown := raceControl.sessionCountdown(now.Add(time.Hour)); countdown.TimeRemaining != 0 {
+			t.Errorf("Expected no time remaining, got %s", countdown.TimeRemaining)
+		}
+	})
+
+	t.Run("Waiting to start", func(t *testing.T) {
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+		raceControl.SessionInfo = udp.SessionInfo{Type: udp.SessionTypeRace, Time: 20, ElapsedMilliseconds: -60000}
+		raceControl.sessionInfoUpdatedAt = now
+
+		if countdown := raceControl.sessionCountdown(now); countdown.Elapsed != 0 || countdown.TimeRemaining != 20*time.Minute {
+			t.Errorf("Expected the whole session to be remaining, got %+v", countdown)
+		}
+	})
+
+	t.Run("Lap session", func(t *testing.T) {
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+		raceControl.SessionInfo = udp.SessionInfo{Type: udp.SessionTypeRace, Laps: 10}
+
+		if countdown := raceControl.sessionCountdown(now); countdown.LapsRemaining != 10 || countdown.TimeRemaining != 0 {
+			t.Errorf("Expected 10 laps remaining, got %+v", countdown)
+		}
+	})
+
+	t.Run("Unlimited session", func(t *testing.T) {
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+		raceControl.SessionInfo = udp.SessionInfo{Type: udp.SessionTypePractice}
+
+		if countdown := raceControl.sessionCountdown(now); !countdown.Unlimited {
+			t.Errorf("Expected an unlimited session, got %+v", countdown)
+		}
+	})
+
+	t.Run("Broadcast until the session ends", func(t *testing.T) {
+		broadcaster := &countingBroadcaster{}
+		raceControl := NewRaceControl(broadcaster, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+		raceControl.sessionCountdownInterval = time.Millisecond * 10
+		defer raceControl.stopSessionCountdown()
+
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 10}); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(time.Millisecond * 55)
+
+		if err := raceControl.OnEndSession(udp.EndSession("2019_3_2_14_41_PRACTICE.json")); err != nil {
+			t.Fatal(err)
+		}
+
+		// allow any broadcast which was already in progress to finish
+		time.Sleep(time.Millisecond * 5)
+
+		count := broadcaster.count(EventSessionCountdown)
+
+		if count == 0 {
+			t.Error("Expected the session countdown to be broadcast")
+		}
+
+		time.Sleep(time.Millisecond * 30)
+
+		if after := broadcaster.count(EventSessionCountdown); after != count {
+			t.Errorf("Expected the session countdown to stop at the end of the session, got %d more broadcasts", after-count)
+		}
+	})
+
+	t.Run("Session updates while broadcasting", func(t *testing.T) {
+		// run with -race to check that the countdown does not race session updates.
+		store, cleanup := newIsolatedTestStore(t, nil)
+		defer cleanup()
+
+		broadcaster := &countingBroadcaster{}
+		raceControl := NewRaceControl(broadcaster, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+		raceControl.sessionCountdownInterval = time.Millisecond
+		defer raceControl.stopSessionCountdown()
+
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 10}); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 20; i++ {
+			if _, err := raceControl.OnSessionUpdate(udp.SessionInfo{ElapsedMilliseconds: int32(i * 1000)}); err != nil {
+				t.Fatal(err)
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+
+		if broadcaster.count(EventSessionCountdown) == 0 {
+			t.Error("Expected the session countdown to be broadcast")
+		}
+	})
+}
+
+func TestRaceControl_SessionClock(t *testing.T) {
+	broadcaster := &countingBroadcaster{}
+	raceControl := NewRaceControl(broadcaster, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace, Laps: 10, WaitTime: 60}); err != nil {
+		t.Fatal(err)
+	}
+
+	// stop the session clock timer
+	defer func() {
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice}); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	if clock := raceControl.SessionClock(); !clock.Waiting || clock.WaitTimeRemaining != time.Minute {
+		t.Errorf("Expected session clock to be waiting for 1m, got %+v", clock)
+	}
+
+	if count := broadcaster.count(EventSessionClock); count != 1 {
+		t.Errorf("Expected waiting session clock to be broadcast, got %d broadcasts", count)
+	}
+
+	raceControl.sessionClockTimerMutex.Lock()
+	scheduled := raceControl.sessionClockTimer != nil
+	raceControl.sessionClockTimerMutex.Unlock()
+
+	if !scheduled {
+		t.Error("Expected the session clock start to be scheduled")
+	}
+
+	t.Run("Running once the wait time has elapsed", func(t *testing.T) {
+		raceControl.SessionStartTime = time.Now().Add(-61 * time.Second)
+
+		if clock := raceControl.SessionClock(); clock.Waiting {
+			t.Errorf("Expected session clock to be running, got %+v", clock)
+		}
+
+		raceControl.broadcastSessionClock()
+
+		if count := broadcaster.count(EventSessionClock); count != 2 {
+			t.Errorf("Expected running session clock to be broadcast, got %d broadcasts", count)
+		}
+	})
+
+	t.Run("Practice sessions do not wait", func(t *testing.T) {
+		raceControl.SessionInfo = udp.SessionInfo{Type: udp.SessionTypePractice, WaitTime: 60}
+		raceControl.SessionStartTime = time.Now()
+
+		if clock := raceControl.SessionClock(); clock.Waiting {
+			t.Errorf("Expected session clock to be running, got %+v", clock)
+		}
+	})
+}
+
+func TestRaceControl_ApplySessionPenalties(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asm-session-penalties")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	oldServerInstallPath := ServerInstallPath
+	ServerInstallPath = dir
+	defer func() { ServerInstallPath = oldServerInstallPath }()
+
+	if err := os.MkdirAll(filepath.Join(dir, "results"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	const filename = "2020_1_2_20_48_RACE.json"
+
+	results := &SessionResults{Type: SessionTypeRace}
+
+	for i, driver := range drivers[:3] {
+		results.Cars = append(results.Cars, &SessionCar{CarID: i, Model: driver.CarModel, Driver: SessionDriver{GUID: string(driver.DriverGUID), Name: driver.DriverName}})
+		results.Result = append(results.Result, &SessionResult{CarID: i, CarModel: driver.CarModel, DriverGUID: string(driver.DriverGUID), DriverName: driver.DriverName, TotalTime: 900000 + i*1000, BestLap: 89000})
+		results.Laps = append(results.Laps, &SessionLap{CarID: i, CarModel: driver.CarModel, DriverGUID: string(driver.DriverGUID), LapTime: 90000})
+	}
+
+	if err := saveResults(filename, results); err != nil {
+		t.Fatal(err)
+	}
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	// penalties from different sources for the same driver are combined
+	raceControl.AddSessionPenalty(drivers[0].DriverGUID, drivers[0].CarModel, 5*time.Second)
+	raceControl.AddSessionPenalty(drivers[0].DriverGUID, drivers[0].CarModel, 10*time.Second)
+	raceControl.AddSessionPenalty(drivers[1].DriverGUID, drivers[1].CarModel, 3*time.Second)
+
+	if err := raceControl.applySessionPenalties(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	penalisedResults, err := LoadResult(filename, LoadResultWithoutPluginFire)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedPenalties := map[string]time.Duration{
+		string(drivers[0].DriverGUID): 15 * time.Second,
+		string(drivers[1].DriverGUID): 3 * time.Second,
+		string(drivers[2].DriverGUID): 0,
+	}
+
+	for _, result := range penalisedResults.Result {
+		expected := expectedPenalties[result.DriverGUID]
+
+		if result.PenaltyTime != expected || result.HasPenalty != (expected > 0) {
+			t.Errorf("Expected driver %s to have a %s penalty, got %s (has penalty: %t)", result.DriverGUID, expected, result.PenaltyTime, result.HasPenalty)
+		}
+	}
+}
+
+func TestRaceControl_SeedDisconnectedDrivers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asm-seed-disconnected-drivers")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	oldServerInstallPath := ServerInstallPath
+	ServerInstallPath = dir
+	defer func() { ServerInstallPath = oldServerInstallPath }()
+
+	if err := os.MkdirAll(filepath.Join(dir, "results"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	const filename = "2020_1_2_22_10_RACE.json"
+
+	results := &SessionResults{Type: SessionTypeRace}
+
+	for i, driver := range drivers[:3] {
+		results.Cars = append(results.Cars, &SessionCar{CarID: i, Model: driver.CarModel, Driver: SessionDriver{GUID: string(driver.DriverGUID), Name: driver.DriverName, Team: "Team " + driver.DriverName}})
+		results.Result = append(results.Result, &SessionResult{CarID: i, CarModel: driver.CarModel, DriverGUID: string(driver.DriverGUID), DriverName: driver.DriverName, TotalTime: 180000 + i*1000, BestLap: 89000})
+
+		for lap := 0; lap < 2; lap++ {
+			results.Laps = append(results.Laps, &SessionLap{CarID: i, CarModel: driver.CarModel, DriverGUID: string(driver.DriverGUID), LapTime: 90000 + i*500 - lap*1000})
+		}
+	}
+
+	// a cut lap is counted, but is not a valid best lap
+	results.Laps = append(results.Laps, &SessionLap{CarID: 0, CarModel: drivers[0].CarModel, DriverGUID: string(drivers[0].DriverGUID), LapTime: 80000, Cuts: 2})
+
+	if err := saveResults(filename, results); err != nil {
+		t.Fatal(err)
+	}
+
+	setup := func(t *testing.T, entryListModels ...string) *RaceControl {
+		entryList := make(EntryList)
+
+		for i, model := range entryListModels {
+			entryList.AddInPitBox(&Entrant{Name: fmt.Sprintf("Entrant %d", i), Model: model}, i)
+		}
+
+		process := &recordingServerProcess{event: &ActiveChampionship{EntryList: entryList}}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, testStore, NewPenaltiesManager(testStore))
+		raceControl.SessionInfo.Type = udp.SessionTypeRace
+
+		return raceControl
+	}
+
+	t.Run("Seeded from results", func(t *testing.T) {
+		raceControl := setup(t, drivers[0].CarModel, drivers[1].CarModel, drivers[2].CarModel)
+
+		// drivers who are already connected are not replaced
+		if err := raceControl.OnClientConnect(drivers[2]); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.SeedDisconnectedDrivers(filename); err != nil {
+			t.Fatal(err)
+		}
+
+		if numDisconnected := raceControl.DisconnectedDrivers.Len(); numDisconnected != 2 {
+			t.Fatalf("Expected 2 disconnected drivers, got %d", numDisconnected)
+		}
+
+		driver, ok := raceControl.DisconnectedDrivers.Get(drivers[0].DriverGUID)
+
+		if !ok {
+			t.Fatal("Expected the first driver to be seeded")
+		}
+
+		driver = driver.Copy()
+		car := driver.CurrentCar()
+
+		if driver.TotalNumLaps != 3 || car.NumValidLaps != 2 || car.BestLap != 89*time.Second || car.TotalLapTime != 259*time.Second {
+			t.Errorf("Expected 3 laps (2 valid), a best lap of 1:29 and a total time of 4:19, got %d laps (%d valid), %s and %s", driver.TotalNumLaps, car.NumValidLaps, car.BestLap, car.TotalLapTime)
+		}
+
+		if driver.TeamName != "Team "+drivers[0].DriverName || driver.Position != 1 {
+			t.Errorf("Expected the first driver to be in position 1 for their team, got position %d for %q", driver.Position, driver.TeamName)
+		}
+	})
+
+	t.Run("Car not in the entry list", func(t *testing.T) {
+		raceControl := setup(t, "ferrari_fxxk")
+
+		if err := raceControl.SeedDisconnectedDrivers(filename); err == nil {
+			t.Error("Expected an error when the results have cars which are not in the entry list")
+		}
+
+		if numDisconnected := raceControl.DisconnectedDrivers.Len(); numDisconnected != 0 {
+			t.Errorf("Expected no disconnected drivers to be seeded, got %d", numDisconnected)
+		}
+	})
+}
+
+func TestRaceControl_SessionDisqualification(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asm-session-disqualification")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	oldServerInstallPath := ServerInstallPath
+	ServerInstallPath = dir
+	defer func() { ServerInstallPath = oldServerInstallPath }()
+
+	if err := os.MkdirAll(filepath.Join(dir, "results"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	const filename = "2020_1_2_21_30_RACE.json"
+
+	results := &SessionResults{Type: SessionTypeRace}
+
+	for i, driver := range drivers[:3] {
+		results.Cars = append(results.Cars, &SessionCar{CarID: i, Model: driver.CarModel, Driver: SessionDriver{GUID: string(driver.DriverGUID), Name: driver.DriverName}})
+		results.Result = append(results.Result, &SessionResult{CarID: i, CarModel: driver.CarModel, DriverGUID: string(driver.DriverGUID), DriverName: driver.DriverName, TotalTime: 900000 + i*1000, BestLap: 89000})
+		results.Laps = append(results.Laps, &SessionLap{CarID: i, CarModel: driver.CarModel, DriverGUID: string(driver.DriverGUID), LapTime: 90000})
+	}
+
+	if err := saveResults(filename, results); err != nil {
+		t.Fatal(err)
+	}
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	const reason = "Left the pits 40s early during a driver swap"
+
+	// a disqualification takes precedence over any time penalties, and only the first reason is kept
+	raceControl.AddSessionPenalty(drivers[0].DriverGUID, drivers[0].CarModel, 5*time.Second)
+	raceControl.AddSessionDisqualification(drivers[0].DriverGUID, drivers[0].CarModel, reason)
+	raceControl.AddSessionDisqualification(drivers[0].DriverGUID, drivers[0].CarModel, "Left the pits 35s early during a driver swap")
+	raceControl.AddSessionPenalty(drivers[1].DriverGUID, drivers[1].CarModel, 3*time.Second)
+
+	if err := raceControl.applySessionPenalties(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	penalisedResults, err := LoadResult(filename, LoadResultWithoutPluginFire)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range penalisedResults.Result {
+		switch result.DriverGUID {
+		case string(drivers[0].DriverGUID):
+			if !result.Disqualified || result.HasPenalty {
+				t.Errorf("Expected driver %s to be disqualified without a time penalty, got disqualified: %t, has penalty: %t", result.DriverGUID, result.Disqualified, result.HasPenalty)
+			}
+
+			if result.DisqualificationReason != reason {
+				t.Errorf("Expected disqualification reason %q, got %q", reason, result.DisqualificationReason)
+			}
+
+			if result.DisqualificationTime.IsZero() {
+				t.Error("Expected disqualification time to be set")
+			}
+		default:
+			if result.Disqualified || result.DisqualificationReason != "" {
+				t.Errorf("Expected driver %s not to be disqualified", result.DriverGUID)
+			}
+		}
+	}
+
+	// disqualified drivers are sorted to the back of the results
+	if last := penalisedResults.Result[len(penalisedResults.Result)-1]; last.DriverGUID != string(drivers[0].DriverGUID) {
+		t.Errorf("Expected disqualified driver to be last in the results, got %s", last.DriverGUID)
+	}
+}
+
+func TestCurrentRaceConfig_DriverSwapEnabledForSession(t *testing.T) {
+	for _, testCase := range []struct {
+		Name     string
+		Config   CurrentRaceConfig
+		Session  SessionType
+		Expected bool
+	}{
+		{Name: "Disabled", Config: CurrentRaceConfig{DriverSwapSessions: []SessionType{SessionTypeRace}}, Session: SessionTypeRace, Expected: false},
+		{Name: "Race by default", Config: CurrentRaceConfig{DriverSwapEnabled: 1}, Session: SessionTypeRace, Expected: true},
+		{Name: "Not practice by default", Config: CurrentRaceConfig{DriverSwapEnabled: 1}, Session: SessionTypePractice, Expected: false},
+		{Name: "Selected practice", Config: CurrentRaceConfig{DriverSwapEnabled: 1, DriverSwapSessions: []SessionType{SessionTypePractice, SessionTypeQualifying}}, Session: SessionTypePractice, Expected: true},
+		{Name: "Unselected race", Config: CurrentRaceConfig{DriverSwapEnabled: 1, DriverSwapSessions: []SessionType{SessionTypePractice, SessionTypeQualifying}}, Session: SessionTypeRace, Expected: false},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			if enabled := testCase.Config.DriverSwapEnabledForSession(testCase.Session); enabled != testCase.Expected {
+				t.Errorf("Expected driver swaps enabled for %s to be %t, got %t", testCase.Session, testCase.Expected, enabled)
+			}
+		})
+	}
+}
+
+func TestRaceControl_DriverSwapSessions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asm-driver-swap-sessions")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	oldServerInstallPath := ServerInstallPath
+	ServerInstallPath = dir
+	defer func() { ServerInstallPath = oldServerInstallPath }()
+
+	if err := os.MkdirAll(filepath.Join(dir, "results"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	const filename = "2020_1_2_19_00_PRACTICE.json"
+
+	// endPractice saves a practice results file in which nobody swapped drivers, ends the session and returns the
+	// penalty given to the first driver.
+	endPractice := func(t *testing.T, config CurrentRaceConfig) time.Duration {
+		results := &SessionResults{Type: SessionTypePractice}
+
+		for i, driver := range drivers[:2] {
+			results.Cars = append(results.Cars, &SessionCar{CarID: i, Model: driver.CarModel, Driver: SessionDriver{GUID: string(driver.DriverGUID), Name: driver.DriverName}})
+			// results without a total time are filtered out as invalid when they are loaded.
+			results.Result = append(results.Result, &SessionResult{CarID: i, CarModel: driver.CarModel, DriverGUID: string(driver.DriverGUID), DriverName: driver.DriverName, BestLap: 89000, TotalTime: 90000})
+			results.Laps = append(results.Laps, &SessionLap{CarID: i, CarModel: driver.CarModel, DriverGUID: string(driver.DriverGUID), LapTime: 90000})
+		}
+
+		if err := saveResults(filename, results); err != nil {
+			t.Fatal(err)
+		}
+
+		process := &recordingServerProcess{event: &ActiveChampionship{RaceConfig: config}}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, testStore, NewPenaltiesManager(testStore))
+		raceControl.SessionInfo.Type = udp.SessionTypePractice
+
+		if err := raceControl.OnEndSession(udp.EndSession(filename)); err != nil {
+			t.Fatal(err)
+		}
+
+		penalisedResults, err := LoadResult(filename, LoadResultWithoutPluginFire)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, result := range penalisedResults.Result {
+			if result.DriverGUID == string(drivers[0].DriverGUID) {
+				return result.PenaltyTime
+			}
+		}
+
+		t.Fatal("Could not find driver in results")
+
+		return 0
+	}
+
+	config := CurrentRaceConfig{
+		DriverSwapEnabled:               1,
+		DriverSwapMinimumNumberOfSwaps:  2,
+		DriverSwapNotEnoughSwapsPenalty: 10,
+	}
+
+	t.Run("Practice not selected", func(t *testing.T) {
+		if penalty := endPractice(t, config); penalty != 0 {
+			t.Errorf("Expected no penalty outside of the race, got %s", penalty)
+		}
+	})
+
+	t.Run("Practice selected", func(t *testing.T) {
+		config.DriverSwapSessions = []SessionType{SessionTypePractice}
+
+		if penalty := endPractice(t, config); penalty != 20*time.Second {
+			t.Errorf("Expected a 20s penalty for missing 2 swaps in practice, got %s", penalty)
+		}
+	})
+}
+
+func TestRaceControl_PersistTimingsMinDrivers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asm-persist-timings")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	for _, testCase := range []struct {
+		MinDrivers       int
+		ConnectedDrivers int
+		ExpectPersisted  bool
+	}{
+		{MinDrivers: 0, ConnectedDrivers: 0, ExpectPersisted: false},
+		{MinDrivers: 0, ConnectedDrivers: 1, ExpectPersisted: true},
+		{MinDrivers: 3, ConnectedDrivers: 2, ExpectPersisted: false},
+		{MinDrivers: 3, ConnectedDrivers: 3, ExpectPersisted: true},
+	} {
+		t.Run(fmt.Sprintf("%d of %d drivers", testCase.ConnectedDrivers, testCase.MinDrivers), func(t *testing.T) {
+			store := NewJSONStore(filepath.Join(dir, t.Name()), filepath.Join(dir, t.Name()+"-shared"))
+
+			opts := ConfigIniDefault().GlobalServerConfig
+			opts.PersistTimingsMinDrivers = testCase.MinDrivers
+
+			if err := store.UpsertServerOptions(&opts); err != nil {
+				t.Fatal(err)
+			}
+
+			raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+			for _, driver := range drivers[:testCase.ConnectedDrivers] {
+				if err := raceControl.OnClientConnect(driver); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			raceControl.persistTimingData()
+
+			data, err := store.LoadLiveTimingsData()
+
+			if persisted := err == nil && data != nil; persisted != testCase.ExpectPersisted {
+				t.Errorf("Expected live timings persisted: %t, got: %t", testCase.ExpectPersisted, persisted)
+			}
+		})
+	}
+}
+
+func TestRaceControl_TrackPosition(t *testing.T) {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	trackMapData := TrackMapData{Width: 1000, Height: 800, ScaleFactor: 1}
+
+	trackPosition := func(t *testing.T) float64 {
+		driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return driver.Copy().TrackPosition
+	}
+
+	t.Run("No car updates", func(t *testing.T) {
+		if position := trackPosition(t); position != -1 {
+			t.Errorf("Expected track position of -1 before any car updates, got %f", position)
+		}
+	})
+
+	t.Run("Spline position", func(t *testing.T) {
+		raceControl.TrackMapData = trackMapData
+
+		for _, splinePos := range []float32{0, 0.25, 0.999} {
+			if err := raceControl.handleCarUpdate(udp.CarUpdate{CarID: drivers[0].CarID, NormalisedSplinePos: splinePos}); err != nil {
+				t.Fatal(err)
+			}
+
+			if position := trackPosition(t); position != float64(splinePos) {
+				t.Errorf("Expected track position of %f, got %f", splinePos, position)
+			}
+		}
+	})
+
+	t.Run("No track map data", func(t *testing.T) {
+		raceControl.TrackMapData = TrackMapData{}
+
+		if err := raceControl.handleCarUpdate(udp.CarUpdate{CarID: drivers[0].CarID, NormalisedSplinePos: 0.5}); err != nil {
+			t.Fatal(err)
+		}
+
+		if position := trackPosition(t); position != -1 {
+			t.Errorf("Expected track position of -1 for a track with no map data, got %f", position)
+		}
+	})
+}
+
+func TestRaceControl_NextSessionReminder(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.NextSessionReminder = 5
+	})
+	defer cleanup()
+
+	event := &ActiveChampionship{RaceConfig: CurrentRaceConfig{
+		ResultScreenTime: 60,
+		Sessions: Sessions{
+			SessionTypePractice:   &SessionConfig{Name: "Practice", Time: 20},
+			SessionTypeQualifying: &SessionConfig{Name: "Qualify", Time: 10},
+			SessionTypeRace:       &SessionConfig{Name: "Race", Laps: 15},
+		},
+	}}
+
+	process := &recordingServerProcess{event: event}
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+	reminderScheduled := func() bool {
+		raceControl.nextSessionReminderTimerMutex.Lock()
+		defer raceControl.nextSessionReminderTimerMutex.Unlock()
+
+		return raceControl.nextSessionReminderTimer != nil
+	}
+
+	for _, testCase := range []struct {
+		SessionIndex      uint8
+		SessionType       udp.SessionType
+		Time              uint16
+		ExpectScheduled   bool
+		ExpectedReminder  string
+		ExpectNoReminders bool
+	}{
+		{SessionIndex: 0, SessionType: udp.SessionTypePractice, Time: 20, ExpectScheduled: true, ExpectedReminder: "Qualifying (10 minutes) starts in about 6 minutes. Stay connected to take part!"},
+		{SessionIndex: 1, SessionType: udp.SessionTypeQualifying, Time: 10, ExpectScheduled: true, ExpectedReminder: "Race (15 laps) starts in about 6 minutes. Stay connected to take part!"},
+		{SessionIndex: 2, SessionType: udp.SessionTypeRace, Time: 0, ExpectScheduled: false, ExpectNoReminders: true},
+	} {
+		t.Run(testCase.SessionType.String(), func(t *testing.T) {
+			err := raceControl.OnNewSession(udp.SessionInfo{
+				Track:               "ks_laguna_seca",
+				Type:                testCase.SessionType,
+				CurrentSessionIndex: testCase.SessionIndex,
+				Time:                testCase.Time,
+			})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if scheduled := reminderScheduled(); scheduled != testCase.ExpectScheduled {
+				t.Errorf("Expected reminder scheduled: %t, got: %t", testCase.ExpectScheduled, scheduled)
+			}
+
+			reminder, ok := raceControl.nextSessionReminder(5 * time.Minute)
+
+			if ok == testCase.ExpectNoReminders || reminder != testCase.ExpectedReminder {
+				t.Errorf("Expected reminder %q, got %q", testCase.ExpectedReminder, reminder)
+			}
+		})
+	}
+
+	t.Run("Sessions shorter than the reminder time", func(t *testing.T) {
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 3}); err != nil {
+			t.Fatal(err)
+		}
+
+		if reminderScheduled() {
+			t.Error("Expected no reminder to be scheduled")
+		}
+	})
+}
+
+func TestRaceControl_WhereAmIChatCommand(t *testing.T) {
+	process := &recordingServerProcess{}
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, testStore, NewPenaltiesManager(testStore))
+
+	splits := []string{"0s", "1.234s", ""}
+
+	for _, entrant := range drivers[:3] {
+		if err := raceControl.OnClientConnect(entrant); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, entrant := range drivers[:3] {
+		driver, ok := raceControl.ConnectedDrivers.Get(entrant.DriverGUID)
+
+		if !ok {
+			t.Fatalf("Driver %s not connected", entrant.DriverGUID)
+		}
+
+		driver.Position = i + 1
+		driver.Split = splits[i]
+	}
+
+	t.Run("Command is parsed regardless of case and whitespace", func(t *testing.T) {
+		raceControl.SessionInfo.Type = udp.SessionTypeRace
+
+		for _, message := range []string{"/whereami", " /WhereAmI "} {
+			raceControl.UDPCallback(udp.Chat{CarID: drivers[1].CarID, Message: message})
+		}
+
+		raceControl.UDPCallback(udp.Chat{CarID: drivers[1].CarID, Message: "/whereami please"})
+
+		if messages := process.chatMessagesTo(drivers[1].CarID); len(messages) != 2 {
+			t.Errorf("Expected 2 chat messages, got: %d", len(messages))
+		}
+
+		raceControl.ChatMessagesMutex.Lock()
+		defer raceControl.ChatMessagesMutex.Unlock()
+
+		if len(raceControl.ChatMessages) != 0 {
+			t.Errorf("Expected chat commands not to be added to the chat log, got: %d messages", len(raceControl.ChatMessages))
+		}
+	})
+
+	for _, testCase := range []struct {
+		Name        string
+		SessionType udp.SessionType
+		Entrant     udp.SessionCarInfo
+		Expected    string
+	}{
+		{Name: "Leader", SessionType: udp.SessionTypeRace, Entrant: drivers[0], Expected: "You are P1 of 3. Gap behind: 1.234s (Test 2)."},
+		{Name: "Middle", SessionType: udp.SessionTypeRace, Entrant: drivers[1], Expected: "You are P2 of 3. Gap ahead: 1.234s (Test 1). Gap behind: unknown (Test 3)."},
+		{Name: "Last", SessionType: udp.SessionTypeRace, Entrant: drivers[2], Expected: "You are P3 of 3. Gap ahead: unknown (Test 2)."},
+		{Name: "Not a race", SessionType: udp.SessionTypePractice, Entrant: drivers[0], Expected: "Position information is only available during a race."},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			raceControl.SessionInfo.Type = testCase.SessionType
+
+			driver, ok := raceControl.ConnectedDrivers.Get(testCase.Entrant.DriverGUID)
+
+			if !ok {
+				t.Fatalf("Driver %s not connected", testCase.Entrant.DriverGUID)
+			}
+
+			if message := raceControl.whereAmIMessage(driver); message != testCase.Expected {
+				t.Errorf("Expected message %q, got %q", testCase.Expected, message)
+			}
+		})
+	}
+
+	t.Run("Splits are updated while the message is built", func(t *testing.T) {
+		raceControl.SessionInfo.Type = udp.SessionTypeRace
+
+		driverBehind, ok := raceControl.ConnectedDrivers.Get(drivers[2].DriverGUID)
+
+		if !ok {
+			t.Fatalf("Driver %s not connected", drivers[2].DriverGUID)
+		}
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			for i := 0; i < 100; i++ {
+				driverBehind.mutex.Lock()
+				driverBehind.Split = fmt.Sprintf("%d.000s", i)
+				driverBehind.mutex.Unlock()
+			}
+		}()
+
+		driver, _ := raceControl.ConnectedDrivers.Get(drivers[1].DriverGUID)
+
+		for i := 0; i < 100; i++ {
+			if message := raceControl.whereAmIMessage(driver); !strings.HasPrefix(message, "You are P2 of 3.") {
+				t.Errorf("Unexpected message %q", message)
+			}
+		}
+
+		<-done
+	})
+}
+
+func TestRaceControl_StandingsTable(t *testing.T) {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	driver, ok := raceControl.ConnectedDrivers.Get(drivers[0].DriverGUID)
+
+	if !ok {
+		t.Fatalf("Driver %s not connected", drivers[0].DriverGUID)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 100; i++ {
+			driver.mutex.Lock()
+			driver.Position = 1
+			driver.Split = fmt.Sprintf("%d.000s", i)
+			driver.mutex.Unlock()
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		if standings := raceControl.StandingsTable(); len(standings) != 1 || standings[0].Name != drivers[0].DriverName {
+			t.Errorf("Unexpected standings %+v", standings)
+		}
+	}
+
+	<-done
+}
+
+func TestRaceControl_AnomalousLaps(t *testing.T) {
+	// each subtest has its own store, so that the laps persisted by one aren't loaded by the next.
+	setup := func(t *testing.T, store Store) (*RaceControl, *RaceControlDriver) {
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 30}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return raceControl, driver
+	}
+
+	completeLap := func(t *testing.T, raceControl *RaceControl, lapTime time.Duration) {
+		err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: uint32(lapTime / time.Millisecond)})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Default maximum lap time", func(t *testing.T) {
+		store, removeStore := newIsolatedTestStore(t, nil)
+		defer removeStore()
+
+		raceControl, driver := setup(t, store)
+
+		completeLap(t, raceControl, 90*time.Second)
+
+		if car := driver.CurrentCar(); car.LastLapAnomalous {
+			t.Error("Expected a normal lap not to be anomalous")
+		}
+
+		completeLap(t, raceControl, 25*time.Minute)
+
+		car := driver.CurrentCar()
+
+		if !car.LastLapAnomalous || car.NumAnomalousLaps != 1 {
+			t.Errorf("Expected a 25 minute lap to be anomalous, got anomalous: %t, num anomalous laps: %d", car.LastLapAnomalous, car.NumAnomalousLaps)
+		}
+
+		completeLap(t, raceControl, 92*time.Second)
+
+		car = driver.CurrentCar()
+
+		if car.NumLaps != 3 {
+			t.Errorf("Expected anomalous laps to be counted, got %d laps", car.NumLaps)
+		}
+
+		if car.BestLap != 90*time.Second {
+			t.Errorf("Expected best lap of 90s, got %s", car.BestLap)
+		}
+
+		if car.AverageLap != 91*time.Second {
+			t.Errorf("Expected average lap of 91s, excluding the anomalous lap, got %s", car.AverageLap)
+		}
+	})
+
+	t.Run("Configured maximum lap time", func(t *testing.T) {
+		store, removeStore := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+			opts.MaxPlausibleLapTime = 120
+		})
+		defer removeStore()
+
+		raceControl, driver := setup(t, store)
+
+		// an anomalous lap is never the best lap, even if it is the driver's only lap
+		completeLap(t, raceControl, 150*time.Second)
+
+		car := driver.CurrentCar()
+
+		if !car.LastLapAnomalous || car.BestLap != 0 || car.AverageLap != 0 {
+			t.Errorf("Expected a 150s lap to be anomalous with no best or average lap, got anomalous: %t, best lap: %s, average lap: %s", car.LastLapAnomalous, car.BestLap, car.AverageLap)
+		}
+
+		completeLap(t, raceControl, 100*time.Second)
+
+		car = driver.CurrentCar()
+
+		if car.LastLapAnomalous || car.BestLap != 100*time.Second || car.AverageLap != 100*time.Second {
+			t.Errorf("Expected a 100s lap to be the best and average lap, got anomalous: %t, best lap: %s, average lap: %s", car.LastLapAnomalous, car.BestLap, car.AverageLap)
+		}
+	})
+}
+
+func TestRaceControl_FirstLapEvent(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("Enabled: %t", enabled), func(t *testing.T) {
+			store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+				opts.BroadcastFirstLaps = enabled
+			})
+			defer cleanup()
+
+			broadcaster := &countingBroadcaster{}
+			raceControl := NewRaceControl(broadcaster, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+			newSession := func() {
+				if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 30}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			completeLap := func(carID udp.CarID) {
+				if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: carID, LapTime: 90000}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			newSession()
+
+			for _, driver := range drivers[:2] {
+				if err := raceControl.OnClientConnect(driver); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			expected := func(count int) int {
+				if !enabled {
+					return 0
+				}
+
+				return count
+			}
+
+			completeLap(drivers[0].CarID)
+			completeLap(drivers[0].CarID)
+			completeLap(drivers[0].CarID)
+
+			if count := broadcaster.count(EventFirstLap); count != expected(1) {
+				t.Errorf("Expected %d first lap events after one driver's laps, got %d", expected(1), count)
+			}
+
+			completeLap(drivers[1].CarID)
+
+			if count := broadcaster.count(EventFirstLap); count != expected(2) {
+				t.Errorf("Expected %d first lap events after a second driver's lap, got %d", expected(2), count)
+			}
+
+			// each driver's first lap in a new session is broadcast again
+			newSession()
+			completeLap(drivers[0].CarID)
+			completeLap(drivers[0].CarID)
+
+			if count := broadcaster.count(EventFirstLap); count != expected(3) {
+				t.Errorf("Expected %d first lap events after a new session, got %d", expected(3), count)
+			}
+		})
+	}
+}
+
+func TestRaceControl_CarContentSwaps(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.DetectCarContentSwaps = true
+		opts.CarContentSwapPenalty = 30
+	})
+	defer cleanup()
+
+	setup := func(t *testing.T) (*RaceControl, *recordingServerProcess) {
+		process := &recordingServerProcess{}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+		if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		return raceControl, process
+	}
+
+	completeLapInCar := func(t *testing.T, raceControl *RaceControl, carModel string) *RaceControlDriver {
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 90000}); err != nil {
+			t.Fatal(err)
+		}
+
+		driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if driver.CarInfo.CarModel != carModel {
+			t.Fatalf("Expected driver to be in %s, got %s", carModel, driver.CarInfo.CarModel)
+		}
+
+		return driver
+	}
+
+	swappedCar := drivers[0]
+	swappedCar.CarModel = "ferrari_fxxk"
+
+	t.Run("Car change after reconnecting", func(t *testing.T) {
+		raceControl, process := setup(t)
+
+		if err := raceControl.OnClientDisconnect(drivers[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnClientConnect(swappedCar); err != nil {
+			t.Fatal(err)
+		}
+
+		if driver := completeLapInCar(t, raceControl, swappedCar.CarModel); driver.WrongCar {
+			t.Error("Expected a driver who reconnected in a new car not to be flagged")
+		}
+
+		if messages := process.chatMessagesTo(drivers[0].CarID); len(messages) != 0 {
+			t.Errorf("Expected no penalty messages, got: %v", messages)
+		}
+	})
+
+	t.Run("Car change without reconnecting", func(t *testing.T) {
+		raceControl, process := setup(t)
+
+		if err := raceControl.OnClientConnect(swappedCar); err != nil {
+			t.Fatal(err)
+		}
+
+		if driver := completeLapInCar(t, raceControl, swappedCar.CarModel); !driver.WrongCar {
+			t.Error("Expected a driver who changed car without reconnecting to be flagged")
+		}
+
+		// the driver is only penalised once
+		completeLapInCar(t, raceControl, swappedCar.CarModel)
+
+		if messages := process.chatMessagesTo(drivers[0].CarID); len(messages) != 1 {
+			t.Errorf("Expected 1 penalty message, got: %d", len(messages))
+		}
+
+		raceControl.sessionPenaltiesMutex.Lock()
+		defer raceControl.sessionPenaltiesMutex.Unlock()
+
+		if penalty, ok := raceControl.sessionPenalties[drivers[0].DriverGUID]; !ok || penalty.penalty != 30*time.Second {
+			t.Errorf("Expected a 30s session penalty, got: %+v", penalty)
+		}
+	})
+}
+
+func TestParseLapTime(t *testing.T) {
+	for _, testCase := range []struct {
+		Milliseconds int
+		Expected     time.Duration
+	}{
+		{Milliseconds: 0, Expected: 0},
+		{Milliseconds: 1, Expected: time.Millisecond},
+		{Milliseconds: 999, Expected: 999 * time.Millisecond},
+		{Milliseconds: 90500, Expected: time.Minute + 30*time.Second + 500*time.Millisecond},
+		{Milliseconds: 3723004, Expected: time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond},
+		{Milliseconds: math.MaxUint32, Expected: 4294967295 * time.Millisecond},
+		{Milliseconds: -1, Expected: -time.Millisecond},
+		{Milliseconds: -90500, Expected: -(time.Minute + 30*time.Second + 500*time.Millisecond)},
+		{Milliseconds: int(maxLapTimeMilliseconds), Expected: time.Duration(maxLapTimeMilliseconds) * time.Millisecond},
+		{Milliseconds: -int(maxLapTimeMilliseconds), Expected: -time.Duration(maxLapTimeMilliseconds) * time.Millisecond},
+		{Milliseconds: int(maxLapTimeMilliseconds) + 1, Expected: 0},
+		{Milliseconds: -int(maxLapTimeMilliseconds) - 1, Expected: 0},
+		{Milliseconds: int(^uint(0) >> 1), Expected: 0},
+	} {
+		t.Run(strconv.Itoa(testCase.Milliseconds), func(t *testing.T) {
+			if d := ParseLapTime(testCase.Milliseconds); d != testCase.Expected {
+				t.Errorf("Expected %d ms to be %s, got %s", testCase.Milliseconds, testCase.Expected, d)
+			}
+
+			// ParseLapTime must agree with parsing the milliseconds as a duration string, which gives 0 for times
+			// which are too long for a duration.
+			parsed, _ := time.ParseDuration(fmt.Sprintf("%dms", testCase.Milliseconds))
+
+			if d := ParseLapTime(testCase.Milliseconds); d != parsed {
+				t.Errorf("Expected %d ms to be %s, got %s", testCase.Milliseconds, parsed, d)
+			}
+		})
+	}
+
+	t.Run("Result times", func(t *testing.T) {
+		results := &SessionResults{
+			Cars: []*SessionCar{{CarID: 1, Model: "ks_mazda_mx5_cup", Driver: SessionDriver{GUID: "1234"}}},
+			Laps: []*SessionLap{{CarID: 1, LapTime: 90500}},
+		}
+
+		for _, ms := range []int{90500, -90500, int(maxLapTimeMilliseconds) + 1} {
+			if d := results.GetTime(ms, "1234", "ks_mazda_mx5_cup", false); d != ParseLapTime(ms) {
+				t.Errorf("Expected a result time of %d ms to be %s, got %s", ms, ParseLapTime(ms), d)
+			}
+		}
+	})
+}
+
+func TestRaceControl_ImpactSpeedClamping(t *testing.T) {
+	setup := func(t *testing.T, store Store) *RaceControl {
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+		for _, driver := range drivers[:2] {
+			if err := raceControl.OnClientConnect(driver); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return raceControl
+	}
+
+	collisionSpeeds := func(t *testing.T, raceControl *RaceControl) []float64 {
+		driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var speeds []float64
+
+		for _, collision := range driver.Collisions {
+			speeds = append(speeds, math.Round(collision.Speed))
+		}
+
+		return speeds
+	}
+
+	for _, testCase := range []struct {
+		Name           string
+		MaxSpeed       int
+		ImpactSpeed    float32
+		ExpectedSpeed  float64
+		EnvironmentHit bool
+	}{
+		{Name: "Normal impact with car", ImpactSpeed: 20, ExpectedSpeed: 72},
+		{Name: "Normal impact with environment", ImpactSpeed: 20, ExpectedSpeed: 72, EnvironmentHit: true},
+		{Name: "Absurd impact with car", ImpactSpeed: 100000, ExpectedSpeed: defaultMaxPlausibleImpactSpeed},
+		{Name: "Absurd impact with environment", ImpactSpeed: 100000, ExpectedSpeed: defaultMaxPlausibleImpactSpeed, EnvironmentHit: true},
+		{Name: "Configured maximum speed", MaxSpeed: 300, ImpactSpeed: 100, ExpectedSpeed: 300},
+		{Name: "Invalid impact speed", ImpactSpeed: float32(math.NaN()), ExpectedSpeed: 0, EnvironmentHit: true},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+				opts.MaxPlausibleImpactSpeed = testCase.MaxSpeed
+			})
+			defer cleanup()
+
+			raceControl := setup(t, store)
+
+			var err error
+
+			if testCase.EnvironmentHit {
+				err = raceControl.OnCollisionWithEnvironment(udp.CollisionWithEnvironment{CarID: drivers[0].CarID, ImpactSpeed: testCase.ImpactSpeed})
+			} else {
+				err = raceControl.OnCollisionWithCar(udp.CollisionWithCar{CarID: drivers[0].CarID, OtherCarID: drivers[1].CarID, ImpactSpeed: testCase.ImpactSpeed})
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if speeds := collisionSpeeds(t, raceControl); len(speeds) != 1 || speeds[0] != testCase.ExpectedSpeed {
+				t.Errorf("Expected a collision at %.0f km/h, got: %v", testCase.ExpectedSpeed, speeds)
+			}
+		})
+	}
+}
+
+func TestRaceControl_LapPhase(t *testing.T) {
+	setup := func(t *testing.T, sessionInfo udp.SessionInfo) (*RaceControl, *RaceControlDriver) {
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+		if err := raceControl.OnNewSession(sessionInfo); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return raceControl, driver
+	}
+
+	load := func(t *testing.T, raceControl *RaceControl) {
+		if err := raceControl.OnClientLoaded(udp.ClientLoaded(drivers[0].CarID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	completeLap := func(t *testing.T, raceControl *RaceControl) {
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 90000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectPhase := func(t *testing.T, driver *RaceControlDriver, expected LapPhase) {
+		t.Helper()
+
+		if driver.LapPhase != expected {
+			t.Errorf("Expected lap phase %s, got %s", expected, driver.LapPhase)
+		}
+	}
+
+	qualifying := udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeQualifying, Time: 10}
+
+	t.Run("On track until loaded", func(t *testing.T) {
+		_, driver := setup(t, qualifying)
+
+		expectPhase(t, driver, LapPhaseOnTrack)
+	})
+
+	t.Run("Out lap, then flying laps", func(t *testing.T) {
+		raceControl, driver := setup(t, qualifying)
+
+		load(t, raceControl)
+		expectPhase(t, driver, LapPhaseOutLap)
+
+		completeLap(t, raceControl)
+		expectPhase(t, driver, LapPhaseFlying)
+
+		completeLap(t, raceControl)
+		expectPhase(t, driver, LapPhaseFlying)
+	})
+
+	t.Run("Out lap at the start of each session", func(t *testing.T) {
+		raceControl, driver := setup(t, qualifying)
+
+		load(t, raceControl)
+		completeLap(t, raceControl)
+
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace, Laps: 10}); err != nil {
+			t.Fatal(err)
+		}
+
+		expectPhase(t, driver, LapPhaseOutLap)
+	})
+
+	t.Run("In lap once the session time has run out", func(t *testing.T) {
+		raceControl, driver := setup(t, qualifying)
+
+		load(t, raceControl)
+		completeLap(t, raceControl)
+
+		raceControl.SessionStartTime = time.Now().Add(-11 * time.Minute)
+
+		completeLap(t, raceControl)
+		expectPhase(t, driver, LapPhaseInLap)
+	})
+
+	t.Run("In lap once all laps are completed", func(t *testing.T) {
+		raceControl, driver := setup(t, udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace, Laps: 2})
+
+		load(t, raceControl)
+		completeLap(t, raceControl)
+		expectPhase(t, driver, LapPhaseFlying)
+
+		completeLap(t, raceControl)
+		expectPhase(t, driver, LapPhaseInLap)
+	})
+}
+
+func TestPositionHasChanged(t *testing.T) {
+	initialPosition := udp.Vec{X: 100, Y: 5, Z: -200}
+
+	for _, testCase := range []struct {
+		Name      string
+		Threshold float64
+		Current   udp.Vec
+		Expected  bool
+	}{
+		{Name: "Stationary", Threshold: 10, Current: initialPosition, Expected: false},
+		{Name: "Just below the threshold", Threshold: 10, Current: udp.Vec{X: 109.9, Y: 5, Z: -200}, Expected: false},
+		{Name: "Just above the threshold", Threshold: 10, Current: udp.Vec{X: 100, Y: 5, Z: -210.1}, Expected: true},
+		{Name: "Below a larger threshold", Threshold: 15, Current: udp.Vec{X: 100, Y: 5, Z: -212}, Expected: false},
+		{Name: "Above a smaller threshold", Threshold: 2.5, Current: udp.Vec{X: 97, Y: 5, Z: -200}, Expected: true},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			if changed := positionHasChanged(initialPosition, testCase.Current, testCase.Threshold); changed != testCase.Expected {
+				t.Errorf("Expected position changed to be %t, got %t", testCase.Expected, changed)
+			}
+		})
+	}
+
+	t.Run("Default threshold", func(t *testing.T) {
+		if threshold := (CurrentRaceConfig{}).DriverSwapPositionThreshold(); threshold != defaultDriverSwapMovementThreshold {
+			t.Errorf("Expected the default threshold of %.1f, got %.1f", defaultDriverSwapMovementThreshold, threshold)
+		}
+	})
+}
+
+func TestRaceControl_DriverSwapSingleSwapPerCar(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, nil)
+	defer cleanup()
+
+	process := &recordingServerProcess{event: &ActiveChampionship{RaceConfig: CurrentRaceConfig{DriverSwapEnabled: 1, DriverSwapMinTime: 60}}}
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace, Laps: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	activeSwap := func() context.Context {
+		raceControl.driverSwapsMutex.Lock()
+		defer raceControl.driverSwapsMutex.Unlock()
+
+		if len(raceControl.driverSwaps) != 1 {
+			t.Fatalf("Expected 1 active driver swap, got %d", len(raceControl.driverSwaps))
+		}
+
+		return raceControl.driverSwaps[drivers[0].CarID].driverSwapContext
+	}
+
+	t.Run("A new swap cancels the previous one", func(t *testing.T) {
+		reconnectAndDisconnect := func() {
+			if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := raceControl.OnClientLoaded(udp.ClientLoaded(drivers[0].CarID)); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := raceControl.OnClientDisconnect(drivers[0]); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		reconnectAndDisconnect()
+		firstSwap := activeSwap()
+
+		reconnectAndDisconnect()
+		secondSwap := activeSwap()
+
+		select {
+		case <-firstSwap.Done():
+		case <-time.After(time.Second):
+			t.Error("Expected the first driver swap to be cancelled when the second one started")
+		}
+
+		if secondSwap.Err() != nil {
+			t.Error("Expected the second driver swap to still be running")
+		}
+
+		raceControl.cancelDriverSwaps()
+	})
+
+	t.Run("Only one swap sends countdown messages", func(t *testing.T) {
+		pitBox := udp.Vec{X: 100, Y: 10, Z: 200}
+
+		previousDriver := NewRaceControlDriver(drivers[0])
+		previousDriver.LastPos = pitBox
+
+		nextDriverInfo := drivers[1]
+		nextDriverInfo.CarID = drivers[0].CarID
+
+		if err := raceControl.OnClientConnect(nextDriverInfo); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnClientLoaded(udp.ClientLoaded(nextDriverInfo.CarID)); err != nil {
+			t.Fatal(err)
+		}
+
+		nextDriver, err := raceControl.findConnectedDriverByCarID(nextDriverInfo.CarID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		nextDriver.mutex.Lock()
+		nextDriver.LastPos = pitBox
+		nextDriver.mutex.Unlock()
+
+		config := CurrentRaceConfig{DriverSwapEnabled: 1, DriverSwapMinTime: 60, DriverSwapDisqualifyTime: 30}
+
+		// the second swap is registered before the first has started running, so the first must still stop.
+		firstCtx := raceControl.registerDriverSwap(drivers[0].CarID, previousDriver)
+		secondCtx := raceControl.registerDriverSwap(drivers[0].CarID, previousDriver)
+
+		var wg sync.WaitGroup
+
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+			raceControl.handleDriverSwap(firstCtx, time.NewTicker(time.Millisecond), config, drivers[0], previousDriver)
+		}()
+
+		go func() {
+			defer wg.Done()
+			raceControl.handleDriverSwap(secondCtx, time.NewTicker(time.Millisecond), config, drivers[0], previousDriver)
+		}()
+
+		wg.Wait()
+
+		countdowns := make(map[string]int)
+		numCompleted := 0
+
+		for _, message := range process.chatMessagesTo(nextDriverInfo.CarID) {
+			if strings.HasPrefix(message, "Free to leave pits in") {
+				countdowns[message]++
+			}
+
+			if message == "You are clear to leave the pits, go go go!" {
+				numCompleted++
+			}
+		}
+
+		if len(countdowns) == 0 {
+			t.Error("Expected countdown messages to be sent")
+		}
+
+		for message, count := range countdowns {
+			if count != 1 {
+				t.Errorf("Expected countdown message %q to be sent once, got %d", message, count)
+			}
+		}
+
+		if numCompleted != 1 {
+			t.Errorf("Expected the driver swap to complete once, got %d", numCompleted)
+		}
+	})
+}
+
+func TestRaceControl_DriverSwapCountdownIntervals(t *testing.T) {
+	countdownMessages := func(t *testing.T, intervals string, every, minTime int) []string {
+		store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+			opts.DriverSwapCountdownAt = intervals
+			opts.DriverSwapCountdownEvery = every
+		})
+		defer cleanup()
+
+		process := &recordingServerProcess{}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace, Laps: 10}); err != nil {
+			t.Fatal(err)
+		}
+
+		pitBox := udp.Vec{X: 100, Y: 10, Z: 200}
+
+		previousDriver := NewRaceControlDriver(drivers[0])
+		previousDriver.LastPos = pitBox
+
+		nextDriverInfo := drivers[1]
+		nextDriverInfo.CarID = drivers[0].CarID
+
+		if err := raceControl.OnClientConnect(nextDriverInfo); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnClientLoaded(udp.ClientLoaded(nextDriverInfo.CarID)); err != nil {
+			t.Fatal(err)
+		}
+
+		nextDriver, err := raceControl.findConnectedDriverByCarID(nextDriverInfo.CarID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		nextDriver.LastPos = pitBox
+
+		config := CurrentRaceConfig{DriverSwapEnabled: 1, DriverSwapMinTime: minTime, DriverSwapDisqualifyTime: 30}
+
+		ctx := raceControl.registerDriverSwap(drivers[0].CarID, previousDriver)
+
+		// each tick counts as a second of the driver swap, so the whole swap completes almost immediately.
+		raceControl.handleDriverSwap(ctx, time.NewTicker(time.Millisecond), config, drivers[0], previousDriver)
+
+		var out []string
+
+		for _, message := range process.chatMessagesTo(nextDriverInfo.CarID) {
+			if strings.HasPrefix(message, "Free to leave pits in") {
+				out = append(out, message)
+			}
+		}
+
+		if messages := process.chatMessagesTo(nextDriverInfo.CarID); len(messages) == 0 || messages[len(messages)-1] != "You are clear to leave the pits, go go go!" {
+			t.Errorf("Expected the driver swap to complete, got messages: %v", messages)
+		}
+
+		return out
+	}
+
+	t.Run("Default intervals", func(t *testing.T) {
+		expected := []string{
+			"Free to leave pits in 1m0s",
+			"Free to leave pits in 30s",
+			"Free to leave pits in 10s",
+			"Free to leave pits in 5s",
+			"Free to leave pits in 3s",
+			"Free to leave pits in 2s",
+			"Free to leave pits in 1s",
+		}
+
+		if messages := countdownMessages(t, "", 0, 70); strings.Join(messages, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("Expected countdown messages %v, got %v", expected, messages)
+		}
+	})
+
+	t.Run("Custom intervals", func(t *testing.T) {
+		expected := []string{
+			"Free to leave pits in 45s",
+			"Free to leave pits in 15s",
+		}
+
+		if messages := countdownMessages(t, "45, 15, nope, -5, 90", 0, 70); strings.Join(messages, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("Expected countdown messages %v, got %v", expected, messages)
+		}
+	})
+
+	t.Run("Every 5 seconds, then every second for the final 5 seconds of a 30 second swap", func(t *testing.T) {
+		expected := []string{
+			"Free to leave pits in 25s",
+			"Free to leave pits in 20s",
+			"Free to leave pits in 15s",
+			"Free to leave pits in 10s",
+			"Free to leave pits in 5s",
+			"Free to leave pits in 4s",
+			"Free to leave pits in 3s",
+			"Free to leave pits in 2s",
+			"Free to leave pits in 1s",
+		}
+
+		if messages := countdownMessages(t, "4,3,2,1", 5, 30); strings.Join(messages, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("Expected countdown messages %v, got %v", expected, messages)
+		}
+	})
+}
+
+func TestRaceControl_Pileups(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+
+	newRaceControl := func(store Store) *RaceControl {
+		rc := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+		collisions := map[int][]Collision{
+			0: {
+				{ID: "a", Type: CollisionWithCar, Time: start, OtherDriverGUID: drivers[1].DriverGUID, OtherDriverName: drivers[1].DriverName, WorldPos: udp.Vec{X: 10, Z: 10}},
+				{ID: "e", Type: CollisionWithCar, Time: start.Add(30 * time.Second), OtherDriverGUID: drivers[1].DriverGUID, OtherDriverName: drivers[1].DriverName, WorldPos: udp.Vec{X: 10, Z: 10}},
+			},
+			1: {
+				{ID: "d", Type: CollisionWithCar, Time: start.Add(3 * time.Second), OtherDriverGUID: drivers[2].DriverGUID, OtherDriverName: drivers[2].DriverName, WorldPos: udp.Vec{X: 40, Z: 15}},
+			},
+			2: {
+				{ID: "b", Type: CollisionWithCar, Time: start.Add(2 * time.Second), OtherDriverGUID: drivers[3].DriverGUID, OtherDriverName: drivers[3].DriverName, WorldPos: udp.Vec{X: 30, Z: 10}},
+			},
+			4: {
+				// a collision elsewhere on track at the same time is a separate incident
+				{ID: "c", Type: CollisionWithEnvironment, Time: start.Add(2 * time.Second), WorldPos: udp.Vec{X: 5000, Z: 5000}},
+			},
+		}
+
+		for i, driverCollisions := range collisions {
+			driver := NewRaceControlDriver(drivers[i])
+			driver.Collisions = driverCollisions
+			rc.ConnectedDrivers.Add(driver.CarInfo.DriverGUID, driver)
+		}
+
+		return rc
+	}
+
+	pileupDrivers := func(pileup Pileup) []udp.DriverGUID {
+		var out []udp.DriverGUID
+
+		for _, driver := range pileup.Drivers {
+			out = append(out, driver.DriverGUID)
+		}
+
+		return out
+	}
+
+	t.Run("Near simultaneous collisions are grouped", func(t *testing.T) {
+		pileups := newRaceControl(testStore).Pileups()
+
+		if len(pileups) != 1 {
+			t.Fatalf("Expected 1 pileup, got: %d", len(pileups))
+		}
+
+		pileup := pileups[0]
+
+		expectedDrivers := []udp.DriverGUID{drivers[0].DriverGUID, drivers[1].DriverGUID, drivers[2].DriverGUID, drivers[3].DriverGUID}
+
+		if !reflect.DeepEqual(pileupDrivers(pileup), expectedDrivers) {
+			t.Errorf("Expected pileup drivers: %v, got: %v", expectedDrivers, pileupDrivers(pileup))
+		}
+
+		if len(pileup.Collisions) != 3 {
+			t.Errorf("Expected 3 collisions in the pileup, got: %d", len(pileup.Collisions))
+		}
+
+		if !pileup.Start.Equal(start) || !pileup.End.Equal(start.Add(3*time.Second)) {
+			t.Errorf("Expected pileup from %s to %s, got %s to %s", start, start.Add(3*time.Second), pileup.Start, pileup.End)
+		}
+	})
+
+	t.Run("Correlation window is configurable", func(t *testing.T) {
+		store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+			opts.PileupWindowSeconds = 1
+		})
+		defer cleanup()
+
+		pileups := newRaceControl(store).Pileups()
+
+		if len(pileups) != 1 {
+			t.Fatalf("Expected 1 pileup, got: %d", len(pileups))
+		}
+
+		expectedDrivers := []udp.DriverGUID{drivers[2].DriverGUID, drivers[3].DriverGUID, drivers[1].DriverGUID}
+
+		if !reflect.DeepEqual(pileupDrivers(pileups[0]), expectedDrivers) {
+			t.Errorf("Expected pileup drivers: %v, got: %v", expectedDrivers, pileupDrivers(pileups[0]))
+		}
+	})
+
+	t.Run("Collisions between two drivers are not a pileup", func(t *testing.T) {
+		rc := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+		driver := NewRaceControlDriver(drivers[0])
+		driver.Collisions = []Collision{
+			{ID: "a", Type: CollisionWithCar, Time: start, OtherDriverGUID: drivers[1].DriverGUID},
+			{ID: "b", Type: CollisionWithCar, Time: start.Add(time.Second), OtherDriverGUID: drivers[1].DriverGUID},
+		}
+		rc.ConnectedDrivers.Add(driver.CarInfo.DriverGUID, driver)
+
+		if pileups := rc.Pileups(); len(pileups) != 0 {
+			t.Errorf("Expected no pileups, got: %d", len(pileups))
+		}
+	})
+}
+
+func TestRaceControl_QualifyingExtension(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.QualifyingExtension = 60
+	})
+	defer cleanup()
+
+	setup := func(t *testing.T) (*RaceControl, *recordingServerProcess) {
+		process := &recordingServerProcess{}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeQualifying, Time: 10}); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, driver := range drivers[:2] {
+			if err := raceControl.OnClientConnect(driver); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := raceControl.OnClientLoaded(udp.ClientLoaded(driver.CarID)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return raceControl, process
+	}
+
+	t.Run("Drivers on a flying lap extend the session", func(t *testing.T) {
+		raceControl, process := setup(t)
+
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 90000}); err != nil {
+			t.Fatal(err)
+		}
+
+		if extension := raceControl.qualifyingExtension(time.Now().Add(50 * time.Second)); extension != 40*time.Second {
+			t.Errorf("Expected a 40s extension, got: %s", extension)
+		}
+
+		// the extension is capped, even if the driver has a long way to go
+		if extension := raceControl.qualifyingExtension(time.Now().Add(-time.Minute)); extension != time.Minute {
+			t.Errorf("Expected a 1m extension, got: %s", extension)
+		}
+
+		raceControl.extendQualifying()
+
+		if chat := strings.Join(process.broadcastChatMessages(), " "); !strings.Contains(chat, "Time is up!") {
+			t.Errorf("Expected a qualifying extension message, got: %q", chat)
+		}
+	})
+
+	t.Run("Drivers on an out lap don't extend the session", func(t *testing.T) {
+		raceControl, process := setup(t)
+
+		if extension := raceControl.qualifyingExtension(time.Now()); extension != 0 {
+			t.Errorf("Expected no extension, got: %s", extension)
+		}
+
+		raceControl.extendQualifying()
+
+		if messages := process.broadcastChatMessages(); len(messages) != 0 {
+			t.Errorf("Expected no qualifying extension message, got: %v", messages)
+		}
+	})
+
+	t.Run("Drivers on an in lap don't extend the session", func(t *testing.T) {
+		raceControl, _ := setup(t)
+
+		raceControl.SessionStartTime = time.Now().Add(-11 * time.Minute)
+
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 90000}); err != nil {
+			t.Fatal(err)
+		}
+
+		if extension := raceControl.qualifyingExtension(time.Now()); extension != 0 {
+			t.Errorf("Expected no extension, got: %s", extension)
+		}
+	})
+}
+
+func TestRaceControl_CleanStreak(t *testing.T) {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace, Laps: 20}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, driver := range drivers[:3] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	completeLaps := func(t *testing.T, carID udp.CarID, numLaps int) {
+		for i := 0; i < numLaps; i++ {
+			if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: carID, LapTime: 90000}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	cleanStreak := func(t *testing.T, carID udp.CarID) int {
+		driver, err := raceControl.findConnectedDriverByCarID(carID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return driver.CleanStreak
+	}
+
+	if cleanest := raceControl.CleanestDrivers(); cleanest != nil {
+		t.Errorf("Expected no cleanest drivers before any laps, got: %v", cleanest)
+	}
+
+	completeLaps(t, drivers[0].CarID, 3)
+	completeLaps(t, drivers[1].CarID, 3)
+	completeLaps(t, drivers[2].CarID, 1)
+
+	if streak := cleanStreak(t, drivers[0].CarID); streak != 3 {
+		t.Errorf("Expected a clean streak of 3, got: %d", streak)
+	}
+
+	if cleanest := raceControl.CleanestDrivers(); !reflect.DeepEqual(cleanest, []udp.DriverGUID{drivers[0].DriverGUID, drivers[1].DriverGUID}) && !reflect.DeepEqual(cleanest, []udp.DriverGUID{drivers[1].DriverGUID, drivers[0].DriverGUID}) {
+		t.Errorf("Expected drivers 0 and 1 to be the cleanest, got: %v", cleanest)
+	}
+
+	if err := raceControl.OnCollisionWithCar(udp.CollisionWithCar{CarID: drivers[0].CarID, OtherCarID: drivers[2].CarID, ImpactSpeed: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := raceControl.OnCollisionWithEnvironment(udp.CollisionWithEnvironment{CarID: drivers[2].CarID, ImpactSpeed: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	if streak := cleanStreak(t, drivers[0].CarID); streak != 0 {
+		t.Errorf("Expected clean streak to be reset by a collision with a car, got: %d", streak)
+	}
+
+	if streak := cleanStreak(t, drivers[2].CarID); streak != 0 {
+		t.Errorf("Expected clean streak to be reset by a collision with the environment, got: %d", streak)
+	}
+
+	completeLaps(t, drivers[0].CarID, 2)
+
+	if streak := cleanStreak(t, drivers[0].CarID); streak != 2 {
+		t.Errorf("Expected clean streak to grow after the collision, got: %d", streak)
+	}
+
+	if cleanest := raceControl.CleanestDrivers(); !reflect.DeepEqual(cleanest, []udp.DriverGUID{drivers[1].DriverGUID}) {
+		t.Errorf("Expected driver 1 to be the cleanest, got: %v", cleanest)
+	}
+}
+
+func TestRaceControl_AnonymiseDriverGUIDs(t *testing.T) {
+	steamDriver := udp.SessionCarInfo{
+		CarID:      7,
+		DriverName: "Anonymous Driver",
+		DriverGUID: "76561198000000001",
+		CarModel:   "ks_mazda_mx5_cup",
+		EventType:  udp.EventNewConnection,
+	}
+
+	// setup returns a race control with a connected Steam driver, its hub, and a func which removes its store.
+	setup := func(t *testing.T, anonymise bool, salt string) (*RaceControl, *RaceControlHub, func()) {
+		store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+			opts.AnonymiseDriverGUIDs = anonymise
+			opts.AnonymiseGUIDSalt = salt
+		})
+
+		hub := newRaceControlHub()
+		raceControl := NewRaceControl(hub, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+		hub.anonymiser = raceControl.anonymiseDriverGUIDs
+
+		if err := raceControl.OnClientConnect(steamDriver); err != nil {
+			t.Fatal(err)
+		}
+
+		return raceControl, hub, cleanup
+	}
+
+	encode := func(t *testing.T, hub *RaceControlHub, message udp.Message) string {
+		encoded, err := hub.encode(message)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(encoded)
+	}
+
+	t.Run("GUIDs are replaced with a consistent token", func(t *testing.T) {
+		raceControl, hub, cleanup := setup(t, true, "salty")
+		defer cleanup()
+
+		token := string(anonymiseDriverGUID(steamDriver.DriverGUID, "salty"))
+
+		for _, message := range []udp.Message{steamDriver, raceControl} {
+			encoded := encode(t, hub, message)
+
+			if strings.Contains(encoded, string(steamDriver.DriverGUID)) {
+				t.Errorf("Expected GUID to be anonymised in %T, got: %s", message, encoded)
+			}
+
+			if !strings.Contains(encoded, token) {
+				t.Errorf("Expected token %s in %T, got: %s", token, message, encoded)
+			}
+		}
+
+		// the driver keeps the same token when they reconnect
+		if err := raceControl.OnClientDisconnect(steamDriver); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnClientConnect(steamDriver); err != nil {
+			t.Fatal(err)
+		}
+
+		if encoded := encode(t, hub, raceControl); !strings.Contains(encoded, token) {
+			t.Errorf("Expected token %s after reconnecting, got: %s", token, encoded)
+		}
+
+		if resolved := raceControl.resolveDriverGUID(token); resolved != steamDriver.DriverGUID {
+			t.Errorf("Expected token to resolve to %s, got: %s", steamDriver.DriverGUID, resolved)
+		}
+	})
+
+	t.Run("Tokens depend on the salt", func(t *testing.T) {
+		if anonymiseDriverGUID(steamDriver.DriverGUID, "salty") == anonymiseDriverGUID(steamDriver.DriverGUID, "peppery") {
+			t.Error("Expected different salts to give different tokens")
+		}
+	})
+
+	t.Run("Anonymised messages are valid JSON", func(t *testing.T) {
+		raceControl, hub, cleanup := setup(t, true, "salty")
+		defer cleanup()
+
+		driver, ok := raceControl.ConnectedDrivers.Get(steamDriver.DriverGUID)
+
+		if !ok {
+			t.Fatal("Expected driver to be connected")
+		}
+
+		// the fractional part of this float has 17 digits, like a Steam ID.
+		driver.mutex.Lock()
+		driver.TrackPosition = float64(float32(0.3))
+		driver.mutex.Unlock()
+
+		messages := []udp.Message{
+			raceControl,
+			steamDriver,
+			PitLane{DriverGUID: steamDriver.DriverGUID, CarID: steamDriver.CarID, InPits: true},
+			udp.Chat{CarID: steamDriver.CarID, Message: "hello", DriverGUID: steamDriver.DriverGUID, DriverName: steamDriver.DriverName},
+		}
+
+		for _, message := range messages {
+			encoded := encode(t, hub, message)
+
+			if !json.Valid([]byte(encoded)) {
+				t.Errorf("Expected anonymised %T to be valid JSON, got: %s", message, encoded)
+			}
+
+			if strings.Contains(encoded, string(steamDriver.DriverGUID)) {
+				t.Errorf("Expected GUID to be anonymised in %T, got: %s", message, encoded)
+			}
+		}
+
+		if encoded := encode(t, hub, raceControl); !strings.Contains(encoded, `"TrackPosition":0.30000001192092896`) {
+			t.Errorf("Expected the track position to be unchanged, got: %s", encoded)
+		}
+
+		snapshot, err := json.Marshal(raceControl.anonymiseSnapshot(raceControl.Snapshot(LiveTimingsFilter{}, SortByPosition)))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !json.Valid(snapshot) || strings.Contains(string(snapshot), string(steamDriver.DriverGUID)) {
+			t.Errorf("Expected a valid, anonymised snapshot, got: %s", snapshot)
+		}
+	})
+
+	t.Run("GUIDs are kept when anonymisation is off", func(t *testing.T) {
+		raceControl, hub, cleanup := setup(t, false, "salty")
+		defer cleanup()
+
+		if encoded := encode(t, hub, raceControl); !strings.Contains(encoded, string(steamDriver.DriverGUID)) {
+			t.Errorf("Expected GUID to be kept, got: %s", encoded)
+		}
+
+		if resolved := raceControl.resolveDriverGUID(string(steamDriver.DriverGUID)); resolved != steamDriver.DriverGUID {
+			t.Errorf("Expected GUID to resolve to itself, got: %s", resolved)
+		}
+	})
+}
+
+func TestRaceControl_IncidentAlert(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.IncidentAlertCollisions = 3
+		opts.IncidentAlertWindow = 10
+	})
+	defer cleanup()
+
+	numAlerts := func(process *recordingServerProcess) int {
+		return strings.Count(strings.Join(process.broadcastChatMessages(), " "), "take care!")
+	}
+
+	t.Run("Alert when the collision rate exceeds the threshold", func(t *testing.T) {
+		process := &recordingServerProcess{}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+		for _, driver := range drivers[:3] {
+			if err := raceControl.OnClientConnect(driver); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := raceControl.OnCollisionWithCar(udp.CollisionWithCar{CarID: drivers[0].CarID, OtherCarID: drivers[1].CarID, ImpactSpeed: 10}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnCollisionWithEnvironment(udp.CollisionWithEnvironment{CarID: drivers[2].CarID, ImpactSpeed: 10}); err != nil {
+			t.Fatal(err)
+		}
+
+		if alerts := numAlerts(process); alerts != 0 {
+			t.Errorf("Expected no alerts below the threshold, got: %d", alerts)
+		}
+
+		if err := raceControl.OnCollisionWithCar(udp.CollisionWithCar{CarID: drivers[1].CarID, OtherCarID: drivers[2].CarID, ImpactSpeed: 10}); err != nil {
+			t.Fatal(err)
+		}
+
+		if alerts := numAlerts(process); alerts != 1 {
+			t.Errorf("Expected 1 alert at the threshold, got: %d", alerts)
+		}
+
+		if err := raceControl.OnCollisionWithEnvironment(udp.CollisionWithEnvironment{CarID: drivers[0].CarID, ImpactSpeed: 10}); err != nil {
+			t.Fatal(err)
+		}
+
+		if alerts := numAlerts(process); alerts != 1 {
+			t.Errorf("Expected alerts to be rate limited, got: %d", alerts)
+		}
+	})
+
+	t.Run("Collisions spread out over time don't trigger an alert", func(t *testing.T) {
+		process := &recordingServerProcess{}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+		start := time.Now()
+
+		for i := 0; i < 5; i++ {
+			raceControl.checkIncidentRate(start.Add(time.Duration(i) * 6 * time.Second))
+		}
+
+		if alerts := numAlerts(process); alerts != 0 {
+			t.Errorf("Expected no alerts, got: %d", alerts)
+		}
+	})
+
+	t.Run("Alerts resume after the cooldown", func(t *testing.T) {
+		process := &recordingServerProcess{}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+		start := time.Now()
+
+		for _, offset := range []time.Duration{0, time.Second, 2 * time.Second, 30 * time.Second, 31 * time.Second, 32 * time.Second} {
+			raceControl.checkIncidentRate(start.Add(offset))
+		}
+
+		if alerts := numAlerts(process); alerts != 1 {
+			t.Errorf("Expected 1 alert within the cooldown, got: %d", alerts)
+		}
+
+		for _, offset := range []time.Duration{2 * time.Minute, 2*time.Minute + time.Second, 2*time.Minute + 2*time.Second} {
+			raceControl.checkIncidentRate(start.Add(offset))
+		}
+
+		if alerts := numAlerts(process); alerts != 2 {
+			t.Errorf("Expected 2 alerts after the cooldown, got: %d", alerts)
+		}
+	})
+}
+
+func TestRaceControl_QuietHoursAnnouncements(t *testing.T) {
+	now := time.Now().UTC()
+
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.AnnounceFastestLap = true
+		opts.AnnouncePolePosition = true
+		opts.IncidentAlertCollisions = 1
+
+		// quiet hours around the current time
+		opts.QuietHoursStart = now.Add(-time.Hour).Format("15:04")
+		opts.QuietHoursEnd = now.Add(time.Hour).Format("15:04")
+		opts.QuietHoursTimezone = "UTC"
+	})
+	defer cleanup()
+
+	process := &recordingServerProcess{}
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+	raceControl.SessionInfo.Type = udp.SessionTypeQualifying
+
+	for _, driver := range drivers[:2] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, lap := range []udp.LapCompleted{
+		{CarID: drivers[0].CarID, LapTime: 89000},
+		{CarID: drivers[1].CarID, LapTime: 88500},
+	} {
+		if err := raceControl.OnLapCompleted(lap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	raceControl.announcePolePosition()
+	raceControl.checkIncidentRate(time.Now())
+
+	if messages := process.broadcastChatMessages(); len(messages) != 0 {
+		t.Errorf("Expected no announcements in chat during quiet hours, got: %v", messages)
+	}
+}
+
+// replayCapture is a short race in which Test 2 overtakes Test 1. The entries are deliberately out of order.
+const replayCapture = `[
+	{"Received": "2020-05-01T20:00:00Z", "EventType": 50, "Data": {"Track": "ks_laguna_seca", "Name": "Race", "Type": 3, "Laps": 3, "EventType": 50}},
+	{"Received": "2020-05-01T20:00:01Z", "EventType": 51, "Data": {"CarID": 1, "DriverName": "Test 1", "DriverGUID": "7827162738272615", "CarModel": "ford_gt", "EventType": 51}},
+	{"Received": "2020-05-01T20:00:02Z", "EventType": 51, "Data": {"CarID": 2, "DriverName": "Test 2", "DriverGUID": "7827162738272616", "CarModel": "ferrari_fxxk", "EventType": 51}},
+	{"Received": "2020-05-01T20:00:03Z", "EventType": 58, "Data": 1},
+	{"Received": "2020-05-01T20:00:04Z", "EventType": 58, "Data": 2},
+	{"Received": "2020-05-01T20:03:01Z", "EventType": 73, "Data": {"CarID": 2, "LapTime": 88000, "Cuts": 0}},
+	{"Received": "2020-05-01T20:01:30Z", "EventType": 73, "Data": {"CarID": 1, "LapTime": 89000, "Cuts": 0}},
+	{"Received": "2020-05-01T20:01:32Z", "EventType": 73, "Data": {"CarID": 2, "LapTime": 91000, "Cuts": 0}},
+	{"Received": "2020-05-01T20:03:05Z", "EventType": 73, "Data": {"CarID": 1, "LapTime": 95000, "Cuts": 0}},
+	{"Received": "2020-05-01T20:04:25Z", "EventType": 73, "Data": {"CarID": 2, "LapTime": 84000, "Cuts": 0}}
+]`
+
+func TestRaceControl_Replay(t *testing.T) {
+	entries, err := replay.ReadEntries(strings.NewReader(replayCapture))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 10 {
+		t.Fatalf("Expected 10 entries, got: %d", len(entries))
+	}
+
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Received.Before(entries[i-1].Received) {
+			t.Errorf("Expected entries to be sorted by the time they were received")
+		}
+	}
+
+	// each replay has its own store, so that it doesn't load the live timings persisted by a previous replay.
+	replaySession := func(t *testing.T, store Store, multiplier int, waitTime time.Duration) *RaceControl {
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+		entries.Replay(multiplier, raceControl.UDPCallback, waitTime)
+
+		return raceControl
+	}
+
+	assertStandings := func(t *testing.T, raceControl *RaceControl) {
+		t.Helper()
+
+		expected := []udp.DriverGUID{"7827162738272616", "7827162738272615"}
+
+		if !reflect.DeepEqual(raceControl.ConnectedDrivers.GUIDsInPositionalOrder, expected) {
+			t.Errorf("Expected final standings: %v, got: %v", expected, raceControl.ConnectedDrivers.GUIDsInPositionalOrder)
+		}
+
+		leader, ok := raceControl.ConnectedDrivers.Get("7827162738272616")
+
+		if !ok {
+			t.Fatal("Expected leader to be connected")
+		}
+
+		if leader.TotalNumLaps != 3 || leader.CurrentCar().BestLap != 84*time.Second {
+			t.Errorf("Expected leader to have completed 3 laps with a best of 1:24, got %d laps with a best of %s", leader.TotalNumLaps, leader.CurrentCar().BestLap)
+		}
+	}
+
+	t.Run("As fast as possible", func(t *testing.T) {
+		store, removeStore := newIsolatedTestStore(t, nil)
+		defer removeStore()
+
+		assertStandings(t, replaySession(t, store, 0, 0))
+	})
+
+	t.Run("Accelerated", func(t *testing.T) {
+		store, removeStore := newIsolatedTestStore(t, nil)
+		defer removeStore()
+
+		started := time.Now()
+
+		raceControl := replaySession(t, store, 1, time.Millisecond)
+
+		if elapsed := time.Since(started); elapsed >= time.Second {
+			t.Errorf("Expected the gaps between entries to be capped, replay took: %s", elapsed)
+		}
+
+		assertStandings(t, raceControl)
+	})
+}
+
+func TestRaceControl_CarUpdateMissThreshold(t *testing.T) {
+	setup := func(t *testing.T, threshold int, missedUpdates int) (*RaceControl, time.Duration) {
+		store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+			opts.CarUpdateMissThreshold = threshold
+		})
+		defer cleanup()
+
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+		if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the driver was last seen just after they would have sent their most recent car update.
+		driver.LastSeen = time.Now().Add(-time.Duration(missedUpdates)*100*time.Millisecond - 50*time.Millisecond)
+
+		return raceControl, raceControl.driverTimeoutFor(100)
+	}
+
+	for _, threshold := range []int{10, 50} {
+		t.Run(fmt.Sprintf("Threshold of %d updates", threshold), func(t *testing.T) {
+			raceControl, timeout := setup(t, threshold, threshold-1)
+
+			if timeout != time.Duration(threshold)*100*time.Millisecond {
+				t.Errorf("Expected timeout of %d updates, got: %s", threshold, timeout)
+			}
+
+			if timedOut := raceControl.timedOutDrivers(timeout); len(timedOut) != 0 {
+				t.Errorf("Expected no drivers to be timed out after missing %d updates, got: %d", threshold-1, len(timedOut))
+			}
+
+			raceControl, timeout = setup(t, threshold, threshold)
+
+			if timedOut := raceControl.timedOutDrivers(timeout); len(timedOut) != 1 {
+				t.Errorf("Expected driver to be timed out after missing %d updates, got: %d", threshold, len(timedOut))
+			}
+		})
+	}
+
+	t.Run("Default timeout", func(t *testing.T) {
+		raceControl, timeout := setup(t, 0, 50)
+
+		if timeout != driverTimeout {
+			t.Errorf("Expected default timeout of %s, got: %s", driverTimeout, timeout)
+		}
+
+		if timedOut := raceControl.timedOutDrivers(timeout); len(timedOut) != 0 {
+			t.Errorf("Expected no drivers to be timed out, got: %d", len(timedOut))
+		}
+	})
+}
+
+func TestRaceControl_Snapshot(t *testing.T) {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 30}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, driver := range drivers[:3] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, lap := range []udp.LapCompleted{
+		{CarID: drivers[0].CarID, LapTime: 90000},
+		{CarID: drivers[0].CarID, LapTime: 89000},
+		{CarID: drivers[1].CarID, LapTime: 85000},
+	} {
+		if err := raceControl.OnLapCompleted(lap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := raceControl.OnClientDisconnect(drivers[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	guids := func(drivers []*RaceControlDriver) []udp.DriverGUID {
+		out := make([]udp.DriverGUID, 0)
+
+		for _, driver := range drivers {
+			out = append(out, driver.CarInfo.DriverGUID)
+		}
+
+		return out
+	}
+
+	for _, testCase := range []struct {
+		name                string
+		filter              LiveTimingsFilter
+		connectedDrivers    []udp.DriverGUID
+		disconnectedDrivers []udp.DriverGUID
+	}{
+		{
+			name:                "No filter",
+			connectedDrivers:    []udp.DriverGUID{drivers[0].DriverGUID, drivers[2].DriverGUID},
+			disconnectedDrivers: []udp.DriverGUID{drivers[1].DriverGUID},
+		},
+		{
+			name:                "Car model",
+			filter:              LiveTimingsFilter{CarModel: "ferrari_fxxk"},
+			connectedDrivers:    []udp.DriverGUID{drivers[2].DriverGUID},
+			disconnectedDrivers: []udp.DriverGUID{drivers[1].DriverGUID},
+		},
+		{
+			name:                "Minimum laps",
+			filter:              LiveTimingsFilter{MinLaps: 2},
+			connectedDrivers:    []udp.DriverGUID{drivers[0].DriverGUID},
+			disconnectedDrivers: []udp.DriverGUID{},
+		},
+		{
+			name:                "Car model and minimum laps",
+			filter:              LiveTimingsFilter{CarModel: "ferrari_fxxk", MinLaps: 1},
+			connectedDrivers:    []udp.DriverGUID{},
+			disconnectedDrivers: []udp.DriverGUID{drivers[1].DriverGUID},
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			snapshot := raceControl.Snapshot(testCase.filter, SortByPosition)
+
+			if connected := guids(snapshot.ConnectedDrivers); !reflect.DeepEqual(connected, testCase.connectedDrivers) {
+				t.Errorf("Expected connected drivers: %v, got: %v", testCase.connectedDrivers, connected)
+			}
+
+			if disconnected := guids(snapshot.DisconnectedDrivers); !reflect.DeepEqual(disconnected, testCase.disconnectedDrivers) {
+				t.Errorf("Expected disconnected drivers: %v, got: %v", testCase.disconnectedDrivers, disconnected)
+			}
+		})
+	}
+}
+
+func TestRaceSplit(t *testing.T) {
+	for _, testCase := range []struct {
+		name     string
+		car      RaceControlCarLapInfo
+		carAhead RaceControlCarLapInfo
+		expected string
+	}{
+		{
+			name:     "Same lap",
+			car:      RaceControlCarLapInfo{NumLaps: 2, TotalLapTime: 182500 * time.Millisecond},
+			carAhead: RaceControlCarLapInfo{NumLaps: 2, TotalLapTime: 180 * time.Second},
+			expected: "2.5s",
+		},
+		{
+			name:     "Same lap, out of order",
+			car:      RaceControlCarLapInfo{NumLaps: 2, TotalLapTime: 178 * time.Second},
+			carAhead: RaceControlCarLapInfo{NumLaps: 2, TotalLapTime: 180 * time.Second},
+			expected: "0s",
+		},
+		{
+			name:     "More laps than the car ahead",
+			car:      RaceControlCarLapInfo{NumLaps: 3, TotalLapTime: 270 * time.Second},
+			carAhead: RaceControlCarLapInfo{NumLaps: 2, TotalLapTime: 300 * time.Second},
+			expected: "0s",
+		},
+		{
+			name:     "One lap down",
+			car:      RaceControlCarLapInfo{NumLaps: 1, TotalLapTime: 90 * time.Second},
+			carAhead: RaceControlCarLapInfo{NumLaps: 2, TotalLapTime: 170 * time.Second},
+			expected: "1 lap",
+		},
+		{
+			name:     "Several laps down",
+			car:      RaceControlCarLapInfo{NumLaps: 1, TotalLapTime: 90 * time.Second},
+			carAhead: RaceControlCarLapInfo{NumLaps: 4, TotalLapTime: 340 * time.Second},
+			expected: "3 laps",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			if split := raceSplit(&testCase.car, &testCase.carAhead); split != testCase.expected {
+				t.Errorf("Expected split: %s, got: %s", testCase.expected, split)
+			}
+		})
+	}
+}
+
+func TestRaceControl_SplitsAfterOrderChanges(t *testing.T) {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace, Laps: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, driver := range drivers[:3] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	completeLap := func(t *testing.T, carID udp.CarID, lapTime uint32) {
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: carID, LapTime: lapTime}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	split := func(t *testing.T, carID udp.CarID) string {
+		driver, err := raceControl.findConnectedDriverByCarID(carID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return driver.Split
+	}
+
+	completeLap(t, drivers[0].CarID, 90000)
+	completeLap(t, drivers[1].CarID, 92000)
+	completeLap(t, drivers[2].CarID, 95000)
+
+	// the middle driver completes their second lap first, and takes the lead
+	completeLap(t, drivers[1].CarID, 85000)
+
+	if s := split(t, drivers[1].CarID); s != "0s" {
+		t.Errorf("Expected the new leader's split to be 0s, got: %s", s)
+	}
+
+	completeLap(t, drivers[0].CarID, 91000)
+
+	if s := split(t, drivers[0].CarID); s != "4s" {
+		t.Errorf("Expected a split of 4s to the new leader, got: %s", s)
+	}
+
+	for _, driver := range drivers[:3] {
+		if s := split(t, driver.CarID); strings.HasPrefix(s, "-") {
+			t.Errorf("Expected no negative splits, driver %d has: %s", driver.CarID, s)
+		}
+	}
+}
+
+func TestRaceControl_AllLapTimesByTeam(t *testing.T) {
+	process := &recordingServerProcess{
+		event: &ActiveChampionship{
+			EntryList: EntryList{
+				"CAR_1": {Name: "Test 1", Team: "Team Ford"},
+				"CAR_2": {Name: "Test 2", Team: "Team Ferrari"},
+			},
+		},
+	}
+
+	// the test has its own store, so that drivers from live timings persisted by other tests aren't loaded.
+	store, removeStore := newIsolatedTestStore(t, nil)
+	defer removeStore()
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace, Laps: 50}); err != nil {
+		t.Fatal(err)
+	}
+
+	completeLap := func(t *testing.T, carID udp.CarID, lapTime uint32) {
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: carID, LapTime: lapTime}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the second driver in car 1 takes over after a driver swap
+	secondDriver := drivers[3]
+	secondDriver.CarID = drivers[0].CarID
+
+	for _, driver := range drivers[:2] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	completeLap(t, drivers[0].CarID, 90000)
+	completeLap(t, drivers[0].CarID, 88000)
+	completeLap(t, drivers[1].CarID, 91000)
+
+	if err := raceControl.OnClientDisconnect(drivers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := raceControl.OnClientConnect(secondDriver); err != nil {
+		t.Fatal(err)
+	}
+
+	completeLap(t, secondDriver.CarID, 87000)
+
+	teams := raceControl.AllLapTimesByTeam()
+
+	if len(teams) != 2 {
+		t.Fatalf("Expected 2 teams, got: %d", len(teams))
+	}
+
+	ford, ferrari := teams[0], teams[1]
+
+	if ford.CarID != drivers[0].CarID || ford.TeamName != "Team Ford" {
+		t.Errorf("Expected Team Ford in car %d to lead, got: %s in car %d", drivers[0].CarID, ford.TeamName, ford.CarID)
+	}
+
+	if len(ford.Drivers) != 2 || ford.Drivers[0].CarInfo.DriverGUID != secondDriver.DriverGUID || ford.Drivers[1].CarInfo.DriverGUID != drivers[0].DriverGUID {
+		t.Errorf("Expected Team Ford to have the current driver followed by the previous driver")
+	}
+
+	if ford.TotalNumLaps != 3 || ford.BestLap != 87*time.Second {
+		t.Errorf("Expected Team Ford to have 3 laps with a best of 1:27, got %d laps with a best of %s", ford.TotalNumLaps, ford.BestLap)
+	}
+
+	if ferrari.TeamName != "Team Ferrari" || len(ferrari.Drivers) != 1 || ferrari.TotalNumLaps != 1 || ferrari.BestLap != 91*time.Second {
+		t.Errorf("Expected Team Ferrari to have 1 driver with 1 lap of 1:31, got %s with %d drivers, %d laps and a best of %s", ferrari.TeamName, len(ferrari.Drivers), ferrari.TotalNumLaps, ferrari.BestLap)
+	}
+
+	t.Run("Drivers without a team are not grouped", func(t *testing.T) {
+		// car 3 has no team in the entry list, and is driven by an unrelated driver after the first one leaves.
+		unrelatedDriver := drivers[4]
+		unrelatedDriver.CarID = drivers[2].CarID
+
+		if err := raceControl.OnClientConnect(drivers[2]); err != nil {
+			t.Fatal(err)
+		}
+
+		completeLap(t, drivers[2].CarID, 92000)
+
+		if err := raceControl.OnClientDisconnect(drivers[2]); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnClientConnect(unrelatedDriver); err != nil {
+			t.Fatal(err)
+		}
+
+		completeLap(t, unrelatedDriver.CarID, 93000)
+
+		teams := raceControl.AllLapTimesByTeam()
+
+		if len(teams) != 4 {
+			t.Fatalf("Expected 4 teams, got: %d", len(teams))
+		}
+
+		for _, team := range teams {
+			if team.CarID == drivers[2].CarID && len(team.Drivers) != 1 {
+				t.Errorf("Expected drivers without a team in car %d to be shown separately, got %d drivers in one team", team.CarID, len(team.Drivers))
+			}
+		}
+	})
+}
+
+func TestRaceControl_WelcomeTemplate(t *testing.T) {
+	welcomeMessage := func(t *testing.T, welcomeTemplate string) string {
+		store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+			opts.WelcomeTemplate = welcomeTemplate
+		})
+		defer cleanup()
+
+		process := &recordingServerProcess{}
+		raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeQualifying, Time: 10}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnClientLoaded(udp.ClientLoaded(drivers[0].CarID)); err != nil {
+			t.Fatal(err)
+		}
+
+		messages := process.chatMessagesTo(drivers[0].CarID)
+
+		for _, message := range messages {
+			if len(message) > 60 {
+				t.Errorf("Expected welcome message to be wrapped at 60 characters, got: %q", message)
+			}
+		}
+
+		return strings.Join(messages, " ")
+	}
+
+	t.Run("Template", func(t *testing.T) {
+		message := welcomeMessage(t, "Welcome {{ .DriverName }}! You're driving the {{ .CarModel }} at {{ .TrackName }} in {{ .SessionType }}. Drive safely and have fun with everyone else on the server.")
+
+		if !strings.HasPrefix(message, "Welcome Test 1! You're driving the ford_gt at Laguna Seca in Qualifying. Drive safely") {
+			t.Errorf("Expected templated welcome message, got: %q", message)
+		}
+	})
+
+	for name, welcomeTemplate := range map[string]string{
+		"Empty template":   "",
+		"Invalid template": "Welcome {{ .DriverName",
+		"Unknown field":    "Welcome {{ .FavouriteColour }}",
+	} {
+		welcomeTemplate := welcomeTemplate
+
+		t.Run(name, func(t *testing.T) {
+			if message := welcomeMessage(t, welcomeTemplate); !strings.HasPrefix(message, "Hi, Test 1! Welcome to the") {
+				t.Errorf("Expected default welcome message, got: %q", message)
+			}
+		})
+	}
+}
+
+func TestRaceControl_PersistCollisions(t *testing.T) {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace, Laps: 20}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, driver := range drivers[:2] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := raceControl.OnCollisionWithCar(udp.CollisionWithCar{CarID: drivers[0].CarID, OtherCarID: drivers[1].CarID, ImpactSpeed: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := raceControl.OnCollisionWithEnvironment(udp.CollisionWithEnvironment{CarID: drivers[1].CarID, ImpactSpeed: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	var collisions []Collision
+
+	// collisions are persisted in the background, so wait for them to be written.
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		var err error
+
+		collisions, err = testStore.LoadCollisions(raceControl.SessionID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(collisions) == 2 {
+			break
+		}
+	}
+
+	if len(collisions) != 2 {
+		t.Fatalf("Expected 2 persisted collisions, got: %d", len(collisions))
+	}
+
+	types := map[CollisionType]bool{collisions[0].Type: true, collisions[1].Type: true}
+
+	if !types[CollisionWithCar] || !types[CollisionWithEnvironment] {
+		t.Errorf("Expected a collision with a car and a collision with the environment, got: %v", types)
+	}
+
+	t.Run("Upserting a collision replaces it", func(t *testing.T) {
+		updated := collisions[0]
+		updated.Speed = 123
+
+		if err := testStore.UpsertCollision(raceControl.SessionID, updated); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := testStore.LoadCollisions(raceControl.SessionID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(loaded) != 2 {
+			t.Fatalf("Expected 2 persisted collisions, got: %d", len(loaded))
+		}
+
+		if loaded[0].ID != updated.ID || loaded[0].Speed != 123 {
+			t.Errorf("Expected the upserted collision to replace the original, got: %v", loaded[0])
+		}
+	})
+
+	t.Run("Collisions are stored per session", func(t *testing.T) {
+		loaded, err := testStore.LoadCollisions("not-a-session")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(loaded) != 0 {
+			t.Errorf("Expected no collisions for an unknown session, got: %d", len(loaded))
+		}
+	})
+}
+
+func TestRaceControl_MinCollisionSpeed(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.MinCollisionSpeed = 10
+	})
+	defer cleanup()
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace, Laps: 20}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, driver := range drivers[:2] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	numCollisions := func(t *testing.T, carID udp.CarID) int {
+		driver, err := raceControl.findConnectedDriverByCarID(carID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return len(driver.Collisions)
+	}
+
+	t.Run("5 km/h tap is dropped", func(t *testing.T) {
+		if err := raceControl.OnCollisionWithCar(udp.CollisionWithCar{CarID: drivers[0].CarID, OtherCarID: drivers[1].CarID, ImpactSpeed: 5 / 3.6}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnCollisionWithEnvironment(udp.CollisionWithEnvironment{CarID: drivers[1].CarID, ImpactSpeed: 5 / 3.6}); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := numCollisions(t, drivers[0].CarID); n != 0 {
+			t.Errorf("Expected a collision with a car below the minimum speed to be dropped, got: %d collisions", n)
+		}
+
+		if n := numCollisions(t, drivers[1].CarID); n != 0 {
+			t.Errorf("Expected a collision with the environment below the minimum speed to be dropped, got: %d collisions", n)
+		}
+	})
+
+	t.Run("60 km/h hit is recorded", func(t *testing.T) {
+		if err := raceControl.OnCollisionWithCar(udp.CollisionWithCar{CarID: drivers[0].CarID, OtherCarID: drivers[1].CarID, ImpactSpeed: 60 / 3.6}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnCollisionWithEnvironment(udp.CollisionWithEnvironment{CarID: drivers[1].CarID, ImpactSpeed: 60 / 3.6}); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := numCollisions(t, drivers[0].CarID); n != 1 {
+			t.Errorf("Expected a collision with a car above the minimum speed to be recorded, got: %d collisions", n)
+		}
+
+		if n := numCollisions(t, drivers[1].CarID); n != 1 {
+			t.Errorf("Expected a collision with the environment above the minimum speed to be recorded, got: %d collisions", n)
+		}
+	})
+}
+
+func TestRaceControl_ChatCommands(t *testing.T) {
+	// the test has its own store, so that drivers' laps from live timings persisted by other tests aren't loaded.
+	store, removeStore := newIsolatedTestStore(t, nil)
+	defer removeStore()
+
+	process := &recordingServerProcess{}
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace, Laps: 20}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entrant := range drivers[:2] {
+		if err := raceControl.OnClientConnect(entrant); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, entrant := range drivers[:2] {
+		driver, ok := raceControl.ConnectedDrivers.Get(entrant.DriverGUID)
+
+		if !ok {
+			t.Fatalf("Driver %s not connected", entrant.DriverGUID)
+		}
+
+		driver.Position = i + 1
+		driver.Split = []string{"0s", "1.234s"}[i]
+	}
+
+	t.Run("Best lap", func(t *testing.T) {
+		raceControl.UDPCallback(udp.Chat{CarID: drivers[0].CarID, Message: "/best"})
+
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 83456}); err != nil {
+			t.Fatal(err)
+		}
+
+		raceControl.UDPCallback(udp.Chat{CarID: drivers[0].CarID, Message: " /BEST "})
+
+		expected := []string{"You have not set a lap time yet.", "Your best lap is " + formatDuration(83456*time.Millisecond, true) + "."}
+
+		if messages := process.chatMessagesTo(drivers[0].CarID); !reflect.DeepEqual(messages, expected) {
+			t.Errorf("Expected messages %q, got %q", expected, messages)
+		}
+	})
+
+	t.Run("Position", func(t *testing.T) {
+		raceControl.UDPCallback(udp.Chat{CarID: drivers[1].CarID, Message: "/pos"})
+
+		expected := []string{"You are P2 of 2. Split to the car ahead: 1.234s."}
+
+		if messages := process.chatMessagesTo(drivers[1].CarID); !reflect.DeepEqual(messages, expected) {
+			t.Errorf("Expected messages %q, got %q", expected, messages)
+		}
+	})
+
+	t.Run("Unknown commands are ignored", func(t *testing.T) {
+		raceControl.UDPCallback(udp.Chat{CarID: drivers[1].CarID, Message: "/nonsense"})
+
+		if messages := process.chatMessagesTo(drivers[1].CarID); len(messages) != 1 {
+			t.Errorf("Expected no reply to an unknown command, got: %q", messages)
+		}
+
+		raceControl.ChatMessagesMutex.Lock()
+		defer raceControl.ChatMessagesMutex.Unlock()
+
+		if len(raceControl.ChatMessages) != 0 {
+			t.Errorf("Expected unknown commands not to be added to the chat log, got: %d messages", len(raceControl.ChatMessages))
+		}
+	})
+}
+
+func TestParsePitLaneAreas(t *testing.T) {
+	areas := parsePitLaneAreas("ks_laguna_seca,,80,10,-120,-40\n\n ks_vallelunga , extended_circuit , 0, 0, 10, 10 \nks_monza,,not,a,pit,lane\nks_silverstone,gp,1,2,3")
+
+	expected := map[string]PitLaneArea{
+		pitLaneAreaKey("ks_laguna_seca", ""):                {MinX: -120, MinZ: -40, MaxX: 80, MaxZ: 10},
+		pitLaneAreaKey("ks_vallelunga", "extended_circuit"): {MinX: 0, MinZ: 0, MaxX: 10, MaxZ: 10},
+	}
+
+	if !reflect.DeepEqual(areas, expected) {
+		t.Errorf("Expected pit lane areas %v, got %v", expected, areas)
+	}
+
+	area := expected[pitLaneAreaKey("ks_laguna_seca", "")]
+
+	if !area.Contains(udp.Vec{X: 0, Y: 100, Z: 0}) {
+		t.Error("Expected a position within the pit lane area to be in the pits")
+	}
+
+	if area.Contains(udp.Vec{X: 0, Z: 20}) {
+		t.Error("Expected a position outside of the pit lane area not to be in the pits")
+	}
+}
+
+func TestRaceControl_Stationary(t *testing.T) {
+	// setup returns a race control with a connected driver, its broadcaster, and a func which removes its store.
+	setup := func(t *testing.T, stationaryCarTime int) (*RaceControl, *countingBroadcaster, func()) {
+		store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+			opts.StationaryCarTime = stationaryCarTime
+		})
+
+		broadcaster := &countingBroadcaster{}
+		raceControl := NewRaceControl(broadcaster, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+		if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		return raceControl, broadcaster, cleanup
+	}
+
+	stoppedAt := udp.Vec{X: 120, Y: 10, Z: -45}
+
+	// sendUpdates feeds repeated identical positions, then makes the car appear to have been stopped for the given
+	// length of time before sending one more.
+	sendUpdates := func(t *testing.T, raceControl *RaceControl, stoppedFor time.Duration) *RaceControlDriver {
+		for i := 0; i < 3; i++ {
+			if err := raceControl.handleCarUpdate(udp.CarUpdate{CarID: drivers[0].CarID, Pos: stoppedAt}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		driver.mutex.Lock()
+		driver.stationarySince = driver.stationarySince.Add(-stoppedFor)
+		driver.mutex.Unlock()
+
+		if err := raceControl.handleCarUpdate(udp.CarUpdate{CarID: drivers[0].CarID, Pos: stoppedAt}); err != nil {
+			t.Fatal(err)
+		}
+
+		return driver
+	}
+
+	t.Run("Flagged after the stationary car time", func(t *testing.T) {
+		raceControl, broadcaster, cleanup := setup(t, 10)
+		defer cleanup()
+
+		if driver := sendUpdates(t, raceControl, 5*time.Second).Copy(); driver.Stationary {
+			t.Error("Expected the driver not to be stationary before the stationary car time")
+		}
+
+		if driver := sendUpdates(t, raceControl, 5*time.Second).Copy(); !driver.Stationary {
+			t.Error("Expected the driver to be stationary")
+		}
+
+		if count := broadcaster.count(EventStationary); count != 1 {
+			t.Errorf("Expected the stationary car to be broadcast once, got %d", count)
+		}
+
+		t.Run("Cleared when the car moves", func(t *testing.T) {
+			if err := raceControl.handleCarUpdate(udp.CarUpdate{CarID: drivers[0].CarID, Pos: udp.Vec{X: 121, Y: 10, Z: -45}, Velocity: udp.Vec{X: 2}}); err != nil {
+				t.Fatal(err)
+			}
+
+			driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if driver.Copy().Stationary {
+				t.Error("Expected the driver to no longer be stationary")
+			}
+
+			if count := broadcaster.count(EventStationary); count != 2 {
+				t.Errorf("Expected the moving car to be broadcast, got %d stationary events", count)
+			}
+		})
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		raceControl, broadcaster, cleanup := setup(t, 0)
+		defer cleanup()
+
+		if driver := sendUpdates(t, raceControl, time.Hour).Copy(); driver.Stationary {
+			t.Error("Expected stationary car detection to be disabled")
+		}
+
+		if count := broadcaster.count(EventStationary); count != 0 {
+			t.Errorf("Expected no stationary car events, got %d", count)
+		}
+	})
+}
+
+func TestRaceControl_PitStops(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.PitLaneAreas = "ks_laguna_seca,,-100,-10,100,10"
+	})
+	defer cleanup()
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+	newSession := func(t *testing.T) {
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 30}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newSession(t)
+
+	if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	inPitLane, onTrack := udp.Vec{X: 0, Z: 0}, udp.Vec{X: 200, Z: 50}
+
+	pitStops := func(t *testing.T) int {
+		driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return driver.PitStops
+	}
+
+	for _, testCase := range []struct {
+		Name      string
+		Positions []udp.Vec
+		NumLaps   int
+		PitStops  int
+	}{
+		{Name: "Leaving the pits at the start of the session", Positions: []udp.Vec{inPitLane, onTrack}, PitStops: 0},
+		{Name: "Pit stop after a lap", NumLaps: 1, Positions: []udp.Vec{inPitLane, onTrack}, PitStops: 1},
+		{Name: "Re-entering the pits without completing a lap", Positions: []udp.Vec{inPitLane, onTrack}, PitStops: 1},
+		{Name: "Pit stop after more laps", NumLaps: 2, Positions: []udp.Vec{inPitLane, onTrack}, PitStops: 2},
+		{Name: "Still in the pit lane", NumLaps: 1, Positions: []udp.Vec{inPitLane}, PitStops: 2},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			for i := 0; i < testCase.NumLaps; i++ {
+				if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 90000}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, pos := range testCase.Positions {
+				if err := raceControl.handleCarUpdate(udp.CarUpdate{CarID: drivers[0].CarID, Pos: pos}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if numPitStops := pitStops(t); numPitStops != testCase.PitStops {
+				t.Errorf("Expected %d pit stops, got: %d", testCase.PitStops, numPitStops)
+			}
+		})
+	}
+
+	t.Run("New session resets pit stops", func(t *testing.T) {
+		newSession(t)
+
+		if numPitStops := pitStops(t); numPitStops != 0 {
+			t.Errorf("Expected pit stops to be reset, got: %d", numPitStops)
+		}
+	})
+}
+
+func TestRaceControl_InPits(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.PitLaneAreas = "ks_laguna_seca,,-100,-10,100,10"
+	})
+	defer cleanup()
+
+	broadcaster := &countingBroadcaster{}
+	raceControl := NewRaceControl(broadcaster, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 30}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testCase := range []struct {
+		Name           string
+		Pos            udp.Vec
+		InPits         bool
+		NumBroadcasted int
+	}{
+		{Name: "Enters the pit lane", Pos: udp.Vec{X: 0, Z: 0}, InPits: true, NumBroadcasted: 1},
+		{Name: "Moves within the pit lane", Pos: udp.Vec{X: 50, Z: 5}, InPits: true, NumBroadcasted: 1},
+		{Name: "Leaves the pit lane", Pos: udp.Vec{X: 150, Z: 5}, InPits: false, NumBroadcasted: 2},
+		{Name: "Stays on track", Pos: udp.Vec{X: 200, Z: 50}, InPits: false, NumBroadcasted: 2},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			if err := raceControl.handleCarUpdate(udp.CarUpdate{CarID: drivers[0].CarID, Pos: testCase.Pos}); err != nil {
+				t.Fatal(err)
+			}
+
+			if driver.InPits != testCase.InPits {
+				t.Errorf("Expected in pits: %t, got: %t", testCase.InPits, driver.InPits)
+			}
+
+			if count := broadcaster.count(EventPitLane); count != testCase.NumBroadcasted {
+				t.Errorf("Expected %d pit lane events, got: %d", testCase.NumBroadcasted, count)
+			}
+		})
+	}
+
+	t.Run("No pit lane area for the track", func(t *testing.T) {
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_monza", Type: udp.SessionTypePractice, Time: 30}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.handleCarUpdate(udp.CarUpdate{CarID: drivers[0].CarID, Pos: udp.Vec{X: 0, Z: 0}}); err != nil {
+			t.Fatal(err)
+		}
+
+		driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if driver.InPits {
+			t.Error("Expected a driver not to be in the pits when there is no pit lane area for the track")
+		}
+	})
+}
+
+func TestRaceControl_SortModes(t *testing.T) {
+	rc := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+	rc.SessionInfo.Type = udp.SessionTypeRace
+
+	for i, entrant := range drivers[:4] {
+		driver := NewRaceControlDriver(entrant)
+		driver.CarInfo.DriverName = []string{"charlie", "Alice", "Dave", "bob"}[i]
+
+		car := driver.CurrentCar()
+		car.NumLaps = []int{10, 10, 9, 0}[i]
+		car.TotalLapTime = []time.Duration{900, 880, 850, 0}[i]
+		car.BestLap = []time.Duration{88, 86, 87, 0}[i]
+		car.LastLap = []time.Duration{89, 92, 90, 0}[i]
+		car.TopSpeedBestLap = []float64{250, 240, 260, 0}[i]
+
+		rc.ConnectedDrivers.Add(driver.CarInfo.DriverGUID, driver)
+	}
+
+	livePositions := map[udp.DriverGUID]int{
+		drivers[1].DriverGUID: 1,
+		drivers[0].DriverGUID: 2,
+		drivers[2].DriverGUID: 3,
+		drivers[3].DriverGUID: 4,
+	}
+
+	for _, testCase := range []struct {
+		Mode     SortMode
+		Expected []int
+	}{
+		{Mode: SortByPosition, Expected: []int{1, 0, 2, 3}},
+		{Mode: SortByBestLap, Expected: []int{1, 2, 0, 3}},
+		{Mode: SortByLastLap, Expected: []int{0, 2, 1, 3}},
+		{Mode: SortByTopSpeed, Expected: []int{2, 0, 1, 3}},
+		{Mode: SortByName, Expected: []int{1, 3, 0, 2}},
+	} {
+		t.Run(string(testCase.Mode), func(t *testing.T) {
+			var expected []udp.DriverGUID
+
+			for _, i := range testCase.Expected {
+				expected = append(expected, drivers[i].DriverGUID)
+			}
+
+			snapshot := rc.Snapshot(LiveTimingsFilter{}, testCase.Mode)
+
+			var order []udp.DriverGUID
+
+			for _, driver := range snapshot.ConnectedDrivers {
+				order = append(order, driver.CarInfo.DriverGUID)
+			}
+
+			if !reflect.DeepEqual(order, expected) {
+				t.Errorf("Expected order %v, got %v", expected, order)
+			}
+
+			// sorting a snapshot must not change the order or positions in Live Timings.
+			if !reflect.DeepEqual(rc.ConnectedDrivers.GUIDsInPositionalOrder, []udp.DriverGUID{drivers[1].DriverGUID, drivers[0].DriverGUID, drivers[2].DriverGUID, drivers[3].DriverGUID}) {
+				t.Errorf("Expected Live Timings to remain in positional order, got %v", rc.ConnectedDrivers.GUIDsInPositionalOrder)
+			}
+
+			for _, driver := range snapshot.ConnectedDrivers {
+				if position := livePositions[driver.CarInfo.DriverGUID]; driver.Position != position {
+					t.Errorf("Expected driver %s to keep their position of %d, got: %d", driver.CarInfo.DriverGUID, position, driver.Position)
+				}
+			}
+		})
+	}
+
+	t.Run("Parse sort mode", func(t *testing.T) {
+		if mode, err := parseSortMode(""); err != nil || mode != SortByPosition {
+			t.Errorf("Expected an empty sort mode to sort by position, got: %s (%v)", mode, err)
+		}
+
+		if mode, err := parseSortMode("top-speed"); err != nil || mode != SortByTopSpeed {
+			t.Errorf("Expected top-speed to sort by top speed, got: %s (%v)", mode, err)
+		}
+
+		if _, err := parseSortMode("fastest"); err == nil {
+			t.Error("Expected an unknown sort mode to be an error")
+		}
+	})
+}
+
+func TestRaceControl_Cuts(t *testing.T) {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 30}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cuts := range []uint8{0, 2, 0, 1} {
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 90000, Cuts: cuts}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if totalCuts := driver.CurrentCar().TotalCuts; totalCuts != 3 {
+		t.Errorf("Expected 3 total cuts, got: %d", totalCuts)
+	}
+
+	if driver.CleanLapPercentage != 50 {
+		t.Errorf("Expected a clean lap percentage of 50, got: %.2f", driver.CleanLapPercentage)
+	}
+
+	t.Run("Reset in a new session", func(t *testing.T) {
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeQualifying, Time: 30}); err != nil {
+			t.Fatal(err)
+		}
+
+		driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if totalCuts := driver.CurrentCar().TotalCuts; totalCuts != 0 {
+			t.Errorf("Expected total cuts to be reset, got: %d", totalCuts)
+		}
+
+		if driver.CleanLapPercentage != 0 {
+			t.Errorf("Expected clean lap percentage to be reset, got: %.2f", driver.CleanLapPercentage)
+		}
+
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 90000}); err != nil {
+			t.Fatal(err)
+		}
+
+		if driver.CleanLapPercentage != 100 {
+			t.Errorf("Expected a clean lap percentage of 100 after a clean lap, got: %.2f", driver.CleanLapPercentage)
+		}
+	})
+}
+
+func TestRaceControl_CutPenalty(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.MaxCutsBeforePenalty = 3
+		opts.CutPenalty = 10
+	})
+	defer cleanup()
+
+	process := &recordingServerProcess{}
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace, Laps: 20}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	penalty := func() time.Duration {
+		raceControl.sessionPenaltiesMutex.Lock()
+		defer raceControl.sessionPenaltiesMutex.Unlock()
+
+		if penalty, ok := raceControl.sessionPenalties[drivers[0].DriverGUID]; ok {
+			return penalty.penalty
+		}
+
+		return 0
+	}
+
+	for _, testCase := range []struct {
+		Name        string
+		Cuts        uint8
+		NumMessages int
+		Penalty     time.Duration
+	}{
+		{Name: "First cut", Cuts: 1, NumMessages: 0, Penalty: 0},
+		{Name: "One cut before the threshold is warned", Cuts: 1, NumMessages: 1, Penalty: 0},
+		{Name: "Clean lap", Cuts: 0, NumMessages: 1, Penalty: 0},
+		{Name: "Reaching the threshold is penalised", Cuts: 1, NumMessages: 2, Penalty: 10 * time.Second},
+		{Name: "Cut after the threshold is not penalised again", Cuts: 1, NumMessages: 2, Penalty: 10 * time.Second},
+		{Name: "Next threshold is penalised", Cuts: 3, NumMessages: 3, Penalty: 20 * time.Second},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 90000, Cuts: testCase.Cuts}); err != nil {
+				t.Fatal(err)
+			}
+
+			if messages := process.chatMessagesTo(drivers[0].CarID); len(messages) != testCase.NumMessages {
+				t.Errorf("Expected %d chat messages, got: %q", testCase.NumMessages, messages)
+			}
+
+			if p := penalty(); p != testCase.Penalty {
+				t.Errorf("Expected a total penalty of %s, got: %s", testCase.Penalty, p)
+			}
+		})
+	}
+}
+
+func TestRaceControl_InvalidLaps(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.MaxConsecutiveInvalidLaps = 2
+	})
+	defer cleanup()
+
+	broadcaster := &countingBroadcaster{}
+	process := &recordingServerProcess{}
+	raceControl := NewRaceControl(broadcaster, nilTrackData{}, process, store, NewPenaltiesManager(store))
+
+	newSession := func(t *testing.T) {
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 30}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	consecutiveInvalidLaps := func(t *testing.T) int {
+		driver, ok := raceControl.ConnectedDrivers.Get(drivers[0].DriverGUID)
+
+		if !ok {
+			t.Fatal("Expected driver to be connected")
+		}
+
+		return driver.ConsecutiveInvalidLaps
+	}
+
+	newSession(t)
+
+	if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, testCase := range []struct {
+		Name                   string
+		Cuts                   uint8
+		ConsecutiveInvalidLaps int
+		NumEvents              int
+		NumMessages            int
+	}{
+		{Name: "First invalid lap", Cuts: 1, ConsecutiveInvalidLaps: 1, NumEvents: 1, NumMessages: 0},
+		{Name: "Reaching the maximum is warned", Cuts: 2, ConsecutiveInvalidLaps: 2, NumEvents: 2, NumMessages: 1},
+		{Name: "Invalid lap after the maximum is not warned again", Cuts: 1, ConsecutiveInvalidLaps: 3, NumEvents: 3, NumMessages: 1},
+		{Name: "Clean lap resets the count", Cuts: 0, ConsecutiveInvalidLaps: 0, NumEvents: 3, NumMessages: 1},
+		{Name: "Invalid lap after a clean lap", Cuts: 1, ConsecutiveInvalidLaps: 1, NumEvents: 4, NumMessages: 1},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 90000, Cuts: testCase.Cuts}); err != nil {
+				t.Fatal(err)
+			}
+
+			if num := consecutiveInvalidLaps(t); num != testCase.ConsecutiveInvalidLaps {
+				t.Errorf("Expected %d consecutive invalid laps, got: %d", testCase.ConsecutiveInvalidLaps, num)
+			}
+
+			if count := broadcaster.count(EventInvalidLap); count != testCase.NumEvents {
+				t.Errorf("Expected %d invalid lap events, got: %d", testCase.NumEvents, count)
+			}
+
+			if messages := process.chatMessagesTo(drivers[0].CarID); len(messages) != testCase.NumMessages {
+				t.Errorf("Expected %d chat messages, got: %q", testCase.NumMessages, messages)
+			}
+		})
+	}
+
+	t.Run("New session resets the count", func(t *testing.T) {
+		newSession(t)
+
+		if num := consecutiveInvalidLaps(t); num != 0 {
+			t.Errorf("Expected consecutive invalid laps to be reset, got: %d", num)
+		}
+	})
+}
+
+func TestRaceControl_DisconnectGracePeriod(t *testing.T) {
+	// the test has its own store, so that drivers' laps from live timings persisted by other tests aren't loaded.
+	store, removeStore := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.DisconnectGracePeriod = 1
+	})
+	defer removeStore()
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 30}); err != nil {
+		t.Fatal(err)
+	}
+
+	connectWithLap := func(t *testing.T, entrant udp.SessionCarInfo) {
+		if err := raceControl.OnClientConnect(entrant); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: entrant.CarID, LapTime: 90000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	isStale := func(t *testing.T, driverGUID udp.DriverGUID) bool {
+		driver, ok := raceControl.ConnectedDrivers.Get(driverGUID)
+
+		if !ok {
+			t.Fatalf("Expected driver %s to be in the connected drivers", driverGUID)
+		}
+
+		return driver.Copy().Stale
+	}
+
+	connectWithLap(t, drivers[0])
+
+	t.Run("Reconnecting in the grace period", func(t *testing.T) {
+		if err := raceControl.OnClientDisconnect(drivers[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		if !isStale(t, drivers[0].DriverGUID) {
+			t.Error("Expected the driver to be stale during their grace period")
+		}
+
+		if _, ok := raceControl.DisconnectedDrivers.Get(drivers[0].DriverGUID); ok {
+			t.Error("Expected the driver not to be disconnected during their grace period")
+		}
+
+		if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		if isStale(t, drivers[0].DriverGUID) {
+			t.Error("Expected the driver not to be stale after reconnecting")
+		}
+
+		driver, _ := raceControl.ConnectedDrivers.Get(drivers[0].DriverGUID)
+
+		if numLaps := driver.Copy().TotalNumLaps; numLaps != 1 {
+			t.Errorf("Expected the driver to keep their laps, got: %d", numLaps)
+		}
+	})
+
+	t.Run("Not reconnecting in the grace period", func(t *testing.T) {
+		if err := raceControl.OnClientDisconnect(drivers[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+			if _, ok := raceControl.DisconnectedDrivers.Get(drivers[0].DriverGUID); ok {
+				break
+			}
+		}
+
+		if _, ok := raceControl.DisconnectedDrivers.Get(drivers[0].DriverGUID); !ok {
+			t.Fatal("Expected the driver to be disconnected after their grace period")
+		}
+
+		if _, ok := raceControl.ConnectedDrivers.Get(drivers[0].DriverGUID); ok {
+			t.Error("Expected the driver to be removed from the connected drivers after their grace period")
+		}
+	})
+
+	t.Run("Replaced by another driver in the same car", func(t *testing.T) {
+		connectWithLap(t, drivers[1])
+
+		if err := raceControl.OnClientDisconnect(drivers[1]); err != nil {
+			t.Fatal(err)
+		}
+
+		replacement := drivers[4]
+		replacement.CarID = drivers[1].CarID
+
+		if err := raceControl.OnClientConnect(replacement); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := raceControl.DisconnectedDrivers.Get(drivers[1].DriverGUID); !ok {
+			t.Error("Expected the replaced driver to be disconnected straight away")
+		}
+	})
+}
+
+func TestRaceControl_ForceDisconnect(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, nil)
+	defer cleanup()
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 30}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entrant := range drivers[:2] {
+		if err := raceControl.OnClientConnect(entrant); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 90000}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Connected driver", func(t *testing.T) {
+		if err := raceControl.ForceDisconnect(drivers[0].DriverGUID); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := raceControl.ConnectedDrivers.Get(drivers[0].DriverGUID); ok {
+			t.Error("Expected the driver to be removed from the connected drivers")
+		}
+
+		if _, ok := raceControl.DisconnectedDrivers.Get(drivers[0].DriverGUID); !ok {
+			t.Error("Expected the driver to be added to the disconnected drivers")
+		}
+	})
+
+	t.Run("Driver who is not connected", func(t *testing.T) {
+		if err := raceControl.ForceDisconnect(drivers[0].DriverGUID); err == nil {
+			t.Error("Expected an error when force disconnecting a driver who is not connected")
+		}
+	})
+
+	t.Run("Driver in their disconnect grace period", func(t *testing.T) {
+		updateServerOptions(t, store, func(opts *GlobalServerConfig) {
+			opts.DisconnectGracePeriod = 60
+		})
+
+		raceControl.refreshServerOptions()
+
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[1].CarID, LapTime: 90000}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnClientDisconnect(drivers[1]); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.ForceDisconnect(drivers[1].DriverGUID); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := raceControl.DisconnectedDrivers.Get(drivers[1].DriverGUID); !ok {
+			t.Error("Expected the driver to be disconnected without waiting for their grace period")
+		}
+	})
+}
+
+func TestRaceControl_ConcurrentDisconnects(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, nil)
+	defer cleanup()
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+	// position history is recorded in races, so each sort updates every driver.
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace, Laps: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entrant := range drivers {
+		if err := raceControl.OnClientConnect(entrant); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: entrant.CarID, LapTime: 90000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	for _, entrant := range drivers {
+		wg.Add(1)
+
+		go func(driverGUID udp.DriverGUID) {
+			defer wg.Done()
+
+			if err := raceControl.ForceDisconnect(driverGUID); err != nil {
+				t.Error(err)
+			}
+		}(entrant.DriverGUID)
+	}
+
+	disconnected := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(disconnected)
+	}()
+
+	select {
+	case <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected drivers disconnecting at the same time not to deadlock")
+	}
+
+	if raceControl.ConnectedDrivers.Len() != 0 || raceControl.DisconnectedDrivers.Len() != len(drivers) {
+		t.Errorf("Expected all drivers to be disconnected, got %d connected and %d disconnected", raceControl.ConnectedDrivers.Len(), raceControl.DisconnectedDrivers.Len())
+	}
+}
+
+func TestRaceControl_RaceProgress(t *testing.T) {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	leader, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	leader.Position = 1
+	car := leader.CurrentCar()
+	car.NumLaps = 5
+	car.TotalLapTime = 500 * time.Second
+	car.LastLap = 100 * time.Second
+
+	for _, testCase := range []struct {
+		Name                string
+		SessionInfo         udp.SessionInfo
+		RaceConfig          CurrentRaceConfig
+		LastCrossing        time.Duration
+		ExpectedLaps        int
+		ExpectedFinish      time.Duration
+		ExpectedOverTimeEnd time.Duration
+	}{
+		{
+			Name:           "Lap limited",
+			SessionInfo:    udp.SessionInfo{Type: udp.SessionTypeRace, Laps: 20},
+			LastCrossing:   -30 * time.Second,
+			ExpectedLaps:   15,
+			ExpectedFinish: 1470 * time.Second,
+		},
+		{
+			Name:                "Lap limited with over time",
+			SessionInfo:         udp.SessionInfo{Type: udp.SessionTypeRace, Laps: 20},
+			RaceConfig:          CurrentRaceConfig{RaceOverTime: 180},
+			LastCrossing:        -30 * time.Second,
+			ExpectedLaps:        15,
+			ExpectedFinish:      1470 * time.Second,
+			ExpectedOverTimeEnd: 1650 * time.Second,
+		},
+		{
+			Name:           "Time limited",
+			SessionInfo:    udp.SessionInfo{Type: udp.SessionTypeRace, Time: 30, ElapsedMilliseconds: 20 * 60 * 1000},
+			LastCrossing:   -30 * time.Second,
+			ExpectedLaps:   7,
+			ExpectedFinish: 670 * time.Second,
+		},
+		{
+			Name:           "Time limited with an extra lap",
+			SessionInfo:    udp.SessionInfo{Type: udp.SessionTypeRace, Time: 30, ElapsedMilliseconds: 20 * 60 * 1000},
+			RaceConfig:     CurrentRaceConfig{RaceExtraLap: 1},
+			LastCrossing:   -30 * time.Second,
+			ExpectedLaps:   8,
+			ExpectedFinish: 770 * time.Second,
+		},
+		{
+			Name:           "Time limited, leader has finished",
+			SessionInfo:    udp.SessionInfo{Type: udp.SessionTypeRace, Time: 30, ElapsedMilliseconds: 31 * 60 * 1000},
+			LastCrossing:   -10 * time.Second,
+			ExpectedLaps:   0,
+			ExpectedFinish: -10 * time.Second,
+		},
+		{
+			Name:           "Time limited, leader is on the extra lap",
+			SessionInfo:    udp.SessionInfo{Type: udp.SessionTypeRace, Time: 30, ElapsedMilliseconds: 31 * 60 * 1000},
+			RaceConfig:     CurrentRaceConfig{RaceExtraLap: 1},
+			LastCrossing:   -10 * time.Second,
+			ExpectedLaps:   1,
+			ExpectedFinish: 90 * time.Second,
+		},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			raceControl.SessionInfo = testCase.SessionInfo
+			raceControl.sessionInfoUpdatedAt = now
+			car.LastLapCompletedTime = now.Add(testCase.LastCrossing)
+
+			progress := raceControl.raceProgress(testCase.RaceConfig)
+
+			if progress == nil {
+				t.Fatal("Expected race progress, got nil")
+			}
+
+			if progress.LeaderAverageLap != 100*time.Second {
+				t.Errorf("Expected a leader average lap of 100s, got: %s", progress.LeaderAverageLap)
+			}
+
+			if progress.EstimatedLapsRemaining != testCase.ExpectedLaps {
+				t.Errorf("Expected %d laps remaining, got: %d", testCase.ExpectedLaps, progress.EstimatedLapsRemaining)
+			}
+
+			if finish := progress.EstimatedFinishTime.Sub(now); finish != testCase.ExpectedFinish {
+				t.Errorf("Expected to finish in %s, got: %s", testCase.ExpectedFinish, finish)
+			}
+
+			if testCase.ExpectedOverTimeEnd == 0 && !progress.OverTimeEnds.IsZero() {
+				t.Errorf("Expected no over time, got: %s", progress.OverTimeEnds)
+			} else if testCase.ExpectedOverTimeEnd != 0 && progress.OverTimeEnds.Sub(now) != testCase.ExpectedOverTimeEnd {
+				t.Errorf("Expected over time to end in %s, got: %s", testCase.ExpectedOverTimeEnd, progress.OverTimeEnds.Sub(now))
+			}
+		})
+	}
+
+	t.Run("Time limited, clock has run out since the last session info", func(t *testing.T) {
+		// the clock ran out a minute ago, and the leader crossed the line 10 seconds ago.
+		raceControl.SessionInfo = udp.SessionInfo{Type: udp.SessionTypeRace, Time: 30, ElapsedMilliseconds: 25 * 60 * 1000}
+		raceControl.sessionInfoUpdatedAt = now.Add(-6 * time.Minute)
+		car.LastLapCompletedTime = now.Add(-10 * time.Second)
+
+		progress := raceControl.raceProgress(CurrentRaceConfig{})
+
+		if progress == nil {
+			t.Fatal("Expected race progress, got nil")
+		}
+
+		if progress.EstimatedLapsRemaining != 0 {
+			t.Errorf("Expected the leader to have finished, got %d laps remaining", progress.EstimatedLapsRemaining)
+		}
+	})
+
+	t.Run("Not a race", func(t *testing.T) {
+		raceControl.SessionInfo = udp.SessionInfo{Type: udp.SessionTypeQualifying, Time: 30}
+
+		if progress := raceControl.raceProgress(CurrentRaceConfig{}); progress != nil {
+			t.Errorf("Expected no race progress outside of a race, got: %+v", progress)
+		}
+	})
+}
+
+func TestLapTimeStandardDeviation(t *testing.T) {
+	seconds := func(laps ...float64) []time.Duration {
+		var out []time.Duration
+
+		for _, lap := range laps {
+			out = append(out, time.Duration(lap*float64(time.Second)))
+		}
+
+		return out
+	}
+
+	for _, testCase := range []struct {
+		Name     string
+		Laps     []time.Duration
+		Expected time.Duration
+	}{
+		{Name: "No laps", Laps: nil, Expected: 0},
+		{Name: "One lap", Laps: seconds(90), Expected: 0},
+		{Name: "Identical laps", Laps: seconds(90, 90, 90), Expected: 0},
+		{Name: "Two laps", Laps: seconds(88, 92), Expected: 2 * time.Second},
+		{Name: "Eight laps", Laps: seconds(2, 4, 4, 4, 5, 5, 7, 9), Expected: 2 * time.Second},
+		{Name: "Rounded to the millisecond", Laps: seconds(90, 90.5, 91), Expected: 408 * time.Millisecond},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			if deviation := lapTimeStandardDeviation(testCase.Laps); deviation != testCase.Expected {
+				t.Errorf("Expected a standard deviation of %s, got: %s", testCase.Expected, deviation)
+			}
+		})
+	}
+}
+
+func TestRaceControl_Consistency(t *testing.T) {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 30}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	completeLap := func(t *testing.T, lapTime uint32, cuts uint8) {
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: lapTime, Cuts: cuts}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	consistency := func(t *testing.T) time.Duration {
+		driver, err := raceControl.findConnectedDriverByCarID(drivers[0].CarID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return driver.Copy().CurrentCar().Consistency
+	}
+
+	completeLap(t, 88000, 0)
+	completeLap(t, 70000, 3)
+	completeLap(t, 92000, 0)
+
+	if c := consistency(t); c != 2*time.Second {
+		t.Errorf("Expected laps with cuts to be ignored, and a consistency of 2s, got: %s", c)
+	}
+
+	t.Run("Only recent laps are counted", func(t *testing.T) {
+		for i := 0; i < consistencyLaps; i++ {
+			completeLap(t, 90000, 0)
+		}
+
+		if c := consistency(t); c != 0 {
+			t.Errorf("Expected a consistency of 0 from identical recent laps, got: %s", c)
+		}
+	})
+
+	t.Run("Reset in a new session", func(t *testing.T) {
+		completeLap(t, 95000, 0)
+
+		if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeQualifying, Time: 30}); err != nil {
+			t.Fatal(err)
+		}
+
+		if c := consistency(t); c != 0 {
+			t.Errorf("Expected consistency to be reset, got: %s", c)
+		}
+
+		completeLap(t, 90000, 0)
+
+		if c := consistency(t); c != 0 {
+			t.Errorf("Expected a consistency of 0 from a single lap in the new session, got: %s", c)
+		}
+	})
+}
+
+func TestParseWebhookEvents(t *testing.T) {
+	if events := parseWebhookEvents(""); len(events) != len(allWebhookEvents) {
+		t.Errorf("Expected an empty list to subscribe to all %d events, got: %v", len(allWebhookEvents), events)
+	}
+
+	events := parseWebhookEvents("new_session, Session_Fastest_Lap,not_an_event")
+
+	if len(events) != 2 || !events[WebhookEventNewSession] || !events[WebhookEventSessionFastestLap] {
+		t.Errorf("Expected new_session and session_fastest_lap events, got: %v", events)
+	}
+}
+
+func TestRaceControl_Webhook(t *testing.T) {
+	defer func(backoff time.Duration) {
+		webhookRetryBackoff = backoff
+	}(webhookRetryBackoff)
+
+	webhookRetryBackoff = time.Millisecond
+
+	var mutex sync.Mutex
+	var events []WebhookEvent
+	var requests int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		requests++
+
+		// the first request fails, so that it is retried.
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var payload WebhookPayload
+
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+
+		events = append(events, payload.Event)
+	}))
+	defer server.Close()
+
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.WebhookURL = server.URL
+		opts.WebhookEvents = "client_connect,client_disconnect"
+	})
+	defer cleanup()
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := raceControl.OnClientConnect(drivers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := raceControl.OnClientDisconnect(drivers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	receivedEvents := func() []WebhookEvent {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return append([]WebhookEvent(nil), events...)
+	}
+
+	for i := 0; i < 100 && len(receivedEvents()) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	received := receivedEvents()
+
+	if len(received) != 2 || received[0] != WebhookEventClientConnect || received[1] != WebhookEventClientDisconnect {
+		t.Errorf("Expected client_connect and client_disconnect events (after a retry), got: %v", received)
+	}
+}
+
+func TestRaceControl_WebhookStop(t *testing.T) {
+	defer func(backoff time.Duration) {
+		webhookRetryBackoff = backoff
+	}(webhookRetryBackoff)
+
+	webhookRetryBackoff = time.Hour
+
+	requested := make(chan struct{}, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case requested <- struct{}{}:
+		default:
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.WebhookURL = server.URL
+	})
+	defer cleanup()
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+	raceControl.sendWebhook(WebhookEventClientConnect, drivers[0])
+
+	select {
+	case <-requested:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the webhook to be sent")
+	}
+
+	stopped := make(chan struct{})
+
+	go func() {
+		raceControl.Stop()
+		close(stopped)
+	}()
+
+	// the webhook is waiting to retry the failed request, which must not hold up stopping race control.
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected race control to stop the webhook worker")
+	}
+}
+
+func TestRaceControl_WebhookQuietHours(t *testing.T) {
+	now := time.Now().UTC()
+
+	for _, testCase := range []struct {
+		Name         string
+		Start, End   time.Time
+		ExpectedSent bool
+	}{
+		{Name: "In window", Start: now.Add(-time.Hour), End: now.Add(time.Hour), ExpectedSent: false},
+		{Name: "Out of window", Start: now.Add(time.Hour), End: now.Add(time.Hour * 2), ExpectedSent: true},
+	} {
+		t.Run(testCase.Name, func(t *testing.T) {
+			var mutex sync.Mutex
+			var events []WebhookEvent
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var payload WebhookPayload
+
+				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+					t.Error(err)
+				}
+
+				mutex.Lock()
+				events = append(events, payload.Event)
+				mutex.Unlock()
+			}))
+			defer server.Close()
+
+			store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+				opts.WebhookURL = server.URL
+				opts.QuietHoursStart = testCase.Start.Format("15:04")
+				opts.QuietHoursEnd = testCase.End.Format("15:04")
+				opts.QuietHoursTimezone = "UTC"
+			})
+			defer cleanup()
+
+			raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+			raceControl.sendWebhook(WebhookEventClientConnect, drivers[0])
+
+			numEvents := func() int {
+				mutex.Lock()
+				defer mutex.Unlock()
+
+				return len(events)
+			}
+
+			for i := 0; i < 50 && numEvents() == 0; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			if sent := numEvents() > 0; sent != testCase.ExpectedSent {
+				t.Errorf("Expected webhook to be sent: %t, got: %t", testCase.ExpectedSent, sent)
+			}
+		})
+	}
+}
+
+func TestRaceControl_EventLog(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, func(opts *GlobalServerConfig) {
+		opts.LogUDPEvents = true
+	})
+	defer cleanup()
+
+	from := time.Now()
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+	messages := []udp.Message{
+		udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 30, EventType: udp.EventNewSession},
+		udp.SessionCarInfo{CarID: drivers[0].CarID, DriverName: drivers[0].DriverName, DriverGUID: drivers[0].DriverGUID, CarModel: drivers[0].CarModel, EventType: udp.EventNewConnection},
+		udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 90000},
+	}
+
+	for _, message := range messages {
+		raceControl.UDPCallback(message)
+	}
+
+	listEventLog := func(t *testing.T) replay.Entries {
+		entries, err := store.ListEventLog(from, time.Now())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return entries
+	}
+
+	// messages are written to the event log in the background.
+	for i := 0; i < 100 && len(listEventLog(t)) < len(messages); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	entries := listEventLog(t)
+
+	if len(entries) != len(messages) {
+		t.Fatalf("Expected %d event log entries, got: %d", len(messages), len(entries))
+	}
+
+	for i, entry := range entries {
+		if entry.EventType != messages[i].Event() {
+			t.Errorf("Expected event log entry %d to be event %d, got: %d", i, messages[i].Event(), entry.EventType)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		replayed, stop, err := raceControl.LoadEventLog(from, time.Now())
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer stop()
+
+		if replayed == raceControl {
+			t.Fatal("Expected the event log to be replayed into a new race control")
+		}
+
+		driver, ok := replayed.ConnectedDrivers.Get(drivers[0].DriverGUID)
+
+		if !ok {
+			t.Fatal("Expected the replayed driver to be connected")
+		}
+
+		if numLaps := driver.Copy().TotalNumLaps; numLaps != 1 {
+			t.Errorf("Expected the replayed driver to have completed 1 lap, got: %d", numLaps)
+		}
+	}
+
+	if driver, ok := raceControl.ConnectedDrivers.Get(drivers[0].DriverGUID); !ok || driver.Copy().TotalNumLaps != 1 {
+		t.Errorf("Expected the live driver to be unchanged by the replay")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if entries := listEventLog(t); len(entries) != len(messages) {
+		t.Errorf("Expected replayed messages not to be added to the event log, got %d entries", len(entries))
+	}
+}
+
+func TestRaceControl_Stop(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, nil)
+	defer cleanup()
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+	// starts requesting session info in the background.
+	if err := raceControl.OnVersion(udp.Version(4)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 30}); err != nil {
+		t.Fatal(err)
+	}
+
+	stopped := make(chan struct{})
+
+	go func() {
+		raceControl.Stop()
+		raceControl.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected race control to stop its background goroutines")
+	}
+
+	raceControl.sessionCountdownMutex.Lock()
+	defer raceControl.sessionCountdownMutex.Unlock()
+
+	if raceControl.sessionCountdownCfn != nil {
+		t.Errorf("Expected the session countdown to be stopped")
+	}
+}
+
+func TestRaceControlCarLapInfo_UpdateAverageLap(t *testing.T) {
+	for _, testCase := range []struct {
+		name            string
+		car             RaceControlCarLapInfo
+		excludeFirstLap bool
+		expected        time.Duration
+	}{
+		{
+			name:     "No laps",
+			car:      RaceControlCarLapInfo{},
+			expected: 0,
+		},
+		{
+			name:     "All laps",
+			car:      RaceControlCarLapInfo{NumLaps: 3, TotalLapTime: 280 * time.Second, firstLapTime: 100 * time.Second},
+			expected: 280 * time.Second / 3,
+		},
+		{
+			name:            "First lap excluded",
+			car:             RaceControlCarLapInfo{NumLaps: 3, TotalLapTime: 280 * time.Second, firstLapTime: 100 * time.Second},
+			excludeFirstLap: true,
+			expected:        90 * time.Second,
+		},
+		{
+			name:            "Anomalous laps and first lap excluded",
+			car:             RaceControlCarLapInfo{NumLaps: 4, TotalLapTime: 1580 * time.Second, NumAnomalousLaps: 1, AnomalousLapTime: 1300 * time.Second, firstLapTime: 100 * time.Second},
+			excludeFirstLap: true,
+			expected:        90 * time.Second,
+		},
+		{
+			name:            "Only the first lap",
+			car:             RaceControlCarLapInfo{NumLaps: 1, TotalLapTime: 100 * time.Second, firstLapTime: 100 * time.Second},
+			excludeFirstLap: true,
+			expected:        0,
+		},
+		{
+			name:            "Anomalous first lap is only excluded once",
+			car:             RaceControlCarLapInfo{NumLaps: 2, TotalLapTime: 1390 * time.Second, NumAnomalousLaps: 1, AnomalousLapTime: 1300 * time.Second},
+			excludeFirstLap: true,
+			expected:        90 * time.Second,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			car := testCase.car
+			car.updateAverageLap(testCase.excludeFirstLap)
+
+			if car.AverageLap != testCase.expected {
+				t.Errorf("Expected average lap of %s, got: %s", testCase.expected, car.AverageLap)
+			}
+		})
+	}
+}
+
+func TestRaceControl_ManualPositions(t *testing.T) {
+	broadcaster := &countingBroadcaster{}
+	raceControl := NewRaceControl(broadcaster, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 30}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, driver := range drivers[:4] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: driver.CarID, LapTime: uint32(90000 + i*1000)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertOrder := func(t *testing.T, expected ...udp.SessionCarInfo) {
+		for i, driver := range expected {
+			guid := raceControl.ConnectedDrivers.GUIDsInPositionalOrder[i]
+
+			if guid != driver.DriverGUID {
+				t.Errorf("Expected driver %s in position %d, got: %s", driver.DriverGUID, i+1, guid)
+			}
+
+			if position := raceControl.ConnectedDrivers.Drivers[guid].Position; position != i+1 {
+				t.Errorf("Expected driver %s to have position %d, got: %d", guid, i+1, position)
+			}
+		}
+	}
+
+	t.Run("Automatic order", func(t *testing.T) {
+		assertOrder(t, drivers[0], drivers[1], drivers[2], drivers[3])
+	})
+
+	t.Run("Pin a driver to the front", func(t *testing.T) {
+		if err := raceControl.SetManualPosition(drivers[3].DriverGUID, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		assertOrder(t, drivers[3], drivers[0], drivers[1], drivers[2])
+	})
+
+	t.Run("Pin a second driver beyond the last position", func(t *testing.T) {
+		if err := raceControl.SetManualPosition(drivers[0].DriverGUID, 10); err != nil {
+			t.Fatal(err)
+		}
+
+		assertOrder(t, drivers[3], drivers[1], drivers[2], drivers[0])
+	})
+
+	t.Run("Manual positions are kept when a lap is completed", func(t *testing.T) {
+		if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: drivers[0].CarID, LapTime: 80000}); err != nil {
+			t.Fatal(err)
+		}
+
+		assertOrder(t, drivers[3], drivers[1], drivers[2], drivers[0])
+	})
+
+	t.Run("Invalid position", func(t *testing.T) {
+		if err := raceControl.SetManualPosition(drivers[1].DriverGUID, 0); err == nil {
+			t.Error("Expected an error setting a manual position of 0, got nil")
+		}
+	})
+
+	t.Run("Unknown driver", func(t *testing.T) {
+		if err := raceControl.SetManualPosition("unknown", 1); err == nil {
+			t.Error("Expected an error setting a manual position for an unknown driver, got nil")
+		}
+	})
+
+	t.Run("Clear manual positions", func(t *testing.T) {
+		numBroadcasts := broadcaster.count(EventRaceControl)
+
+		if err := raceControl.ClearManualPositions(); err != nil {
+			t.Fatal(err)
+		}
+
+		assertOrder(t, drivers[0], drivers[1], drivers[2], drivers[3])
+
+		if count := broadcaster.count(EventRaceControl); count != numBroadcasts+1 {
+			t.Errorf("Expected clearing manual positions to be broadcast")
+		}
+	})
+}
+
+func TestRaceControl_LapsDownAtDisconnect(t *testing.T) {
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, testStore, NewPenaltiesManager(testStore))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypeRace, Laps: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, driver := range drivers[:3] {
+		if err := raceControl.OnClientConnect(driver); err != nil {
+			t.Fatal(err)
+		}
+
+		for lap := 0; lap < 3-i; lap++ {
+			if err := raceControl.OnLapCompleted(udp.LapCompleted{CarID: driver.CarID, LapTime: 90000}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	lapsDown := func(t *testing.T, driverGUID udp.DriverGUID) int {
+		driver, ok := raceControl.DisconnectedDrivers.Get(driverGUID)
+
+		if !ok {
+			t.Fatalf("Expected driver %s to be in the disconnected drivers", driverGUID)
+		}
+
+		return driver.Copy().LapsDownAtDisconnect
+	}
+
+	t.Run("Lapped driver", func(t *testing.T) {
+		if err := raceControl.OnClientDisconnect(drivers[2]); err != nil {
+			t.Fatal(err)
+		}
+
+		if laps := lapsDown(t, drivers[2].DriverGUID); laps != 2 {
+			t.Errorf("Expected driver to be 2 laps down at disconnect, got: %d", laps)
+		}
+	})
+
+	t.Run("Leader", func(t *testing.T) {
+		if err := raceControl.OnClientDisconnect(drivers[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		if laps := lapsDown(t, drivers[0].DriverGUID); laps != 0 {
+			t.Errorf("Expected the leader to be 0 laps down at disconnect, got: %d", laps)
+		}
+	})
+
+	t.Run("Reconnected driver", func(t *testing.T) {
+		if err := raceControl.OnClientConnect(drivers[2]); err != nil {
+			t.Fatal(err)
+		}
+
+		driver, ok := raceControl.ConnectedDrivers.Get(drivers[2].DriverGUID)
+
+		if !ok {
+			t.Fatal("Expected driver to be connected")
+		}
+
+		if laps := driver.Copy().LapsDownAtDisconnect; laps != 0 {
+			t.Errorf("Expected laps down at disconnect to be reset on reconnect, got: %d", laps)
+		}
+	})
+}
+
+func TestRaceControl_ReloadConfig(t *testing.T) {
+	store, cleanup := newIsolatedTestStore(t, nil)
+	defer cleanup()
+
+	raceControl := NewRaceControl(NilBroadcaster{}, nilTrackData{}, dummyServerProcess{}, store, NewPenaltiesManager(store))
+
+	if err := raceControl.OnNewSession(udp.SessionInfo{Track: "ks_laguna_seca", Type: udp.SessionTypePractice, Time: 30}); err != nil {
+		t.Fatal(err)
+	}
+
+	updateServerOptions(t, store, func(opts *GlobalServerConfig) {
+		opts.CarUpdateMissThreshold = 20
+		opts.SessionInfoInterval = 10
+		opts.DriverSwapCountdownAt = "20,10"
+		opts.DriverSwapCountdownEvery = 3
+	})
+
+	t.Run("Changes are not picked up until the config is reloaded", func(t *testing.T) {
+		if timeout := raceControl.driverTimeoutFor(100); timeout != driverTimeout {
+			t.Errorf("Expected the driver timeout to be %s, got: %s", driverTimeout, timeout)
+		}
+
+		if interval := raceControl.currentSessionInfoInterval(); interval != sessionInfoRequestInterval {
+			t.Errorf("Expected the session info interval to be %s, got: %s", sessionInfoRequestInterval, interval)
+		}
+	})
+
+	raceControl.ReloadConfig()
+
+	t.Run("Car update miss threshold", func(t *testing.T) {
+		if timeout := raceControl.driverTimeoutFor(100); timeout != 2*time.Second {
+			t.Errorf("Expected the driver timeout to be 2s, got: %s", timeout)
+		}
+	})
+
+	t.Run("Session info interval", func(t *testing.T) {
+		if interval := raceControl.currentSessionInfoInterval(); interval != 10*time.Second {
+			t.Errorf("Expected the session info interval to be 10s, got: %s", interval)
+		}
+	})
+
+	t.Run("Driver swap countdown", func(t *testing.T) {
+		intervals, every := raceControl.driverSwapCountdown()
+
+		var announced []time.Duration
+
+		for countdown := 30 * time.Second; countdown > 0; countdown -= time.Second {
+			if shouldAnnounceDriverSwapCountdown(countdown, intervals, every) {
+				announced = append(announced, countdown)
+			}
+		}
+
+		expected := []time.Duration{30, 27, 24, 21, 20, 18, 15, 12, 10, 9, 6, 3}
+
+		if len(announced) != len(expected) {
+			t.Fatalf("Expected countdown to be announced at %v seconds, got: %v", expected, announced)
+		}
+
+		for i := range expected {
+			if announced[i] != expected[i]*time.Second {
+				t.Errorf("Expected countdown to be announced at %v seconds, got: %v", expected, announced)
+				break
+			}
+		}
+	})
+
+	t.Run("The session is not restarted", func(t *testing.T) {
+		if raceControl.SessionInfo.Type != udp.SessionTypePractice || raceControl.SessionInfo.Time != 30 {
+			t.Errorf("Expected the session info to be unchanged, got: %v", raceControl.SessionInfo)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package servermanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/JustaPenguin/assetto-server-manager/pkg/udp"
+)
+
+// WebhookEvent is a race control event which can be sent to the webhook URL.
+type WebhookEvent string
+
+const (
+	WebhookEventNewSession        WebhookEvent = "new_session"
+	WebhookEventEndSession        WebhookEvent = "end_session"
+	WebhookEventClientConnect     WebhookEvent = "client_connect"
+	WebhookEventClientDisconnect  WebhookEvent = "client_disconnect"
+	WebhookEventSessionFastestLap WebhookEvent = "session_fastest_lap"
+)
+
+var allWebhookEvents = []WebhookEvent{
+	WebhookEventNewSession,
+	WebhookEventEndSession,
+	WebhookEventClientConnect,
+	WebhookEventClientDisconnect,
+	WebhookEventSessionFastestLap,
+}
+
+const (
+	// webhookQueueSize is the number of payloads which can be waiting to be sent. Payloads are dropped when the
+	// queue is full, e.g. if the webhook URL is down.
+	webhookQueueSize = 100
+
+	// webhookMaxAttempts is the number of times a payload is sent before it is dropped.
+	webhookMaxAttempts = 5
+)
+
+var (
+	// webhookRetryBackoff is the wait before the first retry of a payload. It is doubled for each retry after that.
+	webhookRetryBackoff = time.Second
+
+	webhookTimeout = 10 * time.Second
+)
+
+// WebhookPayload is the JSON body which is POSTed to the webhook URL.
+type WebhookPayload struct {
+	Event     WebhookEvent `json:"Event"`
+	Time      time.Time    `json:"Time"`
+	SessionID string       `json:"SessionID"`
+	Data      interface{}  `json:"Data"`
+}
+
+// WebhookEndSession is the Data of an end_session webhook payload.
+type WebhookEndSession struct {
+	SessionInfo udp.SessionInfo `json:"SessionInfo"`
+	ResultsFile string          `json:"ResultsFile"`
+}
+
+type webhookRequest struct {
+	url     string
+	payload WebhookPayload
+}
+
+// webhookSender POSTs webhook payloads from a background worker, so that a slow webhook URL never holds up the
+// handling of UDP messages. The worker is started with goBackground, and finishes once stopped is closed.
+type webhookSender struct {
+	client *http.Client
+	queue  chan webhookRequest
+
+	stopped      <-chan struct{}
+	goBackground func(fn func())
+	startOnce    sync.Once
+}
+
+func newWebhookSender(stopped <-chan struct{}, goBackground func(fn func())) *webhookSender {
+	return &webhookSender{
+		client:       &http.Client{Timeout: webhookTimeout},
+		queue:        make(chan webhookRequest, webhookQueueSize),
+		stopped:      stopped,
+		goBackground: goBackground,
+	}
+}
+
+// Enqueue adds a payload to the queue to be sent to the url. If the queue is full, the payload is dropped.
+func (w *webhookSender) Enqueue(url string, payload WebhookPayload) {
+	w.startOnce.Do(func() {
+		w.goBackground(w.run)
+	})
+
+	select {
+	case w.queue <- webhookRequest{url: url, payload: payload}:
+	default:
+		logrus.Warnf("Webhook queue is full, dropping %s event", payload.Event)
+	}
+}
+
+func (w *webhookSender) run() {
+	for {
+		select {
+		case <-w.stopped:
+			return
+		case request := <-w.queue:
+			w.sendWithRetry(request)
+		}
+	}
+}
+
+// sendWithRetry sends a payload, retrying with an increasing backoff until it is accepted or webhookMaxAttempts
+// is reached.
+func (w *webhookSender) sendWithRetry(request webhookRequest) {
+	body, err := json.Marshal(request.payload)
+
+	if err != nil {
+		logrus.WithError(err).Errorf("Could not encode %s webhook payload", request.payload.Event)
+		return
+	}
+
+	backoff := webhookRetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := w.send(request.url, body)
+
+		if err == nil {
+			return
+		}
+
+		if attempt >= webhookMaxAttempts {
+			logrus.WithError(err).Errorf("Could not send %s event to webhook after %d attempts, dropping it", request.payload.Event, attempt)
+			return
+		}
+
+		logrus.WithError(err).Warnf("Could not send %s event to webhook, retrying in %s", request.payload.Event, backoff)
+
+		select {
+		case <-w.stopped:
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+func (w *webhookSender) send(url string, body []byte) error {
+	resp, err := w.client.Post(url, "application/json", bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: unexpected status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// parseWebhookEvents parses a comma separated list of webhook events. Invalid events are ignored, and an empty list
+// subscribes to every event.
+func parseWebhookEvents(events string) map[WebhookEvent]bool {
+	out := make(map[WebhookEvent]bool)
+
+	if strings.TrimSpace(events) == "" {
+		for _, event := range allWebhookEvents {
+			out[event] = true
+		}
+
+		return out
+	}
+
+	for _, event := range strings.Split(events, ",") {
+		webhookEvent := WebhookEvent(strings.ToLower(strings.TrimSpace(event)))
+		valid := false
+
+		for _, knownEvent := range allWebhookEvents {
+			if webhookEvent == knownEvent {
+				valid = true
+				break
+			}
+		}
+
+		if !valid {
+			logrus.Warnf("Ignoring invalid webhook event: %q", event)
+			continue
+		}
+
+		out[webhookEvent] = true
+	}
+
+	return out
+}
+
+// sendWebhook queues an event to be sent to the webhook URL, if one is set, the event is subscribed to and it is not
+// within the quiet hours. The data is encoded in the background, so it must not be modified after it is passed in.
+func (rc *RaceControl) sendWebhook(event WebhookEvent, data interface{}) {
+	serverOptions := rc.cachedServerOptions()
+
+	if serverOptions.WebhookURL == "" || !parseWebhookEvents(serverOptions.WebhookEvents)[event] {
+		return
+	}
+
+	now := time.Now()
+
+	if rc.inQuietHours(now) {
+		logrus.Debugf("Not sending %s event to webhook during quiet hours", event)
+		return
+	}
+
+	rc.webhook.Enqueue(serverOptions.WebhookURL, WebhookPayload{
+		Event:     event,
+		Time:      now,
+		SessionID: rc.SessionID,
+		Data:      data,
+	})
+}
@@ -47,6 +47,10 @@ type RaceManager struct {
 	// scheduled races
 	customRaceStartTimers    map[string]*when.Timer
 	customRaceReminderTimers map[string]*when.Timer
+
+	// standings notifications
+	lastStandingsNotification time.Time
+	standingsMutex            sync.Mutex
 }
 
 func NewRaceManager(
@@ -124,9 +128,26 @@ func (rm *RaceManager) applyConfigAndStart(event RaceEvent) error {
 		}
 	}
 
+	var bop BoPTable
+
+	if serverOpts.ApplyBoPTable {
+		bop, err = parseBoPTable(serverOpts.BoPTable)
+
+		if err != nil {
+			logrus.WithError(err).Error("Could not read BoP table, BoP will not be applied")
+		} else {
+			bop.ApplyToEntryList(entryList)
+		}
+	}
+
 	// the server won't start if an entrant has a larger ballast than is set as the max, correct if necessary
 	greatestBallast := entryList.FindGreatestBallast()
 
+	// entrants with 'any car model' are given their BoP once their car is chosen, so allow for any ballast in the table
+	if bopBallast := bop.GreatestBallast(); bopBallast > greatestBallast {
+		greatestBallast = bopBallast
+	}
+
 	if greatestBallast > raceConfig.MaxBallastKilograms {
 		raceConfig.MaxBallastKilograms = greatestBallast
 	}
@@ -256,6 +277,7 @@ func (rm *RaceManager) applyConfigAndStart(event RaceEvent) error {
 			// cars with 'any car model' become random in the entry list.
 			entrant.Model = finalCars[numEntrantsWithAnyCar%len(finalCars)]
 			entrant.Skin = rm.carManager.RandomSkin(entrant.Model)
+			bop.ApplyToEntrant(entrant)
 
 			numEntrantsWithAnyCar++
 		}
@@ -671,6 +693,11 @@ func (rm *RaceManager) BuildCustomRaceFromForm(r *http.Request) (*CurrentRaceCon
 		raceConfig.DriverSwapPenaltyTime = formValueAsInt(r.FormValue("DriverSwapPenaltyTime"))
 		raceConfig.DriverSwapMinimumNumberOfSwaps = formValueAsInt(r.FormValue("DriverSwapMinimumNumberOfSwaps"))
 		raceConfig.DriverSwapNotEnoughSwapsPenalty = formValueAsInt(r.FormValue("DriverSwapNotEnoughSwapsPenalty"))
+		raceConfig.DriverSwapMovementThreshold = formValueAsFloat(r.FormValue("DriverSwapMovementThreshold"))
+
+		for _, session := range r.Form["DriverSwapSessions"] {
+			raceConfig.DriverSwapSessions = append(raceConfig.DriverSwapSessions, SessionType(session))
+		}
 
 		raceConfig.ExportSecondRaceToACSR = formValueAsInt(r.FormValue("ExportSecondRaceToACSR")) == 1
 	} else {
@@ -1485,6 +1512,10 @@ func (rm *RaceManager) SaveServerOptions(newServerOpts *GlobalServerConfig) erro
 		return err
 	}
 
+	if rm.raceControl != nil {
+		rm.raceControl.ReloadConfig()
+	}
+
 	err = rm.notificationManager.SaveServerOptions(oldServerOpts, newServerOpts)
 
 	if err != nil {
@@ -1575,9 +1606,53 @@ func (rm *RaceManager) LoopRaces() {
 	}
 }
 
+// sendStandingsNotification posts the current standings to Discord, if enough time has passed since they were
+// last posted. It is called on every lap, so it uses race control's cached server options rather than loading them
+// from the store.
+func (rm *RaceManager) sendStandingsNotification() {
+	if rm.raceControl == nil {
+		return
+	}
+
+	serverOpts := rm.raceControl.cachedServerOptions()
+
+	if serverOpts.DiscordStandingsInterval <= 0 {
+		return
+	}
+
+	rm.standingsMutex.Lock()
+	defer rm.standingsMutex.Unlock()
+
+	if time.Since(rm.lastStandingsNotification) < time.Duration(serverOpts.DiscordStandingsInterval)*time.Minute {
+		return
+	}
+
+	standings := rm.raceControl.StandingsTable()
+
+	if len(standings) == 0 {
+		return
+	}
+
+	rm.lastStandingsNotification = time.Now()
+
+	sessionInfo := rm.raceControl.SessionInfo
+	title := fmt.Sprintf("Standings - %s at %s", sessionInfo.Name, trackSummary(sessionInfo.Track, sessionInfo.TrackConfig))
+
+	go func() {
+		if err := rm.notificationManager.SendStandings(title, standings); err != nil {
+			logrus.WithError(err).Errorf("couldn't send standings notification")
+		}
+	}()
+}
+
 // callback check for udp end session, load result file, check session type against sessionTypes
 // if session matches last session in sessionTypes then stop server and clear sessionTypes
 func (rm *RaceManager) LoopCallback(message udp.Message) {
+	if _, ok := message.(udp.LapCompleted); ok {
+		rm.sendStandingsNotification()
+		return
+	}
+
 	if a, ok := message.(udp.EndSession); ok {
 		if rm.loopedRaceSessionTypes == nil {
 			logrus.Infof("Session types == nil. ignoring end session callback")
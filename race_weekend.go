@@ -14,6 +14,8 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/teambition/rrule-go"
+
+	"github.com/JustaPenguin/assetto-server-manager/pkg/udp"
 )
 
 func init() {
@@ -934,6 +936,47 @@ func (e *RaceWeekendEntryList) AddInPitBox(entrant *RaceWeekendSessionEntrant, p
 	*e = append(*e, entrant)
 }
 
+// ApplyGridPenalties moves each entrant with a grid penalty back by the given number of places (or to the back
+// of the grid), then reassigns pitboxes to match the new order. Where a penalised entrant is moved to the same
+// position as an entrant without a penalty, the entrant without a penalty starts ahead. The GUIDs of entrants
+// whose penalties were applied are returned.
+func (e RaceWeekendEntryList) ApplyGridPenalties(penalties map[udp.DriverGUID]int) []udp.DriverGUID {
+	var applied []udp.DriverGUID
+
+	positions := make(map[*RaceWeekendSessionEntrant]int, len(e))
+	penalised := make(map[*RaceWeekendSessionEntrant]bool)
+
+	sort.SliceStable(e, func(i, j int) bool {
+		return e[i].PitBox < e[j].PitBox
+	})
+
+	for i, entrant := range e {
+		positions[entrant] = i
+
+		guid := udp.DriverGUID(entrant.Car.GetGUID())
+
+		if places, ok := penalties[guid]; ok && places > 0 {
+			positions[entrant] += places
+			penalised[entrant] = true
+			applied = append(applied, guid)
+		}
+	}
+
+	sort.SliceStable(e, func(i, j int) bool {
+		if positions[e[i]] == positions[e[j]] {
+			return !penalised[e[i]] && penalised[e[j]]
+		}
+
+		return positions[e[i]] < positions[e[j]]
+	})
+
+	for i, entrant := range e {
+		entrant.PitBox = i
+	}
+
+	return applied
+}
+
 // Remove an Entrant from the EntryList
 func (e *RaceWeekendEntryList) Delete(entrant *RaceWeekendSessionEntrant) {
 	toDelete := -1
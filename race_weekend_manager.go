@@ -531,6 +531,12 @@ func (rwm *RaceWeekendManager) StartSession(raceWeekendID string, raceWeekendSes
 		return err
 	}
 
+	if !isPracticeSession && session.RaceConfig.HasSession(SessionTypeRace) {
+		if err := applyGridPenalties(rwm.store, raceWeekendEntryList); err != nil {
+			logrus.WithError(err).Errorf("Could not apply grid penalties to Race Weekend session")
+		}
+	}
+
 	entryList := raceWeekendEntryList.AsEntryList()
 
 	if isPracticeSession && !raceWeekend.SessionCanBeRun(session) {
@@ -401,6 +401,8 @@ func (r *Resolver) ResolveRaceControl() *RaceControl {
 		r.resolvePenaltiesManager(),
 	)
 
+	r.resolveRaceControlHub().anonymiser = r.raceControl.anonymiseDriverGUIDs
+
 	return r.raceControl
 }
 
@@ -421,9 +421,7 @@ func (s *SessionResults) GetOverallAverageLapTime() time.Duration {
 		totalTime += lap.LapTime
 	}
 
-	d, _ := time.ParseDuration(fmt.Sprintf("%dms", int(float64(totalTimeForAverage)/float64(lapsForAverage))))
-
-	return d
+	return ParseLapTime(int(float64(totalTimeForAverage) / float64(lapsForAverage)))
 }
 
 func (s *SessionResults) GetConsistency(guid, model string) float64 {
@@ -753,7 +751,7 @@ func (s *SessionResults) GetTime(timeINT int, driverGUID, model string, penalty
 
 	carID := s.FindCarIDForGUIDAndModel(driverGUID, model)
 
-	d, _ := time.ParseDuration(fmt.Sprintf("%dms", timeINT))
+	d := ParseLapTime(timeINT)
 
 	if penalty {
 		for _, driver := range s.Result {
@@ -1018,6 +1016,11 @@ type SessionResult struct {
 	LapPenalty   int           `json:"LapPenalty"`
 	Disqualified bool          `json:"Disqualified"`
 	ClassID      uuid.UUID     `json:"ClassID"`
+
+	// DisqualificationReason and DisqualificationTime are set when the driver was disqualified automatically
+	// during the session, e.g. for leaving the pits early during a driver swap.
+	DisqualificationReason string    `json:"DisqualificationReason,omitempty"`
+	DisqualificationTime   time.Time `json:"DisqualificationTime,omitempty"`
 }
 
 func (s *SessionResult) BestLapTyre(results *SessionResults) string {
@@ -1046,21 +1049,15 @@ type SessionLap struct {
 }
 
 func (sl *SessionLap) GetSector(x int) time.Duration {
-	d, _ := time.ParseDuration(fmt.Sprintf("%dms", sl.Sectors[x]))
-
-	return d
+	return ParseLapTime(sl.Sectors[x])
 }
 
 func (sl *SessionLap) GetLapTime() time.Duration {
-	d, _ := time.ParseDuration(fmt.Sprintf("%dms", sl.LapTime))
-
-	return d
+	return ParseLapTime(sl.LapTime)
 }
 
 func (sl *SessionLap) DidCheat(averageTime time.Duration) bool {
-	d, _ := time.ParseDuration(fmt.Sprintf("%dms", sl.LapTime))
-
-	return d < averageTime && sl.Cuts > 0
+	return ParseLapTime(sl.LapTime) < averageTime && sl.Cuts > 0
 }
 
 type SessionCar struct {
@@ -159,6 +159,7 @@ func Router(
 
 			r.Get("/live-timing", raceControlHandler.liveTiming)
 			r.Get("/api/race-control", raceControlHandler.websocket)
+			r.Get("/api/live-timing.json", raceControlHandler.liveTimingJSON)
 		})
 
 		// calendar
@@ -345,6 +346,9 @@ func Router(
 		r.HandleFunc("/broadcast-chat", raceControlHandler.broadcastChat)
 		r.HandleFunc("/admin-command", raceControlHandler.adminCommand)
 		r.HandleFunc("/kick-user", raceControlHandler.kickUser)
+		r.HandleFunc("/force-disconnect", raceControlHandler.forceDisconnect)
+		r.HandleFunc("/manual-position", raceControlHandler.manualPosition)
+		r.HandleFunc("/clear-manual-positions", raceControlHandler.clearManualPositions)
 		r.HandleFunc("/send-chat", raceControlHandler.sendChat)
 		r.HandleFunc("/countdown", raceControlHandler.countdown)
 
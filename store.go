@@ -1,5 +1,11 @@
 package servermanager
 
+import (
+	"time"
+
+	"github.com/JustaPenguin/assetto-server-manager/pkg/udp/replay"
+)
+
 type Store interface {
 	// Custom Races
 	UpsertCustomRace(race *CustomRace) error
@@ -32,6 +38,18 @@ type Store interface {
 	UpsertLiveFrames([]string) error
 	ListPrevFrames() ([]string, error)
 
+	// Lap Log
+	AppendLap(lap *LapLogEntry) error
+	ListLaps(from, to time.Time) ([]*LapLogEntry, error)
+
+	// Event Log
+	AppendEventLog(entry *replay.Entry) error
+	ListEventLog(from, to time.Time) (replay.Entries, error)
+
+	// Collisions
+	UpsertCollision(sessionID string, collision Collision) error
+	LoadCollisions(sessionID string) ([]Collision, error)
+
 	// Meta
 	SetMeta(key string, value interface{}) error
 	GetMeta(key string, out interface{}) error
@@ -1,11 +1,15 @@
 package servermanager
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"sort"
 	"time"
 
 	"github.com/etcd-io/bbolt"
+
+	"github.com/JustaPenguin/assetto-server-manager/pkg/udp/replay"
 )
 
 type BoltStore struct {
@@ -25,6 +29,9 @@ var (
 	frameLinksBucketName    = []byte("frameLinks")
 	raceWeekendsBucketName  = []byte("raceWeekends")
 	liveTimingsBucketName   = []byte("liveTimings")
+	lapLogBucketName        = []byte("lapLog")
+	collisionsBucketName    = []byte("collisions")
+	eventLogBucketName      = []byte("eventLog")
 
 	serverOptionsKey      = []byte("serverOptions")
 	strackerOptionsKey    = []byte("strackerOptions")
@@ -276,6 +283,235 @@ func (rs *BoltStore) ListPrevFrames() ([]string, error) {
 	return links, err
 }
 
+func (rs *BoltStore) lapLogBucket(tx *bbolt.Tx) (*bbolt.Bucket, error) {
+	if !tx.Writable() {
+		bkt := tx.Bucket(lapLogBucketName)
+
+		if bkt == nil {
+			return nil, bbolt.ErrBucketNotFound
+		}
+
+		return bkt, nil
+	}
+
+	return tx.CreateBucketIfNotExists(lapLogBucketName)
+}
+
+// AppendLap adds a lap to the end of the lap log. Laps are keyed by an increasing sequence number, so existing
+// entries are never overwritten.
+func (rs *BoltStore) AppendLap(lap *LapLogEntry) error {
+	return rs.db.Update(func(tx *bbolt.Tx) error {
+		bkt, err := rs.lapLogBucket(tx)
+
+		if err != nil {
+			return err
+		}
+
+		id, err := bkt.NextSequence()
+
+		if err != nil {
+			return err
+		}
+
+		encoded, err := rs.encode(lap)
+
+		if err != nil {
+			return err
+		}
+
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, id)
+
+		return bkt.Put(key, encoded)
+	})
+}
+
+// ListLaps returns all laps in the lap log which were completed between from and to (inclusive), in the order
+// they were added.
+func (rs *BoltStore) ListLaps(from, to time.Time) ([]*LapLogEntry, error) {
+	var laps []*LapLogEntry
+
+	err := rs.db.View(func(tx *bbolt.Tx) error {
+		bkt, err := rs.lapLogBucket(tx)
+
+		if err == bbolt.ErrBucketNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		return bkt.ForEach(func(k, v []byte) error {
+			var lap *LapLogEntry
+
+			if err := rs.decode(v, &lap); err != nil {
+				return err
+			}
+
+			if !lap.Time.Before(from) && !lap.Time.After(to) {
+				laps = append(laps, lap)
+			}
+
+			return nil
+		})
+	})
+
+	return laps, err
+}
+
+func (rs *BoltStore) eventLogBucket(tx *bbolt.Tx) (*bbolt.Bucket, error) {
+	if !tx.Writable() {
+		bkt := tx.Bucket(eventLogBucketName)
+
+		if bkt == nil {
+			return nil, bbolt.ErrBucketNotFound
+		}
+
+		return bkt, nil
+	}
+
+	return tx.CreateBucketIfNotExists(eventLogBucketName)
+}
+
+// AppendEventLog adds a UDP message to the end of the event log. Messages are keyed by an increasing sequence number,
+// so existing entries are never overwritten.
+func (rs *BoltStore) AppendEventLog(entry *replay.Entry) error {
+	return rs.db.Update(func(tx *bbolt.Tx) error {
+		bkt, err := rs.eventLogBucket(tx)
+
+		if err != nil {
+			return err
+		}
+
+		id, err := bkt.NextSequence()
+
+		if err != nil {
+			return err
+		}
+
+		encoded, err := rs.encode(entry)
+
+		if err != nil {
+			return err
+		}
+
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, id)
+
+		return bkt.Put(key, encoded)
+	})
+}
+
+// ListEventLog returns all UDP messages in the event log which were received between from and to (inclusive), in
+// the order they were added.
+func (rs *BoltStore) ListEventLog(from, to time.Time) (replay.Entries, error) {
+	var entries replay.Entries
+
+	err := rs.db.View(func(tx *bbolt.Tx) error {
+		bkt, err := rs.eventLogBucket(tx)
+
+		if err == bbolt.ErrBucketNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		return bkt.ForEach(func(k, v []byte) error {
+			var entry *replay.Entry
+
+			if err := rs.decode(v, &entry); err != nil {
+				return err
+			}
+
+			if !entry.Received.Before(from) && !entry.Received.After(to) {
+				entries = append(entries, entry)
+			}
+
+			return nil
+		})
+	})
+
+	return entries, err
+}
+
+// collisionsBucket is the bucket of collisions for a session, nested within the collisions bucket.
+func (rs *BoltStore) collisionsBucket(tx *bbolt.Tx, sessionID string) (*bbolt.Bucket, error) {
+	if !tx.Writable() {
+		bkt := tx.Bucket(collisionsBucketName)
+
+		if bkt == nil {
+			return nil, bbolt.ErrBucketNotFound
+		}
+
+		sessionBkt := bkt.Bucket([]byte(sessionID))
+
+		if sessionBkt == nil {
+			return nil, bbolt.ErrBucketNotFound
+		}
+
+		return sessionBkt, nil
+	}
+
+	bkt, err := tx.CreateBucketIfNotExists(collisionsBucketName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return bkt.CreateBucketIfNotExists([]byte(sessionID))
+}
+
+// UpsertCollision adds a collision to the collision log for a session, replacing any collision with the same ID.
+func (rs *BoltStore) UpsertCollision(sessionID string, collision Collision) error {
+	return rs.db.Update(func(tx *bbolt.Tx) error {
+		bkt, err := rs.collisionsBucket(tx, sessionID)
+
+		if err != nil {
+			return err
+		}
+
+		encoded, err := rs.encode(collision)
+
+		if err != nil {
+			return err
+		}
+
+		return bkt.Put([]byte(collision.ID), encoded)
+	})
+}
+
+// LoadCollisions returns the collisions recorded in a session, sorted by the time they occurred.
+func (rs *BoltStore) LoadCollisions(sessionID string) ([]Collision, error) {
+	var collisions []Collision
+
+	err := rs.db.View(func(tx *bbolt.Tx) error {
+		bkt, err := rs.collisionsBucket(tx, sessionID)
+
+		if err == bbolt.ErrBucketNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		return bkt.ForEach(func(k, v []byte) error {
+			var collision Collision
+
+			if err := rs.decode(v, &collision); err != nil {
+				return err
+			}
+
+			collisions = append(collisions, collision)
+
+			return nil
+		})
+	})
+
+	sort.SliceStable(collisions, func(i, j int) bool {
+		return collisions[i].Time.Before(collisions[j].Time)
+	})
+
+	return collisions, err
+}
+
 func (rs *BoltStore) serverOptionsBucket(tx *bbolt.Tx) (*bbolt.Bucket, error) {
 	if !tx.Writable() {
 		bkt := tx.Bucket(serverOptionsBucketName)
@@ -1,6 +1,7 @@
 package servermanager
 
 import (
+	"bufio"
 	"encoding/json"
 	"io/ioutil"
 	"os"
@@ -9,6 +10,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/JustaPenguin/assetto-server-manager/pkg/udp/replay"
 )
 
 const (
@@ -25,6 +28,9 @@ const (
 	realPenaltyOptionsFile = "realpenalty_options.json"
 	liveTimingsDataFile    = "live_timings.json"
 	lastRaceEventFile      = "last_race_event.json"
+	lapLogFile             = "lap_log.json"
+	eventLogFile           = "event_log.json"
+	collisionsDir          = "collisions"
 
 	// shared data
 	championshipsDir = "championships"
@@ -382,6 +388,205 @@ func (rs *JSONStore) ListPrevFrames() ([]string, error) {
 	return links, nil
 }
 
+// AppendLap adds a lap to the end of the lap log. Each lap is written as a single line of JSON, so that the
+// existing log does not need to be read or rewritten.
+func (rs *JSONStore) AppendLap(lap *LapLogEntry) error {
+	encoded, err := json.Marshal(lap)
+
+	if err != nil {
+		return err
+	}
+
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	if err := os.MkdirAll(rs.base, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(rs.base, lapLogFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	_, err = f.Write(append(encoded, '\n'))
+
+	return err
+}
+
+// ListLaps returns all laps in the lap log which were completed between from and to (inclusive), in the order
+// they were added.
+func (rs *JSONStore) ListLaps(from, to time.Time) ([]*LapLogEntry, error) {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	f, err := os.Open(filepath.Join(rs.base, lapLogFile))
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var laps []*LapLogEntry
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		var lap *LapLogEntry
+
+		if err := json.Unmarshal(scanner.Bytes(), &lap); err != nil {
+			return nil, err
+		}
+
+		if !lap.Time.Before(from) && !lap.Time.After(to) {
+			laps = append(laps, lap)
+		}
+	}
+
+	return laps, scanner.Err()
+}
+
+// AppendEventLog adds a UDP message to the end of the event log. Each message is written as a single line of JSON, so
+// that the existing log does not need to be read or rewritten.
+func (rs *JSONStore) AppendEventLog(entry *replay.Entry) error {
+	encoded, err := json.Marshal(entry)
+
+	if err != nil {
+		return err
+	}
+
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	if err := os.MkdirAll(rs.base, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(rs.base, eventLogFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	_, err = f.Write(append(encoded, '\n'))
+
+	return err
+}
+
+// ListEventLog returns all UDP messages in the event log which were received between from and to (inclusive), in
+// the order they were added.
+func (rs *JSONStore) ListEventLog(from, to time.Time) (replay.Entries, error) {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	f, err := os.Open(filepath.Join(rs.base, eventLogFile))
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var entries replay.Entries
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		var entry *replay.Entry
+
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+
+		if !entry.Received.Before(from) && !entry.Received.After(to) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+// UpsertCollision adds a collision to the collision log for a session. Each collision is written as a single line of
+// JSON, so that the existing log does not need to be read or rewritten. If a collision with the same ID is upserted
+// again, the latest version replaces it when the collisions are loaded.
+func (rs *JSONStore) UpsertCollision(sessionID string, collision Collision) error {
+	encoded, err := json.Marshal(collision)
+
+	if err != nil {
+		return err
+	}
+
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	dir := filepath.Join(rs.base, collisionsDir)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, sessionID+".json"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	_, err = f.Write(append(encoded, '\n'))
+
+	return err
+}
+
+// LoadCollisions returns the collisions recorded in a session, in the order they were first added.
+func (rs *JSONStore) LoadCollisions(sessionID string) ([]Collision, error) {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	f, err := os.Open(filepath.Join(rs.base, collisionsDir, sessionID+".json"))
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var collisions []Collision
+
+	indexes := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		var collision Collision
+
+		if err := json.Unmarshal(scanner.Bytes(), &collision); err != nil {
+			return nil, err
+		}
+
+		if i, ok := indexes[collision.ID]; ok {
+			collisions[i] = collision
+			continue
+		}
+
+		indexes[collision.ID] = len(collisions)
+		collisions = append(collisions, collision)
+	}
+
+	return collisions, scanner.Err()
+}
+
 func (rs *JSONStore) ListAccounts() ([]*Account, error) {
 	files, err := rs.listFiles(filepath.Join(rs.shared, accountsDir))
 